@@ -19,6 +19,17 @@ func makeMainUIMap() UIMap {
 			"type":   "toolbar",
 			"width":  "fill_parent",
 			"height": "20",
+			"children": []UIMap{
+				{
+					"type":   "image_view",
+					"left":   2,
+					"top":    2,
+					"width":  16,
+					"height": 16,
+					"color":  0x2c2c2c,
+					"image":  "toolbar_open",
+				},
+			},
 		},
 		"left_panel": UIMap{
 			"type":   "base_view",
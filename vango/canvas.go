@@ -6,20 +6,30 @@ package vango
 
 import (
 	. "image"
+	"image/color"
 )
 
+// Canvas is the pixels in one PixelFormat. It implements draw.Image, the
+// coordinate of At and Set is the one of Bounds, like the sub images of the
+// image package.
 type Canvas struct {
-	pix    []byte    // Pixels in RGBA order.
-	bounds Rectangle // Bounds is the sub rectangle of the pixels's bounds.
-	stride int       // The number of pixels in bytes for one line.
-	opaque bool      // Is the canvas opaque.
+	pix    []byte      // Pixels in the format, BGRA premultiplied by default.
+	bounds Rectangle   // Bounds is the sub rectangle of the pixels's bounds.
+	stride int         // The number of pixels in bytes for one line.
+	opaque bool        // Is the canvas opaque.
+	format PixelFormat // The layout of the pixels.
 }
 
 func NewCanvas(width int, height int) *Canvas {
+	return NewCanvasWithFormat(width, height, PixelFormatBGRA8)
+}
+
+func NewCanvasWithFormat(width int, height int, format PixelFormat) *Canvas {
 	var c Canvas
+	c.format = format
 	c.bounds = Rect(0, 0, width, height)
-	c.pix = make([]byte, c.W()*c.H()*4)
-	c.stride = c.W() * 4
+	c.stride = c.W() * format.BytesPerPixel()
+	c.pix = make([]byte, c.H()*c.stride)
 	return &c
 }
 
@@ -67,6 +77,44 @@ func (c *Canvas) SetBounds(bounds Rectangle) {
 	c.bounds = bounds
 }
 
+func (c *Canvas) Format() PixelFormat {
+	return c.format
+}
+
+// ConvertTo returns a copy of the canvas in the |format|. The copy has the
+// same local bounds.
+func (c *Canvas) ConvertTo(format PixelFormat) *Canvas {
+	dst := NewCanvasWithFormat(c.W(), c.H(), format)
+	dst.opaque = c.opaque || format == PixelFormatGray8
+	for y := 0; y < c.H(); y++ {
+		convert_row(format, dst.pix[dst.PixOffset(0, y):], c.format,
+			c.pix[c.PixOffset(0, y):], c.W())
+	}
+	return dst
+}
+
+func (c *Canvas) ColorModel() color.Model {
+	return c.format.ColorModel()
+}
+
+// At returns the color of the pixel at (x, y) in the coordinate of Bounds.
+func (c *Canvas) At(x, y int) color.Color {
+	if !(Point{x, y}.In(c.bounds)) {
+		return pixel_to_color(c.format, [4]uint32{})
+	}
+	i := y*c.stride + x*c.format.BytesPerPixel()
+	return pixel_to_color(c.format, read_pixel(c.format, c.pix[i:]))
+}
+
+// Set sets the color of the pixel at (x, y) in the coordinate of Bounds.
+func (c *Canvas) Set(x, y int, clr color.Color) {
+	if !(Point{x, y}.In(c.bounds)) {
+		return
+	}
+	i := y*c.stride + x*c.format.BytesPerPixel()
+	write_pixel(c.format, c.pix[i:], color_to_pixel(clr))
+}
+
 func (c *Canvas) Opaque() bool {
 	return c.opaque
 }
@@ -89,11 +137,12 @@ func (c *Canvas) SubCanvas(rect Rectangle) *Canvas {
 		pix:    c.pix,
 		stride: c.stride,
 		bounds: rect,
+		format: c.format,
 	}
 }
 
 func (c *Canvas) PixOffset(x int, y int) int {
-	return (y+c.bounds.Min.Y)*c.Stride() + (x+c.bounds.Min.X)*4
+	return (y+c.bounds.Min.Y)*c.Stride() + (x+c.bounds.Min.X)*c.format.BytesPerPixel()
 }
 
 func (dst *Canvas) DrawCanvas(x int, y int, src *Canvas, src_rect Rectangle) {
@@ -114,14 +163,10 @@ func (dst *Canvas) DrawCanvas(x int, y int, src *Canvas, src_rect Rectangle) {
 
 	w, h := r.Dx(), r.Dy()
 
-	// from src(x0, y0) draw |r| area to dst(x1, y1)
+	// from src(x0, y0) draw |r| area to dst(x1, y1), converted to the format
+	// of dst.
 	for j := 0; j < h; j++ {
-		for i := 0; i < w*4; i = i + 4 {
-			p1[i1+i+0] = p0[i0+i+0]
-			p1[i1+i+1] = p0[i0+i+1]
-			p1[i1+i+2] = p0[i0+i+2]
-			p1[i1+i+3] = p0[i0+i+3]
-		}
+		convert_row(dst.format, p1[i1:], src.format, p0[i0:], w)
 		i0 = i0 + s0
 		i1 = i1 + s1
 	}
@@ -178,108 +223,132 @@ func (dst *Canvas) DrawTexture(dstRc Rectangle, tex *Canvas, texRc Rectangle) {
 	}
 }
 
+// StretchDraw scales |src| to the |dst_rect| of the canvas with the bilinear
+// filter. The pixels are replaced, converted to the format of the canvas.
 func (dst *Canvas) StretchDraw(dst_rect Rectangle, src *Canvas) {
-	bounds0 := src.Bounds()
-	bounds1 := dst.Bounds()
-
-	rect0 := bounds0
-	rect1 := dst_rect.Add(bounds1.Min).Intersect(bounds1)
-
-	width0, height0 := rect0.Dx(), rect0.Dy()
-	width1, height1 := rect1.Dx(), rect1.Dy()
-
-	stride0 := src.Stride()
-
-	pix0 := src.Pix()
-	pix1 := dst.Pix()
-
-	max_pix_offset0 := len(pix0) - 1
-
-	scale_x := float64(width0) / float64(width1)
-	scale_y := float64(height0) / float64(height1)
-
-	to_color_channel := func(f64 float64) byte {
-		if f64 < 255 {
-			return byte(f64)
-		}
-		return 255
-	}
-
-	for j := 0; j < height1; j++ {
-		for i := 0; i < width1; i++ {
-			xf := float64(i) * scale_x
-			yf := float64(j) * scale_y
-
-			x0, y0 := int(xf), int(yf)
-			pix_offset0 := src.PixOffset(x0, y0)
-
-			pix_offset1 := pix_offset0 + 4
-			pix_offset2 := pix_offset0 + stride0
-			pix_offset3 := pix_offset2 + 4
-
-			if pix_offset3 > max_pix_offset0 {
-				break
-			}
-
-			b0, g0, r0 := pix0[pix_offset0+0], pix0[pix_offset0+1], pix0[pix_offset0+2]
-			b1, g1, r1 := pix0[pix_offset1+0], pix0[pix_offset1+1], pix0[pix_offset1+2]
-			b2, g2, r2 := pix0[pix_offset2+0], pix0[pix_offset2+1], pix0[pix_offset2+2]
-			b3, g3, r3 := pix0[pix_offset3+0], pix0[pix_offset3+1], pix0[pix_offset3+2]
-
-			factor0 := xf - float64(int(xf))
-			factor1 := 1 - factor0
-
-			b4 := factor1*float64(b0) + factor0*float64(b1)
-			g4 := factor1*float64(g0) + factor0*float64(g1)
-			r4 := factor1*float64(r0) + factor0*float64(r1)
-			b5 := factor1*float64(b2) + factor0*float64(b3)
-			g5 := factor1*float64(g2) + factor0*float64(g3)
-			r5 := factor1*float64(r2) + factor0*float64(r3)
-
-			factor3 := yf - float64(int(yf))
-			factor4 := 1 - factor3
-
-			b := factor4*b4 + factor3*b5
-			g := factor4*g4 + factor3*g5
-			r := factor4*r4 + factor3*r5
-
-			pix_offset := dst.PixOffset(i, j)
-			pix1[pix_offset+0] = to_color_channel(b)
-			pix1[pix_offset+1] = to_color_channel(g)
-			pix1[pix_offset+2] = to_color_channel(r)
-		}
-	}
+	visible := dst_rect.Intersect(dst.LocalBounds()).Sub(dst_rect.Min)
+	stretch_rows(src, src.LocalBounds(), dst_rect.Dx(), dst_rect.Dy(), FilterBilinear, visible,
+		func(y int, row []byte) {
+			i := dst.PixOffset(dst_rect.Min.X+visible.Min.X, dst_rect.Min.Y+y)
+			convert_row(dst.format, dst.pix[i:], PixelFormatBGRA8, row, visible.Dx())
+		})
 }
 
+// CanvasFromImage copies |img| to a new canvas at the origin. The colors are
+// converted to the premultiplied BGRA, image.Alpha and image.Gray keep their
+// formats, and a canvas is copied in its format.
 func CanvasFromImage(img Image) *Canvas {
 	var (
 		pix    []byte
 		stride int
-		bounds Rectangle
 		opaque bool
+		format PixelFormat
 	)
 
 	switch src := img.(type) {
 	case *RGBA:
-		pix = src.Pix
-		stride = src.Stride
-		bounds = src.Rect
-		opaque = true
+		// The image.RGBA is premultiplied already, only the red and the blue
+		// are swapped.
+		pix, stride, opaque = src.Pix, src.Stride, src.Opaque()
+		format = PixelFormatBGRA8
 	case *NRGBA:
-		pix = src.Pix
-		stride = src.Stride
-		bounds = src.Rect
-		opaque = false
+		pix, stride, opaque = src.Pix, src.Stride, src.Opaque()
+		format = PixelFormatRGBA8
+	case *Alpha:
+		pix, stride, opaque = src.Pix, src.Stride, src.Opaque()
+		format = PixelFormatA8
+	case *Gray:
+		pix, stride, opaque = src.Pix, src.Stride, true
+		format = PixelFormatGray8
+	case *Canvas:
+		return src.ConvertTo(src.format)
+	case *YCbCr:
+		return canvas_from_ycbcr(src, nil, 0)
+	case *NYCbCrA:
+		return canvas_from_ycbcr(&src.YCbCr, src.A, src.AStride)
+	case *Paletted:
+		return canvas_from_paletted(src)
 	default:
-		return nil
+		return canvas_from_colors(img)
 	}
 
-	canvas := &Canvas{
-		pix:    pix,
-		stride: stride,
-		bounds: bounds,
-		opaque: opaque,
+	// The pixels of the images start at the min of their bounds.
+	bounds := img.Bounds()
+	src := &Canvas{pix: pix, stride: stride, bounds: bounds.Sub(bounds.Min), opaque: opaque, format: format}
+	canvas := src.ConvertTo(PixelFormatBGRA8)
+	switch format {
+	case PixelFormatBGRA8:
+		for i := 0; i < len(canvas.pix); i += 4 {
+			canvas.pix[i+0], canvas.pix[i+2] = canvas.pix[i+2], canvas.pix[i+0]
+		}
+	case PixelFormatA8, PixelFormatGray8:
+		canvas = src.ConvertTo(format)
 	}
+	return canvas
+}
 
+// canvas_from_ycbcr converts the pixels of |src| to BGRA, premultiplied by
+// the alpha of |a| if it's not nil.
+func canvas_from_ycbcr(src *YCbCr, a []byte, a_stride int) *Canvas {
+	b := src.Rect
+	canvas := NewCanvas(b.Dx(), b.Dy())
+	canvas.opaque = a == nil
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		p := canvas.pix[(y-b.Min.Y)*canvas.stride:]
+		for x := b.Min.X; x < b.Max.X; x++ {
+			yi, ci := src.YOffset(x, y), src.COffset(x, y)
+			r, g, bl := color.YCbCrToRGB(src.Y[yi], src.Cb[ci], src.Cr[ci])
+			i := (x - b.Min.X) * 4
+			if a == nil {
+				p[i+0], p[i+1], p[i+2], p[i+3] = bl, g, r, 0xff
+				continue
+			}
+			alpha := uint32(a[(y-b.Min.Y)*a_stride+x-b.Min.X])
+			p[i+0], p[i+1], p[i+2], p[i+3] = byte(div255(uint32(bl)*alpha)),
+				byte(div255(uint32(g)*alpha)), byte(div255(uint32(r)*alpha)), byte(alpha)
+		}
+	}
+	return canvas
+}
+
+// canvas_from_paletted converts the indices of |src| by the palette, which is
+// premultiplied once.
+func canvas_from_paletted(src *Paletted) *Canvas {
+	var palette [256][4]byte
+	for i, c := range src.Palette {
+		if i == len(palette) {
+			break
+		}
+		p := color_to_pixel(c)
+		palette[i] = [4]byte{byte(p[0]), byte(p[1]), byte(p[2]), byte(p[3])}
+	}
+
+	b := src.Rect
+	canvas := NewCanvas(b.Dx(), b.Dy())
+	canvas.opaque = true
+	for y := 0; y < b.Dy(); y++ {
+		p, s := canvas.pix[y*canvas.stride:], src.Pix[y*src.Stride:]
+		for x := 0; x < b.Dx(); x++ {
+			c := &palette[s[x]]
+			copy(p[x*4:x*4+4], c[:])
+			canvas.opaque = canvas.opaque && c[3] == 0xff
+		}
+	}
+	return canvas
+}
+
+// canvas_from_colors converts the colors of any image, pixel by pixel.
+func canvas_from_colors(img Image) *Canvas {
+	b := img.Bounds()
+	canvas := NewCanvas(b.Dx(), b.Dy())
+	canvas.opaque = true
+	for y := 0; y < b.Dy(); y++ {
+		p := canvas.pix[y*canvas.stride:]
+		for x := 0; x < b.Dx(); x++ {
+			c := color_to_pixel(img.At(b.Min.X+x, b.Min.Y+y))
+			p[x*4+0], p[x*4+1], p[x*4+2], p[x*4+3] = byte(c[0]), byte(c[1]), byte(c[2]), byte(c[3])
+			canvas.opaque = canvas.opaque && c[3] == 0xff
+		}
+	}
 	return canvas
 }
@@ -0,0 +1,173 @@
+// Copyright 2014 By Jshi. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vango
+
+import (
+	"gwk/vango/freetype"
+	"image"
+)
+
+// Clip is the region the draws of a Context are limited to. It's in the
+// coordinate of the pixels shared by a canvas and its sub canvases, so a clip
+// made on a canvas also clips the sub canvases. It's never changed once
+// created, so the saved states can share it.
+type Clip struct {
+	rect image.Rectangle
+	// The coverage of the pixels in rect, nil if all of them are covered.
+	mask *image.Alpha
+}
+
+// ClipRect intersects the clip with |rect| in the user space. The clip stays
+// pixel aligned if the transform only translates by whole pixels, otherwise
+// the transformed rect is clipped like ClipPath.
+func (c *CanvasContext) ClipRect(rect image.Rectangle) {
+	if c.canvas == nil {
+		return
+	}
+
+	dx, dy, ok := c.transform.int_translation()
+	if !ok {
+		x0, y0 := float64(rect.Min.X), float64(rect.Min.Y)
+		x1, y1 := float64(rect.Max.X), float64(rect.Max.Y)
+
+		var path freetype.Path
+		path.Start(c.device_point(x0, y0))
+		path.Add1(c.device_point(x1, y0))
+		path.Add1(c.device_point(x1, y1))
+		path.Add1(c.device_point(x0, y1))
+		c.clip_path(path, true)
+		return
+	}
+
+	rect = rect.Add(image.Pt(dx, dy)).Intersect(c.clip_bounds())
+	rect = rect.Add(c.canvas.Bounds().Min)
+	clip := &Clip{rect: rect}
+	if c.clip != nil && c.clip.mask != nil && !rect.Empty() {
+		clip.mask = c.clip.mask.SubImage(rect).(*image.Alpha)
+	}
+	c.clip = clip
+}
+
+// ClipPath intersects the clip with the current path filled by the fill rule.
+// The edges of the path are anti-aliased.
+func (c *CanvasContext) ClipPath() {
+	c.clip_path(c.path, c.fill_rule == FillRuleNonZero)
+}
+
+// ResetClip removes the clip. The clip set after SaveState is removed by
+// RestoreState anyway, so it's only needed by the owner of the canvas.
+func (c *CanvasContext) ResetClip() {
+	c.clip = nil
+}
+
+// Clip returns the current clip, nil if there is no clip.
+func (c *CanvasContext) Clip() *Clip {
+	return c.clip
+}
+
+// SetClip replaces the current clip with the one returned by Clip.
+func (c *CanvasContext) SetClip(clip *Clip) {
+	c.clip = clip
+}
+
+func (c *CanvasContext) save_clip() interface{} {
+	return c.clip
+}
+
+func (c *CanvasContext) restore_clip(clip interface{}) {
+	c.clip = clip.(*Clip)
+}
+
+// ClipBounds returns the bounds of the clip in the canvas coordinate.
+func (c *CanvasContext) ClipBounds() image.Rectangle {
+	return c.clip_bounds()
+}
+
+func (c *CanvasContext) clip_path(path freetype.Path, non_zero bool) {
+	if c.canvas == nil {
+		return
+	}
+
+	bounds := c.clip_bounds()
+	if bounds.Empty() || len(path) == 0 {
+		c.clip = &Clip{}
+		return
+	}
+
+	mask := image.NewAlpha(c.canvas.LocalBounds())
+	rast := c.prepare_rast()
+	rast.UseNonZeroWinding = non_zero
+	add_closed_path(rast, path)
+	rast.Rast(freetype.NewAlphaSrcDrawer(mask))
+
+	// Shrink the rect to the covered pixels, and apply the old mask.
+	rect := image.Rectangle{}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		i := mask.PixOffset(bounds.Min.X, y)
+		m := c.clip_mask(bounds.Min.X, y)
+		for x := bounds.Min.X; x < bounds.Max.X; x, i = x+1, i+1 {
+			a := uint32(mask.Pix[i])
+			if m != nil {
+				a = a * uint32(m[x-bounds.Min.X]) / 0xff
+				mask.Pix[i] = byte(a)
+			}
+			if a == 0 {
+				continue
+			}
+			if rect.Empty() {
+				rect = image.Rect(x, y, x+1, y+1)
+			} else {
+				rect = rect.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+
+	if rect.Empty() {
+		c.clip = &Clip{}
+		return
+	}
+
+	// Move the mask to the coordinate of the pixels.
+	min := c.canvas.Bounds().Min
+	mask.Rect = mask.Rect.Add(min)
+	rect = rect.Add(min)
+	c.clip = &Clip{rect: rect, mask: mask.SubImage(rect).(*image.Alpha)}
+}
+
+// clip_bounds returns the rect the draws are limited to in the canvas
+// coordinate.
+func (c *CanvasContext) clip_bounds() image.Rectangle {
+	if c.canvas == nil {
+		return image.ZR
+	}
+	l := c.canvas.LocalBounds()
+	if c.clip == nil {
+		return l
+	}
+	return c.clip.rect.Sub(c.canvas.Bounds().Min).Intersect(l)
+}
+
+// clip_mask returns the coverage of the clip in the row from (x, y) in the
+// canvas coordinate, nil if the pixels are fully covered. The (x, y) must be
+// in the clip bounds.
+func (c *CanvasContext) clip_mask(x, y int) []byte {
+	if c.clip == nil || c.clip.mask == nil {
+		return nil
+	}
+	m := c.clip.mask
+	min := c.canvas.Bounds().Min
+	i := m.PixOffset(x+min.X, y+min.Y)
+	return m.Pix[i : i+m.Rect.Max.X-x-min.X]
+}
+
+// draw_rect returns the rect in the canvas to draw the |rect| of the source
+// at (x, y), limited by the canvas and the clip, and the point in the source
+// that is drawn at its top left.
+func (c *CanvasContext) draw_rect(x, y int, rect image.Rectangle) (dr image.Rectangle, sp image.Point) {
+	dr = rect.Sub(rect.Min).Add(image.Pt(x, y))
+	dr = dr.Intersect(c.clip_bounds())
+	sp = rect.Min.Add(dr.Min.Sub(image.Pt(x, y)))
+	return dr, sp
+}
@@ -0,0 +1,146 @@
+package vango
+
+import (
+	"image"
+	"testing"
+)
+
+func TestClipRect(t *testing.T) {
+	ctxt, canvas := new_test_context(40, 40)
+	ctxt.ClipRect(image.Rect(10, 10, 30, 30))
+	ctxt.ClipRect(image.Rect(20, 0, 40, 40))
+	ctxt.FillRect(canvas.LocalBounds())
+
+	if got := ctxt.ClipBounds(); got != image.Rect(20, 10, 30, 30) {
+		t.Errorf("ClipBounds: got %v, want (20,10)-(30,30)", got)
+	}
+	if !is_painted(canvas, 25, 20) {
+		t.Errorf("inside the clip isn't painted")
+	}
+	if is_painted(canvas, 15, 20) || is_painted(canvas, 25, 5) || is_painted(canvas, 35, 20) {
+		t.Errorf("outside the clip is painted")
+	}
+}
+
+func TestClipPath(t *testing.T) {
+	ctxt, canvas := new_test_context(40, 40)
+
+	// A circle of radius 10 at (20, 20).
+	const k = 10 * 0.5523
+	ctxt.BeginPath()
+	ctxt.MoveTo(30, 20)
+	ctxt.CubicTo(30, 20+k, 20+k, 30, 20, 30)
+	ctxt.CubicTo(20-k, 30, 10, 20+k, 10, 20)
+	ctxt.CubicTo(10, 20-k, 20-k, 10, 20, 10)
+	ctxt.CubicTo(20+k, 10, 30, 20-k, 30, 20)
+	ctxt.ClipPath()
+
+	ctxt.FillRect(canvas.LocalBounds())
+	if !is_painted(canvas, 20, 20) || is_painted(canvas, 11, 11) {
+		t.Errorf("FillRect isn't clipped by the circle")
+	}
+
+	// The edges are anti-aliased.
+	partial := 0
+	for x := 0; x < 40; x++ {
+		if r, _, _, _ := pixel_at(canvas, x, 13); r > 0 && r < 0xff {
+			partial++
+		}
+	}
+	if partial == 0 {
+		t.Errorf("no anti-aliased pixels on the edge of the clip")
+	}
+}
+
+func TestClipSaveRestore(t *testing.T) {
+	ctxt, canvas := new_test_context(40, 40)
+	ctxt.SaveState()
+	ctxt.ClipRect(image.Rect(0, 0, 10, 10))
+	ctxt.RestoreState()
+
+	ctxt.FillRect(canvas.LocalBounds())
+	if !is_painted(canvas, 30, 30) {
+		t.Errorf("the clip isn't removed by RestoreState")
+	}
+}
+
+func TestClipSubCanvas(t *testing.T) {
+	ctxt, canvas := new_test_context(40, 40)
+	ctxt.ClipRect(image.Rect(0, 0, 20, 40))
+
+	// The clip of the canvas limits its sub canvas, in the sub canvas
+	// coordinate.
+	ctxt.SetCanvas(canvas.SubCanvas(image.Rect(10, 10, 40, 40)))
+	if got := ctxt.ClipBounds(); got != image.Rect(0, 0, 10, 30) {
+		t.Errorf("ClipBounds of the sub canvas: got %v, want (0,0)-(10,30)", got)
+	}
+
+	ctxt.FillRect(image.Rect(0, 0, 30, 30))
+	if !is_painted(canvas, 15, 15) || is_painted(canvas, 25, 15) {
+		t.Errorf("the sub canvas isn't clipped")
+	}
+}
+
+func TestClipDraws(t *testing.T) {
+	src := NewCanvas(40, 40)
+	for i := 0; i < len(src.Pix()); i += 4 {
+		src.Pix()[i+2], src.Pix()[i+3] = 0xff, 0xff
+	}
+	nrgba := image.NewNRGBA(image.Rect(0, 0, 40, 40))
+	for i := 0; i < len(nrgba.Pix); i += 4 {
+		nrgba.Pix[i+0], nrgba.Pix[i+3] = 0xff, 0xff
+	}
+
+	for _, draw := range []func(*CanvasContext){
+		func(c *CanvasContext) { c.AlphaBlend(0, 0, src, src.LocalBounds()) },
+		func(c *CanvasContext) { c.DrawNRGBA(0, 0, nrgba, nrgba.Bounds()) },
+		func(c *CanvasContext) { c.StrokeRect(image.Rect(0, 0, 39, 39)) },
+		func(c *CanvasContext) { c.StrokeRect(image.Rect(5, 5, 35, 35)) },
+	} {
+		ctxt, canvas := new_test_context(40, 40)
+		ctxt.ClipRect(image.Rect(0, 0, 20, 40))
+		draw(ctxt)
+
+		painted := false
+		for y := 0; y < 40; y++ {
+			for x := 0; x < 40; x++ {
+				if !is_painted(canvas, x, y) {
+					continue
+				}
+				if x >= 20 {
+					t.Fatalf("(%v, %v) outside the clip is painted", x, y)
+				}
+				painted = true
+			}
+		}
+		if !painted {
+			t.Errorf("nothing is painted inside the clip")
+		}
+	}
+}
+
+func TestClipText(t *testing.T) {
+	load_test_font(t)
+
+	ctxt, canvas := new_test_context(100, 20)
+	ctxt.SetFont("default")
+	ctxt.SetFontColor(0xff, 0, 0)
+	ctxt.ClipRect(image.Rect(0, 0, 20, 20))
+	ctxt.DrawText("MMMMMMMM", image.Rect(0, 0, 100, 20))
+
+	inside := false
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 100; x++ {
+			if !is_painted(canvas, x, y) {
+				continue
+			}
+			if x >= 20 {
+				t.Fatalf("(%v, %v) outside the clip is painted", x, y)
+			}
+			inside = true
+		}
+	}
+	if !inside {
+		t.Errorf("the text isn't painted inside the clip")
+	}
+}
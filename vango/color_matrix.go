@@ -0,0 +1,137 @@
+// Copyright 2014 By Jshi. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vango
+
+import "math"
+
+// ColorMatrix is the 4x5 matrix transforming the colors, row by row the red,
+// the green, the blue and the alpha:
+//
+//	R' = m[0]*R + m[1]*G + m[2]*B + m[3]*A + m[4]
+//
+// The colors are not premultiplied and in [0, 1], like the feColorMatrix of
+// svg, so the offsets in the last column are in [0, 1] too.
+type ColorMatrix [20]float64
+
+// IdentityColorMatrix returns the matrix keeping the colors.
+func IdentityColorMatrix() ColorMatrix {
+	return ColorMatrix{
+		1, 0, 0, 0, 0,
+		0, 1, 0, 0, 0,
+		0, 0, 1, 0, 0,
+		0, 0, 0, 1, 0,
+	}
+}
+
+// The matrices below are the filter functions of css, |amount| 0 keeps the
+// colors. Grayscale and Sepia are full at 1, the others keep the colors at 1.
+
+func GrayscaleColorMatrix(amount float64) ColorMatrix {
+	k := 1 - math.Min(1, math.Max(0, amount))
+	return ColorMatrix{
+		0.2126 + 0.7874*k, 0.7152 - 0.7152*k, 0.0722 - 0.0722*k, 0, 0,
+		0.2126 - 0.2126*k, 0.7152 + 0.2848*k, 0.0722 - 0.0722*k, 0, 0,
+		0.2126 - 0.2126*k, 0.7152 - 0.7152*k, 0.0722 + 0.9278*k, 0, 0,
+		0, 0, 0, 1, 0,
+	}
+}
+
+func SepiaColorMatrix(amount float64) ColorMatrix {
+	k := 1 - math.Min(1, math.Max(0, amount))
+	return ColorMatrix{
+		0.393 + 0.607*k, 0.769 - 0.769*k, 0.189 - 0.189*k, 0, 0,
+		0.349 - 0.349*k, 0.686 + 0.314*k, 0.168 - 0.168*k, 0, 0,
+		0.272 - 0.272*k, 0.534 - 0.534*k, 0.131 + 0.869*k, 0, 0,
+		0, 0, 0, 1, 0,
+	}
+}
+
+// SaturateColorMatrix scales the saturation, 0 is gray and more than 1 is
+// over saturated.
+func SaturateColorMatrix(amount float64) ColorMatrix {
+	s := math.Max(0, amount)
+	return ColorMatrix{
+		0.213 + 0.787*s, 0.715 - 0.715*s, 0.072 - 0.072*s, 0, 0,
+		0.213 - 0.213*s, 0.715 + 0.285*s, 0.072 - 0.072*s, 0, 0,
+		0.213 - 0.213*s, 0.715 - 0.715*s, 0.072 + 0.928*s, 0, 0,
+		0, 0, 0, 1, 0,
+	}
+}
+
+// BrightnessColorMatrix multiplies the colors by |amount|, 0 is black.
+func BrightnessColorMatrix(amount float64) ColorMatrix {
+	b := math.Max(0, amount)
+	return ColorMatrix{
+		b, 0, 0, 0, 0,
+		0, b, 0, 0, 0,
+		0, 0, b, 0, 0,
+		0, 0, 0, 1, 0,
+	}
+}
+
+// ContrastColorMatrix scales the colors from the middle gray by |amount|, 0
+// is the gray.
+func ContrastColorMatrix(amount float64) ColorMatrix {
+	k := math.Max(0, amount)
+	o := 0.5 - 0.5*k
+	return ColorMatrix{
+		k, 0, 0, 0, o,
+		0, k, 0, 0, o,
+		0, 0, k, 0, o,
+		0, 0, 0, 1, 0,
+	}
+}
+
+// Concat returns the matrix transforming the colors by |m| and then by |n|.
+func (m ColorMatrix) Concat(n ColorMatrix) ColorMatrix {
+	var o ColorMatrix
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 5; j++ {
+			v := 0.0
+			for k := 0; k < 4; k++ {
+				v += n[i*5+k] * m[k*5+j]
+			}
+			if j == 4 {
+				v += n[i*5+4]
+			}
+			o[i*5+j] = v
+		}
+	}
+	return o
+}
+
+// ApplyColorMatrix transforms the colors of the pixels of the canvas by |m|.
+// To filter a part of a canvas, pass its SubCanvas.
+func ApplyColorMatrix(canvas *Canvas, m ColorMatrix) {
+	f, bpp := canvas.Format(), canvas.Format().BytesPerPixel()
+	for y := 0; y < canvas.H(); y++ {
+		p := canvas.Pix()[canvas.PixOffset(0, y):]
+		for x := 0; x < canvas.W(); x++ {
+			px := p[x*bpp : (x+1)*bpp]
+			write_pixel(f, px, m.transform(read_pixel(f, px)))
+		}
+	}
+}
+
+// transform returns the premultiplied color |clr| in the canvas order
+// transformed by the matrix.
+func (m *ColorMatrix) transform(clr [4]uint32) [4]uint32 {
+	var in [4]float64
+	if a := float64(clr[3]); a > 0 {
+		in = [4]float64{float64(clr[2]) / a, float64(clr[1]) / a, float64(clr[0]) / a, a / 255}
+	}
+
+	var out [4]float64
+	for i := range out {
+		v := m[i*5]*in[0] + m[i*5+1]*in[1] + m[i*5+2]*in[2] + m[i*5+3]*in[3] + m[i*5+4]
+		out[i] = math.Min(1, math.Max(0, v))
+	}
+
+	a := out[3] * 255
+	premul := func(v float64) uint32 {
+		return uint32(v*a + 0.5)
+	}
+	return [4]uint32{premul(out[2]), premul(out[1]), premul(out[0]), uint32(a + 0.5)}
+}
@@ -0,0 +1,75 @@
+// Copyright 2014 By Jshi. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vango
+
+import (
+	"errors"
+	"math"
+)
+
+// CompareResult is the difference of two canvases.
+type CompareResult struct {
+	// The mismatched pixels in red, by how much they differ, over the faded
+	// pixels of the first canvas.
+	Diff *Canvas
+	// The number of the pixels with a channel differing more than the
+	// tolerance.
+	Mismatched int
+	// The peak signal to noise ratio in dB of all the channels, +Inf if the
+	// canvases are the same.
+	PSNR float64
+}
+
+// Compare compares the premultiplied BGRA pixels of |a| and |b|. A pixel is
+// mismatched if any channel differs more than |tolerance|. It returns an
+// error if the sizes differ.
+func Compare(a, b *Canvas, tolerance int) (*CompareResult, error) {
+	if a.W() != b.W() || a.H() != b.H() {
+		return nil, errors.New("vango compare canvases of different sizes")
+	}
+	a, b = a.ConvertTo(PixelFormatBGRA8), b.ConvertTo(PixelFormatBGRA8)
+
+	w, h := a.W(), a.H()
+	result := &CompareResult{Diff: NewCanvas(w, h)}
+	diff := result.Diff.Pix()
+	sum := 0.0
+	for y := 0; y < h; y++ {
+		p0, p1 := a.Pix()[a.PixOffset(0, y):], b.Pix()[b.PixOffset(0, y):]
+		d := diff[result.Diff.PixOffset(0, y):]
+		for x := 0; x < w*4; x += 4 {
+			max := 0
+			for j := 0; j < 4; j++ {
+				v := int(p0[x+j]) - int(p1[x+j])
+				sum += float64(v * v)
+				if v < 0 {
+					v = -v
+				}
+				if v > max {
+					max = v
+				}
+			}
+
+			if max > tolerance {
+				result.Mismatched++
+				// At least half red, so the small differences show up.
+				r := byte(0x80 + max/2)
+				d[x+0], d[x+1], d[x+2], d[x+3] = 0, 0, r, r
+			} else {
+				// The gray of the pixel over white, faded to a quarter.
+				g := (int(p0[x+0]) + int(p0[x+1]) + int(p0[x+2])) / 3
+				g = 255 - (int(p0[x+3])-g)/4
+				d[x+0], d[x+1], d[x+2], d[x+3] = byte(g), byte(g), byte(g), 0xff
+			}
+		}
+	}
+
+	n := float64(w * h * 4)
+	if sum == 0 || n == 0 {
+		result.PSNR = math.Inf(1)
+	} else {
+		result.PSNR = 10 * math.Log10(255*255/(sum/n))
+	}
+	return result, nil
+}
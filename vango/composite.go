@@ -0,0 +1,247 @@
+// Copyright 2014 By Jshi. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vango
+
+// CompositeOp is how the drawn colors (the source) are combined with the
+// pixels of the canvas (the destination). The ops only change the pixels
+// covered by the draw, the rest of the canvas is kept.
+type CompositeOp int
+
+const (
+	// The Porter-Duff operators.
+	CompositeSrcOver CompositeOp = iota // The default, source over destination.
+	CompositeClear
+	CompositeSrc
+	CompositeDst
+	CompositeDstOver
+	CompositeSrcIn
+	CompositeDstIn
+	CompositeSrcOut
+	CompositeDstOut
+	CompositeSrcAtop
+	CompositeDstAtop
+	CompositeXor
+	CompositePlus
+
+	// The separable blend modes, composited like source over.
+	CompositeMultiply
+	CompositeScreen
+	CompositeOverlay
+	CompositeDarken
+	CompositeLighten
+)
+
+// SetCompositeOp sets the operator used by all the draws.
+func (c *CanvasContext) SetCompositeOp(op CompositeOp) {
+	c.composite_op = op
+}
+
+func (c *CanvasContext) CompositeOp() CompositeOp {
+	return c.composite_op
+}
+
+// pack_color packs the color like the Set*Color methods. The alpha is in the
+// lowest byte.
+func pack_color(r, g, b, a byte) uint32 {
+	return uint32(r)<<24 | uint32(g)<<16 | uint32(b)<<8 | uint32(a)
+}
+
+// premul_color returns the packed color premultiplied in the canvas order
+// (b, g, r, a).
+func premul_color(clr uint32) [4]uint32 {
+	r, g, b, a := unpack_rgba(clr)
+	k := uint32(a)
+	return [4]uint32{div255(uint32(b) * k), div255(uint32(g) * k), div255(uint32(r) * k), k}
+}
+
+// div255 returns x / 255 rounded, for x in [0, 255*255].
+func div255(x uint32) uint32 {
+	x += 128
+	return (x + x>>8) >> 8
+}
+
+// composite returns the premultiplied source |s| composited with the
+// destination |d| by |op|. The colors are in [0, 255].
+func composite(op CompositeOp, s, d [4]uint32) (o [4]uint32) {
+	sa, da := s[3], d[3]
+	switch op {
+	case CompositeClear:
+		return o
+	case CompositeSrc:
+		return s
+	case CompositeDst:
+		return d
+	case CompositeSrcOver:
+		for i := range o {
+			o[i] = s[i] + div255(d[i]*(255-sa))
+		}
+	case CompositeDstOver:
+		for i := range o {
+			o[i] = d[i] + div255(s[i]*(255-da))
+		}
+	case CompositeSrcIn:
+		for i := range o {
+			o[i] = div255(s[i] * da)
+		}
+	case CompositeDstIn:
+		for i := range o {
+			o[i] = div255(d[i] * sa)
+		}
+	case CompositeSrcOut:
+		for i := range o {
+			o[i] = div255(s[i] * (255 - da))
+		}
+	case CompositeDstOut:
+		for i := range o {
+			o[i] = div255(d[i] * (255 - sa))
+		}
+	case CompositeSrcAtop:
+		for i := range o {
+			o[i] = div255(s[i]*da + d[i]*(255-sa))
+		}
+	case CompositeDstAtop:
+		for i := range o {
+			o[i] = div255(d[i]*sa + s[i]*(255-da))
+		}
+	case CompositeXor:
+		for i := range o {
+			o[i] = div255(s[i]*(255-da) + d[i]*(255-sa))
+		}
+	case CompositePlus:
+		for i := range o {
+			o[i] = s[i] + d[i]
+		}
+	default:
+		// The premultiplied form of the W3C compositing spec:
+		// co = cs * (1 - ab) + cb * (1 - as) + as * ab * B(Cb, Cs)
+		o[3] = sa + da - div255(sa*da)
+		for i := 0; i < 3; i++ {
+			sc, dc := int32(s[i]), int32(d[i])
+			sa, da := int32(sa), int32(da)
+
+			var b int32 // as * ab * B(Cb, Cs), in [0, 255*255].
+			switch op {
+			case CompositeMultiply:
+				b = sc * dc
+			case CompositeScreen:
+				b = sc*da + dc*sa - sc*dc
+			case CompositeOverlay:
+				if 2*dc <= da {
+					b = 2 * sc * dc
+				} else {
+					b = sa*da - 2*(da-dc)*(sa-sc)
+				}
+			case CompositeDarken:
+				b = min_int32(sc*da, dc*sa)
+			case CompositeLighten:
+				b = max_int32(sc*da, dc*sa)
+			}
+			b = max_int32(b, 0)
+			o[i] = div255(uint32(b)) + div255(uint32(sc*(255-da)+dc*(255-sa)))
+		}
+	}
+
+	for i := range o {
+		if o[i] > 255 {
+			o[i] = 255
+		}
+	}
+	return o
+}
+
+func min_int32(a, b int32) int32 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max_int32(a, b int32) int32 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// blend_pixel composites the premultiplied |s| into the pixel |p| by |op|,
+// then mixes the result with the pixel by the coverage |k| in [0, 255].
+func blend_pixel(op CompositeOp, p []byte, s [4]uint32, k uint32) {
+	if op == CompositeSrcOver {
+		// Source over is linear in the source, so the coverage scales it.
+		if k != 255 {
+			for i := range s {
+				s[i] = div255(s[i] * k)
+			}
+		}
+		ia := 255 - s[3]
+		for i := 0; i < 4; i++ {
+			v := s[i] + div255(uint32(p[i])*ia)
+			if v > 255 {
+				v = 255
+			}
+			p[i] = byte(v)
+		}
+		return
+	}
+
+	d := [4]uint32{uint32(p[0]), uint32(p[1]), uint32(p[2]), uint32(p[3])}
+	o := composite(op, s, d)
+	for i := 0; i < 4; i++ {
+		if k != 255 {
+			o[i] = div255(o[i]*k + d[i]*(255-k))
+		}
+		p[i] = byte(o[i])
+	}
+}
+
+// blend_span composites |n| pixels of the canvas from (x, y) with the
+// composite op, the global alpha and the clip. The source is |clr| if
+// |colors| is nil, otherwise the premultiplied colors of each pixel. The
+// coverage is |cov| in [0, 255], times |mask| of each pixel if it isn't nil.
+// The pixels must be in the clip bounds.
+func (c *CanvasContext) blend_span(x, y, n int, clr [4]uint32, colors []byte, mask []byte, cov uint32) {
+	cov = div255(cov * c.global_alpha_8())
+	if cov == 0 {
+		return
+	}
+
+	dst := c.canvas
+	p := dst.Pix()
+	op := c.composite_op
+	clip := c.clip_mask(x, y)
+
+	i := dst.PixOffset(x, y)
+	for j := 0; j < n; j, i = j+1, i+4 {
+		k := cov
+		if mask != nil {
+			k = div255(k * uint32(mask[j]))
+		}
+		if clip != nil {
+			k = div255(k * uint32(clip[j]))
+		}
+		if k == 0 {
+			continue
+		}
+
+		s := clr
+		if colors != nil {
+			o := j * 4
+			s = [4]uint32{uint32(colors[o]), uint32(colors[o+1]), uint32(colors[o+2]), uint32(colors[o+3])}
+		}
+		blend_pixel(op, p[i:i+4], s, k)
+	}
+}
+
+// nrgba_to_premul converts the pixels of an image.NRGBA in |src| to the
+// premultiplied pixels in the canvas order in |dst|.
+func nrgba_to_premul(dst, src []byte) {
+	for i := 0; i+3 < len(src) && i+3 < len(dst); i += 4 {
+		a := uint32(src[i+3])
+		dst[i+0] = byte(div255(uint32(src[i+2]) * a))
+		dst[i+1] = byte(div255(uint32(src[i+1]) * a))
+		dst[i+2] = byte(div255(uint32(src[i+0]) * a))
+		dst[i+3] = byte(a)
+	}
+}
@@ -0,0 +1,135 @@
+package vango
+
+import (
+	"image"
+	"testing"
+)
+
+func near_byte(a, b byte) bool {
+	d := int(a) - int(b)
+	return d >= -2 && d <= 2
+}
+
+func TestFillRGBA(t *testing.T) {
+	ctxt, canvas := new_test_context(10, 10)
+	ctxt.FillRect(canvas.LocalBounds())
+	if _, _, _, a := pixel_at(canvas, 5, 5); a != 0xff {
+		t.Errorf("the alpha of FillRect: got %v, want 0xff", a)
+	}
+
+	// Half transparent blue over the red.
+	ctxt.SetFillRGBA(0, 0, 0xff, 0x80)
+	ctxt.FillRect(canvas.LocalBounds())
+	r, g, b, a := pixel_at(canvas, 5, 5)
+	if !near_byte(r, 0x7f) || g != 0 || !near_byte(b, 0x80) || a != 0xff {
+		t.Errorf("half blue over red: got (%v, %v, %v, %v)", r, g, b, a)
+	}
+
+	// The canvas is premultiplied.
+	ctxt, canvas = new_test_context(10, 10)
+	ctxt.SetFillRGBA(0xff, 0, 0, 0x80)
+	ctxt.FillRect(canvas.LocalBounds())
+	if r, _, _, a := pixel_at(canvas, 5, 5); r != 0x80 || a != 0x80 {
+		t.Errorf("half red on transparent: got r=%v a=%v, want 0x80", r, a)
+	}
+
+	if p := ctxt.FillPaint().(SolidPaint); p.A != 0x80 {
+		t.Errorf("FillPaint lost the alpha")
+	}
+}
+
+func TestCompositePorterDuff(t *testing.T) {
+	// The source is half red, the destination is opaque blue, in the canvas
+	// order (b, g, r, a).
+	s := [4]uint32{0, 0, 0x80, 0x80}
+	d := [4]uint32{0xff, 0, 0, 0xff}
+	for _, c := range []struct {
+		op   CompositeOp
+		want [4]uint32
+	}{
+		{CompositeClear, [4]uint32{0, 0, 0, 0}},
+		{CompositeSrc, [4]uint32{0, 0, 0x80, 0x80}},
+		{CompositeDst, [4]uint32{0xff, 0, 0, 0xff}},
+		{CompositeSrcOver, [4]uint32{0x7f, 0, 0x80, 0xff}},
+		{CompositeDstOver, [4]uint32{0xff, 0, 0, 0xff}},
+		{CompositeSrcIn, [4]uint32{0, 0, 0x80, 0x80}},
+		{CompositeDstIn, [4]uint32{0x80, 0, 0, 0x80}},
+		{CompositeSrcOut, [4]uint32{0, 0, 0, 0}},
+		{CompositeDstOut, [4]uint32{0x7f, 0, 0, 0x7f}},
+		{CompositeSrcAtop, [4]uint32{0x7f, 0, 0x80, 0xff}},
+		{CompositeDstAtop, [4]uint32{0x80, 0, 0, 0x80}},
+		{CompositeXor, [4]uint32{0x7f, 0, 0, 0x7f}},
+		{CompositePlus, [4]uint32{0xff, 0, 0x80, 0xff}},
+	} {
+		if got := composite(c.op, s, d); got != c.want {
+			t.Errorf("op %v: got %v, want %v", c.op, got, c.want)
+		}
+	}
+}
+
+func TestCompositeBlendModes(t *testing.T) {
+	// Opaque colors, the blend modes reduce to the blend functions.
+	s := [4]uint32{0x40, 0x80, 0xc0, 0xff}
+	d := [4]uint32{0x80, 0x80, 0x80, 0xff}
+	for _, c := range []struct {
+		op   CompositeOp
+		want [4]uint32
+	}{
+		{CompositeMultiply, [4]uint32{0x20, 0x40, 0x60, 0xff}},
+		{CompositeScreen, [4]uint32{0xa0, 0xc0, 0xe0, 0xff}},
+		{CompositeOverlay, [4]uint32{0x40, 0x80, 0xc0, 0xff}},
+		{CompositeDarken, [4]uint32{0x40, 0x80, 0x80, 0xff}},
+		{CompositeLighten, [4]uint32{0x80, 0x80, 0xc0, 0xff}},
+	} {
+		got := composite(c.op, s, d)
+		for i := range got {
+			if !near_byte(byte(got[i]), byte(c.want[i])) {
+				t.Errorf("op %v: got %v, want %v", c.op, got, c.want)
+				break
+			}
+		}
+	}
+
+	// A transparent destination keeps the source.
+	if got := composite(CompositeMultiply, s, [4]uint32{}); got != s {
+		t.Errorf("multiply on transparent: got %v, want %v", got, s)
+	}
+}
+
+func TestCompositeOp(t *testing.T) {
+	ctxt, canvas := new_test_context(20, 20)
+	ctxt.FillRect(canvas.LocalBounds())
+
+	// Only the covered pixels are changed.
+	ctxt.SetCompositeOp(CompositeClear)
+	ctxt.FillRect(image.Rect(0, 0, 10, 20))
+	if _, _, _, a := pixel_at(canvas, 5, 5); a != 0 {
+		t.Errorf("CompositeClear: got alpha %v, want 0", a)
+	}
+	if !is_painted(canvas, 15, 5) {
+		t.Errorf("CompositeClear changed the pixels outside the draw")
+	}
+
+	// The op is saved with the state, and applies to the paths.
+	ctxt.SaveState()
+	ctxt.SetCompositeOp(CompositeDstOver)
+	ctxt.SetFillColor(0, 0xff, 0)
+	ctxt.BeginPath()
+	ctxt.MoveTo(0, 0)
+	ctxt.LineTo(20, 0)
+	ctxt.LineTo(20, 20)
+	ctxt.LineTo(0, 20)
+	ctxt.ClosePath()
+	ctxt.Fill()
+	ctxt.RestoreState()
+
+	if ctxt.CompositeOp() != CompositeClear {
+		t.Errorf("RestoreState: got op %v, want CompositeClear", ctxt.CompositeOp())
+	}
+	if _, g, _, _ := pixel_at(canvas, 5, 5); g != 0xff {
+		t.Errorf("DstOver on the cleared pixels: got g=%v, want 0xff", g)
+	}
+	if r, g, _, _ := pixel_at(canvas, 15, 5); r != 0xff || g != 0 {
+		t.Errorf("DstOver on the red: got r=%v g=%v, want red", r, g)
+	}
+}
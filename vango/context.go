@@ -7,406 +7,576 @@ import (
 	"log"
 )
 
-type Context struct {
-	font         *Font
-	canvas       *Canvas
-	dpi          float64
-	stroke_color uint32
-	fill_color   uint32
-	font_color   uint32
+// Context is the drawing api of vango. CanvasContext draws into a canvas,
+// Recorder records the draws into a Picture, SVGWriter and PDFWriter write
+// them as the vector documents. The coordinates are in the user space, mapped
+// to the device by the current transform.
+type Context interface {
+	SaveState()
+	RestoreState()
+	StateDepth() int
+
+	Translate(tx, ty float64)
+	Scale(sx, sy float64)
+	Rotate(angle float64)
+	Transform(m Matrix)
+	SetTransform(m Matrix)
+	ResetTransform()
+	CurrentTransform() Matrix
+
+	ClipRect(rect image.Rectangle)
+	ClipPath()
+	ResetClip()
+
+	SetStrokeColor(r, g, b byte)
+	SetStrokeRGBA(r, g, b, a byte)
+	SetStrokePaint(paint Paint)
+	SetFillColor(r, g, b byte)
+	SetFillRGBA(r, g, b, a byte)
+	SetFillPaint(paint Paint)
+	SetFontColor(r, g, b byte)
+	SetFontRGBA(r, g, b, a byte)
+	SetFontPaint(paint Paint)
+	SetFontSize(size float64)
+	FontSize() float64
+	SetFont(font_name string)
+	SetFontFace(face *freetype.Font)
+	SetFallbackFonts(font_names ...string)
+	FontMetrics() FontMetrics
+	MeasureText(text string) TextMetrics
+	SetDither(dither bool)
+	SetGlobalAlpha(alpha float64)
+	GlobalAlpha() float64
+	SetCompositeOp(op CompositeOp)
+	SetImageFilter(filter Filter)
+
+	SetFillRule(rule FillRule)
+	SetLineWidth(width float64)
+	LineWidth() float64
+	SetLineCap(line_cap LineCap)
+	SetLineJoin(line_join LineJoin)
+	SetMiterLimit(limit float64)
+	SetLineDash(dashes []float64)
+	SetLineDashOffset(offset float64)
+
+	BeginPath()
+	MoveTo(x, y float64)
+	LineTo(x, y float64)
+	QuadTo(cx, cy, x, y float64)
+	CubicTo(c1x, c1y, c2x, c2y, x, y float64)
+	ClosePath()
+	Fill()
+	Stroke()
+
+	DrawText(text string, rect image.Rectangle) (freetype.RastPoint, error)
+	DrawColor(r, g, b byte)
+	DrawImage(x, y int, src image.Image, rect image.Rectangle)
+	DrawAlpha(x, y int, src *image.Alpha, rect image.Rectangle)
+	DrawNRGBA(x, y int, src *image.NRGBA, rect image.Rectangle)
+	DrawRGBA(x, y int, src *image.RGBA, rect image.Rectangle)
+	DrawCanvas(x, y int, src *Canvas, rect image.Rectangle)
+	AlphaBlend(x, y int, src *Canvas, rect image.Rectangle)
+	DrawStretch(dst_rect image.Rectangle, src *Canvas, src_rect image.Rectangle)
+	DrawNinePatch(np *NinePatch, rect image.Rectangle)
+	DrawPicture(p *Picture)
+	DrawShadow(rect image.Rectangle, radii CornerRadii, shadow Shadow)
+
+	FillRect(rect image.Rectangle)
+	StrokeRect(rect image.Rectangle)
+	FillRoundedRect(rect image.Rectangle, radii CornerRadii)
+	StrokeRoundedRect(rect image.Rectangle, radii CornerRadii)
+	FillEllipse(cx, cy, rx, ry float64)
+	StrokeEllipse(cx, cy, rx, ry float64)
+	Arc(cx, cy, rx, ry, start, sweep float64)
+	Pie(cx, cy, rx, ry, start, sweep float64)
+	StrokePie(cx, cy, rx, ry, start, sweep float64)
+	Polygon(points []image.Point)
+	StrokePolygon(points []image.Point)
+	Polyline(points []image.Point)
 }
 
-func NewContext() *Context {
-	ctxt := new(Context)
+// CanvasContext is the Context drawing into a canvas in PixelFormatBGRA8 by
+// the rasterizer.
+type CanvasContext struct {
+	context_state_t
+	state_stack []context_state_t
+
+	font   *Font
+	canvas *Canvas
+	dpi    float64
+
+	// path.
+	path              freetype.Path
+	start_point       freetype.RastPoint
+	current_point     freetype.RastPoint
+	has_current_point bool
+	// The current sub path is closed, the next segment starts a new one at
+	// the start point.
+	path_closed bool
+
+	// The rasterizer for the paths, sized to the current canvas.
+	rast                    *freetype.Rast
+	rast_width, rast_height int
+}
+
+// NewContext returns a CanvasContext without a canvas, set it by SetCanvas.
+func NewContext() *CanvasContext {
+	ctxt := new(CanvasContext)
 	ctxt.font = NewFont()
 	ctxt.dpi = 72
-	ctxt.stroke_color = 0x000000
+	ctxt.stroke_color = pack_color(0, 0, 0, 0xff)
+	ctxt.fill_color = pack_color(0, 0, 0, 0xff)
+	ctxt.font_color = pack_color(0, 0, 0, 0xff)
+	ctxt.font_face = ctxt.font.font
+	ctxt.font_size = ctxt.font.size
+	ctxt.global_alpha = 1
+	ctxt.image_filter = FilterBilinear
+	ctxt.transform = IdentityMatrix()
+	ctxt.line_width = 1
+	ctxt.miter_limit = 10
+	ctxt.rast = freetype.NewRast(0, 0)
 	return ctxt
 }
 
-func (c *Context) SetStrokeColor(r, g, b byte) {
-	c.stroke_color = uint32(b)<<8 | uint32(g)<<16 | uint32(r)<<24
+func (c *CanvasContext) SetStrokeColor(r, g, b byte) {
+	c.SetStrokeRGBA(r, g, b, 0xff)
+}
+
+// SetStrokeRGBA sets the stroke color with the alpha, not premultiplied.
+func (c *CanvasContext) SetStrokeRGBA(r, g, b, a byte) {
+	c.stroke_color = pack_color(r, g, b, a)
+	c.stroke_paint = nil
+}
+
+func (c *CanvasContext) SetFillColor(r, g, b byte) {
+	c.SetFillRGBA(r, g, b, 0xff)
+}
+
+// SetFillRGBA sets the fill color with the alpha, not premultiplied.
+func (c *CanvasContext) SetFillRGBA(r, g, b, a byte) {
+	c.fill_color = pack_color(r, g, b, a)
+	c.fill_paint = nil
 }
 
-func (c *Context) SetFillColor(r, g, b byte) {
-	c.fill_color = uint32(b)<<8 | uint32(g)<<16 | uint32(r)<<24
+func (c *CanvasContext) SetFontColor(r, g, b byte) {
+	c.SetFontRGBA(r, g, b, 0xff)
 }
 
-func (c *Context) SetFontColor(r, g, b byte) {
-	c.font_color = uint32(b)<<8 | uint32(g)<<16 | uint32(r)<<24
+// SetFontRGBA sets the font color with the alpha, not premultiplied.
+func (c *CanvasContext) SetFontRGBA(r, g, b, a byte) {
+	c.font_color = pack_color(r, g, b, a)
+	c.font_paint = nil
 }
 
-func (c *Context) SetFontSize(size float64) {
+func (c *CanvasContext) SetFontSize(size float64) {
+	c.font_size = size
 	c.font.SetFontSize(size)
 }
 
-func (c *Context) SetFont(font_name string) {
-	if font_name == "default" {
-		c.font.font = g_default_font
-	} else {
-		log.Printf("NOT IMPLEMENTATION: font name %v", font_name)
+func (c *CanvasContext) FontSize() float64 {
+	return c.font_size
+}
+
+// SetFont sets the font by |font_name|, "default" or a description parsed by
+// ParseFontDescription, and its size if it has one. The font is kept if none
+// of the registered fonts matches.
+func (c *CanvasContext) SetFont(font_name string) {
+	face, size := font_by_name(font_name)
+	if face == nil {
+		return
 	}
+	c.SetFontFace(face)
+	if size > 0 {
+		c.SetFontSize(size)
+	}
+}
+
+// SetFontFace sets the parsed font used by DrawText.
+func (c *CanvasContext) SetFontFace(face *freetype.Font) {
+	c.font_face = face
+	c.font.SetFontFace(face)
 }
 
-func (c *Context) SetCanvas(canvas *Canvas) *Canvas {
+// SetFallbackFonts sets the fonts of the runes the current font has no glyph
+// of, by the names like SetFont, tried in order. The names no font matches
+// are skipped.
+func (c *CanvasContext) SetFallbackFonts(font_names ...string) {
+	c.font_fallbacks = fallbacks_by_names(font_names)
+	c.font.SetFallbacks(c.font_fallbacks)
+}
+
+// FontMetrics returns the metrics of the current font at the font size.
+func (c *CanvasContext) FontMetrics() FontMetrics {
+	return c.font.Metrics()
+}
+
+// MeasureText returns the advance and the ink bounds of |text| drawn by
+// DrawText with the current font, in the user space.
+func (c *CanvasContext) MeasureText(text string) TextMetrics {
+	return c.font.MeasureText(text)
+}
+
+// SetCanvas sets the canvas to draw to, and returns the old one. The canvas
+// must be in PixelFormatBGRA8, the canvas in other formats is ignored, so
+// nothing is drawn until the next SetCanvas.
+func (c *CanvasContext) SetCanvas(canvas *Canvas) *Canvas {
 	old := c.canvas
+	if canvas != nil && canvas.Format() != PixelFormatBGRA8 {
+		log.Printf("WARNING: SetCanvas with the %v canvas, BGRA8 is needed.", canvas.Format())
+		canvas = nil
+	}
 	c.canvas = canvas
 	return old
 }
 
-func (c *Context) DrawText(text string, rect image.Rectangle) (freetype.RastPoint, error) {
-	if c.font == nil {
+// DrawText draws |text| in |rect| through the current transform, from the
+// left of |rect| on the baseline below its top by the ascent of the font. The
+// glyph masks are used if the transform only translates, otherwise the
+// outlines are rasterized in the device space. It returns the pen position
+// after the text in the user space.
+func (c *CanvasContext) DrawText(text string, rect image.Rectangle) (freetype.RastPoint, error) {
+	if c.font == nil || c.font.font == nil {
 		return freetype.RastPoint{}, errors.New("vango DrawString called with nil font.")
 	}
 
-	pt := freetype.Point(rect.Min.X+1, rect.Min.Y+10)
+	if !c.transform.is_translation() {
+		return c.draw_text_outline(text, rect)
+	}
 
-	prev, has_prev := uint16(0), false
-	for _, rune := range text {
-		idx := c.font.Index(rune)
-		if has_prev {
-			pt.X += freetype.Fix32(c.font.Kerning(prev, idx)) << 2
-		}
+	pt := c.font.text_origin(rect)
+	origin := to_rast_point(c.transform.E, c.transform.F)
+	pt = pt.Add(origin)
 
-		mask, offset, err := c.font.GlyphAt(idx, pt)
+	pt, err := c.font.walk_text(text, pt, func(face *freetype.Font, idx uint16, pt freetype.RastPoint) error {
+		mask, offset, err := c.font.glyph_at(face, idx, pt)
 		if err != nil {
-			return freetype.RastPoint{}, err
+			return err
 		}
-
-		pt.X += freetype.Fix32(c.font.HMetric(idx).AdvanceWidth) << 2
 		glyph_rect := mask.Bounds().Add(offset)
-
 		c.draw_text_mask(glyph_rect.Min.X, glyph_rect.Min.Y, mask)
-
-		prev, has_prev = idx, true
+		return nil
+	})
+	if err != nil {
+		return freetype.RastPoint{}, err
 	}
-	return pt, nil
+	return pt.Sub(origin), nil
 }
 
-func (c *Context) draw_text_mask(x, y int, mask *image.Alpha) {
-	src := mask
-	dst := c.canvas
-	rect := mask.Bounds()
+// draw_text_outline fills the outlines of all the glyphs in one pass of the
+// rasterizer, which keeps the text sharp when it's scaled or rotated.
+func (c *CanvasContext) draw_text_outline(text string, rect image.Rectangle) (freetype.RastPoint, error) {
+	pt := c.font.text_origin(rect)
+	if c.canvas == nil {
+		return pt, nil
+	}
 
-	i0, i1 := src.PixOffset(rect.Min.X, rect.Min.Y), dst.PixOffset(x, y) // pix offset
-	s0, s1 := src.Stride, dst.Stride()                                   // stride
-	p0, p1 := src.Pix, dst.Pix()                                         // pix
+	rast := c.prepare_rast()
+	rast.UseNonZeroWinding = true
 
-	// calculate the draw rect
-	r0 := rect.Sub(rect.Min) // align in (0, 0)
-	r1 := dst.LocalBounds()  // bounds in self coordinate.
-	r1.Min = image.Pt(x, y)  // dst draw position start at (x, y)
-	r1 = r1.Sub(r1.Min)      // align in (0, 0)
+	pt, err := c.font.walk_text(text, pt, func(face *freetype.Font, idx uint16, pt freetype.RastPoint) error {
+		x, y := float64(pt.X)/256, float64(pt.Y)/256
+		return c.font.add_glyph_outline(rast, face, idx, x, y, c.transform)
+	})
+	if err != nil {
+		return freetype.RastPoint{}, err
+	}
+
+	rast.Rast(c.new_span_drawer(c.font_color, c.font_paint))
+	return pt, nil
+}
 
-	dr := r0.Intersect(r1)
+func (c *CanvasContext) draw_text_mask(x, y int, mask *image.Alpha) {
+	c.draw_mask(x, y, mask, mask.Bounds(), c.font_color, c.font_paint)
+}
+
+// draw_mask composites the color or the paint through the |rect| of |mask| at
+// (x, y) in the canvas.
+func (c *CanvasContext) draw_mask(x, y int, mask *image.Alpha, rect image.Rectangle, clr uint32, paint Paint) {
+	// calculate the draw rect, limited by the clip.
+	dr, sp := c.draw_rect(x, y, rect.Intersect(mask.Bounds()))
 	if dr.Empty() {
 		return
 	}
 
-	clr := c.font_color
-	b, g, r := byte(clr>>8&0xff), byte(clr>>16&0xff), byte(clr>>24&0xff)
-
-	for y := 0; y < dr.Dy(); y++ {
-		for x := 0; x < dr.Dx(); x++ {
-			o0, o1 := i0+int(x), i1+x*4 // pix offset in bytes
+	i0, s0 := mask.PixOffset(sp.X, sp.Y), mask.Stride
 
-			a := int32(p0[o0])
-			if a == 0 {
-				continue
-			}
-
-			r1, g1, b1 := p1[o1+0], p1[o1+1], p1[o1+2]
+	// The colors of the paint of one line.
+	shader := c.shader_of(paint)
+	var row []byte
+	if shader != nil {
+		row = make([]byte, dr.Dx()*4)
+	}
 
-			p1[o1+0] = byte((a*(int32(r)-int32(r1)))/256) + r1
-			p1[o1+1] = byte((a*(int32(g)-int32(g1)))/256) + g1
-			p1[o1+2] = byte((a*(int32(b)-int32(b1)))/256) + b1
+	premul := premul_color(clr)
+	for y := dr.Min.Y; y < dr.Max.Y; y++ {
+		if shader != nil {
+			shader.shade_row(dr.Min.X, y, row)
 		}
+		c.blend_span(dr.Min.X, y, dr.Dx(), premul, row, mask.Pix[i0:i0+dr.Dx()], 0xff)
 		i0 = i0 + s0
-		i1 = i1 + s1
 	}
 }
 
-func (c *Context) DrawColor(r, g, b byte) {
-	dst := c.canvas
-	i := dst.PixOffset(0, 0)
-	dr := dst.LocalBounds()
-	p := dst.Pix()
-
-	for y := 0; y < dr.Dy(); y++ {
-		for x := 0; x < dr.Dx()*4; x += 4 {
-			p[i+x+0] = b
-			p[i+x+1] = g
-			p[i+x+2] = r
-			p[i+x+3] = 255
-		}
-		i += dst.Stride()
+// DrawColor fills the canvas, limited by the clip, with the opaque color.
+func (c *CanvasContext) DrawColor(r, g, b byte) {
+	dr := c.clip_bounds()
+	clr := premul_color(pack_color(r, g, b, 0xff))
+	for y := dr.Min.Y; y < dr.Max.Y; y++ {
+		c.blend_span(dr.Min.X, y, dr.Dx(), clr, nil, nil, 0xff)
 	}
 }
 
-func (c *Context) DrawImage(x, y int, src image.Image, rect image.Rectangle) {
+func (c *CanvasContext) DrawImage(x, y int, src image.Image, rect image.Rectangle) {
 	switch typ := src.(type) {
 	case *image.Alpha:
 		c.DrawAlpha(x, y, typ, rect)
 	}
 }
 
-func (c *Context) DrawAlpha(x, y int, src *image.Alpha, rect image.Rectangle) {
-	dst := c.canvas
-
-	i0, i1 := src.PixOffset(rect.Min.X, rect.Min.Y), dst.PixOffset(x, y) // pix offset
-	s0, s1 := src.Stride, dst.Stride()                                   // stride
-	p0, p1 := src.Pix, dst.Pix()                                         // pix
+// DrawAlpha draws black through the |rect| of |src| at (x, y).
+func (c *CanvasContext) DrawAlpha(x, y int, src *image.Alpha, rect image.Rectangle) {
+	dx, dy, _ := c.transform.int_translation()
+	c.draw_mask(x+dx, y+dy, src, rect, pack_color(0, 0, 0, 0xff), nil)
+}
 
-	// calculate the draw rect
-	r0 := rect.Sub(rect.Min) // align in (0, 0)
-	r1 := dst.LocalBounds()  // bounds in self coordinate.
-	r1.Min = image.Pt(x, y)  // dst draw position start at (x, y)
-	r1 = r1.Sub(r1.Min)      // align in (0, 0)
+// DrawNRGBA composites the |rect| of |src| to (x, y) through the current
+// transform. The image is resampled unless the transform only translates by
+// whole pixels.
+func (c *CanvasContext) DrawNRGBA(x int, y int, src *image.NRGBA, rect image.Rectangle) {
+	rect = rect.Intersect(src.Bounds())
+	if dx, dy, ok := c.transform.int_translation(); ok {
+		x, y = x+dx, y+dy
+	} else {
+		c.draw_transformed(x, y, &sample_src_t{src.Pix, src.Stride,
+			src.PixOffset(rect.Min.X, rect.Min.Y), rect.Dx(), rect.Dy(), true})
+		return
+	}
 
-	dr := r0.Intersect(r1)
+	dr, sp := c.draw_rect(x, y, rect)
 	if dr.Empty() {
 		return
 	}
 
-	for y := 0; y < dr.Dy(); y++ {
-		for x := 0; x < dr.Dx(); x++ {
-			o0, o1 := i0+int(x), i1+x*4 // pix offset in bytes
-
-			a := int32(p0[o0])
-			if a == 0 {
-				continue
-			}
-
-			r0, g0, b0 := 0x00, 0x00, 0x00
-			r1, g1, b1 := p1[o1+0], p1[o1+1], p1[o1+2]
-
-			p1[o1+0] = byte((a*(int32(r0)-int32(r1)))/256) + r1
-			p1[o1+1] = byte((a*(int32(g0)-int32(g1)))/256) + g1
-			p1[o1+2] = byte((a*(int32(b0)-int32(b1)))/256) + b1
-		}
+	i0, s0 := src.PixOffset(sp.X, sp.Y), src.Stride
+	row := make([]byte, dr.Dx()*4)
+	for y := dr.Min.Y; y < dr.Max.Y; y++ {
+		nrgba_to_premul(row, src.Pix[i0:i0+dr.Dx()*4])
+		c.blend_span(dr.Min.X, y, dr.Dx(), [4]uint32{}, row, nil, 0xff)
 		i0 = i0 + s0
-		i1 = i1 + s1
 	}
 }
 
-func (c *Context) DrawNRGBA(x int, y int, src *image.NRGBA, rect image.Rectangle) {
-	dst := c.canvas
-	x0, y0 := rect.Min.X, rect.Min.Y
-	x1, y1 := x, y
-
-	i0, i1 := src.PixOffset(x0, y0), dst.PixOffset(x1, y1) // index
-	p0, p1 := src.Pix, dst.Pix()                           // pix
-	s0, s1 := src.Stride, dst.Stride()                     // stride
-
-	// draw rect
-	r0 := rect
-	r1 := dst.LocalBounds()
-	r1.Min = image.Pt(x, y)
-	r1 = r1.Sub(r1.Min)
-	dr := r0.Intersect(r1)
+// DrawRGBA composites the |rect| of |src| to (x, y). The image.RGBA is
+// premultiplied already, only the red and the blue are swapped.
+func (c *CanvasContext) DrawRGBA(x, y int, src *image.RGBA, rect image.Rectangle) {
+	dx, dy, _ := c.transform.int_translation()
+	dr, sp := c.draw_rect(x+dx, y+dy, rect.Intersect(src.Bounds()))
 	if dr.Empty() {
 		return
 	}
 
-	for y := 0; y < dr.Dy(); y++ {
-		for x := 0; x < dr.Dx(); x++ {
-			o0, o1 := i0+4*x, i1+4*x
-			p1[o1+0] = p0[o0+0]
-			p1[o1+1] = p0[o0+1]
-			p1[o1+2] = p0[o0+2]
-			p1[o1+3] = p0[o0+3]
+	i0, s0 := src.PixOffset(sp.X, sp.Y), src.Stride
+	row := make([]byte, dr.Dx()*4)
+	for y := dr.Min.Y; y < dr.Max.Y; y++ {
+		copy(row, src.Pix[i0:i0+dr.Dx()*4])
+		for i := 0; i < len(row); i += 4 {
+			row[i+0], row[i+2] = row[i+2], row[i+0]
 		}
+		c.blend_span(dr.Min.X, y, dr.Dx(), [4]uint32{}, row, nil, 0xff)
 		i0 = i0 + s0
-		i1 = i1 + s1
 	}
 }
 
-// TODO(refactory)
-func (c *Context) DrawRGBA(x, y int, src *image.RGBA, rect image.Rectangle) {
-	dst := c.canvas
-
-	x0, y0 := rect.Min.X, rect.Min.Y
-	x1, y1 := x, y
-
-	i0, i1 := src.PixOffset(x0, y0), dst.PixOffset(x1, y1)
-	p0, p1 := src.Pix, dst.Pix()
-	s0, s1 := src.Stride, dst.Stride()
-
-	// calculate draw rect.
-	r0 := rect
-	r1 := dst.LocalBounds()
-	r1.Min = image.Pt(x, y)
-	r1 = r1.Sub(r1.Min)
-	dr := r0.Intersect(r1)
-
+// DrawCanvas copies the |rect| of |src| to (x, y) like a blit. Unlike
+// AlphaBlend, the composite op and the global alpha are ignored. The source
+// in other formats is converted to BGRA8 first.
+func (c *CanvasContext) DrawCanvas(x, y int, src *Canvas, rect image.Rectangle) {
+	if src.Format() != PixelFormatBGRA8 {
+		src = src.ConvertTo(PixelFormatBGRA8)
+	}
+	dx, dy, _ := c.transform.int_translation()
+	dr, sp := c.draw_rect(x+dx, y+dy, rect.Intersect(src.LocalBounds()))
 	if dr.Empty() {
 		return
 	}
-
-	for y := 0; y < dr.Dy(); y++ {
-		for x := 0; x < dr.Dx(); x++ {
-			o0, o1 := i0+4*x, i1+4*x
-			p1[o1+0] = p0[o0+0]
-			p1[o1+1] = p0[o0+1]
-			p1[o1+2] = p0[o0+2]
-			p1[o1+3] = p0[o0+3]
-		}
-		i0 = i0 + s0
-		i1 = i1 + s1
-	}
+	c.copy_pix(dr, src.Pix(), src.Stride(), src.PixOffset(sp.X, sp.Y))
 }
 
-func (c *Context) DrawCanvas(x, y int, src *Canvas, rect image.Rectangle) {
+// copy_pix copies the 4 bytes pixels from |p0| at the offset |i0| to |dr| of
+// the canvas. The pixels on the anti-aliased edges of the clip are blended
+// by the coverage.
+func (c *CanvasContext) copy_pix(dr image.Rectangle, p0 []byte, s0 int, i0 int) {
 	dst := c.canvas
-	// 0 means src, 1 means dst.
-	// b0, b1 := src.Bounds(), dst.Bounds()
-	// l0, l1 := src.LocalBounds(), dst.LocalBounds()
-	x0, y0, x1, y1 := rect.Min.X, rect.Min.Y, x, y
-	i0, i1 := src.PixOffset(x0, y0), dst.PixOffset(x1, y1)
-	s0, s1 := src.Stride(), dst.Stride()
-	p0, p1 := src.Pix(), dst.Pix()
-
-	// the shared draw rect.
-	r0 := rect.Sub(rect.Min)
-	r1 := dst.LocalBounds()
-	r1.Min = image.Pt(x, y)
-
-	dr := r0.Intersect(r1)
-	if dr.Empty() {
-		return
-	}
+	i1 := dst.PixOffset(dr.Min.X, dr.Min.Y)
+	s1 := dst.Stride()
+	p1 := dst.Pix()
 
-	// from src(x0, y0) draw |r| area to dst(x1, y1)
 	for y := 0; y < dr.Dy(); y++ {
-		for x := 0; x < dr.Dx(); x++ {
-			o0, o1 := i0+4*x, i1+4*x
-			p1[o1+0] = p0[o0+0]
-			p1[o1+1] = p0[o0+1]
-			p1[o1+2] = p0[o0+2]
-			p1[o1+3] = p0[o0+3]
+		m := c.clip_mask(dr.Min.X, dr.Min.Y+y)
+		if m == nil {
+			copy(p1[i1:i1+dr.Dx()*4], p0[i0:i0+dr.Dx()*4])
+		} else {
+			for x := 0; x < dr.Dx(); x++ {
+				o0, o1 := i0+4*x, i1+4*x
+				k := uint32(m[x])
+				for j := 0; j < 4; j++ {
+					p1[o1+j] = byte(div255(uint32(p0[o0+j])*k + uint32(p1[o1+j])*(255-k)))
+				}
+			}
 		}
 		i0 = i0 + s0
 		i1 = i1 + s1
 	}
 }
 
-func (c *Context) AlphaBlend(x int, y int, src *Canvas, rect image.Rectangle) {
-	dst := c.canvas
-	// 0 means src, 1 means dst.
-	// l0, l1 := src.LocalBounds(), dst.LocalBounds()
-	x0, y0, x1, y1 := 0, 0, x, y
-	i0, i1 := src.PixOffset(x0, y0), dst.PixOffset(x1, y1)
-	s0, s1 := src.Stride(), dst.Stride()
-	p0, p1 := src.Pix(), dst.Pix()
-
-	// the shared draw rect.
-	r0 := rect.Sub(rect.Min)
-	r1 := dst.LocalBounds()
-	r1.Min = image.Pt(x, y)
-	r1 = r1.Sub(r1.Min)
-	dr := r0.Intersect(r1)
-	if dr.Empty() {
+// AlphaBlend composites the |rect| of |src| to (x, y) through the current
+// transform, with the composite op and the global alpha. The source in other
+// formats is converted to BGRA8 first, an A8 source is black.
+func (c *CanvasContext) AlphaBlend(x int, y int, src *Canvas, rect image.Rectangle) {
+	if src.Format() != PixelFormatBGRA8 {
+		src = src.ConvertTo(PixelFormatBGRA8)
+	}
+	rect = rect.Intersect(src.LocalBounds())
+	if dx, dy, ok := c.transform.int_translation(); ok {
+		x, y = x+dx, y+dy
+	} else {
+		c.draw_transformed(x, y, &sample_src_t{src.Pix(), src.Stride(),
+			src.PixOffset(rect.Min.X, rect.Min.Y), rect.Dx(), rect.Dy(), false})
 		return
 	}
 
-	// from src(x0, y0) draw |r| area to dst(x1, y1)
-	for y := 0; y < dr.Dy(); y++ {
-		for x := 0; x < dr.Dx(); x++ {
-			// http://archive.gamedev.net/archive/reference/articles/article817.html
-			o0, o1 := i0+4*x, i1+4*x
-			r0, g0, b0 := p0[o0+0], p0[o0+1], p0[o0+2]
-			r1, g1, b1 := p1[o1+0], p1[o1+1], p1[o1+2]
-
-			// alpha value
-			a := int32(p0[o0+3])
-
-			p1[o1+0] = byte((a*(int32(r0)-int32(r1)))/256) + r1
-			p1[o1+1] = byte((a*(int32(g0)-int32(g1)))/256) + g1
-			p1[o1+2] = byte((a*(int32(b0)-int32(b1)))/256) + b1
-		}
+	// the shared draw rect, limited by the clip.
+	dr, sp := c.draw_rect(x, y, rect)
+	if dr.Empty() {
+		return
+	}
 
+	i0, s0 := src.PixOffset(sp.X, sp.Y), src.Stride()
+	p0 := src.Pix()
+	for y := dr.Min.Y; y < dr.Max.Y; y++ {
+		c.blend_span(dr.Min.X, y, dr.Dx(), [4]uint32{}, p0[i0:i0+dr.Dx()*4], nil, 0xff)
 		i0 = i0 + s0
-		i1 = i1 + s1
 	}
 }
 
-func (c *Context) DrawStretch(dst_rect image.Rectangle, src *Canvas, src_rect image.Rectangle) {
-
-}
+func (c *CanvasContext) FillRect(rect image.Rectangle) {
+	if dx, dy, ok := c.transform.int_translation(); ok && c.fill_paint == nil {
+		rect = rect.Add(image.Pt(dx, dy))
+	} else {
+		c.fill_transformed_rect(rect)
+		return
+	}
 
-func (c *Context) FillRect(rect image.Rectangle) {
 	dst := c.canvas
-	l := dst.LocalBounds()
-	dr := rect.Intersect(l) // draw rect
+	dr := rect.Intersect(c.clip_bounds()) // draw rect
 
 	if dr.Empty() {
 		return
 	}
+
+	clr := premul_color(c.fill_color)
+	opaque := clr[3] == 0xff && c.global_alpha_8() == 0xff &&
+		(c.composite_op == CompositeSrcOver || c.composite_op == CompositeSrc)
+	if !opaque || c.clip_mask(dr.Min.X, dr.Min.Y) != nil {
+		for y := dr.Min.Y; y < dr.Max.Y; y++ {
+			c.blend_span(dr.Min.X, y, dr.Dx(), clr, nil, nil, 0xff)
+		}
+		return
+	}
+
+	// The opaque color replaces the pixels.
 	s := dst.Stride()
 	p := dst.Pix()
 
 	i0 := dst.PixOffset(dr.Min.X, dr.Min.Y) // offset of the pix that at the BEGIN of one line
 	i1 := i0 + dr.Dx()*4                    // offset of the pix that at the END of one line
 
-	clr := c.fill_color
-	b, g, r := byte(clr>>8&0xff), byte(clr>>16&0xff), byte(clr>>24&0xff)
+	b, g, r := byte(clr[0]), byte(clr[1]), byte(clr[2])
 	for y := 0; y < dr.Dy(); y++ {
 		for x := i0; x < i1; x = x + 4 {
 			p[x+0] = b
 			p[x+1] = g
 			p[x+2] = r
-			p[x+3] = 0
+			p[x+3] = 0xff
 		}
 		i0 += s
 		i1 += s
 	}
 }
 
-func (c *Context) StrokeRect(rect image.Rectangle) {
-	dst := c.canvas
-	i := dst.PixOffset(rect.Min.X, rect.Min.Y)
-	s := dst.Stride()
-	p := dst.Pix()
+// fill_transformed_rect fills the |rect| in the user space with the rasterizer
+// when it isn't aligned to the pixels, or it's filled by a paint.
+func (c *CanvasContext) fill_transformed_rect(rect image.Rectangle) {
+	if c.canvas == nil || rect.Empty() {
+		return
+	}
 
-	clr := c.stroke_color
-	b, g, r := byte(clr>>8&0xff), byte(clr>>16&0xff), byte(clr>>24&0xff)
-	// r, g, b = 0x00, 0xff, 0xff
-	// step 1
-	for x := 0; x < rect.Dx(); x++ {
-		o := i + x*4
-		p[o+0] = r
-		p[o+1] = g
-		p[o+2] = b
-		p[o+3] = 0xff
+	x0, y0 := float64(rect.Min.X), float64(rect.Min.Y)
+	x1, y1 := float64(rect.Max.X), float64(rect.Max.Y)
+
+	rast := c.prepare_rast()
+	rast.UseNonZeroWinding = true
+	rast.Start(c.device_point(x0, y0))
+	rast.Add1(c.device_point(x1, y0))
+	rast.Add1(c.device_point(x1, y1))
+	rast.Add1(c.device_point(x0, y1))
+	rast.Add1(c.device_point(x0, y0))
+	rast.Rast(c.new_span_drawer(c.fill_color, c.fill_paint))
+}
+
+func (c *CanvasContext) StrokeRect(rect image.Rectangle) {
+	if dx, dy, ok := c.transform.int_translation(); ok && c.stroke_paint == nil && len(c.line_dash) == 0 {
+		rect = rect.Add(image.Pt(dx, dy))
+	} else {
+		c.stroke_transformed_rect(rect)
+		return
 	}
 
+	clr := premul_color(c.stroke_color)
+	// step 1
+	c.stroke_line(image.Rect(rect.Min.X, rect.Min.Y, rect.Max.X, rect.Min.Y+1), clr)
+
 	// step 2
-	i = dst.PixOffset(rect.Min.X, rect.Max.Y)
-	for x := 0; x < rect.Dx(); x++ {
-		o := i + x*4
-		p[o+0] = r
-		p[o+1] = g
-		p[o+2] = b
-		p[o+3] = 0xff
-	}
+	c.stroke_line(image.Rect(rect.Min.X, rect.Max.Y, rect.Max.X, rect.Max.Y+1), clr)
 
 	// step 3
-	i = dst.PixOffset(rect.Min.X, rect.Min.Y)
-	for y := 0; y < rect.Dy(); y++ {
-		o := i + y*s
-		p[o+0] = r
-		p[o+1] = g
-		p[o+2] = b
-		p[o+3] = 0xff
-	}
+	c.stroke_line(image.Rect(rect.Min.X, rect.Min.Y+1, rect.Min.X+1, rect.Max.Y), clr)
 
 	// step 4
-	i = dst.PixOffset(rect.Max.X, rect.Min.Y)
-	for y := 0; y < rect.Dy(); y++ {
-		o := i + y*s
-		p[o+0] = r
-		p[o+1] = g
-		p[o+2] = b
-		p[o+3] = 0xff
+	c.stroke_line(image.Rect(rect.Max.X, rect.Min.Y, rect.Max.X+1, rect.Max.Y+1), clr)
+}
+
+// stroke_line paints the 1 pixel wide |line| of StrokeRect, limited by the
+// clip.
+func (c *CanvasContext) stroke_line(line image.Rectangle, clr [4]uint32) {
+	dr := line.Intersect(c.clip_bounds())
+	for y := dr.Min.Y; y < dr.Max.Y; y++ {
+		c.blend_span(dr.Min.X, y, dr.Dx(), clr, nil, nil, 0xff)
 	}
 }
+
+// stroke_transformed_rect strokes the 1 pixel outline drawn by StrokeRect
+// through the current transform, or with the stroke paint or the dashes.
+func (c *CanvasContext) stroke_transformed_rect(rect image.Rectangle) {
+	if c.canvas == nil {
+		return
+	}
+
+	// The center of the outline pixels.
+	x0, y0 := float64(rect.Min.X)+0.5, float64(rect.Min.Y)+0.5
+	x1, y1 := float64(rect.Max.X)+0.5, float64(rect.Max.Y)+0.5
+
+	var path freetype.Path
+	path.Start(c.device_point(x0, y0))
+	path.Add1(c.device_point(x1, y0))
+	path.Add1(c.device_point(x1, y1))
+	path.Add1(c.device_point(x0, y1))
+	path.Add1(c.device_point(x0, y0))
+	path.Close()
+
+	rast := c.prepare_rast()
+	rast.UseNonZeroWinding = true
+	width := to_fix32(c.transform.scale_factor())
+	freetype.Stroke(rast, c.dashed(path), width, freetype.ButtCapper, freetype.NewMiterJoiner(c.miter_limit))
+	rast.Rast(c.new_span_drawer(c.stroke_color, c.stroke_paint))
+}
@@ -0,0 +1,234 @@
+// Copyright 2014 By Jshi. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vango
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+	"io/ioutil"
+	"math/bits"
+)
+
+var (
+	errBMPFormat      = errors.New("vango bmp bad format")
+	errBMPUnsupported = errors.New("vango bmp unsupported")
+)
+
+// The compressions of the bmp.
+const (
+	kBmpRGB       = 0
+	kBmpBitFields = 3
+)
+
+func init() {
+	image.RegisterFormat("bmp", "BM", DecodeBMP, DecodeBMPConfig)
+}
+
+// bmp_header_t is the BITMAPINFOHEADER with the masks of the bit fields and
+// the palette.
+type bmp_header_t struct {
+	offset      int
+	width       int
+	height      int
+	top_down    bool
+	bpp         int
+	compression uint32
+	masks       [4]uint32 // red, green, blue and alpha.
+	palette     color.Palette
+}
+
+// read_bmp_header reads the file header and the info header, up to the pixels
+// at the offset.
+func read_bmp_header(r io.Reader) (*bmp_header_t, int, error) {
+	var file [kBmpFileHeaderSize + 4]byte
+	if _, err := io.ReadFull(r, file[:]); err != nil {
+		return nil, 0, errBMPFormat
+	}
+	if file[0] != 'B' || file[1] != 'M' {
+		return nil, 0, errBMPFormat
+	}
+	info_size := int(binary.LittleEndian.Uint32(file[kBmpFileHeaderSize:]))
+	if info_size < 40 || info_size > 1024 {
+		// The OS/2 headers aren't supported.
+		return nil, 0, errBMPUnsupported
+	}
+	info := make([]byte, info_size)
+	copy(info, file[kBmpFileHeaderSize:])
+	if _, err := io.ReadFull(r, info[4:]); err != nil {
+		return nil, 0, errBMPFormat
+	}
+	read := kBmpFileHeaderSize + info_size
+
+	h := &bmp_header_t{
+		offset:      int(binary.LittleEndian.Uint32(file[10:])),
+		width:       int(int32(binary.LittleEndian.Uint32(info[4:]))),
+		height:      int(int32(binary.LittleEndian.Uint32(info[8:]))),
+		bpp:         int(binary.LittleEndian.Uint16(info[14:])),
+		compression: binary.LittleEndian.Uint32(info[16:]),
+	}
+	if h.height < 0 {
+		h.height, h.top_down = -h.height, true
+	}
+	if h.width <= 0 || h.height == 0 || h.width > 1<<15 || h.height > 1<<15 {
+		return nil, 0, errBMPUnsupported
+	}
+
+	switch {
+	case h.compression == kBmpRGB && h.bpp == 16:
+		h.masks = [4]uint32{0x7c00, 0x03e0, 0x001f, 0}
+	case h.compression == kBmpRGB && (h.bpp == 24 || h.bpp == 32):
+		// The alpha of BI_RGB is unused, and left 0 by most writers.
+		h.masks = [4]uint32{0xff0000, 0x00ff00, 0x0000ff, 0}
+	case h.compression == kBmpRGB && (h.bpp == 1 || h.bpp == 4 || h.bpp == 8):
+	case h.compression == kBmpBitFields && (h.bpp == 16 || h.bpp == 32):
+		// The masks follow the BITMAPINFOHEADER, or are in the larger headers.
+		if info_size == 40 {
+			var masks [12]byte
+			if _, err := io.ReadFull(r, masks[:]); err != nil {
+				return nil, 0, errBMPFormat
+			}
+			info = append(info, masks[:]...)
+			read += len(masks)
+		}
+		for i := range h.masks {
+			if 40+i*4+4 <= len(info) {
+				h.masks[i] = binary.LittleEndian.Uint32(info[40+i*4:])
+			}
+		}
+	default:
+		return nil, 0, errBMPUnsupported
+	}
+
+	if h.bpp <= 8 {
+		n := int(binary.LittleEndian.Uint32(info[32:]))
+		if n == 0 || n > 1<<uint(h.bpp) {
+			n = 1 << uint(h.bpp)
+		}
+		p := make([]byte, n*4)
+		if _, err := io.ReadFull(r, p); err != nil {
+			return nil, 0, errBMPFormat
+		}
+		read += len(p)
+		h.palette = make(color.Palette, n)
+		for i := range h.palette {
+			h.palette[i] = color.RGBA{p[i*4+2], p[i*4+1], p[i*4+0], 0xff}
+		}
+	}
+	if h.offset < read {
+		return nil, 0, errBMPFormat
+	}
+	return h, read, nil
+}
+
+// DecodeBMPConfig returns the size and the color model of the bmp in |r|.
+func DecodeBMPConfig(r io.Reader) (image.Config, error) {
+	h, _, err := read_bmp_header(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+	var model color.Model = color.NRGBAModel
+	if h.palette != nil {
+		model = h.palette
+	}
+	return image.Config{ColorModel: model, Width: h.width, Height: h.height}, nil
+}
+
+// DecodeBMP decodes the uncompressed bmp in |r|. The bmp of 1, 4 or 8 bits is
+// an image.Paletted, the others are an image.NRGBA, with the alpha of the bit
+// fields if it has.
+func DecodeBMP(r io.Reader) (image.Image, error) {
+	h, read, err := read_bmp_header(r)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.CopyN(ioutil.Discard, r, int64(h.offset-read)); err != nil {
+		return nil, errBMPFormat
+	}
+
+	// The rows are read before the image is allocated, so the bmp of a huge
+	// size has to have the data of it. The rows are padded to 4 bytes.
+	row_size := (h.width*h.bpp + 31) / 32 * 4
+	var data bytes.Buffer
+	if n, _ := io.CopyN(&data, r, int64(row_size*h.height)); n != int64(row_size*h.height) {
+		return nil, errBMPFormat
+	}
+	rows := data.Bytes()
+
+	rect := image.Rect(0, 0, h.width, h.height)
+	var paletted *image.Paletted
+	var nrgba *image.NRGBA
+	if h.palette != nil {
+		paletted = image.NewPaletted(rect, h.palette)
+	} else {
+		nrgba = image.NewNRGBA(rect)
+	}
+
+	for j := 0; j < h.height; j++ {
+		row := rows[j*row_size : (j+1)*row_size]
+		y := h.height - 1 - j
+		if h.top_down {
+			y = j
+		}
+		if paletted != nil {
+			read_bmp_indices(paletted, y, row, h.bpp)
+		} else {
+			read_bmp_pixels(nrgba, y, row, h.bpp, &h.masks)
+		}
+	}
+	if paletted != nil {
+		return paletted, nil
+	}
+	return nrgba, nil
+}
+
+// read_bmp_indices reads the row of the palette indices packed in |bpp| bits,
+// the left most pixel in the high bits.
+func read_bmp_indices(img *image.Paletted, y int, row []byte, bpp int) {
+	pix := img.Pix[y*img.Stride:]
+	per_byte := 8 / bpp
+	mask := byte(1<<uint(bpp) - 1)
+	for x := 0; x < img.Rect.Dx(); x++ {
+		shift := uint(8 - bpp*(x%per_byte+1))
+		index := row[x/per_byte] >> shift & mask
+		if int(index) >= len(img.Palette) {
+			index = 0
+		}
+		pix[x] = index
+	}
+}
+
+// read_bmp_pixels reads the row of the pixels of 16, 24 or 32 bits, with the
+// channels in |masks|.
+func read_bmp_pixels(img *image.NRGBA, y int, row []byte, bpp int, masks *[4]uint32) {
+	pix := img.Pix[y*img.Stride:]
+	size := bpp / 8
+	for x := 0; x < img.Rect.Dx(); x++ {
+		var v uint32
+		for i := size - 1; i >= 0; i-- {
+			v = v<<8 | uint32(row[x*size+i])
+		}
+		p := pix[x*4 : x*4+4]
+		for i, mask := range masks {
+			p[i] = bmp_channel(v, mask)
+		}
+		if masks[3] == 0 {
+			p[3] = 0xff
+		}
+	}
+}
+
+// bmp_channel returns the channel of |mask| in |v| scaled to 8 bits.
+func bmp_channel(v, mask uint32) byte {
+	if mask == 0 {
+		return 0
+	}
+	shift := uint(bits.TrailingZeros32(mask))
+	max := uint64(mask >> shift)
+	return byte((uint64((v&mask)>>shift)*0xff + max/2) / max)
+}
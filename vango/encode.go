@@ -0,0 +1,89 @@
+// Copyright 2014 By Jshi. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vango
+
+import (
+	"encoding/binary"
+	"image"
+	"image/png"
+	"io"
+)
+
+// EncodePNG writes the canvas to |w| as png. A Gray8 canvas is written as a
+// gray png, the others with the colors not premultiplied and the alpha.
+func EncodePNG(w io.Writer, canvas *Canvas) error {
+	return png.Encode(w, standard_image(canvas))
+}
+
+// standard_image returns the copy of the canvas as image.Gray or image.NRGBA,
+// which the standard encoders write without the slow generic path.
+func standard_image(canvas *Canvas) image.Image {
+	if canvas.Format() == PixelFormatGray8 {
+		c := canvas.ConvertTo(PixelFormatGray8)
+		return &image.Gray{Pix: c.Pix(), Stride: c.Stride(), Rect: c.LocalBounds()}
+	}
+	c := canvas.ConvertTo(PixelFormatRGBA8)
+	return &image.NRGBA{Pix: c.Pix(), Stride: c.Stride(), Rect: c.LocalBounds()}
+}
+
+// The sizes of the headers of a bmp file with the BITMAPV4HEADER.
+const (
+	kBmpFileHeaderSize = 14
+	kBmpV4HeaderSize   = 108
+)
+
+// EncodeBMP writes the canvas to |w| as a 32 bits bmp, top down, with the
+// colors not premultiplied and the alpha.
+func EncodeBMP(w io.Writer, canvas *Canvas) error {
+	c := canvas.ConvertTo(PixelFormatRGBA8)
+	width, height := c.W(), c.H()
+	size := width * height * 4
+	offset := kBmpFileHeaderSize + kBmpV4HeaderSize
+
+	header := []interface{}{
+		// BITMAPFILEHEADER
+		[2]byte{'B', 'M'},
+		uint32(offset + size),
+		uint32(0),
+		uint32(offset),
+		// BITMAPV4HEADER, the negative height is top down.
+		uint32(kBmpV4HeaderSize),
+		int32(width),
+		int32(-height),
+		uint16(1),
+		uint16(32),
+		uint32(3), // BI_BITFIELDS
+		uint32(size),
+		int32(2835), // 72 dpi
+		int32(2835),
+		uint32(0),
+		uint32(0),
+		// The masks of red, green, blue and alpha.
+		uint32(0x00ff0000),
+		uint32(0x0000ff00),
+		uint32(0x000000ff),
+		uint32(0xff000000),
+		[4]byte{'B', 'G', 'R', 's'}, // LCS_sRGB
+		[36]byte{},                  // the endpoints, unused by sRGB.
+		[3]uint32{},                 // the gammas, unused by sRGB.
+	}
+	for _, v := range header {
+		if err := binary.Write(w, binary.LittleEndian, v); err != nil {
+			return err
+		}
+	}
+
+	row := make([]byte, width*4)
+	for y := 0; y < height; y++ {
+		p := c.Pix()[c.PixOffset(0, y):]
+		for x := 0; x < width; x++ {
+			row[x*4+0], row[x*4+1], row[x*4+2], row[x*4+3] = p[x*4+2], p[x*4+1], p[x*4+0], p[x*4+3]
+		}
+		if _, err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package vango
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/png"
+	"math"
+	"runtime"
+	"testing"
+)
+
+func TestEncodePNG(t *testing.T) {
+	canvas := NewCanvas(10, 10)
+	set_pixel(canvas, 3, 4, 0, 0, 0x80, 0x80)
+	sub := canvas.SubCanvas(image.Rect(2, 2, 8, 8))
+
+	var buf bytes.Buffer
+	if err := EncodePNG(&buf, sub); err != nil {
+		t.Fatalf("EncodePNG: %v", err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatalf("png.Decode: %v", err)
+	}
+	if img.Bounds() != image.Rect(0, 0, 6, 6) {
+		t.Errorf("the bounds of the sub canvas: got %v", img.Bounds())
+	}
+	// The png isn't premultiplied.
+	if r, _, _, a := img.At(1, 2).RGBA(); r>>8 != 0x80 || a>>8 != 0x80 {
+		t.Errorf("the pixel: got r=%x a=%x, want premultiplied 0x80", r>>8, a>>8)
+	}
+}
+
+func TestEncodeBMP(t *testing.T) {
+	canvas := NewCanvas(3, 2)
+	set_pixel(canvas, 0, 0, 0xff, 0, 0, 0xff)
+	set_pixel(canvas, 2, 1, 0, 0, 0x80, 0x80)
+
+	var buf bytes.Buffer
+	if err := EncodeBMP(&buf, canvas); err != nil {
+		t.Fatalf("EncodeBMP: %v", err)
+	}
+	b := buf.Bytes()
+	if len(b) != kBmpFileHeaderSize+kBmpV4HeaderSize+3*2*4 || b[0] != 'B' || b[1] != 'M' {
+		t.Fatalf("the bmp header: got %v bytes, %q", len(b), b[:2])
+	}
+	if w, h := int32(binary.LittleEndian.Uint32(b[18:])), int32(binary.LittleEndian.Uint32(b[22:])); w != 3 || h != -2 {
+		t.Errorf("the bmp size: got %vx%v, want 3x-2", w, h)
+	}
+
+	p := b[kBmpFileHeaderSize+kBmpV4HeaderSize:]
+	if p[0] != 0xff || p[3] != 0xff {
+		t.Errorf("the top left pixel: got %v", p[:4])
+	}
+	if q := p[(3+2)*4:]; q[2] != 0xff || q[3] != 0x80 {
+		t.Errorf("the bottom right pixel isn't unpremultiplied: got %v", q[:4])
+	}
+}
+
+func TestDecodeBMP(t *testing.T) {
+	canvas := NewCanvas(3, 2)
+	set_pixel(canvas, 0, 0, 0xff, 0, 0, 0xff)
+	set_pixel(canvas, 2, 1, 0, 0, 0x80, 0x80)
+	var buf bytes.Buffer
+	if err := EncodeBMP(&buf, canvas); err != nil {
+		t.Fatalf("EncodeBMP: %v", err)
+	}
+	img, format, err := image.Decode(&buf)
+	if err != nil || format != "bmp" {
+		t.Fatalf("image.Decode: got %v, %v", format, err)
+	}
+	assert_same_canvas(t, "the 32 bits bmp", CanvasFromImage(img), canvas)
+
+	// A bottom up bmp of 4 bits, with the rows padded to 4 bytes.
+	var b bytes.Buffer
+	header := []interface{}{
+		[2]byte{'B', 'M'}, uint32(0), uint32(0), uint32(kBmpFileHeaderSize + 40 + 2*4),
+		uint32(40), int32(3), int32(2), uint16(1), uint16(4), uint32(kBmpRGB),
+		uint32(0), int32(0), int32(0), uint32(2), uint32(0),
+		[8]byte{0x10, 0x20, 0x30, 0, 0xff, 0xff, 0xff, 0},
+		[4]byte{0x10, 0x10}, [4]byte{0x01, 0x00},
+	}
+	for _, v := range header {
+		binary.Write(&b, binary.LittleEndian, v)
+	}
+	img, err = DecodeBMP(&b)
+	if err != nil {
+		t.Fatalf("DecodeBMP: %v", err)
+	}
+	paletted, ok := img.(*image.Paletted)
+	if !ok || paletted.Bounds() != image.Rect(0, 0, 3, 2) {
+		t.Fatalf("the 4 bits bmp: got %T %v", img, img.Bounds())
+	}
+	if got := paletted.Pix; !bytes.Equal(got, []byte{0, 1, 0, 1, 0, 1}) {
+		t.Errorf("the indices: got %v", got)
+	}
+	if r, g, b, _ := paletted.At(0, 0).RGBA(); r>>8 != 0x30 || g>>8 != 0x20 || b>>8 != 0x10 {
+		t.Errorf("the palette: got %x %x %x", r>>8, g>>8, b>>8)
+	}
+
+	if _, err := DecodeBMP(bytes.NewReader([]byte("BM"))); err == nil {
+		t.Errorf("DecodeBMP of a truncated bmp: no error")
+	}
+
+	// The header of a 32768x32768 bmp without the pixels fails before the
+	// image is allocated.
+	var huge bytes.Buffer
+	for _, v := range []interface{}{
+		[2]byte{'B', 'M'}, uint32(0), uint32(0), uint32(kBmpFileHeaderSize + 40),
+		uint32(40), int32(1 << 15), int32(1 << 15), uint16(1), uint16(32), uint32(kBmpRGB),
+		uint32(0), int32(0), int32(0), uint32(0), uint32(0), [64]byte{},
+	} {
+		binary.Write(&huge, binary.LittleEndian, v)
+	}
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, _, err = image.Decode(&huge)
+	runtime.ReadMemStats(&after)
+	if err != errBMPFormat {
+		t.Errorf("image.Decode of a huge bmp without the pixels: got %v", err)
+	}
+	if n := after.TotalAlloc - before.TotalAlloc; n > 1<<20 {
+		t.Errorf("the huge bmp allocated %d bytes", n)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	a, b := NewCanvas(4, 4), NewCanvas(4, 4)
+	result, err := Compare(a, b, 0)
+	if err != nil || result.Mismatched != 0 || !math.IsInf(result.PSNR, 1) {
+		t.Fatalf("the same canvases: got %+v, %v", result, err)
+	}
+
+	set_pixel(b, 1, 2, 0, 0, 0x10, 0x10)
+	if result, _ = Compare(a, b, 0x10); result.Mismatched != 0 {
+		t.Errorf("within the tolerance: got %v mismatched", result.Mismatched)
+	}
+	result, _ = Compare(a, b, 0)
+	if result.Mismatched != 1 || math.IsInf(result.PSNR, 1) || result.PSNR < 30 {
+		t.Errorf("one pixel: got %v mismatched, PSNR %v", result.Mismatched, result.PSNR)
+	}
+	if r, g, _, _ := pixel_at(result.Diff, 1, 2); r < 0x80 || g != 0 {
+		t.Errorf("the diff of the mismatched pixel: got r=%v g=%v", r, g)
+	}
+	if r, g, _, _ := pixel_at(result.Diff, 0, 0); r != g {
+		t.Errorf("the diff of the matched pixel isn't gray: got r=%v g=%v", r, g)
+	}
+
+	if _, err := Compare(a, NewCanvas(4, 5), 0); err == nil {
+		t.Errorf("the different sizes: got no error")
+	}
+}
@@ -0,0 +1,262 @@
+// Copyright 2014 By Jshi. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vango
+
+import (
+	"image"
+	"math"
+)
+
+// The filters below change the pixels of a canvas in place. To filter a part
+// of a canvas, pass its SubCanvas. The blurs take the pixels beyond the edges
+// as the ones on the edges.
+
+// BoxBlur averages every pixel with the ones at most |radius| pixels away,
+// along the rows and then the columns.
+func BoxBlur(canvas *Canvas, radius int) {
+	if radius <= 0 {
+		return
+	}
+	blur_canvas(canvas, func(dst, src []byte, n, bpp int) {
+		box_blur_line(dst, src, n, bpp, radius)
+	})
+}
+
+// GaussianBlur blurs the canvas by the separable gaussian kernel. The standard
+// deviation is half the |radius|, like the blur radius of the css shadows, so
+// the blur reaches about 1.5 times the radius.
+func GaussianBlur(canvas *Canvas, radius float64) {
+	kernel := gaussian_kernel(radius)
+	if len(kernel) <= 1 {
+		return
+	}
+	blur_canvas(canvas, func(dst, src []byte, n, bpp int) {
+		convolve_line(dst, src, n, bpp, kernel)
+	})
+}
+
+// kMaxBlurExtent is the most pixels a blur spreads the pixels by, the larger
+// radii blur a bit less than they would.
+const kMaxBlurExtent = 256
+
+// blur_extent returns how many pixels GaussianBlur spreads the pixels by, 0
+// for the radii that aren't positive numbers.
+func blur_extent(radius float64) int {
+	if !(radius > 0) || math.IsInf(radius, 0) {
+		return 0
+	}
+	return int(math.Ceil(math.Min(radius*1.5, kMaxBlurExtent)))
+}
+
+// gaussian_kernel returns the weights of the kernel in 16.16 fixed point,
+// which sum to exactly 1.
+func gaussian_kernel(radius float64) []int32 {
+	r := blur_extent(radius)
+	if r == 0 {
+		return nil
+	}
+	sigma := radius / 2
+	weights := make([]float64, 2*r+1)
+	sum := 0.0
+	for i := range weights {
+		d := float64(i - r)
+		weights[i] = math.Exp(-d * d / (2 * sigma * sigma))
+		sum += weights[i]
+	}
+
+	kernel := make([]int32, len(weights))
+	total := int32(0)
+	for i, w := range weights {
+		kernel[i] = int32(w/sum*(1<<16) + 0.5)
+		total += kernel[i]
+	}
+	// The rounding error goes to the center.
+	kernel[r] += 1<<16 - total
+	return kernel
+}
+
+// blur_canvas runs |blur| along the rows and then the columns of the canvas.
+// |blur| blurs |n| packed pixels of |bpp| bytes from |src| to |dst|. The
+// pixels are blurred premultiplied, so an RGBA8 canvas is converted to BGRA8
+// and back.
+func blur_canvas(canvas *Canvas, blur func(dst, src []byte, n, bpp int)) {
+	if canvas.Format() == PixelFormatRGBA8 {
+		tmp := canvas.ConvertTo(PixelFormatBGRA8)
+		blur_canvas(tmp, blur)
+		canvas.DrawCanvas(0, 0, tmp, tmp.LocalBounds())
+		return
+	}
+
+	w, h, bpp := canvas.W(), canvas.H(), canvas.Format().BytesPerPixel()
+	if w <= 0 || h <= 0 {
+		return
+	}
+	pix, stride := canvas.Pix(), canvas.Stride()
+	n := w
+	if h > n {
+		n = h
+	}
+	src, dst := make([]byte, n*bpp), make([]byte, n*bpp)
+
+	for y := 0; y < h; y++ {
+		row := pix[canvas.PixOffset(0, y):][:w*bpp]
+		copy(src, row)
+		blur(dst, src[:w*bpp], w, bpp)
+		copy(row, dst[:w*bpp])
+	}
+
+	for x := 0; x < w; x++ {
+		i0 := canvas.PixOffset(x, 0)
+		for y, i := 0, i0; y < h; y, i = y+1, i+stride {
+			copy(src[y*bpp:(y+1)*bpp], pix[i:])
+		}
+		blur(dst, src[:h*bpp], h, bpp)
+		for y, i := 0, i0; y < h; y, i = y+1, i+stride {
+			copy(pix[i:i+bpp], dst[y*bpp:])
+		}
+	}
+}
+
+func clamp_int(i, lo, hi int) int {
+	if i < lo {
+		return lo
+	}
+	if i > hi {
+		return hi
+	}
+	return i
+}
+
+// box_blur_line averages the channels by the running sums of the windows.
+func box_blur_line(dst, src []byte, n, bpp, r int) {
+	k := 2*r + 1
+	for ch := 0; ch < bpp; ch++ {
+		at := func(i int) int {
+			return int(src[clamp_int(i, 0, n-1)*bpp+ch])
+		}
+		sum := 0
+		for i := -r; i <= r; i++ {
+			sum += at(i)
+		}
+		for i := 0; i < n; i++ {
+			dst[i*bpp+ch] = byte((sum + k/2) / k)
+			sum += at(i+r+1) - at(i-r)
+		}
+	}
+}
+
+func convolve_line(dst, src []byte, n, bpp int, kernel []int32) {
+	r := len(kernel) / 2
+	for i := 0; i < n; i++ {
+		for ch := 0; ch < bpp; ch++ {
+			sum := int32(1 << 15)
+			for j, w := range kernel {
+				sum += w * int32(src[clamp_int(i+j-r, 0, n-1)*bpp+ch])
+			}
+			dst[i*bpp+ch] = byte(sum >> 16)
+		}
+	}
+}
+
+// Shadow is the alpha of a shape, blurred, offset and painted in one color.
+type Shadow struct {
+	DX, DY int        // The offset in pixels.
+	Blur   float64    // The radius of GaussianBlur.
+	Color  SolidPaint // The color, its alpha scales the shadow.
+}
+
+// Bounds returns the rect covered by the drop shadow of |rect|.
+func (s Shadow) Bounds(rect image.Rectangle) image.Rectangle {
+	e := blur_extent(s.Blur)
+	return rect.Add(image.Pt(s.DX, s.DY)).Inset(-e)
+}
+
+// DropShadow draws the shadow of the pixels of the canvas behind them. The
+// shadow is cut off at the edges of the canvas, leave the transparent margins
+// of Shadow.Bounds for it.
+func DropShadow(canvas *Canvas, shadow Shadow) {
+	apply_shadow(canvas, shadow_of(canvas, shadow, false), shadow.Color, CompositeDstOver)
+}
+
+// InnerShadow draws the shadow cast by the outside of the shape of the pixels
+// onto them, like the shape is cut in the surface.
+func InnerShadow(canvas *Canvas, shadow Shadow) {
+	apply_shadow(canvas, shadow_of(canvas, shadow, true), shadow.Color, CompositeSrcAtop)
+}
+
+// shadow_of returns the alpha of the canvas offset and blurred, inverted if
+// |inner| is true. The pixels moved in from the outside are transparent.
+func shadow_of(canvas *Canvas, shadow Shadow, inner bool) *Canvas {
+	w, h := canvas.W(), canvas.H()
+	f, bpp := canvas.Format(), canvas.Format().BytesPerPixel()
+	mask := NewCanvasWithFormat(w, h, PixelFormatA8)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			a := byte(0)
+			if sx, sy := x-shadow.DX, y-shadow.DY; sx >= 0 && sx < w && sy >= 0 && sy < h {
+				a = byte(read_pixel(f, canvas.Pix()[canvas.PixOffset(sx, sy):][:bpp])[3])
+			}
+			if inner {
+				a = 0xff - a
+			}
+			mask.pix[mask.PixOffset(x, y)] = a
+		}
+	}
+	GaussianBlur(mask, shadow.Blur)
+	return mask
+}
+
+// apply_shadow composites the |mask| in the color |paint| with the pixels of
+// the canvas by |op|.
+func apply_shadow(canvas *Canvas, mask *Canvas, paint SolidPaint, op CompositeOp) {
+	clr := premul_color(pack_color(paint.R, paint.G, paint.B, paint.A))
+	f, bpp := canvas.Format(), canvas.Format().BytesPerPixel()
+	for y := 0; y < canvas.H(); y++ {
+		p := canvas.Pix()[canvas.PixOffset(0, y):]
+		m := mask.Pix()[mask.PixOffset(0, y):]
+		for x := 0; x < canvas.W(); x++ {
+			k := uint32(m[x])
+			s := [4]uint32{div255(clr[0] * k), div255(clr[1] * k), div255(clr[2] * k), div255(clr[3] * k)}
+			px := p[x*bpp : (x+1)*bpp]
+			write_pixel(f, px, composite(op, s, read_pixel(f, px)))
+		}
+	}
+}
+
+// DrawShadow draws the drop shadow of the rounded rect |rect| with |radii| in
+// the user space, but not the rect, so it can be drawn over the shadow. The
+// offset and the blur are in pixels, they don't follow the transform.
+func (c *CanvasContext) DrawShadow(rect image.Rectangle, radii CornerRadii, shadow Shadow) {
+	if c.canvas == nil || rect.Empty() {
+		return
+	}
+	shape, pt := shadow_canvas(rect, radii, shadow, c.transform.inverse_bounds(c.clip_bounds()))
+	if shape != nil {
+		c.AlphaBlend(pt.X, pt.Y, shape, shape.LocalBounds())
+	}
+}
+
+// shadow_canvas returns the part in |visible| of the drop shadow of the
+// rounded rect, and where it's drawn in the user space, or nil if none of it
+// is visible. Only the shape around the visible part by the blur extent is
+// rasterized, which is all the visible pixels take.
+func shadow_canvas(rect image.Rectangle, radii CornerRadii, shadow Shadow, visible image.Rectangle) (*Canvas, image.Point) {
+	e := blur_extent(shadow.Blur)
+	offset := image.Pt(shadow.DX, shadow.DY)
+	visible = visible.Intersect(shadow.Bounds(rect))
+	if visible.Empty() {
+		return nil, image.ZP
+	}
+	area := visible.Sub(offset).Inset(-e).Intersect(rect.Inset(-e))
+	shape := NewCanvas(area.Dx(), area.Dy())
+	ctxt := NewContext()
+	ctxt.SetCanvas(shape)
+	ctxt.SetFillColor(0, 0, 0)
+	ctxt.FillRoundedRect(rect.Sub(area.Min), radii)
+
+	mask := shadow_of(shape, Shadow{Blur: shadow.Blur}, false)
+	apply_shadow(shape, mask, shadow.Color, CompositeSrc)
+	return shape, area.Min.Add(offset)
+}
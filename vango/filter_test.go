@@ -0,0 +1,234 @@
+package vango
+
+import (
+	"image"
+	"math"
+	"runtime"
+	"testing"
+)
+
+func TestGaussianKernel(t *testing.T) {
+	kernel := gaussian_kernel(4)
+	if len(kernel) != 2*6+1 {
+		t.Fatalf("kernel size: got %v, want 13", len(kernel))
+	}
+	sum := int32(0)
+	for i, w := range kernel {
+		sum += w
+		if w != kernel[len(kernel)-1-i] {
+			t.Errorf("kernel isn't symmetric at %v: %v", i, kernel)
+		}
+	}
+	if sum != 1<<16 {
+		t.Errorf("kernel sum: got %v, want %v", sum, 1<<16)
+	}
+	if gaussian_kernel(0) != nil {
+		t.Errorf("kernel of radius 0 isn't empty")
+	}
+	for _, radius := range []float64{-1, math.NaN(), math.Inf(1), math.Inf(-1)} {
+		if e := blur_extent(radius); e != 0 || gaussian_kernel(radius) != nil {
+			t.Errorf("the extent of the radius %v: got %v, want 0", radius, e)
+		}
+	}
+	if e := blur_extent(1e300); e != kMaxBlurExtent {
+		t.Errorf("the extent of the huge radius: got %v, want %v", e, kMaxBlurExtent)
+	}
+}
+
+func TestBlur(t *testing.T) {
+	for _, blur := range []func(*Canvas){
+		func(c *Canvas) { BoxBlur(c, 2) },
+		func(c *Canvas) { GaussianBlur(c, 3) },
+	} {
+		canvas := NewCanvas(21, 21)
+		set_pixel(canvas, 10, 10, 0xff, 0xff, 0xff, 0xff)
+		blur(canvas)
+
+		_, _, _, center := pixel_at(canvas, 10, 10)
+		if center == 0 || center == 0xff {
+			t.Errorf("the center isn't spread: alpha %v", center)
+		}
+		_, _, _, a0 := pixel_at(canvas, 8, 10)
+		_, _, _, a1 := pixel_at(canvas, 12, 10)
+		_, _, _, a2 := pixel_at(canvas, 10, 8)
+		if a0 == 0 || a0 != a1 || a0 != a2 || a0 > center {
+			t.Errorf("the blur isn't symmetric: %v %v %v, center %v", a0, a1, a2, center)
+		}
+		if _, _, _, a := pixel_at(canvas, 0, 0); a != 0 {
+			t.Errorf("the corner is reached: alpha %v", a)
+		}
+	}
+
+	// The flat colors are kept, the edges are clamped.
+	canvas := NewCanvas(8, 8)
+	ctxt := NewContext()
+	ctxt.SetCanvas(canvas)
+	ctxt.DrawColor(0x40, 0x80, 0xc0)
+	GaussianBlur(canvas, 2)
+	if r, g, b, a := pixel_at(canvas, 0, 7); r != 0x40 || g != 0x80 || b != 0xc0 || a != 0xff {
+		t.Errorf("flat color: got %x %x %x %x", r, g, b, a)
+	}
+}
+
+func TestBlurSubCanvas(t *testing.T) {
+	canvas := NewCanvas(20, 10)
+	set_pixel(canvas, 5, 5, 0, 0, 0, 0xff)
+	set_pixel(canvas, 15, 5, 0, 0, 0, 0xff)
+	BoxBlur(canvas.SubCanvas(image.Rect(10, 0, 20, 10)), 1)
+
+	if _, _, _, a := pixel_at(canvas, 5, 5); a != 0xff {
+		t.Errorf("the pixel outside the sub canvas is blurred: alpha %v", a)
+	}
+	if _, _, _, a := pixel_at(canvas, 15, 5); a != 0x1c {
+		t.Errorf("the pixel inside the sub canvas: got alpha %#x, want 0x1c", a)
+	}
+}
+
+func TestBlurFormats(t *testing.T) {
+	canvas := NewCanvasWithFormat(9, 1, PixelFormatA8)
+	canvas.Pix()[4] = 0xff
+	BoxBlur(canvas, 1)
+	if got := canvas.Pix()[3:6]; got[0] != 0x55 || got[1] != 0x55 || got[2] != 0x55 {
+		t.Errorf("A8 box blur: got %v", got)
+	}
+
+	// The color of the transparent pixels doesn't bleed in.
+	canvas = NewCanvasWithFormat(3, 1, PixelFormatRGBA8)
+	copy(canvas.Pix(), []byte{0xff, 0, 0, 0, 0, 0xff, 0, 0xff, 0xff, 0, 0, 0})
+	BoxBlur(canvas, 1)
+	if p := canvas.Pix()[4:8]; p[0] != 0 || p[1] != 0xff || p[3] != 0x55 {
+		t.Errorf("RGBA8 box blur: got %v", p)
+	}
+}
+
+func TestDropShadow(t *testing.T) {
+	canvas := NewCanvas(30, 30)
+	ctxt := NewContext()
+	ctxt.SetCanvas(canvas)
+	ctxt.SetFillColor(0xff, 0, 0)
+	ctxt.FillRect(image.Rect(5, 5, 15, 15))
+
+	shadow := Shadow{DX: 6, DY: 6, Blur: 2, Color: NewSolidPaint(0, 0, 0xff, 0xff)}
+	DropShadow(canvas, shadow)
+
+	if r, _, b, _ := pixel_at(canvas, 10, 10); r != 0xff || b != 0 {
+		t.Errorf("the shape is covered by the shadow: r %x b %x", r, b)
+	}
+	if r, _, b, a := pixel_at(canvas, 17, 17); r != 0 || b != 0xff || a != 0xff {
+		t.Errorf("the shadow: got r %x b %x a %x", r, b, a)
+	}
+	if _, _, _, a := pixel_at(canvas, 22, 22); a == 0 || a == 0xff {
+		t.Errorf("the shadow edge isn't blurred: alpha %x", a)
+	}
+	if _, _, _, a := pixel_at(canvas, 28, 3); a != 0 {
+		t.Errorf("the shadow is outside its bounds: alpha %x", a)
+	}
+	if got := shadow.Bounds(image.Rect(5, 5, 15, 15)); got != image.Rect(8, 8, 24, 24) {
+		t.Errorf("Bounds: got %v", got)
+	}
+}
+
+func TestInnerShadow(t *testing.T) {
+	canvas := NewCanvas(30, 30)
+	ctxt := NewContext()
+	ctxt.SetCanvas(canvas)
+	ctxt.SetFillColor(0xff, 0xff, 0xff)
+	ctxt.FillRect(image.Rect(5, 5, 25, 25))
+
+	InnerShadow(canvas, Shadow{DX: 3, DY: 3, Blur: 1, Color: NewSolidPaint(0, 0, 0, 0xff)})
+
+	if r, _, _, _ := pixel_at(canvas, 5, 5); r > 0x20 {
+		t.Errorf("the top left edge isn't shadowed: r %x", r)
+	}
+	if r, _, _, _ := pixel_at(canvas, 23, 23); r != 0xff {
+		t.Errorf("the bottom right edge is shadowed: r %x", r)
+	}
+	if _, _, _, a := pixel_at(canvas, 2, 2); a != 0 {
+		t.Errorf("the shadow is outside the shape: alpha %x", a)
+	}
+}
+
+func TestDrawShadow(t *testing.T) {
+	ctxt, canvas := new_test_context(40, 40)
+	ctxt.Translate(5, 5)
+	ctxt.DrawShadow(image.Rect(5, 5, 25, 25), UniformRadii(4),
+		Shadow{DX: 2, DY: 2, Blur: 2, Color: NewSolidPaint(0, 0, 0, 0x80)})
+
+	if _, _, _, a := pixel_at(canvas, 20, 20); a < 0x7e || a > 0x80 {
+		t.Errorf("inside the shadow: got alpha %x, want 0x80", a)
+	}
+	if _, _, _, a := pixel_at(canvas, 33, 20); a == 0 || a >= 0x7e {
+		t.Errorf("the blurred edge: got alpha %x", a)
+	}
+	if _, _, _, a := pixel_at(canvas, 8, 20); a != 0 {
+		t.Errorf("outside the shadow: got alpha %x", a)
+	}
+
+	// The NaN blur is no blur.
+	ctxt, canvas = new_test_context(40, 40)
+	ctxt.DrawShadow(image.Rect(5, 5, 25, 25), CornerRadii{}, Shadow{Blur: math.NaN(), Color: NewSolidPaint(0, 0, 0, 0xff)})
+	if _, _, _, a := pixel_at(canvas, 24, 24); a != 0xff {
+		t.Errorf("the shadow of the NaN blur: got alpha %x", a)
+	}
+}
+
+func TestDrawShadowClipped(t *testing.T) {
+	shadow := Shadow{DX: 3, DY: 1, Blur: 4, Color: NewSolidPaint(0, 0, 0, 0xc0)}
+	for _, m := range []Matrix{IdentityMatrix(), {A: 1, D: 1, E: 0.5, F: 0.25}} {
+		// The shadow far larger than the canvas only takes the visible part.
+		ctxt, got := new_test_context(20, 20)
+		ctxt.SetTransform(m)
+		ctxt.ClipRect(image.Rect(2, 0, 20, 18))
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		ctxt.DrawShadow(image.Rect(5, 5, 8000, 8000), UniformRadii(3), shadow)
+		runtime.ReadMemStats(&after)
+		if n := after.TotalAlloc - before.TotalAlloc; n > 1<<20 {
+			t.Errorf("transform %v: the shadow allocated %d bytes", m, n)
+		}
+
+		want_ctxt, want := new_test_context(20, 20)
+		want_ctxt.SetTransform(m)
+		want_ctxt.ClipRect(image.Rect(2, 0, 20, 18))
+		want_ctxt.DrawShadow(image.Rect(5, 5, 40, 40), UniformRadii(3), shadow)
+		assert_same_canvas(t, "the clipped shadow", got, want)
+	}
+}
+
+func TestColorMatrix(t *testing.T) {
+	canvas := NewCanvas(2, 1)
+	set_pixel(canvas, 0, 0, 0x00, 0x00, 0xff, 0xff) // red
+	set_pixel(canvas, 1, 0, 0x00, 0x40, 0x00, 0x80) // half transparent green
+
+	ApplyColorMatrix(canvas, IdentityColorMatrix())
+	if r, g, b, a := pixel_at(canvas, 1, 0); r != 0 || g != 0x40 || b != 0 || a != 0x80 {
+		t.Errorf("identity: got %x %x %x %x", r, g, b, a)
+	}
+
+	ApplyColorMatrix(canvas, GrayscaleColorMatrix(1))
+	if r, g, b, _ := pixel_at(canvas, 0, 0); r != g || g != b || r != 0x36 {
+		t.Errorf("grayscale of red: got %x %x %x, want 36 36 36", r, g, b)
+	}
+	if r, g, b, a := pixel_at(canvas, 1, 0); r != g || g != b || a != 0x80 {
+		t.Errorf("grayscale keeps the alpha: got %x %x %x %x", r, g, b, a)
+	}
+
+	canvas = NewCanvasWithFormat(1, 1, PixelFormatRGBA8)
+	copy(canvas.Pix(), []byte{0x80, 0x40, 0xc0, 0xff})
+	ApplyColorMatrix(canvas, BrightnessColorMatrix(2).Concat(ContrastColorMatrix(0)))
+	if p := canvas.Pix(); p[0] != 0x80 || p[1] != 0x80 || p[2] != 0x80 {
+		t.Errorf("brightness then contrast 0: got %v, want the middle gray", p)
+	}
+
+	copy(canvas.Pix(), []byte{0x80, 0x40, 0xc0, 0xff})
+	ApplyColorMatrix(canvas, ContrastColorMatrix(0).Concat(BrightnessColorMatrix(1.5)))
+	if p := canvas.Pix(); p[0] != 0xbf || p[1] != 0xbf || p[2] != 0xbf {
+		t.Errorf("contrast 0 then brightness: got %v", p)
+	}
+
+	copy(canvas.Pix(), []byte{0x80, 0x40, 0xc0, 0xff})
+	ApplyColorMatrix(canvas, SaturateColorMatrix(1).Concat(SepiaColorMatrix(0)))
+	if p := canvas.Pix(); p[0] != 0x80 || p[1] != 0x40 || p[2] != 0xc0 {
+		t.Errorf("the amounts keeping the colors: got %v", p)
+	}
+}
@@ -4,54 +4,159 @@ import (
 	"errors"
 	"gwk/vango/freetype"
 	"image"
-	"io/ioutil"
 	"log"
+	"sync"
 )
 
 var g_default_font *freetype.Font
 
+// kDefaultFontFamily is the family of the default font, the first font
+// registered is the default without it.
+const kDefaultFontFamily = "Luxi Sans"
+
+// g_font_dirs is the directories scanned for the fonts by InitVango.
+var g_font_dirs = []string{"./resc"}
+
+var g_font_registry = NewFontRegistry()
+
+// SetFontDirs sets the directories of the fonts registered by InitVango,
+// "./resc" by default.
+func SetFontDirs(dirs ...string) {
+	g_font_dirs = append([]string(nil), dirs...)
+}
+
 func init_font() {
-	bytes, err := ioutil.ReadFile("./resc/luxisr.ttf")
-	if err != nil {
-		log.Printf("error: load font failed -> %v", err)
-		return
+	for _, dir := range g_font_dirs {
+		if _, err := AddFontDir(dir); err != nil {
+			log.Printf("error: scan fonts in %v failed -> %v", dir, err)
+		}
 	}
 
-	g_default_font, err = freetype.ParseFont(bytes)
+	g_default_font = g_font_registry.Match(FontDescription{Families: []string{kDefaultFontFamily}})
+	if g_default_font == nil && len(g_font_registry.entries) > 0 {
+		g_default_font = g_font_registry.entries[0].face
+	}
 	if g_default_font == nil {
-		log.Printf("err: load font failed -> %v", err)
-		return
+		log.Printf("error: load font failed -> no font in %v", g_font_dirs)
+	}
+}
+
+// RegisterFont parses the font in |data| and registers it for SetFont.
+func RegisterFont(data []byte) (*freetype.Font, error) {
+	face, err := g_font_registry.Register(data)
+	if err == nil {
+		g_description_cache.clear()
 	}
+	return face, err
 }
 
-var g_description_font_map map[string]*Font
+// AddFontDir registers the fonts in |dir| for SetFont, and returns their
+// number.
+func AddFontDir(dir string) (int, error) {
+	count, err := g_font_registry.AddDir(dir)
+	if count > 0 {
+		g_description_cache.clear()
+	}
+	return count, err
+}
 
-func find_font_by_description(description string) *Font {
-	return g_description_font_map[description]
+// FontFamilies returns the families of the registered fonts.
+func FontFamilies() []string {
+	return g_font_registry.Families()
+}
+
+// font_match_t is the face matched by a description, and the size it sets,
+// zero for none.
+type font_match_t struct {
+	face *freetype.Font
+	size float64
+}
+
+// description_cache_t is the faces matched by the descriptions, it's cleared
+// when a font is registered. It's safe for concurrent use.
+type description_cache_t struct {
+	lock    sync.Mutex
+	matches map[string]font_match_t
+}
+
+var g_description_cache = &description_cache_t{}
+
+func (c *description_cache_t) get(description string) (font_match_t, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	m, ok := c.matches[description]
+	return m, ok
+}
+
+func (c *description_cache_t) put(description string, m font_match_t) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.matches == nil {
+		c.matches = make(map[string]font_match_t)
+	}
+	c.matches[description] = m
+}
+
+func (c *description_cache_t) clear() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.matches = nil
+}
+
+// find_font_by_description returns the face matching |description|, parsed
+// by ParseFontDescription, and the size it sets, or a nil face if no font
+// matches.
+func find_font_by_description(description string) (*freetype.Font, float64) {
+	if m, ok := g_description_cache.get(description); ok {
+		return m.face, m.size
+	}
+
+	desc, err := ParseFontDescription(description)
+	if err != nil {
+		log.Printf("WARNING: %v", err)
+		return nil, 0
+	}
+	face := g_font_registry.Match(desc)
+	if face == nil {
+		return nil, 0
+	}
+	g_description_cache.put(description, font_match_t{face, desc.Size})
+	return face, desc.Size
+}
+
+// font_by_name returns the face of |font_name| for SetFont, "default" or a
+// description, and the size it sets, zero for none.
+func font_by_name(font_name string) (*freetype.Font, float64) {
+	if font_name == "default" {
+		return g_default_font, 0
+	}
+	face, size := find_font_by_description(font_name)
+	if face == nil {
+		log.Printf("WARNING: no font matches %v", font_name)
+	}
+	return face, size
 }
 
 const (
-	kGlyphNum      = 256
 	kXFractionsNum = 4
 	kYFractionsNum = 1
 )
 
-type glyph_cache_t struct {
-	valid  bool
-	glyph  uint16
-	mask   *image.Alpha
-	offset image.Point
-}
-
 // vango.Font is a wrapper of freetype.Font. It is more like freetype.Context
 type Font struct {
 	font  *freetype.Font
 	size  float64
-	cache [kGlyphNum * kXFractionsNum * kYFractionsNum]glyph_cache_t
 	glyph *freetype.Glyph
 	rast  *freetype.Rast
 	dpi   float64
 	scale int32
+
+	// fallbacks is the faces for the runes the font has no glyph of.
+	fallbacks []*freetype.Font
+
+	// hinting is whether the glyphs are execed by the instructions of the
+	// faces.
+	hinting bool
 }
 
 func NewFont() *Font {
@@ -68,30 +173,61 @@ func NewFont() *Font {
 }
 
 func (f *Font) SetFontSize(size float64) {
+	if f.size == size {
+		return
+	}
 	f.size = size
 	f.recalc()
 }
 
+func (f *Font) SetFontFace(font *freetype.Font) {
+	if f.font == font {
+		return
+	}
+	f.font = font
+	f.recalc()
+}
+
+// SetHinting sets whether the glyphs are fitted to the pixel grid by the
+// instructions of the faces.
+func (f *Font) SetHinting(hinting bool) {
+	f.hinting = hinting
+}
+
+func (f *Font) Hinting() bool {
+	return f.hinting
+}
+
+// GlyphAt returns the mask of the glyph of the font at |pt|, and its top left.
+// The mask is shared by the Fonts of the same face and size, it must not be
+// modified.
 func (f *Font) GlyphAt(glyph uint16, pt freetype.RastPoint) (*image.Alpha, image.Point, error) {
+	return f.glyph_at(f.font, glyph, pt)
+}
+
+// glyph_at returns the mask of the glyph of |face| at |pt|, and its top left,
+// from the glyph cache shared by all the Fonts. The subpixel offset is
+// rounded down to kXFractionsNum steps, so the mask of a glyph is the same
+// wherever it's rasterized first.
+func (f *Font) glyph_at(face *freetype.Font, glyph uint16, pt freetype.RastPoint) (*image.Alpha, image.Point, error) {
 	ix, fx := int(pt.X>>8), pt.X&0xff
 	iy, fy := int(pt.Y>>8), pt.Y&0xff
 
-	tg := int(glyph) % kGlyphNum
 	tx := int(fx) / (256 / kXFractionsNum)
 	ty := int(fy) / (256 / kYFractionsNum)
-	t := ((tg*kXFractionsNum)+tx)*kYFractionsNum + ty
-
-	if f.cache[t].valid && f.cache[t].glyph == glyph {
-		return f.cache[t].mask, f.cache[t].offset.Add(image.Point{ix, iy}), nil
-	}
+	key := glyph_key_t{face, f.scale, uint8(tx), uint8(ty), f.hinting, glyph}
 
-	mask, offset, err := f.rasterize(glyph, fx, fy)
-	if err != nil {
-		return nil, image.ZP, err
+	e, ok := g_glyph_cache.get(key)
+	if !ok {
+		fx = freetype.Fix32(tx * (256 / kXFractionsNum))
+		fy = freetype.Fix32(ty * (256 / kYFractionsNum))
+		mask, offset, err := f.rasterize(face, glyph, fx, fy)
+		if err != nil {
+			return nil, image.ZP, err
+		}
+		e = g_glyph_cache.put(key, mask, offset)
 	}
-
-	f.cache[t] = glyph_cache_t{true, glyph, mask, offset}
-	return mask, offset.Add(image.Point{ix, iy}), nil
+	return e.mask, e.offset.Add(image.Point{ix, iy}), nil
 }
 
 func (f *Font) Index(ch rune) uint16 {
@@ -106,12 +242,122 @@ func (f *Font) HMetric(i uint16) freetype.HMetric {
 	return f.font.HMetric(f.scale, i)
 }
 
-func (f *Font) rasterize(glyph uint16, fx, fy freetype.Fix32) (*image.Alpha, image.Point, error) {
-	if f.glyph != nil {
-		err := f.glyph.Load(f.font, f.scale, glyph, nil)
-		if err != nil {
-			return nil, image.ZP, err
+// FontMetrics is the vertical metrics of the font at its size, in pixels. The
+// Descent is the distance below the baseline, and the LineGap is the space
+// between the lines.
+type FontMetrics struct {
+	UnitsPerEm int
+	Ascent     float64
+	Descent    float64
+	LineGap    float64
+	CapHeight  float64
+	XHeight    float64
+}
+
+// LineHeight returns the distance between the baselines of the lines.
+func (m FontMetrics) LineHeight() float64 {
+	return m.Ascent + m.Descent + m.LineGap
+}
+
+// TextMetrics is the measure of a line of text. The InkBounds are the pixels
+// covered by the glyphs, relative to the pen at the start of the baseline.
+type TextMetrics struct {
+	Width     float64
+	InkBounds image.Rectangle
+	Ascent    float64
+	Descent   float64
+	LineGap   float64
+}
+
+// Metrics returns the metrics of the font at its size, zero without a font.
+func (f *Font) Metrics() FontMetrics {
+	if f.font == nil {
+		return FontMetrics{}
+	}
+	m := f.font.Metrics(f.scale)
+	return FontMetrics{
+		UnitsPerEm: int(f.font.FUnitsPerEm()),
+		Ascent:     float64(m.Ascent) / 64,
+		Descent:    float64(m.Descent) / 64,
+		LineGap:    float64(m.LineGap) / 64,
+		CapHeight:  float64(m.CapHeight) / 64,
+		XHeight:    float64(m.XHeight) / 64,
+	}
+}
+
+// MeasureText returns the advance and the ink bounds of |text| laid out like
+// DrawText, with the metrics of the font.
+func (f *Font) MeasureText(text string) TextMetrics {
+	if f.font == nil {
+		return TextMetrics{}
+	}
+	var ink image.Rectangle
+	end, _ := f.walk_text(text, freetype.RastPoint{}, func(face *freetype.Font, idx uint16, pt freetype.RastPoint) error {
+		if err := f.glyph.Load(face, f.scale, idx, nil); err != nil {
+			return nil
+		}
+		// The glyph bounds are in 26.6 fixed point, with the y axis up.
+		b := f.glyph.Rect
+		r := image.Rect(int(pt.X+freetype.Fix32(b.XMin<<2))>>8, -(int(b.YMax+63) >> 6),
+			int(pt.X+freetype.Fix32(b.XMax<<2)+0xff)>>8, -(int(b.YMin) >> 6))
+		if !r.Empty() {
+			ink = ink.Union(r)
 		}
+		return nil
+	})
+	m := f.Metrics()
+	return TextMetrics{
+		Width:     float64(end.X) / 256,
+		InkBounds: ink,
+		Ascent:    m.Ascent,
+		Descent:   m.Descent,
+		LineGap:   m.LineGap,
+	}
+}
+
+// text_origin returns the pen position where the text in |rect| starts, on
+// the baseline below the top of |rect| by the ascent in whole pixels.
+func (f *Font) text_origin(rect image.Rectangle) freetype.RastPoint {
+	ascent := 0
+	if f.font != nil {
+		ascent = int(f.font.Metrics(f.scale).Ascent+32) >> 6
+	}
+	return freetype.Point(rect.Min.X, rect.Min.Y+ascent)
+}
+
+// walk_text calls |glyph| with every glyph of |text| and its face at its pen
+// position, from |pt| by the advances and the kernings, and returns the pen
+// after. The runes of the same face in a row are a run, kerned by the face,
+// and the runs follow each other without the kerning.
+func (f *Font) walk_text(text string, pt freetype.RastPoint,
+	glyph func(face *freetype.Font, idx uint16, pt freetype.RastPoint) error) (freetype.RastPoint, error) {
+	var prev_face *freetype.Font
+	prev := uint16(0)
+	for _, rune := range text {
+		face, idx := f.face_of(rune)
+		if face == prev_face {
+			pt.X += freetype.Fix32(face.Kerning(f.scale, prev, idx)) << 2
+		}
+		if glyph != nil {
+			if err := glyph(face, idx, pt); err != nil {
+				return freetype.RastPoint{}, err
+			}
+		}
+		pt.X += freetype.Fix32(face.HMetric(f.scale, idx).AdvanceWidth) << 2
+		prev_face, prev = face, idx
+	}
+	return pt, nil
+}
+
+func (f *Font) rasterize(face *freetype.Font, glyph uint16, fx, fy freetype.Fix32) (*image.Alpha, image.Point, error) {
+	var err error
+	if f.hinting {
+		err = f.glyph.LoadHinted(face, f.scale, glyph)
+	} else {
+		err = f.glyph.Load(face, f.scale, glyph, nil)
+	}
+	if err != nil {
+		return nil, image.ZP, err
 	}
 
 	xmin := int(fx+freetype.Fix32(f.glyph.Rect.XMin<<2)) >> 8
@@ -141,28 +387,66 @@ func (f *Font) rasterize(glyph uint16, fx, fy freetype.Fix32) (*image.Alpha, ima
 }
 
 func (f *Font) draw_contour(pt_array []freetype.FontPoint, dx, dy freetype.Fix32) {
+	add_contour(f.rast, pt_array, func(pt freetype.FontPoint) freetype.RastPoint {
+		return freetype.RastPoint{
+			X: dx + freetype.Fix32(pt.X<<2),
+			Y: dy - freetype.Fix32(pt.Y<<2),
+		}
+	})
+}
+
+// add_glyph_outline adds the outline of |glyph| of |face| with the origin at
+// (x, y) to |adder|. Every point is mapped by |m|, so the glyph can be scaled
+// or rotated without resampling its mask.
+func (f *Font) add_glyph_outline(adder freetype.Adder, face *freetype.Font, glyph uint16, x, y float64, m Matrix) error {
+	err := f.glyph.Load(face, f.scale, glyph, nil)
+	if err != nil {
+		return err
+	}
+
+	e0 := 0
+	for _, e1 := range f.glyph.EndIndexArray {
+		add_contour(adder, f.glyph.AllPoints[e0:e1], func(pt freetype.FontPoint) freetype.RastPoint {
+			// The font points are in 26.6 fixed point, with the y axis up.
+			return to_rast_point(m.TransformPoint(x+float64(pt.X)/64, y-float64(pt.Y)/64))
+		})
+		e0 = e1
+	}
+	return nil
+}
+
+// add_contour adds one closed contour of the quadratic glyph outline to
+// |adder|, with the points mapped by |to_point|.
+func add_contour(adder freetype.Adder, pt_array []freetype.FontPoint, to_point func(freetype.FontPoint) freetype.RastPoint) {
 	if len(pt_array) == 0 {
 		return
 	}
 
-	start := freetype.RastPoint{
-		X: dx + freetype.Fix32(pt_array[0].X<<2),
-		Y: dy - freetype.Fix32(pt_array[0].Y<<2),
-	}
+	start := to_point(pt_array[0])
 
-	f.rast.Start(start)
+	adder.Start(start)
 	q0, on0 := start, true
+	// The two control points of a cubic curve of a CFF glyph before its end.
+	var cubic [2]freetype.RastPoint
+	cubic_num := 0
 	for _, pt := range pt_array[1:] {
-		q := freetype.RastPoint{
-			X: dx + freetype.Fix32(pt.X<<2),
-			Y: dy - freetype.Fix32(pt.Y<<2),
+		q := to_point(pt)
+		if pt.Flag&freetype.FlagCubic != 0 && cubic_num < 2 {
+			cubic[cubic_num] = q
+			cubic_num++
+			continue
+		}
+		if cubic_num == 2 {
+			adder.Add3(cubic[0], cubic[1], q)
+			q0, on0, cubic_num = q, true, 0
+			continue
 		}
 		on := pt.Flag&0x01 != 0
 		if on {
 			if on0 {
-				f.rast.Add1(q)
+				adder.Add1(q)
 			} else {
-				f.rast.Add2(q0, q)
+				adder.Add2(q0, q)
 			}
 		} else {
 			if on0 {
@@ -172,23 +456,37 @@ func (f *Font) draw_contour(pt_array []freetype.FontPoint, dx, dy freetype.Fix32
 					X: (q0.X + q.X) / 2,
 					Y: (q0.Y + q.Y) / 2,
 				}
-				f.rast.Add2(q0, mid)
+				adder.Add2(q0, mid)
 			}
 		}
 		q0, on0 = q, on
 	}
 	// close the curve.
-	if on0 {
-		f.rast.Add1(start)
+	if cubic_num == 2 {
+		adder.Add3(cubic[0], cubic[1], start)
+	} else if on0 {
+		adder.Add1(start)
 	} else {
-		f.rast.Add2(q0, start)
+		adder.Add2(q0, start)
 	}
 }
 
 func (f *Font) recalc() {
 	f.scale = int32(f.size * f.dpi * (64.0 / 72.0))
 
+	if f.font == nil {
+		// No font loaded yet. e.g. running headless without the resc folder.
+		f.rast.SetBounds(0, 0)
+		return
+	}
+
+	// The rasterizer fits the largest glyph of the font and its fallbacks.
 	b := f.font.Bounds(f.scale)
+	for _, face := range f.fallbacks {
+		if face != nil {
+			b = union_bounds(b, face.Bounds(f.scale))
+		}
+	}
 	xmin := +int(b.XMin) >> 6
 	ymin := -int(b.YMax) >> 6
 	xmax := +int(b.XMax+63) >> 6
@@ -196,3 +494,19 @@ func (f *Font) recalc() {
 
 	f.rast.SetBounds(xmax-xmin, ymax-ymin)
 }
+
+func union_bounds(a, b freetype.Bounds) freetype.Bounds {
+	if b.XMin < a.XMin {
+		a.XMin = b.XMin
+	}
+	if b.YMin < a.YMin {
+		a.YMin = b.YMin
+	}
+	if b.XMax > a.XMax {
+		a.XMax = b.XMax
+	}
+	if b.YMax > a.YMax {
+		a.YMax = b.YMax
+	}
+	return a
+}
@@ -0,0 +1,111 @@
+// Copyright 2014 By Jshi. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vango
+
+import (
+	"gwk/vango/freetype"
+	"sync"
+)
+
+// kCoverageNum is the number of the runes of the faces cached, the cache is
+// cleared past it.
+const kCoverageNum = 1 << 16
+
+// coverage_key_t is a rune of a face.
+type coverage_key_t struct {
+	face *freetype.Font
+	r    rune
+}
+
+// coverage_cache_t is the glyphs of the runes of the faces, 0 for the runes
+// a face has no glyph of. It's shared by all the Fonts, since the Fonts of
+// the Contexts mostly have the same faces. It's safe for concurrent use.
+type coverage_cache_t struct {
+	lock   sync.Mutex
+	glyphs map[coverage_key_t]uint16
+}
+
+var g_coverage_cache = &coverage_cache_t{glyphs: make(map[coverage_key_t]uint16)}
+
+// index returns the glyph of |r| in |face|.
+func (c *coverage_cache_t) index(face *freetype.Font, r rune) uint16 {
+	key := coverage_key_t{face, r}
+	c.lock.Lock()
+	glyph, ok := c.glyphs[key]
+	c.lock.Unlock()
+	if ok {
+		return glyph
+	}
+
+	glyph = face.Index(r)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if len(c.glyphs) >= kCoverageNum {
+		c.glyphs = make(map[coverage_key_t]uint16)
+	}
+	c.glyphs[key] = glyph
+	return glyph
+}
+
+// SetFallbacks sets the faces tried in order for the runes the font has no
+// glyph of. The text is drawn in the runs of the runes of the same face,
+// with the metrics and the kerning of that face.
+func (f *Font) SetFallbacks(faces []*freetype.Font) {
+	if equal_faces(f.fallbacks, faces) {
+		return
+	}
+	f.fallbacks = append([]*freetype.Font(nil), faces...)
+	// The glyphs of the fallbacks may be larger than the ones of the font.
+	f.recalc()
+}
+
+// Fallbacks returns the faces of the runes the font has no glyph of.
+func (f *Font) Fallbacks() []*freetype.Font {
+	return f.fallbacks
+}
+
+// face_of returns the first face of the chain that has the glyph of |r|,
+// and the glyph. It's the .notdef glyph of the font if no face has one.
+func (f *Font) face_of(r rune) (*freetype.Font, uint16) {
+	if len(f.fallbacks) == 0 {
+		return f.font, f.font.Index(r)
+	}
+
+	if glyph := g_coverage_cache.index(f.font, r); glyph != 0 {
+		return f.font, glyph
+	}
+	for _, face := range f.fallbacks {
+		if face != nil {
+			if glyph := g_coverage_cache.index(face, r); glyph != 0 {
+				return face, glyph
+			}
+		}
+	}
+	return f.font, 0
+}
+
+func equal_faces(a, b []*freetype.Font) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// fallbacks_by_names returns the faces of |font_names| for
+// SetFallbackFonts, without the names no font matches.
+func fallbacks_by_names(font_names []string) []*freetype.Font {
+	var faces []*freetype.Font
+	for _, name := range font_names {
+		if face, _ := font_by_name(name); face != nil {
+			faces = append(faces, face)
+		}
+	}
+	return faces
+}
@@ -0,0 +1,182 @@
+package vango
+
+import (
+	"bytes"
+	"gwk/vango/freetype"
+	"io/ioutil"
+	"sync"
+	"testing"
+)
+
+func read_test_font(t *testing.T) []byte {
+	data, err := ioutil.ReadFile("./freetype/exp/data/luxisr.ttf")
+	if err != nil {
+		t.Skipf("no test font: %v", err)
+	}
+	return data
+}
+
+// new_test_digits_font returns a copy of the test font without the glyphs
+// from 'A', by cutting its first cmap segment, from ' ' to '~', at '@'.
+func new_test_digits_font(t *testing.T) *freetype.Font {
+	load_test_font(t)
+	data := read_test_font(t)
+	u16 := func(i int) int { return int(data[i])<<8 | int(data[i+1]) }
+	u32 := func(i int) int { return u16(i)<<16 | u16(i+2) }
+	for i := 0; i < u16(4); i++ {
+		if string(data[12+16*i:][:4]) != "cmap" {
+			continue
+		}
+		cmap := u32(12 + 16*i + 8)
+		for j := 0; j < u16(cmap+2); j++ {
+			sub := cmap + u32(cmap+4+8*j+4)
+			if u16(sub) == 4 && u16(sub+14) == '~' {
+				data[sub+14], data[sub+15] = 0, '@'
+				face, err := freetype.ParseFont(data)
+				if err != nil {
+					t.Fatalf("ParseFont: %v", err)
+				}
+				return face
+			}
+		}
+	}
+	t.Fatalf("no cmap segment of ASCII in the test font")
+	return nil
+}
+
+func TestFontFallbacks(t *testing.T) {
+	digits := new_test_digits_font(t)
+	if _, err := RegisterFont(read_test_font(t)); err != nil {
+		t.Fatalf("RegisterFont: %v", err)
+	}
+	full, _ := font_by_name("Luxi Sans")
+
+	f := NewFont()
+	f.SetFontFace(digits)
+	if face, glyph := f.face_of('A'); face != digits || glyph != 0 {
+		t.Errorf("the rune without the fallbacks: got %p %d", face, glyph)
+	}
+
+	f.SetFallbacks([]*freetype.Font{nil, full})
+	if face, glyph := f.face_of('1'); face != digits || glyph != full.Index('1') {
+		t.Errorf("the rune of the font: got %p %d", face, glyph)
+	}
+	if face, glyph := f.face_of('A'); face != full || glyph != full.Index('A') {
+		t.Errorf("the rune of the fallback: got %p %d", face, glyph)
+	}
+	if glyph, ok := g_coverage_cache.glyphs[coverage_key_t{full, 'A'}]; !ok || glyph != full.Index('A') {
+		t.Errorf("the coverage of 'A' isn't cached: %v %v", glyph, ok)
+	}
+	if face, glyph := f.face_of('日'); face != digits || glyph != 0 {
+		t.Errorf("the rune of no face: got %p %d", face, glyph)
+	}
+
+	// The run of the fallback is kerned by it, like the font it is.
+	plain := NewFont()
+	plain.SetFontFace(full)
+	if got, want := f.MeasureText("AV"), plain.MeasureText("AV"); got.Width != want.Width ||
+		want.Width >= plain.MeasureText("A").Width+plain.MeasureText("V").Width {
+		t.Errorf("the kerned run: got %v, want %v", got.Width, want.Width)
+	}
+	if got, want := f.MeasureText("1AV2").Width, plain.MeasureText("1AV2").Width; got != want {
+		t.Errorf("the runs: got %v, want %v", got, want)
+	}
+
+	f.SetFontFace(full)
+	if face, _ := f.face_of('A'); face != full {
+		t.Errorf("the rune after SetFontFace: got %p, want the font", face)
+	}
+
+	// The paragraph measures the runes by its fallbacks.
+	p := NewParagraph("12 AVA", digits, 12, ParagraphStyle{Fallbacks: []string{"Luxi Sans"}})
+	if got, want := p.Width(), plain.MeasureText("12 AVA").Width; got != want {
+		t.Errorf("the paragraph with the fallbacks: got %v, want %v", got, want)
+	}
+}
+
+func TestDrawTextFallbacks(t *testing.T) {
+	digits := new_test_digits_font(t)
+	if _, err := RegisterFont(read_test_font(t)); err != nil {
+		t.Fatalf("RegisterFont: %v", err)
+	}
+	text := "12 AVA 34"
+
+	want_ctxt, want := new_test_context(100, 30)
+	want_ctxt.SetFont("Luxi Sans")
+	want_ctxt.SetFontColor(0, 0, 0)
+	want_ctxt.DrawText(text, want.LocalBounds())
+
+	// The glyphs missing from the font are drawn by the fallback, at the same
+	// places.
+	ctxt, canvas := new_test_context(100, 30)
+	ctxt.SetFontFace(digits)
+	ctxt.SetFontColor(0, 0, 0)
+	ctxt.SaveState()
+	ctxt.SetFallbackFonts("Nope", "Luxi Sans")
+	ctxt.DrawText(text, canvas.LocalBounds())
+	ctxt.RestoreState()
+	assert_same_canvas(t, "the fallback", canvas, want)
+
+	if got := ctxt.font.Fallbacks(); len(got) != 0 {
+		t.Errorf("RestoreState kept the fallbacks: %v", got)
+	}
+
+	// The picture plays the fallbacks by the names.
+	recorder := NewRecorder(canvas.LocalBounds())
+	recorder.SetFontColor(0, 0, 0)
+	recorder.SetFallbackFonts("Luxi Sans")
+	recorder.DrawText(text, canvas.LocalBounds())
+	var buf bytes.Buffer
+	if err := EncodePicture(&buf, recorder.Finish()); err != nil {
+		t.Fatalf("EncodePicture: %v", err)
+	}
+	picture, err := DecodePicture(&buf)
+	if err != nil {
+		t.Fatalf("DecodePicture: %v", err)
+	}
+	ctxt, canvas = new_test_context(100, 30)
+	ctxt.SetFontFace(digits)
+	ctxt.DrawPicture(picture)
+	assert_same_canvas(t, "the picture", canvas, want)
+}
+
+func TestCoverageCacheShared(t *testing.T) {
+	digits := new_test_digits_font(t)
+	if _, err := RegisterFont(read_test_font(t)); err != nil {
+		t.Fatalf("RegisterFont: %v", err)
+	}
+	full, _ := font_by_name("Luxi Sans")
+
+	// The CJK runes 1024 apart took the same slot of the cache of a Font.
+	c := &coverage_cache_t{glyphs: make(map[coverage_key_t]uint16)}
+	for _, r := range []rune{'A', 0x4e00, 0x4e00 + 1024, 0x4e00 + 2048} {
+		c.index(digits, r)
+		c.index(full, r)
+	}
+	if len(c.glyphs) != 8 {
+		t.Errorf("the runes of the faces cached: got %v, want 8", len(c.glyphs))
+	}
+	if c.glyphs[coverage_key_t{digits, 'A'}] != 0 || c.glyphs[coverage_key_t{full, 'A'}] != full.Index('A') {
+		t.Errorf("the glyphs of 'A': got %v", c.glyphs)
+	}
+
+	// The Fonts of many goroutines share the coverage of the faces.
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f := NewFont()
+			f.SetFontFace(digits)
+			f.SetFallbacks([]*freetype.Font{full})
+			for r := rune(' '); r < 0x3000; r++ {
+				face, glyph := f.face_of(r)
+				if want := digits.Index(r); want != 0 && (face != digits || glyph != want) {
+					t.Errorf("the rune %q: got %p %v", r, face, glyph)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
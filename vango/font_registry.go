@@ -0,0 +1,329 @@
+// Copyright 2014 By Jshi. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vango
+
+import (
+	"errors"
+	"gwk/vango/freetype"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FontStyle is the slant of a font face.
+type FontStyle int
+
+const (
+	FontStyleNormal FontStyle = iota
+	FontStyleItalic
+	FontStyleOblique
+)
+
+// FontDescription is the font asked for, like the font shorthand of CSS. The
+// Families are tried in order, the generic families "sans-serif", "serif"
+// and "monospace" and "default" are the default font. Zero Weight is 400,
+// zero Stretch is 5, the normal width, and zero Size keeps the font size.
+type FontDescription struct {
+	Families []string
+	Weight   int
+	Style    FontStyle
+	Stretch  int
+	Size     float64
+}
+
+var g_font_weights = map[string]int{
+	"thin": 100, "hairline": 100,
+	"extralight": 200, "extra-light": 200, "ultralight": 200, "ultra-light": 200,
+	"light": 300, "regular": 400, "medium": 500,
+	"semibold": 600, "semi-bold": 600, "demibold": 600, "demi-bold": 600, "bold": 700,
+	"extrabold": 800, "extra-bold": 800, "ultrabold": 800, "ultra-bold": 800,
+	"black": 900, "heavy": 900,
+}
+
+var g_font_stretches = map[string]int{
+	"ultra-condensed": 1, "extra-condensed": 2, "condensed": 3,
+	"semi-condensed": 4, "semi-expanded": 6, "expanded": 7,
+	"extra-expanded": 8, "ultra-expanded": 9,
+}
+
+var g_generic_families = map[string]bool{
+	"default": true, "sans-serif": true, "serif": true, "monospace": true,
+	"system-ui": true,
+}
+
+// ParseFontDescription parses |description| like "italic bold 14px Luxi
+// Sans, sans-serif", the style, the weight, the stretch and the size in
+// pixels in any order, then the families separated by commas, which can be
+// quoted.
+func ParseFontDescription(description string) (FontDescription, error) {
+	var desc FontDescription
+	parts := strings.Split(description, ",")
+
+	// The keywords lead the first family.
+	words := strings.Fields(parts[0])
+	for len(words) > 0 {
+		word := strings.ToLower(words[0])
+		if word == "normal" {
+			// Any of the style, the weight and the stretch.
+		} else if word == "italic" {
+			desc.Style = FontStyleItalic
+		} else if word == "oblique" {
+			desc.Style = FontStyleOblique
+		} else if weight, ok := g_font_weights[word]; ok {
+			desc.Weight = weight
+		} else if stretch, ok := g_font_stretches[word]; ok {
+			desc.Stretch = stretch
+		} else if weight, err := strconv.Atoi(word); err == nil && 1 <= weight && weight <= 1000 {
+			desc.Weight = weight
+		} else if strings.HasSuffix(word, "px") {
+			size, err := strconv.ParseFloat(strings.TrimSuffix(word, "px"), 64)
+			if err != nil || size <= 0 {
+				return desc, errors.New("vango bad font size " + words[0])
+			}
+			desc.Size = size
+		} else {
+			break
+		}
+		words = words[1:]
+	}
+	parts[0] = strings.Join(words, " ")
+
+	for _, part := range parts {
+		family := strings.Trim(strings.TrimSpace(part), "\"'")
+		if family != "" {
+			desc.Families = append(desc.Families, family)
+		} else if len(parts) > 1 {
+			return desc, errors.New("vango empty font family in " + description)
+		}
+	}
+	return desc, nil
+}
+
+// font_entry_t is a font face registered with its family and style.
+type font_entry_t struct {
+	face *freetype.Font
+	// families is the lower case names the face matches, the typographic
+	// family, the family and the full name.
+	families []string
+	weight   int
+	stretch  int
+	style    FontStyle
+}
+
+// FontRegistry is the set of the font faces, found by their families and
+// styles like the font matching of CSS.
+type FontRegistry struct {
+	entries []font_entry_t
+}
+
+func NewFontRegistry() *FontRegistry {
+	return new(FontRegistry)
+}
+
+// Register parses the font in |data| and adds it to the registry.
+func (r *FontRegistry) Register(data []byte) (*freetype.Font, error) {
+	face, err := freetype.ParseFont(data)
+	if err != nil {
+		return nil, err
+	}
+	r.AddFace(face)
+	return face, nil
+}
+
+// AddFace adds the parsed |face| to the registry.
+func (r *FontRegistry) AddFace(face *freetype.Font) {
+	style := face.Style()
+	entry := font_entry_t{
+		face:    face,
+		weight:  style.Weight,
+		stretch: style.Width,
+	}
+	if style.Italic {
+		entry.style = FontStyleItalic
+	} else if style.Oblique {
+		entry.style = FontStyleOblique
+	}
+	for _, name := range []string{face.Family(), face.Name(freetype.NameIDFamily),
+		face.Name(freetype.NameIDFullName)} {
+		if name = strings.ToLower(name); name != "" {
+			entry.families = append(entry.families, name)
+		}
+	}
+	r.entries = append(r.entries, entry)
+}
+
+// AddDir registers the fonts in |dir| and its subdirectories, the files with
+// the extensions .ttf, .ttc and .otf, the first font of a collection. The
+// files that fail to parse are skipped, it returns the number of the fonts
+// registered.
+func (r *FontRegistry) AddDir(dir string) (int, error) {
+	var paths []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".ttf", ".ttc", ".otf":
+			if !info.IsDir() {
+				paths = append(paths, path)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if _, err := r.Register(data); err == nil {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// Families returns the sorted family names of the faces.
+func (r *FontRegistry) Families() []string {
+	seen := make(map[string]bool)
+	var families []string
+	for _, entry := range r.entries {
+		if family := entry.face.Family(); family != "" && !seen[family] {
+			seen[family] = true
+			families = append(families, family)
+		}
+	}
+	sort.Strings(families)
+	return families
+}
+
+// Match returns the face of the first family in |desc| that has one, the
+// closest to the stretch, then the style, then the weight, by the rules of
+// the font matching of CSS. The generic families are the default font, and
+// it returns nil if no family has a face.
+func (r *FontRegistry) Match(desc FontDescription) *freetype.Font {
+	weight, stretch := desc.Weight, desc.Stretch
+	if weight == 0 {
+		weight = 400
+	}
+	if stretch == 0 {
+		stretch = 5
+	}
+
+	families := desc.Families
+	if len(families) == 0 {
+		families = []string{"default"}
+	}
+	for _, family := range families {
+		family = strings.ToLower(family)
+		generic := g_generic_families[family] && g_default_font != nil
+		if generic {
+			family = strings.ToLower(g_default_font.Family())
+		}
+
+		var faces []font_entry_t
+		for _, entry := range r.entries {
+			for _, name := range entry.families {
+				if name == family {
+					faces = append(faces, entry)
+					break
+				}
+			}
+		}
+		if len(faces) == 0 {
+			if generic {
+				// The default font isn't in the registry.
+				return g_default_font
+			}
+			continue
+		}
+
+		faces = closest_faces(faces, func(e font_entry_t) int { return stretch_distance(stretch, e.stretch) })
+		faces = closest_faces(faces, func(e font_entry_t) int { return style_distance(desc.Style, e.style) })
+		faces = closest_faces(faces, func(e font_entry_t) int { return weight_distance(weight, e.weight) })
+		return faces[0].face
+	}
+	return nil
+}
+
+// closest_faces returns the faces with the least |distance|.
+func closest_faces(faces []font_entry_t, distance func(font_entry_t) int) []font_entry_t {
+	var closest []font_entry_t
+	least := 0
+	for _, face := range faces {
+		d := distance(face)
+		if len(closest) == 0 || d < least {
+			closest, least = closest[:0], d
+		}
+		if d == least {
+			closest = append(closest, face)
+		}
+	}
+	return closest
+}
+
+// stretch_distance orders the widths for |want|, the narrower ones first if
+// it's normal or condensed, otherwise the wider ones first.
+func stretch_distance(want, have int) int {
+	narrower := have < want
+	if want > 5 {
+		narrower = have > want
+	}
+	d := have - want
+	if d < 0 {
+		d = -d
+	}
+	if !narrower && d != 0 {
+		d += 10
+	}
+	return d
+}
+
+// style_distance orders the italic, the oblique and the normal faces for
+// |want|.
+func style_distance(want, have FontStyle) int {
+	order := [3][3]int{
+		FontStyleNormal:  {FontStyleNormal: 0, FontStyleOblique: 1, FontStyleItalic: 2},
+		FontStyleItalic:  {FontStyleItalic: 0, FontStyleOblique: 1, FontStyleNormal: 2},
+		FontStyleOblique: {FontStyleOblique: 0, FontStyleItalic: 1, FontStyleNormal: 2},
+	}
+	return order[want][have]
+}
+
+// weight_distance orders the weights for |want|. From 400 to 500, the
+// heavier ones up to 500 go first, then the lighter ones, then the rest.
+// Below 400 the lighter ones go first, above 500 the heavier ones do.
+func weight_distance(want, have int) int {
+	d := have - want
+	switch {
+	case d == 0:
+		return 0
+	case 400 <= want && want <= 500:
+		if d > 0 && have <= 500 {
+			return d
+		}
+		if d < 0 {
+			return 1000 - d
+		}
+		return 2000 + d
+	case want < 400:
+		if d < 0 {
+			return -d
+		}
+		return 1000 + d
+	default:
+		if d > 0 {
+			return d
+		}
+		return 1000 - d
+	}
+}
@@ -0,0 +1,158 @@
+package vango
+
+import (
+	"fmt"
+	"gwk/vango/freetype"
+	"io/ioutil"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestParseFontDescription(t *testing.T) {
+	cases := []struct {
+		text string
+		want FontDescription
+	}{
+		{"Luxi Sans", FontDescription{Families: []string{"Luxi Sans"}}},
+		{"italic bold 14px Luxi Sans, 'DejaVu Sans', sans-serif", FontDescription{
+			Families: []string{"Luxi Sans", "DejaVu Sans", "sans-serif"},
+			Weight:   700, Style: FontStyleItalic, Size: 14}},
+		{"condensed 300 oblique \"Luxi Mono\"", FontDescription{
+			Families: []string{"Luxi Mono"}, Weight: 300, Style: FontStyleOblique, Stretch: 3}},
+		{"normal semi-bold 9.5px serif", FontDescription{
+			Families: []string{"serif"}, Weight: 600, Size: 9.5}},
+		{"bold", FontDescription{Weight: 700}},
+	}
+	for _, c := range cases {
+		got, err := ParseFontDescription(c.text)
+		if err != nil || !reflect.DeepEqual(got, c.want) {
+			t.Errorf("ParseFontDescription(%q): got %+v, %v, want %+v", c.text, got, err, c.want)
+		}
+	}
+
+	for _, text := range []string{"-2px Luxi Sans", "Luxi Sans,,serif", "abcpx Luxi Sans"} {
+		if _, err := ParseFontDescription(text); err == nil {
+			t.Errorf("ParseFontDescription(%q): no error", text)
+		}
+	}
+}
+
+func TestFontRegistryMatch(t *testing.T) {
+	data, err := ioutil.ReadFile("./freetype/exp/data/luxisr.ttf")
+	if err != nil {
+		t.Skipf("no test font: %v", err)
+	}
+	// The faces of a family are the copies of the test font with the styles
+	// set, so the match is known by the pointer.
+	r := NewFontRegistry()
+	faces := make(map[string]*freetype.Font)
+	add := func(name string, weight, stretch int, style FontStyle) {
+		face, err := freetype.ParseFont(data)
+		if err != nil {
+			t.Fatalf("ParseFont: %v", err)
+		}
+		faces[name] = face
+		r.entries = append(r.entries, font_entry_t{face, []string{"test sans"}, weight, stretch, style})
+	}
+	add("regular", 400, 5, FontStyleNormal)
+	add("bold", 700, 5, FontStyleNormal)
+	add("light", 300, 5, FontStyleNormal)
+	add("italic", 400, 5, FontStyleItalic)
+	add("condensed", 400, 3, FontStyleNormal)
+	add("black expanded", 900, 8, FontStyleNormal)
+
+	cases := []struct {
+		desc string
+		want string
+	}{
+		{"Test Sans", "regular"},
+		{"bold Test Sans", "bold"},
+		// Above 500 the heavier weights go first, below 400 the lighter
+		// ones, and from 400 to 500 the ones up to 500, then the lighter.
+		{"600 Test Sans", "bold"},
+		{"800 Test Sans", "bold"},
+		{"350 Test Sans", "light"},
+		{"200 Test Sans", "light"},
+		{"450 Test Sans", "regular"},
+		{"italic Test Sans", "italic"},
+		{"oblique Test Sans", "italic"},
+		{"italic bold Test Sans", "italic"},
+		// The narrower widths go first for the normal and condensed ones.
+		{"condensed Test Sans", "condensed"},
+		{"semi-condensed Test Sans", "condensed"},
+		{"ultra-condensed Test Sans", "condensed"},
+		{"expanded Test Sans", "black expanded"},
+		{"Nope, test sans", "regular"},
+	}
+	for _, c := range cases {
+		desc, _ := ParseFontDescription(c.desc)
+		if got := r.Match(desc); got != faces[c.want] {
+			t.Errorf("Match(%q): got %p, want %v", c.desc, got, c.want)
+		}
+	}
+
+	desc, _ := ParseFontDescription("Nope, Other")
+	if got := r.Match(desc); got != nil {
+		t.Errorf("Match of the unknown families: got %p", got)
+	}
+}
+
+func TestSetFont(t *testing.T) {
+	load_test_font(t)
+	count, err := AddFontDir("./freetype/exp/data")
+	if err != nil || count != 1 {
+		t.Fatalf("AddFontDir: got %d, %v", count, err)
+	}
+	if got := FontFamilies(); !reflect.DeepEqual(got, []string{"Luxi Sans"}) {
+		t.Errorf("FontFamilies: got %q", got)
+	}
+
+	ctxt, _ := new_test_context(10, 10)
+	ctxt.SetFontFace(nil)
+	ctxt.SetFont("bold 20px Nope, Luxi Sans")
+	if ctxt.font_face == nil || ctxt.font_face.Family() != "Luxi Sans" || ctxt.FontSize() != 20 {
+		t.Errorf("SetFont: got %p at %v", ctxt.font_face, ctxt.FontSize())
+	}
+	face := ctxt.font_face
+	if m, ok := g_description_cache.get("bold 20px Nope, Luxi Sans"); !ok || m.face != face || m.size != 20 {
+		t.Errorf("the matched face isn't cached: %v", m)
+	}
+
+	// The font is kept if nothing matches, the generic families are the
+	// default font.
+	ctxt.SetFont("Nope")
+	if ctxt.font_face != face || ctxt.FontSize() != 20 {
+		t.Errorf("SetFont of the unknown font: got %p at %v", ctxt.font_face, ctxt.FontSize())
+	}
+	ctxt.SetFont("12px monospace")
+	if ctxt.font_face == nil || ctxt.font_face.Family() != g_default_font.Family() || ctxt.FontSize() != 12 {
+		t.Errorf("SetFont of the generic family: got %p at %v", ctxt.font_face, ctxt.FontSize())
+	}
+}
+
+func TestFontByNameConcurrent(t *testing.T) {
+	if _, err := RegisterFont(read_test_font(t)); err != nil {
+		t.Fatalf("RegisterFont: %v", err)
+	}
+	face, _ := font_by_name("Luxi Sans")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(size int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if j%10 == 0 {
+					g_description_cache.clear()
+				}
+				got, got_size := font_by_name(fmt.Sprintf("bold %dpx Luxi Sans", size))
+				if got != face || got_size != float64(size) {
+					t.Errorf("font_by_name: got %p at %v", got, got_size)
+					return
+				}
+			}
+		}(10 + i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,104 @@
+package vango
+
+import (
+	"gwk/vango/freetype"
+	"image"
+	"reflect"
+	"testing"
+)
+
+func TestFontMetrics(t *testing.T) {
+	load_test_font(t)
+	ctxt, _ := new_test_context(10, 10)
+
+	// The hhea metrics of luxisr at 12 pixels, in 26.6 fixed point.
+	want := FontMetrics{
+		UnitsPerEm: 2048,
+		Ascent:     762.0 / 64,
+		Descent:    162.0 / 64,
+		LineGap:    0,
+		CapHeight:  555.0 / 64,
+		XHeight:    407.0 / 64,
+	}
+	if got := ctxt.FontMetrics(); got != want {
+		t.Errorf("FontMetrics: got %+v, want %+v", got, want)
+	}
+	if got := want.LineHeight(); got != 924.0/64 {
+		t.Errorf("LineHeight: got %v", got)
+	}
+
+	ctxt.SetFontSize(24)
+	if got := ctxt.FontMetrics().Ascent; !near(got, 1525.0/64) {
+		t.Errorf("the ascent at 24 pixels: got %v", got)
+	}
+}
+
+func TestMeasureText(t *testing.T) {
+	load_test_font(t)
+	ctxt, canvas := new_test_context(80, 40)
+	ctxt.SetFontColor(0, 0, 0)
+
+	m := ctxt.MeasureText("Hix")
+	rect := image.Rect(10, 5, 80, 40)
+	end, err := ctxt.DrawText("Hix", rect)
+	if err != nil {
+		t.Fatalf("DrawText: %v", err)
+	}
+
+	// The text starts at the left of the rect, on the baseline below its top
+	// by the ascent.
+	origin := image.Pt(10, 5+12)
+	if got := float64(end.X)/256 - 10; got != m.Width || end.Y>>8 != 17 {
+		t.Errorf("the advance: got %v at y %v, want %v", got, end.Y>>8, m.Width)
+	}
+	if m.Ascent != ctxt.FontMetrics().Ascent || m.Descent != ctxt.FontMetrics().Descent {
+		t.Errorf("the metrics of the text: got %+v", m)
+	}
+	// The top of 'H' is at the cap height, the glyphs sit on the baseline.
+	if m.InkBounds.Min.Y != -9 || m.InkBounds.Max.Y != 0 || m.InkBounds.Min.X < 0 ||
+		float64(m.InkBounds.Max.X) > m.Width+1 {
+		t.Errorf("InkBounds: got %v", m.InkBounds)
+	}
+
+	ink := m.InkBounds.Add(origin)
+	painted := image.ZR
+	for y := 0; y < canvas.H(); y++ {
+		for x := 0; x < canvas.W(); x++ {
+			if _, _, _, a := pixel_at(canvas, x, y); a != 0 {
+				painted = painted.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	if painted.Empty() || !painted.In(ink) {
+		t.Errorf("the painted pixels %v out of the ink bounds %v", painted, ink)
+	}
+
+	if m := ctxt.MeasureText(""); m.Width != 0 || !m.InkBounds.Empty() {
+		t.Errorf("the empty text: got %+v", m)
+	}
+}
+
+func TestAddContourCubic(t *testing.T) {
+	// A quadratic curve, then a cubic one of a CFF glyph, closed by a line.
+	contour := []freetype.FontPoint{
+		{X: 0, Y: 0, Flag: 1},
+		{X: 10, Y: 0},
+		{X: 10, Y: 10, Flag: 1},
+		{X: 10, Y: 20, Flag: freetype.FlagCubic},
+		{X: 0, Y: 20, Flag: freetype.FlagCubic},
+		{X: 0, Y: 10, Flag: 1},
+	}
+	pt := func(x, y int32) freetype.RastPoint {
+		return freetype.RastPoint{X: freetype.Fix32(x), Y: freetype.Fix32(y)}
+	}
+
+	var got, want freetype.Path
+	add_contour(&got, contour, func(p freetype.FontPoint) freetype.RastPoint { return pt(p.X, p.Y) })
+	want.Start(pt(0, 0))
+	want.Add2(pt(10, 0), pt(10, 10))
+	want.Add3(pt(10, 20), pt(0, 20), pt(0, 10))
+	want.Add1(pt(0, 0))
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,1047 @@
+// Copyright 2012 The Freetype-Go Authors. All rights reserved.
+// Use of this source code is governed by your choice of either the
+// FreeType License or the GNU General Public License version 2 (or
+// any later version), both of which can be found in the LICENSE file.
+
+package freetype
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// The CFF and the CFF2 sections hold the PostScript outlines of an OpenType
+// font, as Type 2 charstrings of cubic Bézier curves.
+// https://docs.microsoft.com/typography/opentype/spec/cff
+// https://docs.microsoft.com/typography/opentype/spec/cff2
+// https://adobe-type-tools.github.io/font-tech-notes/pdfs/5176.CFF.pdf
+// https://adobe-type-tools.github.io/font-tech-notes/pdfs/5177.Type2.pdf
+
+const (
+	// The limits of the argument stack of the charstrings.
+	kCffMaxStack  = 48
+	kCff2MaxStack = 513
+
+	// kCffMaxSubrDepth is the limit of the nested subroutine calls.
+	kCffMaxSubrDepth = 10
+)
+
+// The operators of the DICTs, the escaped ones are 12<<8|op.
+const (
+	kDictCharStrings    = 17
+	kDictPrivate        = 18
+	kDictSubrs          = 19
+	kDictVsindex        = 22
+	kDictBlend          = 23
+	kDictVstore         = 24
+	kDictCharstringType = 12<<8 | 6
+	kDictFDArray        = 12<<8 | 36
+	kDictFDSelect       = 12<<8 | 37
+)
+
+// cff_index_t is an INDEX of the CFF section, the array of the objects of
+// variable lengths.
+type cff_index_t struct {
+	data    []byte
+	offsets []int
+}
+
+func (x *cff_index_t) count() int {
+	if len(x.offsets) == 0 {
+		return 0
+	}
+	return len(x.offsets) - 1
+}
+
+func (x *cff_index_t) at(i int) []byte {
+	return x.data[x.offsets[i]:x.offsets[i+1]]
+}
+
+// parse_cff_index parses the INDEX at |offset| of |b|, and returns the offset
+// after it. The count is 32 bits in the CFF2.
+func parse_cff_index(b []byte, offset int, cff2 bool) (cff_index_t, int, error) {
+	var x cff_index_t
+	count := 0
+	if cff2 {
+		if offset < 0 || offset+4 > len(b) {
+			return x, 0, errors.New("INVALID: CFF INDEX offset.")
+		}
+		count, offset = int(octets_to_u32(b, offset)), offset+4
+	} else {
+		if offset < 0 || offset+2 > len(b) {
+			return x, 0, errors.New("INVALID: CFF INDEX offset.")
+		}
+		count, offset = int(octets_to_u16(b, offset)), offset+2
+	}
+	if count == 0 {
+		return x, offset, nil
+	}
+
+	if offset >= len(b) {
+		return x, 0, errors.New("INVALID: CFF INDEX too short.")
+	}
+	off_size, offset := int(b[offset]), offset+1
+	if off_size < 1 || off_size > 4 || count > (len(b)-offset)/off_size-1 {
+		return x, 0, errors.New("INVALID: CFF INDEX offsets.")
+	}
+
+	x.offsets = make([]int, count+1)
+	for i := range x.offsets {
+		v := 0
+		for j := 0; j < off_size; j++ {
+			v = v<<8 | int(b[offset])
+			offset++
+		}
+		// The offsets are from the byte before the data, so they start at 1.
+		x.offsets[i] = v - 1
+		if x.offsets[i] < 0 || i > 0 && x.offsets[i] < x.offsets[i-1] {
+			return x, 0, errors.New("INVALID: CFF INDEX offsets.")
+		}
+	}
+	end := offset + x.offsets[count]
+	if end > len(b) {
+		return x, 0, errors.New("INVALID: CFF INDEX data too short.")
+	}
+	x.data = b[offset:end]
+	return x, end, nil
+}
+
+// cff_dict_t is the operands of a DICT by the operators.
+type cff_dict_t map[int][]float64
+
+func (d cff_dict_t) int_at(op, i int) int {
+	if i >= len(d[op]) {
+		return 0
+	}
+	return int(d[op][i])
+}
+
+// parse_cff_dict parses the DICT in |b|. The blend operator of a Private DICT
+// of the CFF2 keeps the default values, |region_counts| is the number of the
+// regions of each vsindex.
+func parse_cff_dict(b []byte, region_counts []int) (cff_dict_t, error) {
+	d := make(cff_dict_t)
+	var operands []float64
+	vsindex := 0
+	for i := 0; i < len(b); {
+		b0 := b[i]
+		switch {
+		case b0 == 28 && i+3 <= len(b):
+			operands = append(operands, float64(int16(octets_to_u16(b, i+1))))
+			i += 3
+		case b0 == 29 && i+5 <= len(b):
+			operands = append(operands, float64(int32(octets_to_u32(b, i+1))))
+			i += 5
+		case b0 == 30:
+			v, n, err := parse_cff_real(b[i+1:])
+			if err != nil {
+				return nil, err
+			}
+			operands = append(operands, v)
+			i += 1 + n
+		case 32 <= b0 && b0 <= 246:
+			operands = append(operands, float64(int(b0)-139))
+			i++
+		case 247 <= b0 && b0 <= 250 && i+2 <= len(b):
+			operands = append(operands, float64((int(b0)-247)*256+int(b[i+1])+108))
+			i += 2
+		case 251 <= b0 && b0 <= 254 && i+2 <= len(b):
+			operands = append(operands, float64(-(int(b0)-251)*256-int(b[i+1])-108))
+			i += 2
+		case b0 <= 21 || b0 == kDictVsindex || b0 == kDictBlend || b0 == kDictVstore:
+			op := int(b0)
+			i++
+			if b0 == 12 {
+				if i >= len(b) {
+					return nil, errors.New("INVALID: CFF DICT escape.")
+				}
+				op, i = 12<<8|int(b[i]), i+1
+			}
+			switch op {
+			case kDictVsindex:
+				if len(operands) > 0 {
+					vsindex = int(operands[0])
+				}
+			case kDictBlend:
+				var err error
+				if operands, err = cff_blend(operands, vsindex, region_counts); err != nil {
+					return nil, err
+				}
+				// The operands of the blend are the ones of the next
+				// operator.
+				continue
+			}
+			d[op] = operands
+			operands = nil
+		default:
+			return nil, fmt.Errorf("INVALID: CFF DICT byte %d.", b0)
+		}
+	}
+	return d, nil
+}
+
+// parse_cff_real parses the real number of the nibbles in |b|, and returns
+// the number of the bytes.
+func parse_cff_real(b []byte) (float64, int, error) {
+	const nibbles = "0123456789.E??-"
+	var s []byte
+	for i, c := range b {
+		for _, n := range [2]byte{c >> 4, c & 0x0f} {
+			switch {
+			case n == 0x0f:
+				v, err := strconv.ParseFloat(string(s), 64)
+				if err != nil {
+					return 0, 0, errors.New("INVALID: CFF real number.")
+				}
+				return v, i + 1, nil
+			case n == 0x0c:
+				s = append(s, 'E', '-')
+			case n == 0x0d:
+				return 0, 0, errors.New("INVALID: CFF real number.")
+			default:
+				s = append(s, nibbles[n])
+			}
+		}
+	}
+	return 0, 0, errors.New("INVALID: CFF real number too short.")
+}
+
+// cff_blend replaces the operands of a blend of the CFF2, the n default
+// values and their n*k deltas and n, with the default values.
+func cff_blend(stack []float64, vsindex int, region_counts []int) ([]float64, error) {
+	if len(stack) < 1 || vsindex < 0 || vsindex >= len(region_counts) {
+		return nil, errors.New("INVALID: CFF2 blend.")
+	}
+	n := int(stack[len(stack)-1])
+	stack = stack[:len(stack)-1]
+	k := region_counts[vsindex]
+	base := len(stack) - n*(k+1)
+	if n < 0 || base < 0 {
+		return nil, errors.New("INVALID: CFF2 blend operands.")
+	}
+	return stack[:base+n], nil
+}
+
+// cff_private_t is a Private DICT, the local subroutines of the glyphs of a
+// Font DICT.
+type cff_private_t struct {
+	subrs   cff_index_t
+	vsindex int
+}
+
+// cff_font_t is the CFF or the CFF2 section of a font. The glyphs of a CFF2
+// are the default instance of the variable font.
+type cff_font_t struct {
+	cff2          bool
+	char_strings  cff_index_t
+	global_subrs  cff_index_t
+	privates      []cff_private_t
+	fd_select     []uint16
+	region_counts []int
+}
+
+// parse_cff parses the CFF section in |b|, or the CFF2 one.
+func parse_cff(b []byte) (*cff_font_t, error) {
+	if len(b) < 4 {
+		return nil, errors.New("INVALID: CFF header too short.")
+	}
+	c := new(cff_font_t)
+	hdr_size := int(b[2])
+
+	var top cff_dict_t
+	var err error
+	switch b[0] {
+	case 1:
+		// The Name INDEX, the Top DICT INDEX, the String INDEX and the
+		// Global Subr INDEX.
+		var tops cff_index_t
+		offset := hdr_size
+		if _, offset, err = parse_cff_index(b, offset, false); err != nil {
+			return nil, err
+		}
+		if tops, offset, err = parse_cff_index(b, offset, false); err != nil {
+			return nil, err
+		}
+		if tops.count() != 1 {
+			return nil, errors.New("UNSUPPORT: CFF font set.")
+		}
+		if _, offset, err = parse_cff_index(b, offset, false); err != nil {
+			return nil, err
+		}
+		if c.global_subrs, _, err = parse_cff_index(b, offset, false); err != nil {
+			return nil, err
+		}
+		if top, err = parse_cff_dict(tops.at(0), nil); err != nil {
+			return nil, err
+		}
+		if ops, ok := top[kDictCharstringType]; ok && (len(ops) != 1 || ops[0] != 2) {
+			return nil, errors.New("UNSUPPORT: CFF charstring type.")
+		}
+
+	case 2:
+		// The Top DICT and the Global Subr INDEX.
+		if len(b) < 5 {
+			return nil, errors.New("INVALID: CFF2 header too short.")
+		}
+		c.cff2 = true
+		top_end := hdr_size + int(octets_to_u16(b, 3))
+		if top_end > len(b) {
+			return nil, errors.New("INVALID: CFF2 Top DICT too long.")
+		}
+		if top, err = parse_cff_dict(b[hdr_size:top_end], nil); err != nil {
+			return nil, err
+		}
+		if c.global_subrs, _, err = parse_cff_index(b, top_end, true); err != nil {
+			return nil, err
+		}
+		c.region_counts = []int{0}
+		if _, ok := top[kDictVstore]; ok {
+			if c.region_counts, err = parse_cff2_vstore(b, top.int_at(kDictVstore, 0)); err != nil {
+				return nil, err
+			}
+		}
+
+	default:
+		return nil, fmt.Errorf("UNSUPPORT: CFF version %d.", b[0])
+	}
+
+	if _, ok := top[kDictCharStrings]; !ok {
+		return nil, errors.New("INVALID: CFF without CharStrings.")
+	}
+	if c.char_strings, _, err = parse_cff_index(b, top.int_at(kDictCharStrings, 0), c.cff2); err != nil {
+		return nil, err
+	}
+
+	// The Private DICTs of the Font DICTs of a CID font or a CFF2, or the
+	// one of the Top DICT.
+	if _, ok := top[kDictFDArray]; ok {
+		fds, _, err := parse_cff_index(b, top.int_at(kDictFDArray, 0), c.cff2)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < fds.count(); i++ {
+			fd, err := parse_cff_dict(fds.at(i), nil)
+			if err != nil {
+				return nil, err
+			}
+			private, err := c.parse_private(b, fd)
+			if err != nil {
+				return nil, err
+			}
+			c.privates = append(c.privates, private)
+		}
+		if _, ok := top[kDictFDSelect]; ok && len(c.privates) > 1 {
+			c.fd_select, err = parse_cff_fd_select(b, top.int_at(kDictFDSelect, 0), c.char_strings.count())
+			if err != nil {
+				return nil, err
+			}
+		}
+	} else if c.cff2 {
+		return nil, errors.New("INVALID: CFF2 without FDArray.")
+	} else {
+		private, err := c.parse_private(b, top)
+		if err != nil {
+			return nil, err
+		}
+		c.privates = append(c.privates, private)
+	}
+	if len(c.privates) == 0 {
+		return nil, errors.New("INVALID: CFF without Font DICT.")
+	}
+	return c, nil
+}
+
+// parse_private parses the Private DICT of the Top DICT or the Font DICT
+// |d|, and its Subrs INDEX.
+func (c *cff_font_t) parse_private(b []byte, d cff_dict_t) (cff_private_t, error) {
+	var p cff_private_t
+	if len(d[kDictPrivate]) < 2 {
+		return p, nil
+	}
+	size, offset := d.int_at(kDictPrivate, 0), d.int_at(kDictPrivate, 1)
+	if size < 0 || offset < 0 || offset+size > len(b) {
+		return p, errors.New("INVALID: CFF Private DICT offset.")
+	}
+	pd, err := parse_cff_dict(b[offset:offset+size], c.region_counts)
+	if err != nil {
+		return p, err
+	}
+	p.vsindex = pd.int_at(kDictVsindex, 0)
+	if _, ok := pd[kDictSubrs]; ok {
+		// The Subrs offset is from the start of the Private DICT.
+		p.subrs, _, err = parse_cff_index(b, offset+pd.int_at(kDictSubrs, 0), c.cff2)
+	}
+	return p, err
+}
+
+// parse_cff_fd_select parses the FDSelect at |offset|, the Font DICT of each
+// of the |glyph_num| glyphs.
+func parse_cff_fd_select(b []byte, offset, glyph_num int) ([]uint16, error) {
+	if offset <= 0 || offset >= len(b) {
+		return nil, errors.New("INVALID: CFF FDSelect offset.")
+	}
+	fds := make([]uint16, glyph_num)
+	format, offset := b[offset], offset+1
+	switch format {
+	case 0:
+		if offset+glyph_num > len(b) {
+			return nil, errors.New("INVALID: CFF FDSelect too short.")
+		}
+		for i := range fds {
+			fds[i] = uint16(b[offset+i])
+		}
+
+	case 3, 4:
+		// The ranges of the first glyphs and their Font DICTs, then the
+		// sentinel, with 32 bits glyphs and 16 bits Font DICTs in format 4.
+		gsize, fsize := 2, 1
+		if format == 4 {
+			gsize, fsize = 4, 2
+		}
+		read := func(i, size int) int {
+			v := 0
+			for j := 0; j < size; j++ {
+				v = v<<8 | int(b[i+j])
+			}
+			return v
+		}
+		if offset+gsize > len(b) {
+			return nil, errors.New("INVALID: CFF FDSelect too short.")
+		}
+		range_num, offset := read(offset, gsize), offset+gsize
+		if range_num > (len(b)-offset-gsize)/(gsize+fsize) {
+			return nil, errors.New("INVALID: CFF FDSelect too short.")
+		}
+		for i := 0; i < range_num; i++ {
+			r := offset + i*(gsize+fsize)
+			first, fd := read(r, gsize), read(r+gsize, fsize)
+			last := read(r+gsize+fsize, gsize)
+			for g := first; g < last && g < glyph_num; g++ {
+				fds[g] = uint16(fd)
+			}
+		}
+
+	default:
+		return nil, fmt.Errorf("UNSUPPORT: CFF FDSelect format %d.", format)
+	}
+	return fds, nil
+}
+
+// parse_cff2_vstore returns the number of the regions of each
+// ItemVariationData of the VariationStore at |offset|, the ones the blends
+// of the vsindex have deltas of.
+func parse_cff2_vstore(b []byte, offset int) ([]int, error) {
+	// The length, then the ItemVariationStore.
+	base := offset + 2
+	if offset <= 0 || base+8 > len(b) {
+		return nil, errors.New("INVALID: CFF2 VariationStore offset.")
+	}
+	count := int(octets_to_u16(b, base+6))
+	if base+8+4*count > len(b) {
+		return nil, errors.New("INVALID: CFF2 VariationStore too short.")
+	}
+	counts := make([]int, count)
+	for i := range counts {
+		data := base + int(octets_to_u32(b, base+8+4*i))
+		if data+6 > len(b) {
+			return nil, errors.New("INVALID: CFF2 ItemVariationData offset.")
+		}
+		counts[i] = int(octets_to_u16(b, data+4))
+	}
+	return counts, nil
+}
+
+// cff_subr_bias returns the bias of the subroutine numbers of |subrs|.
+func cff_subr_bias(subrs *cff_index_t) int {
+	switch n := subrs.count(); {
+	case n < 1240:
+		return 107
+	case n < 33900:
+		return 1131
+	default:
+		return 32768
+	}
+}
+
+// cff_point_t is a point of the outline of a charstring in font units. The
+// flag is the one of FontPoint, on curve or a control point of a cubic.
+type cff_point_t struct {
+	x, y float64
+	flag uint32
+}
+
+// cff_interp_t runs the Type 2 charstring of a glyph to its outline.
+type cff_interp_t struct {
+	font    *cff_font_t
+	private *cff_private_t
+
+	stack     []float64
+	max_stack int
+	transient [32]float64
+	x, y      float64
+	stems     int
+	vsindex   int
+	depth     int
+	// seen_width is whether the first stack clearing operator is run, the
+	// one that may have the width of a CFF glyph before its arguments.
+	seen_width bool
+	done       bool
+
+	points []cff_point_t
+	ends   []int
+	open   bool
+}
+
+// outline returns the points and the contour ends of the glyph |idx| in font
+// units.
+func (c *cff_font_t) outline(idx uint16) ([]cff_point_t, []int, error) {
+	if int(idx) >= c.char_strings.count() {
+		return nil, nil, nil
+	}
+	fd := 0
+	if c.fd_select != nil {
+		fd = int(c.fd_select[idx])
+	}
+	if fd >= len(c.privates) {
+		return nil, nil, errors.New("INVALID: CFF FDSelect index.")
+	}
+
+	in := &cff_interp_t{
+		font:      c,
+		private:   &c.privates[fd],
+		max_stack: kCffMaxStack,
+		vsindex:   c.privates[fd].vsindex,
+	}
+	if c.cff2 {
+		in.max_stack = kCff2MaxStack
+		// A CFF2 charstring has no width and no endchar.
+		in.seen_width = true
+	}
+	if err := in.run(c.char_strings.at(int(idx))); err != nil {
+		return nil, nil, err
+	}
+	in.close_contour()
+	return in.points, in.ends, nil
+}
+
+// bounds returns the bounds of the points of the glyph |idx| in font units.
+func (c *cff_font_t) bounds(idx uint16) Bounds {
+	points, _, err := c.outline(idx)
+	if err != nil || len(points) == 0 {
+		return Bounds{}
+	}
+	xmin, ymin := points[0].x, points[0].y
+	xmax, ymax := xmin, ymin
+	for _, pt := range points[1:] {
+		xmin, xmax = math.Min(xmin, pt.x), math.Max(xmax, pt.x)
+		ymin, ymax = math.Min(ymin, pt.y), math.Max(ymax, pt.y)
+	}
+	return Bounds{
+		XMin: int32(math.Floor(xmin)),
+		YMin: int32(math.Floor(ymin)),
+		XMax: int32(math.Ceil(xmax)),
+		YMax: int32(math.Ceil(ymax)),
+	}
+}
+
+func (in *cff_interp_t) push(v float64) error {
+	if len(in.stack) >= in.max_stack {
+		return errors.New("INVALID: CFF charstring stack overflow.")
+	}
+	in.stack = append(in.stack, v)
+	return nil
+}
+
+// take_width drops the width of a CFF glyph, the extra first argument of the
+// first stack clearing operator, if |has_width|.
+func (in *cff_interp_t) take_width(has_width bool) {
+	if !in.seen_width {
+		in.seen_width = true
+		if has_width && len(in.stack) > 0 {
+			in.stack = in.stack[1:]
+		}
+	}
+}
+
+func (in *cff_interp_t) close_contour() {
+	if in.open {
+		in.ends = append(in.ends, len(in.points))
+		in.open = false
+	}
+}
+
+func (in *cff_interp_t) move_to(dx, dy float64) {
+	in.close_contour()
+	in.x, in.y = in.x+dx, in.y+dy
+	in.points = append(in.points, cff_point_t{in.x, in.y, kFlagOnCurve})
+	in.open = true
+}
+
+func (in *cff_interp_t) line_to(dx, dy float64) {
+	if !in.open {
+		in.move_to(0, 0)
+	}
+	in.x, in.y = in.x+dx, in.y+dy
+	in.points = append(in.points, cff_point_t{in.x, in.y, kFlagOnCurve})
+}
+
+func (in *cff_interp_t) curve_to(dxa, dya, dxb, dyb, dxc, dyc float64) {
+	if !in.open {
+		in.move_to(0, 0)
+	}
+	xa, ya := in.x+dxa, in.y+dya
+	xb, yb := xa+dxb, ya+dyb
+	in.x, in.y = xb+dxc, yb+dyc
+	in.points = append(in.points,
+		cff_point_t{xa, ya, FlagCubic},
+		cff_point_t{xb, yb, FlagCubic},
+		cff_point_t{in.x, in.y, kFlagOnCurve})
+}
+
+// The operators of the Type 2 charstrings, the escaped ones are 12<<8|op.
+const (
+	kCsHstem      = 1
+	kCsVstem      = 3
+	kCsVmoveto    = 4
+	kCsRlineto    = 5
+	kCsHlineto    = 6
+	kCsVlineto    = 7
+	kCsRrcurveto  = 8
+	kCsCallsubr   = 10
+	kCsReturn     = 11
+	kCsEscape     = 12
+	kCsEndchar    = 14
+	kCsVsindex    = 15
+	kCsBlend      = 16
+	kCsHstemhm    = 18
+	kCsHintmask   = 19
+	kCsCntrmask   = 20
+	kCsRmoveto    = 21
+	kCsHmoveto    = 22
+	kCsVstemhm    = 23
+	kCsRcurveline = 24
+	kCsRlinecurve = 25
+	kCsVvcurveto  = 26
+	kCsHhcurveto  = 27
+	kCsShortint   = 28
+	kCsCallgsubr  = 29
+	kCsVhcurveto  = 30
+	kCsHvcurveto  = 31
+
+	kCsAnd    = 12<<8 | 3
+	kCsOr     = 12<<8 | 4
+	kCsNot    = 12<<8 | 5
+	kCsAbs    = 12<<8 | 9
+	kCsAdd    = 12<<8 | 10
+	kCsSub    = 12<<8 | 11
+	kCsDiv    = 12<<8 | 12
+	kCsNeg    = 12<<8 | 14
+	kCsEq     = 12<<8 | 15
+	kCsDrop   = 12<<8 | 18
+	kCsPut    = 12<<8 | 20
+	kCsGet    = 12<<8 | 21
+	kCsIfelse = 12<<8 | 22
+	kCsRandom = 12<<8 | 23
+	kCsMul    = 12<<8 | 24
+	kCsSqrt   = 12<<8 | 26
+	kCsDup    = 12<<8 | 27
+	kCsExch   = 12<<8 | 28
+	kCsIndex  = 12<<8 | 29
+	kCsRoll   = 12<<8 | 30
+	kCsHflex  = 12<<8 | 34
+	kCsFlex   = 12<<8 | 35
+	kCsHflex1 = 12<<8 | 36
+	kCsFlex1  = 12<<8 | 37
+)
+
+// run runs the charstring |code|, or a subroutine of it.
+func (in *cff_interp_t) run(code []byte) error {
+	for i := 0; i < len(code) && !in.done; {
+		b0 := code[i]
+		i++
+
+		// The operands.
+		switch {
+		case b0 == kCsShortint:
+			if i+2 > len(code) {
+				return errors.New("INVALID: CFF charstring too short.")
+			}
+			if err := in.push(float64(int16(octets_to_u16(code, i)))); err != nil {
+				return err
+			}
+			i += 2
+			continue
+		case 32 <= b0 && b0 <= 246:
+			if err := in.push(float64(int(b0) - 139)); err != nil {
+				return err
+			}
+			continue
+		case 247 <= b0 && b0 <= 254:
+			if i >= len(code) {
+				return errors.New("INVALID: CFF charstring too short.")
+			}
+			v := (int(b0)-247)*256 + int(code[i]) + 108
+			if b0 >= 251 {
+				v = -(int(b0)-251)*256 - int(code[i]) - 108
+			}
+			if err := in.push(float64(v)); err != nil {
+				return err
+			}
+			i++
+			continue
+		case b0 == 255:
+			// A 16.16 fixed point number.
+			if i+4 > len(code) {
+				return errors.New("INVALID: CFF charstring too short.")
+			}
+			if err := in.push(float64(int32(octets_to_u32(code, i))) / 65536); err != nil {
+				return err
+			}
+			i += 4
+			continue
+		}
+
+		op := int(b0)
+		if b0 == kCsEscape {
+			if i >= len(code) {
+				return errors.New("INVALID: CFF charstring escape.")
+			}
+			op, i = 12<<8|int(code[i]), i+1
+		}
+
+		s := in.stack
+		n := len(s)
+		switch op {
+		case kCsHstem, kCsVstem, kCsHstemhm, kCsVstemhm:
+			in.take_width(n%2 == 1)
+			in.stems += len(in.stack) / 2
+
+		case kCsHintmask, kCsCntrmask:
+			// The arguments are the vstems of a vstemhm before the mask.
+			in.take_width(n%2 == 1)
+			in.stems += len(in.stack) / 2
+			i += (in.stems + 7) / 8
+			if i > len(code) {
+				return errors.New("INVALID: CFF charstring hintmask.")
+			}
+
+		case kCsRmoveto:
+			in.take_width(n > 2)
+			if s = in.stack; len(s) < 2 {
+				return errors.New("INVALID: CFF charstring rmoveto.")
+			}
+			in.move_to(s[0], s[1])
+
+		case kCsHmoveto, kCsVmoveto:
+			in.take_width(n > 1)
+			if s = in.stack; len(s) < 1 {
+				return errors.New("INVALID: CFF charstring moveto.")
+			}
+			if op == kCsHmoveto {
+				in.move_to(s[0], 0)
+			} else {
+				in.move_to(0, s[0])
+			}
+
+		case kCsRlineto:
+			for j := 0; j+2 <= n; j += 2 {
+				in.line_to(s[j], s[j+1])
+			}
+
+		case kCsHlineto, kCsVlineto:
+			// The lines alternate horizontal and vertical.
+			horizontal := op == kCsHlineto
+			for j := 0; j < n; j++ {
+				if horizontal {
+					in.line_to(s[j], 0)
+				} else {
+					in.line_to(0, s[j])
+				}
+				horizontal = !horizontal
+			}
+
+		case kCsRrcurveto:
+			for j := 0; j+6 <= n; j += 6 {
+				in.curve_to(s[j], s[j+1], s[j+2], s[j+3], s[j+4], s[j+5])
+			}
+
+		case kCsHhcurveto:
+			dy1 := 0.0
+			if n%2 == 1 {
+				dy1, s = s[0], s[1:]
+			}
+			for j := 0; j+4 <= len(s); j += 4 {
+				in.curve_to(s[j], dy1, s[j+1], s[j+2], s[j+3], 0)
+				dy1 = 0
+			}
+
+		case kCsVvcurveto:
+			dx1 := 0.0
+			if n%2 == 1 {
+				dx1, s = s[0], s[1:]
+			}
+			for j := 0; j+4 <= len(s); j += 4 {
+				in.curve_to(dx1, s[j], s[j+1], s[j+2], 0, s[j+3])
+				dx1 = 0
+			}
+
+		case kCsHvcurveto, kCsVhcurveto:
+			// The curves alternate starting horizontal and vertical, the
+			// last one may end with the other coordinate too.
+			horizontal := op == kCsHvcurveto
+			for j := 0; j+4 <= n; j += 4 {
+				last := 0.0
+				if n-j == 5 {
+					last = s[j+4]
+				}
+				if horizontal {
+					in.curve_to(s[j], 0, s[j+1], s[j+2], last, s[j+3])
+				} else {
+					in.curve_to(0, s[j], s[j+1], s[j+2], s[j+3], last)
+				}
+				horizontal = !horizontal
+			}
+
+		case kCsRcurveline:
+			if n < 8 {
+				return errors.New("INVALID: CFF charstring rcurveline.")
+			}
+			j := 0
+			for ; j+8 <= n; j += 6 {
+				in.curve_to(s[j], s[j+1], s[j+2], s[j+3], s[j+4], s[j+5])
+			}
+			in.line_to(s[j], s[j+1])
+
+		case kCsRlinecurve:
+			if n < 8 {
+				return errors.New("INVALID: CFF charstring rlinecurve.")
+			}
+			j := 0
+			for ; j+8 <= n; j += 2 {
+				in.line_to(s[j], s[j+1])
+			}
+			in.curve_to(s[j], s[j+1], s[j+2], s[j+3], s[j+4], s[j+5])
+
+		case kCsHflex:
+			if n < 7 {
+				return errors.New("INVALID: CFF charstring hflex.")
+			}
+			in.curve_to(s[0], 0, s[1], s[2], s[3], 0)
+			in.curve_to(s[4], 0, s[5], -s[2], s[6], 0)
+
+		case kCsFlex:
+			if n < 13 {
+				return errors.New("INVALID: CFF charstring flex.")
+			}
+			in.curve_to(s[0], s[1], s[2], s[3], s[4], s[5])
+			in.curve_to(s[6], s[7], s[8], s[9], s[10], s[11])
+
+		case kCsHflex1:
+			if n < 9 {
+				return errors.New("INVALID: CFF charstring hflex1.")
+			}
+			in.curve_to(s[0], s[1], s[2], s[3], s[4], 0)
+			in.curve_to(s[5], 0, s[6], s[7], s[8], -(s[1] + s[3] + s[7]))
+
+		case kCsFlex1:
+			if n < 11 {
+				return errors.New("INVALID: CFF charstring flex1.")
+			}
+			// The last point is on the start in the shorter direction.
+			dx := s[0] + s[2] + s[4] + s[6] + s[8]
+			dy := s[1] + s[3] + s[5] + s[7] + s[9]
+			dx6, dy6 := s[10], -dy
+			if math.Abs(dx) <= math.Abs(dy) {
+				dx6, dy6 = -dx, s[10]
+			}
+			in.curve_to(s[0], s[1], s[2], s[3], s[4], s[5])
+			in.curve_to(s[6], s[7], s[8], s[9], dx6, dy6)
+
+		case kCsCallsubr, kCsCallgsubr:
+			if n < 1 {
+				return errors.New("INVALID: CFF charstring callsubr.")
+			}
+			subrs := &in.font.global_subrs
+			if op == kCsCallsubr {
+				subrs = &in.private.subrs
+			}
+			idx := int(s[n-1]) + cff_subr_bias(subrs)
+			in.stack = s[:n-1]
+			if idx < 0 || idx >= subrs.count() {
+				return errors.New("INVALID: CFF charstring subroutine index.")
+			}
+			if in.depth >= kCffMaxSubrDepth {
+				return errors.New("INVALID: CFF charstring subroutines too deep.")
+			}
+			in.depth++
+			err := in.run(subrs.at(idx))
+			in.depth--
+			if err != nil {
+				return err
+			}
+			// The stack is kept across the calls.
+			continue
+
+		case kCsReturn:
+			if in.font.cff2 {
+				return errors.New("INVALID: CFF2 charstring return.")
+			}
+			return nil
+
+		case kCsEndchar:
+			if in.font.cff2 {
+				return errors.New("INVALID: CFF2 charstring endchar.")
+			}
+			in.take_width(n == 1 || n == 5)
+			if len(in.stack) >= 4 {
+				// The accented character of the seac of Type 1.
+				return errors.New("UNSUPPORT: CFF charstring seac.")
+			}
+			in.close_contour()
+			in.done = true
+
+		case kCsVsindex:
+			if !in.font.cff2 || n < 1 {
+				return errors.New("INVALID: CFF charstring vsindex.")
+			}
+			in.vsindex = int(s[n-1])
+			in.stack = s[:0]
+			continue
+
+		case kCsBlend:
+			if !in.font.cff2 {
+				return errors.New("INVALID: CFF charstring blend.")
+			}
+			var err error
+			if in.stack, err = cff_blend(s, in.vsindex, in.font.region_counts); err != nil {
+				return err
+			}
+			continue
+
+		default:
+			// The arithmetic and the storage operators keep the stack.
+			if in.font.cff2 {
+				return fmt.Errorf("INVALID: CFF2 charstring operator %d.", op)
+			}
+			if err := in.arith(op); err != nil {
+				return err
+			}
+			continue
+		}
+		in.stack = in.stack[:0]
+	}
+	return nil
+}
+
+// arith runs the arithmetic, the conditional and the storage operator |op|
+// of a CFF charstring on the stack.
+func (in *cff_interp_t) arith(op int) error {
+	s := in.stack
+	n := len(s)
+	need := map[int]int{
+		kCsAnd: 2, kCsOr: 2, kCsNot: 1, kCsAbs: 1, kCsAdd: 2, kCsSub: 2,
+		kCsDiv: 2, kCsNeg: 1, kCsEq: 2, kCsDrop: 1, kCsPut: 2, kCsGet: 1,
+		kCsIfelse: 4, kCsRandom: 0, kCsMul: 2, kCsSqrt: 1, kCsDup: 1,
+		kCsExch: 2, kCsIndex: 1, kCsRoll: 2,
+	}
+	k, ok := need[op]
+	if !ok {
+		return fmt.Errorf("INVALID: CFF charstring operator %d.", op)
+	}
+	if n < k {
+		return fmt.Errorf("INVALID: CFF charstring stack underflow of %d.", op)
+	}
+	truth := func(b bool) float64 {
+		if b {
+			return 1
+		}
+		return 0
+	}
+
+	switch op {
+	case kCsAnd:
+		s[n-2] = truth(s[n-2] != 0 && s[n-1] != 0)
+	case kCsOr:
+		s[n-2] = truth(s[n-2] != 0 || s[n-1] != 0)
+	case kCsNot:
+		s[n-1] = truth(s[n-1] == 0)
+	case kCsAbs:
+		s[n-1] = math.Abs(s[n-1])
+	case kCsAdd:
+		s[n-2] += s[n-1]
+	case kCsSub:
+		s[n-2] -= s[n-1]
+	case kCsDiv:
+		if s[n-1] == 0 {
+			return errors.New("INVALID: CFF charstring div by zero.")
+		}
+		s[n-2] /= s[n-1]
+	case kCsNeg:
+		s[n-1] = -s[n-1]
+	case kCsEq:
+		s[n-2] = truth(s[n-2] == s[n-1])
+	case kCsDrop:
+		in.stack = s[:n-1]
+	case kCsPut:
+		i := int(s[n-1])
+		if i < 0 || i >= len(in.transient) {
+			return errors.New("INVALID: CFF charstring put.")
+		}
+		in.transient[i] = s[n-2]
+		in.stack = s[:n-2]
+	case kCsGet:
+		i := int(s[n-1])
+		if i < 0 || i >= len(in.transient) {
+			return errors.New("INVALID: CFF charstring get.")
+		}
+		s[n-1] = in.transient[i]
+	case kCsIfelse:
+		// s1 s2 v1 v2 ifelse is s1 if v1 <= v2, otherwise s2.
+		if s[n-2] > s[n-1] {
+			s[n-4] = s[n-3]
+		}
+		in.stack = s[:n-3]
+	case kCsRandom:
+		// Any number in (0, 1], the same one each time so the glyphs are
+		// reproducible.
+		return in.push(0.5)
+	case kCsMul:
+		s[n-2] *= s[n-1]
+	case kCsSqrt:
+		s[n-1] = math.Sqrt(math.Abs(s[n-1]))
+	case kCsDup:
+		return in.push(s[n-1])
+	case kCsExch:
+		s[n-2], s[n-1] = s[n-1], s[n-2]
+	case kCsIndex:
+		i := int(s[n-1])
+		if i < 0 {
+			i = 0
+		}
+		if i >= n-1 {
+			return errors.New("INVALID: CFF charstring index.")
+		}
+		s[n-1] = s[n-2-i]
+	case kCsRoll:
+		// num j roll rotates the top num elements by j.
+		num, j := int(s[n-2]), int(s[n-1])
+		s = s[:n-2]
+		if num < 0 || num > len(s) {
+			return errors.New("INVALID: CFF charstring roll.")
+		}
+		if num > 0 {
+			top := s[len(s)-num:]
+			j = ((j % num) + num) % num
+			rolled := append(append([]float64(nil), top[num-j:]...), top[:num-j]...)
+			copy(top, rolled)
+		}
+		in.stack = s
+	}
+	if op == kCsAnd || op == kCsOr || op == kCsAdd || op == kCsSub || op == kCsDiv ||
+		op == kCsEq || op == kCsMul {
+		in.stack = s[:n-1]
+	}
+	return nil
+}
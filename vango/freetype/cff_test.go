@@ -0,0 +1,400 @@
+// Copyright 2012 The Freetype-Go Authors. All rights reserved.
+// Use of this source code is governed by your choice of either the
+// FreeType License or the GNU General Public License version 2 (or
+// any later version), both of which can be found in the LICENSE file.
+
+package freetype
+
+import (
+	"image"
+	"image/color"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var cs_operators = map[string][]byte{
+	"hstem": {1}, "vstem": {3}, "vmoveto": {4}, "rlineto": {5},
+	"hlineto": {6}, "vlineto": {7}, "rrcurveto": {8}, "callsubr": {10},
+	"return": {11}, "endchar": {14}, "vsindex": {15}, "blend": {16},
+	"hstemhm": {18}, "hintmask": {19}, "cntrmask": {20}, "rmoveto": {21},
+	"hmoveto": {22}, "vstemhm": {23}, "rcurveline": {24}, "rlinecurve": {25},
+	"vvcurveto": {26}, "hhcurveto": {27}, "callgsubr": {29},
+	"vhcurveto": {30}, "hvcurveto": {31},
+	"and": {12, 3}, "or": {12, 4}, "not": {12, 5}, "abs": {12, 9},
+	"add": {12, 10}, "sub": {12, 11}, "div": {12, 12}, "neg": {12, 14},
+	"eq": {12, 15}, "drop": {12, 18}, "put": {12, 20}, "get": {12, 21},
+	"ifelse": {12, 22}, "random": {12, 23}, "mul": {12, 24},
+	"sqrt": {12, 26}, "dup": {12, 27}, "exch": {12, 28}, "index": {12, 29},
+	"roll": {12, 30}, "hflex": {12, 34}, "flex": {12, 35},
+	"hflex1": {12, 36}, "flex1": {12, 37},
+}
+
+// charstring assembles the operators and the numbers of |src|, the integers
+// as shortints and the others as 16.16 fixed, and 0x.. as raw bytes.
+func charstring(src string) []byte {
+	var b []byte
+	for _, tok := range strings.Fields(src) {
+		if op, ok := cs_operators[tok]; ok {
+			b = append(b, op...)
+		} else if strings.HasPrefix(tok, "0x") {
+			v, _ := strconv.ParseUint(tok[2:], 16, 8)
+			b = append(b, byte(v))
+		} else if v, err := strconv.Atoi(tok); err == nil {
+			b = append(b, 28, byte(v>>8), byte(v))
+		} else if f, err := strconv.ParseFloat(tok, 64); err == nil {
+			v := int32(f * 65536)
+			b = append(b, 255, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+		} else {
+			panic("bad charstring token " + tok)
+		}
+	}
+	return b
+}
+
+func u16_bytes(v int) []byte { return []byte{byte(v >> 8), byte(v)} }
+func u32_bytes(v int) []byte { return []byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)} }
+
+// cff_index returns the INDEX of |objs|, with a 32 bits count for the CFF2.
+func cff_index(cff2 bool, objs ...[]byte) []byte {
+	b := u16_bytes(len(objs))
+	if cff2 {
+		b = u32_bytes(len(objs))
+	}
+	if len(objs) == 0 {
+		return b
+	}
+	b = append(b, 4)
+	offset := 1
+	b = append(b, u32_bytes(offset)...)
+	for _, obj := range objs {
+		offset += len(obj)
+		b = append(b, u32_bytes(offset)...)
+	}
+	for _, obj := range objs {
+		b = append(b, obj...)
+	}
+	return b
+}
+
+// dict_int is the 5 bytes integer operand of a DICT.
+func dict_int(v int) []byte {
+	return append([]byte{29}, u32_bytes(v)...)
+}
+
+// build_cff returns a CFF section of the |glyphs|, with the global and the
+// local subroutines.
+func build_cff(glyphs, gsubrs, subrs []string) []byte {
+	assemble := func(srcs []string) [][]byte {
+		var objs [][]byte
+		for _, src := range srcs {
+			objs = append(objs, charstring(src))
+		}
+		return objs
+	}
+	names := cff_index(false, []byte("Test"))
+	strs := cff_index(false)
+	gsubr_index := cff_index(false, assemble(gsubrs)...)
+	char_strings := cff_index(false, assemble(glyphs)...)
+	// The private DICT has the offset of the subrs right after it.
+	private := append(dict_int(6), kDictSubrs)
+	subr_index := cff_index(false, assemble(subrs)...)
+
+	// The Top DICT of 17 bytes, the CharStrings and the Private offsets.
+	const top_size = 17
+	header := []byte{1, 0, 4, 4}
+	tops_size := len(cff_index(false, make([]byte, top_size)))
+	cs_offset := len(header) + len(names) + tops_size + len(strs) + len(gsubr_index)
+	private_offset := cs_offset + len(char_strings)
+	top := append(dict_int(cs_offset), kDictCharStrings)
+	top = append(top, dict_int(len(private))...)
+	top = append(top, dict_int(private_offset)...)
+	top = append(top, kDictPrivate)
+
+	var b []byte
+	for _, part := range [][]byte{header, names, cff_index(false, top), strs,
+		gsubr_index, char_strings, private, subr_index} {
+		b = append(b, part...)
+	}
+	return b
+}
+
+// build_otf returns an OpenType font of the CFF section |cff| with 1000 units
+// per em, the glyphs of 'A' and 'B' are 1 and 2.
+func build_otf(cff []byte, glyph_num int) []byte {
+	head := make([]byte, 54)
+	copy(head[18:], u16_bytes(1000))
+	for i, v := range []int{0, -200, 1000, 800} {
+		copy(head[36+2*i:], u16_bytes(v))
+	}
+	hhea := make([]byte, 36)
+	copy(hhea[4:], u16_bytes(800))
+	copy(hhea[6:], u16_bytes(-200))
+	copy(hhea[34:], u16_bytes(glyph_num))
+	var hmtx []byte
+	for i := 0; i < glyph_num; i++ {
+		hmtx = append(hmtx, u16_bytes(700)...)
+		hmtx = append(hmtx, u16_bytes(50)...)
+	}
+	maxp := append(u32_bytes(0x00005000), u16_bytes(glyph_num)...)
+
+	// A format 4 cmap of 'A' and 'B', and the sentinel.
+	var cmap []byte
+	cmap = append(cmap, u16_bytes(0)...)
+	cmap = append(cmap, u16_bytes(1)...)
+	cmap = append(cmap, u16_bytes(3)...)
+	cmap = append(cmap, u16_bytes(1)...)
+	cmap = append(cmap, u32_bytes(12)...)
+	for _, v := range []int{4, 32, 0, 4, 4, 1, 0,
+		'B', 0xffff, 0, 'A', 0xffff, 1 - 'A', 1, 0, 0} {
+		cmap = append(cmap, u16_bytes(v&0xffff)...)
+	}
+
+	tables := []struct {
+		tag  string
+		data []byte
+	}{
+		{"CFF ", cff}, {"cmap", cmap}, {"head", head}, {"hhea", hhea},
+		{"hmtx", hmtx}, {"maxp", maxp},
+	}
+	b := append(u32_bytes(0x4f54544f), u16_bytes(len(tables))...)
+	b = append(b, make([]byte, 6)...)
+	offset := len(b) + 16*len(tables)
+	for _, table := range tables {
+		b = append(b, table.tag...)
+		b = append(b, make([]byte, 4)...)
+		b = append(b, u32_bytes(offset)...)
+		b = append(b, u32_bytes(len(table.data))...)
+		offset += len(table.data)
+	}
+	for _, table := range tables {
+		b = append(b, table.data...)
+	}
+	return b
+}
+
+func on(x, y float64) cff_point_t { return cff_point_t{x, y, kFlagOnCurve} }
+func cu(x, y float64) cff_point_t { return cff_point_t{x, y, FlagCubic} }
+
+func TestCFFCharstrings(t *testing.T) {
+	testCases := []struct {
+		code   string
+		points []cff_point_t
+		ends   []int
+	}{
+		{"50 10 20 rmoveto 30 hlineto 40 vlineto 5 6 rlineto endchar",
+			[]cff_point_t{on(10, 20), on(40, 20), on(40, 60), on(45, 66)}, []int{4}},
+		{"10 hmoveto 5 vlineto 20 vmoveto 7 8 9 hlineto endchar",
+			[]cff_point_t{on(10, 0), on(10, 5), on(10, 25), on(17, 25), on(17, 33), on(26, 33)},
+			[]int{2, 6}},
+		{"0 0 rmoveto 1 2 3 4 5 6 rrcurveto endchar",
+			[]cff_point_t{on(0, 0), cu(1, 2), cu(4, 6), on(9, 12)}, []int{4}},
+		{"0 0 rmoveto 1 2 3 4 5 hhcurveto endchar",
+			[]cff_point_t{on(0, 0), cu(2, 1), cu(5, 5), on(10, 5)}, []int{4}},
+		{"0 0 rmoveto 1 2 3 4 5 vvcurveto endchar",
+			[]cff_point_t{on(0, 0), cu(1, 2), cu(4, 6), on(4, 11)}, []int{4}},
+		{"0 0 rmoveto 1 2 3 4 5 hvcurveto endchar",
+			[]cff_point_t{on(0, 0), cu(1, 0), cu(3, 3), on(8, 7)}, []int{4}},
+		{"0 0 rmoveto 1 2 3 4 5 6 7 8 vhcurveto endchar",
+			[]cff_point_t{on(0, 0), cu(0, 1), cu(2, 4), on(6, 4), cu(11, 4), cu(17, 11), on(17, 19)},
+			[]int{7}},
+		{"0 0 rmoveto 1 2 3 4 5 6 7 8 rcurveline endchar",
+			[]cff_point_t{on(0, 0), cu(1, 2), cu(4, 6), on(9, 12), on(16, 20)}, []int{5}},
+		{"0 0 rmoveto 1 2 3 4 5 6 7 8 rlinecurve endchar",
+			[]cff_point_t{on(0, 0), on(1, 2), cu(4, 6), cu(9, 12), on(16, 20)}, []int{5}},
+		{"0 0 rmoveto 1 2 3 4 5 6 7 hflex endchar",
+			[]cff_point_t{on(0, 0), cu(1, 0), cu(3, 3), on(7, 3), cu(12, 3), cu(18, 0), on(25, 0)},
+			[]int{7}},
+		{"0 0 rmoveto 1 1 1 1 1 1 2 2 2 2 2 2 50 flex endchar",
+			[]cff_point_t{on(0, 0), cu(1, 1), cu(2, 2), on(3, 3), cu(5, 5), cu(7, 7), on(9, 9)},
+			[]int{7}},
+		{"0 0 rmoveto 1 2 3 4 5 6 7 8 9 hflex1 endchar",
+			[]cff_point_t{on(0, 0), cu(1, 2), cu(4, 6), on(9, 6), cu(15, 6), cu(22, 14), on(31, 0)},
+			[]int{7}},
+		{"0 0 rmoveto 10 1 10 1 10 1 10 -1 10 -1 5 flex1 endchar",
+			[]cff_point_t{on(0, 0), cu(10, 1), cu(20, 2), on(30, 3), cu(40, 2), cu(50, 1), on(55, 0)},
+			[]int{7}},
+		// The subroutines and their biases.
+		{"-107 callgsubr -107 callsubr endchar",
+			[]cff_point_t{on(10, 20), on(40, 20)}, []int{2}},
+		{"-107 callgsubr -106 callsubr endchar",
+			[]cff_point_t{on(10, 20), on(40, 20)}, []int{2}},
+		// The hints and the masks of the 3 stems.
+		{"1 2 3 4 hstemhm 5 6 hintmask 0xc0 10 hmoveto cntrmask 0xff 5 hlineto endchar",
+			[]cff_point_t{on(10, 0), on(15, 0)}, []int{2}},
+		{"99 1 2 hstem 3 hmoveto endchar", []cff_point_t{on(3, 0)}, []int{1}},
+		{"99 endchar", nil, nil},
+		// The arithmetic and the storage operators.
+		{"2 3 add 4 mul hmoveto 8 2 div 1 sub vlineto endchar",
+			[]cff_point_t{on(20, 0), on(20, 3)}, []int{2}},
+		{"7 1 put 1 get 3 exch drop hmoveto endchar", []cff_point_t{on(3, 0)}, []int{1}},
+		{"1 2 3 4 ifelse 1 2 4 3 ifelse rmoveto endchar", []cff_point_t{on(1, 2)}, []int{1}},
+		{"0 0 rmoveto 1 2 3 4 3 1 roll rlineto endchar",
+			[]cff_point_t{on(0, 0), on(1, 4), on(3, 7)}, []int{3}},
+		{"5 6 1 index -9 abs rlineto 16 sqrt 2 neg rlineto 1 dup eq 1 1 and rlineto 0 not 0 or 0 rlineto endchar",
+			[]cff_point_t{on(0, 0), on(5, 6), on(10, 15), on(14, 13), on(15, 14), on(16, 14)}, []int{6}},
+		{"1.5 -2.25 rmoveto endchar", []cff_point_t{on(1.5, -2.25)}, []int{1}},
+	}
+	for _, tc := range testCases {
+		font, err := parse_cff(build_cff([]string{tc.code},
+			[]string{"10 20 rmoveto return"},
+			[]string{"30 hlineto return", "-107 callsubr"}))
+		if err != nil {
+			t.Fatalf("%q: parse_cff: %v", tc.code, err)
+		}
+		points, ends, err := font.outline(0)
+		if err != nil {
+			t.Errorf("%q: %v", tc.code, err)
+			continue
+		}
+		if !reflect.DeepEqual(points, tc.points) || !reflect.DeepEqual(ends, tc.ends) {
+			t.Errorf("%q:\ngot  %v %v\nwant %v %v", tc.code, points, ends, tc.points, tc.ends)
+		}
+	}
+
+	for _, code := range []string{
+		strings.Repeat("1 ", kCffMaxStack+1) + "endchar",
+		"0 0 rmoveto 1 1 1 1 endchar",
+		"-107 callsubr endchar",
+		"1 rmoveto endchar",
+		"0 0 rmoveto 1 2 3 4 5 6 7 rcurveline endchar",
+		"1 0 div endchar",
+		"1 2 blend endchar",
+	} {
+		font, err := parse_cff(build_cff([]string{code}, nil, []string{"-107 callsubr"}))
+		if err != nil {
+			t.Fatalf("%q: parse_cff: %v", code, err)
+		}
+		if _, _, err := font.outline(0); err == nil {
+			t.Errorf("%q: got no error", code)
+		}
+	}
+}
+
+func TestParseCFF2(t *testing.T) {
+	// A vstore of an ItemVariationData of 2 regions, then the CharStrings,
+	// the FDArray and the Private DICT with the vsindex.
+	const top_size = 19
+	header := []byte{2, 0, 5, 0, top_size}
+	gsubrs := cff_index(true)
+	vstore_offset := len(header) + top_size + len(gsubrs)
+	var vstore []byte
+	vstore = append(vstore, u16_bytes(22)...)
+	vstore = append(vstore, u16_bytes(1)...)
+	vstore = append(vstore, u32_bytes(0)...)
+	vstore = append(vstore, u16_bytes(1)...)
+	vstore = append(vstore, u32_bytes(12)...)
+	vstore = append(vstore, 0, 0, 0, 0, 0, 2, 0, 0, 0, 1)
+
+	char_strings := cff_index(true, charstring(
+		"100 200 10 20 30 40 2 blend rmoveto 50 5 5 1 blend hlineto 0 vsindex 60 vlineto"))
+	cs_offset := vstore_offset + len(vstore)
+	// The StdHW of 100 and its deltas of the 2 regions.
+	private := []byte{139, kDictVsindex, 239, 139, 139, 140, kDictBlend, 10}
+	fds := cff_index(true, append(append(dict_int(len(private)), dict_int(0)...), kDictPrivate))
+	fd_offset := cs_offset + len(char_strings)
+	private_offset := fd_offset + len(fds)
+	copy(fds[len(fds)-6:], dict_int(private_offset))
+
+	top := append(dict_int(cs_offset), kDictCharStrings)
+	top = append(top, dict_int(fd_offset)...)
+	top = append(top, 12, 36)
+	top = append(top, dict_int(vstore_offset)...)
+	top = append(top, kDictVstore)
+
+	var b []byte
+	for _, part := range [][]byte{header, top, gsubrs, vstore, char_strings, fds, private} {
+		b = append(b, part...)
+	}
+	font, err := parse_cff(b)
+	if err != nil {
+		t.Fatalf("parse_cff: %v", err)
+	}
+	if !font.cff2 || !reflect.DeepEqual(font.region_counts, []int{2}) {
+		t.Errorf("got the CFF2 %v with the regions %v", font.cff2, font.region_counts)
+	}
+	points, ends, err := font.outline(0)
+	if err != nil {
+		t.Fatalf("outline: %v", err)
+	}
+	want := []cff_point_t{on(100, 200), on(150, 200), on(150, 260)}
+	if !reflect.DeepEqual(points, want) || !reflect.DeepEqual(ends, []int{3}) {
+		t.Errorf("got %v %v, want %v [3]", points, ends, want)
+	}
+}
+
+func TestParseCFFFont(t *testing.T) {
+	cff := build_cff([]string{
+		"endchar",
+		// A square.
+		"700 100 0 rmoveto 500 700 -500 hlineto endchar",
+		// A circle of the cubic curves.
+		"500 0 rmoveto 276 0 224 224 0 276 rrcurveto " +
+			"0 276 -224 224 -276 0 rrcurveto -276 0 -224 -224 0 -276 rrcurveto " +
+			"0 -276 224 -224 276 0 rrcurveto endchar",
+	}, nil, nil)
+	font, err := Parse(build_otf(cff, 3))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if got, want := font.Index('A'), uint16(1); got != want {
+		t.Errorf("Index: got %d, want %d", got, want)
+	}
+	if got, want := font.HMetric(1000, 1), (HMetric{700, 50}); got != want {
+		t.Errorf("HMetric: got %v, want %v", got, want)
+	}
+	if got, want := font.Metrics(1000).CapHeight, int32(0); got != want {
+		t.Errorf("CapHeight: got %v, want %v", got, want)
+	}
+	if got, want := font.unscaled_bounds(2), (Bounds{0, 0, 1000, 1000}); got != want {
+		t.Errorf("unscaled_bounds: got %v, want %v", got, want)
+	}
+
+	// The points are in 26.6 units, 1 per font unit at the scale 1000.
+	g := NewGlyph()
+	if err := g.Load(font, 1000, 1, nil); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	want := []FontPoint{{100, 0, 1}, {600, 0, 1}, {600, 700, 1}, {100, 700, 1}}
+	if !reflect.DeepEqual(g.AllPoints, want) || !reflect.DeepEqual(g.EndIndexArray, []int{4}) {
+		t.Errorf("Load: got %v %v, want %v [4]", g.AllPoints, g.EndIndexArray, want)
+	}
+	if got, want := g.Rect, (Bounds{100, 0, 600, 700}); got != want {
+		t.Errorf("Rect: got %v, want %v", got, want)
+	}
+	// The CFF glyphs have no TrueType hints.
+	if err := g.LoadHinted(font, 1000, 1); err != nil || !reflect.DeepEqual(g.AllPoints, want) {
+		t.Errorf("LoadHinted: got %v %v", g.AllPoints, err)
+	}
+	if err := g.Load(font, 500, 2, nil); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got, want := g.AllPoints[1], (FontPoint{388, 0, FlagCubic}); got != want {
+		t.Errorf("Load: got the control point %v, want %v", got, want)
+	}
+
+	// The square of 'A' and the circle of 'B' at 100 pixels per em.
+	dst := image.NewAlpha(image.Rect(0, 0, 200, 100))
+	c := NewContext()
+	c.SetFont(font)
+	c.SetFontSize(100)
+	c.SetDst(dst)
+	c.SetSrc(image.Opaque)
+	c.SetClip(dst.Bounds())
+	if _, err := c.DrawString("AB", Point(0, 90)); err != nil {
+		t.Fatalf("DrawString: %v", err)
+	}
+	for _, tc := range []struct {
+		x, y int
+		want uint8
+	}{
+		{12, 50, 0xff}, {58, 25, 0xff}, {8, 50, 0}, {62, 50, 0}, {30, 15, 0},
+		{120, 40, 0xff}, {75, 45, 0xff}, {165, 45, 0xff}, {76, 45, 0xff},
+		{68, 40, 0}, {78, 48, 0xff}, {79, 0, 0}, {72, 10, 0},
+	} {
+		if got := dst.AlphaAt(tc.x, tc.y); got != (color.Alpha{tc.want}) {
+			t.Errorf("(%d, %d): got %v, want %v", tc.x, tc.y, got.A, tc.want)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+// Copyright 2012 The Freetype-Go Authors. All rights reserved.
+// Use of this source code is governed by your choice of either the
+// FreeType License or the GNU General Public License version 2 (or
+// any later version), both of which can be found in the LICENSE file.
+
+package freetype
+
+import "math"
+
+// kDashSamples is the number of the lines measuring the length of a curve.
+const kDashSamples = 16
+
+// dash_point_t is a point of the dashing in pixels.
+type dash_point_t struct {
+	x, y float64
+}
+
+func to_dash_point(pt RastPoint) dash_point_t {
+	return dash_point_t{float64(pt.X) / 256, float64(pt.Y) / 256}
+}
+
+func (pt dash_point_t) rast_point() RastPoint {
+	return RastPoint{Fix32(math.Floor(pt.x*256 + 0.5)), Fix32(math.Floor(pt.y*256 + 0.5))}
+}
+
+func lerp_dash_point(a, b dash_point_t, t float64) dash_point_t {
+	return dash_point_t{a.x + (b.x-a.x)*t, a.y + (b.y-a.y)*t}
+}
+
+// split_bezier returns the control points of the part of the Bézier curve
+// |pts| in [t0, t1].
+func split_bezier(pts []dash_point_t, t0, t1 float64) []dash_point_t {
+	lo, _ := split_bezier_at(pts, t1)
+	if t1 <= 0 {
+		return lo
+	}
+	_, hi := split_bezier_at(lo, t0/t1)
+	return hi
+}
+
+// split_bezier_at splits the Bézier curve |pts| at |t| by de Casteljau's
+// algorithm.
+func split_bezier_at(pts []dash_point_t, t float64) (lo, hi []dash_point_t) {
+	n := len(pts)
+	lo, hi = make([]dash_point_t, n), make([]dash_point_t, n)
+	tmp := append([]dash_point_t(nil), pts...)
+	for i := 0; i < n; i++ {
+		lo[i], hi[n-1-i] = tmp[0], tmp[n-1-i]
+		for j := 0; j < n-1-i; j++ {
+			tmp[j] = lerp_dash_point(tmp[j], tmp[j+1], t)
+		}
+	}
+	return lo, hi
+}
+
+// bezier_point returns the point at |t| on the Bézier curve |pts|.
+func bezier_point(pts []dash_point_t, t float64) dash_point_t {
+	tmp := append([]dash_point_t(nil), pts...)
+	for n := len(tmp) - 1; n > 0; n-- {
+		for j := 0; j < n; j++ {
+			tmp[j] = lerp_dash_point(tmp[j], tmp[j+1], t)
+		}
+	}
+	return tmp[0]
+}
+
+// dash_segment_t is a line or a curve of the dashed path, with the table to
+// map the lengths along it to the curve parameters.
+type dash_segment_t struct {
+	pts    []dash_point_t
+	length float64
+	// The lengths at the parameters i/kDashSamples, for the curves.
+	lengths []float64
+}
+
+func new_dash_segment(pts []dash_point_t) *dash_segment_t {
+	s := &dash_segment_t{pts: pts}
+	if len(pts) == 2 {
+		s.length = math.Hypot(pts[1].x-pts[0].x, pts[1].y-pts[0].y)
+		return s
+	}
+
+	s.lengths = make([]float64, kDashSamples+1)
+	prev := pts[0]
+	for i := 1; i <= kDashSamples; i++ {
+		pt := bezier_point(pts, float64(i)/kDashSamples)
+		s.lengths[i] = s.lengths[i-1] + math.Hypot(pt.x-prev.x, pt.y-prev.y)
+		prev = pt
+	}
+	s.length = s.lengths[kDashSamples]
+	return s
+}
+
+// t_at returns the parameter at the length |l| along the segment.
+func (s *dash_segment_t) t_at(l float64) float64 {
+	if s.length == 0 {
+		return 0
+	}
+	if s.lengths == nil {
+		return l / s.length
+	}
+	for i := 1; i <= kDashSamples; i++ {
+		if l <= s.lengths[i] || i == kDashSamples {
+			d := s.lengths[i] - s.lengths[i-1]
+			k := 0.0
+			if d > 0 {
+				k = (l - s.lengths[i-1]) / d
+			}
+			return (float64(i-1) + math.Max(0, math.Min(1, k))) / kDashSamples
+		}
+	}
+	return 1
+}
+
+// dasher_t cuts the sub paths into the dashes.
+type dasher_t struct {
+	out    Path
+	dashes []float64
+	phase  float64
+
+	index int     // the current dash or gap.
+	rem   float64 // the length left of it.
+	on    bool    // is it a dash.
+	down  bool    // is the current dash started in out.
+
+	// The index in out and the count of the dashes of the sub path, and
+	// whether its first dash starts at the start of the sub path.
+	sub, n   int
+	start_on bool
+}
+
+// reset starts the pattern at the phase, for a new sub path.
+func (d *dasher_t) reset() {
+	d.index, d.rem, d.on, d.down = 0, d.dashes[0], true, false
+	phase := d.phase
+	for phase > 0 {
+		if phase < d.rem {
+			d.rem -= phase
+			break
+		}
+		phase -= d.rem
+		d.next()
+	}
+	d.sub, d.n, d.start_on = len(d.out), 0, d.on
+}
+
+// close marks the dashes of the sub path closed if the sub path is |closed|
+// and they're a single dash all around it, so it's stroked with the join at
+// the start as the solid one.
+func (d *dasher_t) close(closed bool) {
+	if closed && d.n == 1 && d.start_on && d.down {
+		d.out[d.sub+3] = kPathClosed
+	}
+}
+
+func (d *dasher_t) next() {
+	d.index = (d.index + 1) % len(d.dashes)
+	d.rem = d.dashes[d.index]
+	d.on = !d.on
+	d.down = false
+}
+
+// add cuts the segment into the dashes.
+func (d *dasher_t) add(s *dash_segment_t) {
+	pos := 0.0
+	for {
+		step := math.Min(d.rem, s.length-pos)
+		if d.on {
+			d.emit(s, pos, pos+step)
+		}
+		pos += step
+		d.rem -= step
+		if d.rem > 1e-9 {
+			return
+		}
+		d.next()
+		if pos >= s.length-1e-9 && d.rem > 0 {
+			return
+		}
+	}
+}
+
+// emit adds the part of the segment in the lengths [l0, l1] to the current
+// dash. A dash of zero length is a tiny line along the segment, so it's
+// drawn as a dot by the round and the square caps.
+func (d *dasher_t) emit(s *dash_segment_t, l0, l1 float64) {
+	t0, t1 := s.t_at(l0), s.t_at(l1)
+	pts := s.pts
+	if len(pts) > 2 {
+		pts = split_bezier(pts, t0, t1)
+	} else {
+		pts = []dash_point_t{lerp_dash_point(pts[0], pts[1], t0), lerp_dash_point(pts[0], pts[1], t1)}
+	}
+
+	first, last := pts[0].rast_point(), pts[len(pts)-1].rast_point()
+	if !d.down {
+		d.out.Start(first)
+		d.down = true
+		d.n++
+		if first == last {
+			// The direction of the dot.
+			dx, dy := pts[len(pts)-1].x-pts[0].x, pts[len(pts)-1].y-pts[0].y
+			if dx == 0 && dy == 0 {
+				a := bezier_point(s.pts, math.Min(1, t1+1e-3))
+				b := bezier_point(s.pts, math.Max(0, t0-1e-3))
+				dx, dy = a.x-b.x, a.y-b.y
+			}
+			if l := math.Hypot(dx, dy); l > 0 {
+				d.out.Add1(first.Add(RastPoint{Fix32(dx / l * 4), Fix32(dy / l * 4)}))
+			}
+			return
+		}
+	}
+	if first == last {
+		return
+	}
+
+	switch len(pts) {
+	case 2:
+		d.out.Add1(last)
+	case 3:
+		d.out.Add2(pts[1].rast_point(), last)
+	case 4:
+		d.out.Add3(pts[1].rast_point(), pts[2].rast_point(), last)
+	}
+}
+
+// Dash returns the dashes of |path|. The |dashes| are the lengths of the
+// dashes and the gaps in turn, repeated twice if the count is odd, and the
+// pattern starts at the |phase| along every sub path. The dashes follow the
+// curves, a dash going around a corner keeps the join. The path is returned
+// as it is if the pattern is empty, has a negative length or is all zero.
+func Dash(path Path, dashes []Fix32, phase Fix32) Path {
+	total := Fix32(0)
+	for _, l := range dashes {
+		if l < 0 {
+			return path
+		}
+		total += l
+	}
+	if len(path) == 0 || total <= 0 {
+		return path
+	}
+
+	d := dasher_t{}
+	for _, l := range dashes {
+		d.dashes = append(d.dashes, float64(l)/256)
+	}
+	if len(d.dashes)%2 == 1 {
+		d.dashes = append(d.dashes, d.dashes...)
+	}
+
+	// A negative phase goes back from the start of the pattern.
+	l := float64(total) / 256
+	if len(dashes)%2 == 1 {
+		l *= 2
+	}
+	d.phase = math.Mod(float64(phase)/256, l)
+	if d.phase < 0 {
+		d.phase += l
+	}
+
+	var last dash_point_t
+	closed := false
+	for i := 0; i < len(path); {
+		switch path[i] {
+		case 0:
+			d.close(closed)
+			last = to_dash_point(RastPoint{path[i+1], path[i+2]})
+			closed = path.Closed(i)
+			d.reset()
+			i += 4
+		case 1:
+			pt := to_dash_point(RastPoint{path[i+1], path[i+2]})
+			d.add(new_dash_segment([]dash_point_t{last, pt}))
+			last = pt
+			i += 4
+		case 2:
+			pt1 := to_dash_point(RastPoint{path[i+1], path[i+2]})
+			pt2 := to_dash_point(RastPoint{path[i+3], path[i+4]})
+			d.add(new_dash_segment([]dash_point_t{last, pt1, pt2}))
+			last = pt2
+			i += 6
+		case 3:
+			pt1 := to_dash_point(RastPoint{path[i+1], path[i+2]})
+			pt2 := to_dash_point(RastPoint{path[i+3], path[i+4]})
+			pt3 := to_dash_point(RastPoint{path[i+5], path[i+6]})
+			d.add(new_dash_segment([]dash_point_t{last, pt1, pt2, pt3}))
+			last = pt3
+			i += 8
+		default:
+			panic("FONT raster bad path.")
+		}
+	}
+	d.close(closed)
+	return d.out
+}
+
+// StrokeDashed adds the outline of the dashes of |path| stroked with |width|
+// to |adder|. Every dash has the caps, the joins are kept inside the dashes.
+func StrokeDashed(adder Adder, path Path, width Fix32, dashes []Fix32, phase Fix32,
+	capper Capper, joiner Joiner) {
+	Stroke(adder, Dash(path, dashes, phase), width, capper, joiner)
+}
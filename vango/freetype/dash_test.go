@@ -0,0 +1,150 @@
+// Copyright 2012 The Freetype-Go Authors. All rights reserved.
+// Use of this source code is governed by your choice of either the
+// FreeType License or the GNU General Public License version 2 (or
+// any later version), both of which can be found in the LICENSE file.
+
+package freetype
+
+import (
+	"math"
+	"testing"
+)
+
+func fix(f float64) Fix32 {
+	return Fix32(f * 256)
+}
+
+func pt(x, y float64) RastPoint {
+	return RastPoint{fix(x), fix(y)}
+}
+
+// sub_paths returns the first and the last points of the sub paths.
+func sub_paths(p Path) (firsts, lasts []RastPoint) {
+	for i := 0; i < len(p); {
+		n := map[Fix32]int{0: 4, 1: 4, 2: 6, 3: 8}[p[i]]
+		if p[i] == 0 {
+			firsts = append(firsts, RastPoint{p[i+1], p[i+2]})
+			lasts = append(lasts, RastPoint{p[i+1], p[i+2]})
+		} else {
+			lasts[len(lasts)-1] = RastPoint{p[i+n-3], p[i+n-2]}
+		}
+		i += n
+	}
+	return firsts, lasts
+}
+
+func TestDashLine(t *testing.T) {
+	var path Path
+	path.Start(pt(0, 0))
+	path.Add1(pt(20, 0))
+
+	firsts, lasts := sub_paths(Dash(path, []Fix32{fix(4), fix(2)}, 0))
+	if len(firsts) != 4 {
+		t.Fatalf("got %v dashes, want 4", len(firsts))
+	}
+	for i := range firsts {
+		end := math.Min(float64(i*6+4), 20)
+		if firsts[i] != pt(float64(i*6), 0) || lasts[i] != pt(end, 0) {
+			t.Errorf("dash %v: got %v-%v", i, firsts[i], lasts[i])
+		}
+	}
+
+	// The phase shifts the pattern, the odd pattern is repeated.
+	firsts, lasts = sub_paths(Dash(path, []Fix32{fix(5)}, fix(2)))
+	if len(firsts) != 3 || firsts[0] != pt(0, 0) || lasts[0] != pt(3, 0) || firsts[1] != pt(8, 0) {
+		t.Errorf("the phase: got %v %v", firsts, lasts)
+	}
+
+	// The invalid patterns keep the path solid.
+	if got := Dash(path, []Fix32{fix(-1), fix(2)}, 0); len(got) != len(path) {
+		t.Errorf("a negative dash: got %v", got)
+	}
+	if got := Dash(path, []Fix32{0, 0}, 0); len(got) != len(path) {
+		t.Errorf("the zero dashes: got %v", got)
+	}
+}
+
+func TestDashCorner(t *testing.T) {
+	// The dash going around the corner is one sub path.
+	var path Path
+	path.Start(pt(0, 0))
+	path.Add1(pt(10, 0))
+	path.Add1(pt(10, 10))
+
+	firsts, lasts := sub_paths(Dash(path, []Fix32{fix(14), fix(2)}, 0))
+	if len(firsts) != 2 || lasts[0] != pt(10, 4) || firsts[1] != pt(10, 6) {
+		t.Errorf("got %v %v", firsts, lasts)
+	}
+}
+
+func TestDashCurve(t *testing.T) {
+	// A quarter of the circle of radius 10 by a cubic, about 15.7 long.
+	k := 10 * 0.5523
+	var path Path
+	path.Start(pt(10, 0))
+	path.Add3(pt(10, k), pt(k, 10), pt(0, 10))
+
+	dashed := Dash(path, []Fix32{fix(1), fix(1)}, 0)
+	firsts, lasts := sub_paths(dashed)
+	if len(firsts) != 8 {
+		t.Errorf("got %v dashes, want 8", len(firsts))
+	}
+
+	// The dashes stay on the circle.
+	for i := range firsts {
+		for _, p := range []RastPoint{firsts[i], lasts[i]} {
+			r := math.Hypot(float64(p.X)/256, float64(p.Y)/256)
+			if math.Abs(r-10) > 0.05 {
+				t.Errorf("dash %v: %v is off the circle, r=%v", i, p, r)
+			}
+		}
+	}
+}
+
+func TestDashDot(t *testing.T) {
+	var path Path
+	path.Start(pt(0, 0))
+	path.Add1(pt(10, 0))
+
+	// The zero length dashes are the dots.
+	firsts, lasts := sub_paths(Dash(path, []Fix32{0, fix(4)}, 0))
+	if len(firsts) != 3 {
+		t.Fatalf("got %v dots, want 3", len(firsts))
+	}
+	for i := range firsts {
+		if firsts[i] != pt(float64(i*4), 0) || lasts[i] == firsts[i] {
+			t.Errorf("dot %v: got %v-%v", i, firsts[i], lasts[i])
+		}
+	}
+}
+
+func TestDashClosed(t *testing.T) {
+	triangle := func(closed bool) Path {
+		var path Path
+		path.Start(pt(0, 0))
+		path.Add1(pt(10, 0))
+		path.Add1(pt(10, 10))
+		path.Add1(pt(0, 0))
+		if closed {
+			path.Close()
+		}
+		return path
+	}
+
+	// A single dash all around is closed like the solid path.
+	if dashed := Dash(triangle(true), []Fix32{fix(100), fix(1)}, 0); !dashed.Closed(0) {
+		t.Errorf("the dash all around isn't closed: %v", dashed)
+	}
+	// The dashes with the gaps are open.
+	dashed := Dash(triangle(true), []Fix32{fix(4), fix(2)}, 0)
+	for i := 0; i < len(dashed); {
+		if dashed[i] == 0 && dashed.Closed(i) {
+			t.Errorf("the dash at %v is closed", i)
+		}
+		i += map[Fix32]int{0: 4, 1: 4, 2: 6, 3: 8}[dashed[i]]
+	}
+	// So is the dash all around the open path.
+	if dashed := Dash(triangle(false), []Fix32{fix(100), fix(1)}, 0); dashed.Closed(0) {
+		t.Errorf("the dash of the open path is closed")
+	}
+}
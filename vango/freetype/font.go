@@ -14,6 +14,9 @@ import (
 type Font struct {
 	// Tables sliced from the TTF data. The different tables are documented at
 	// http://developer.apple.com/fonts/TTRefMan/RM06/Chap6.html
+	// The cff is the CFF or the CFF2 section of an OpenType font with the
+	// PostScript outlines, which has no glyf and loca.
+	cff  []byte
 	cmap []byte
 	cvt  []byte
 	fpgm []byte
@@ -24,6 +27,8 @@ type Font struct {
 	kern []byte
 	loca []byte
 	maxp []byte
+	name []byte
+	os2  []byte
 	prep []byte
 
 	// Cached values derives from the raw ttf data.
@@ -35,6 +40,20 @@ type Font struct {
 	hmetric_num        int
 	kern_num           int
 	bounds             Bounds
+	cff_font           *cff_font_t
+
+	// The vertical metrics from the hhea and the OS/2 sections, in font
+	// units. The descent is positive below the baseline.
+	ascent     int32
+	descent    int32
+	line_gap   int32
+	cap_height int32
+	x_height   int32
+
+	// The strings of the name section by their IDs, and the style from the
+	// OS/2 section.
+	names map[uint16]string
+	style Style
 
 	// Values from the maxp section.
 	max_twilight_points uint16
@@ -58,8 +77,8 @@ func parse_impl(ttf_bytes []byte, index int) (font *Font, err error) {
 	magic, index := octets_to_u32(ttf_bytes, index), index+4
 	// log.Printf("magic %x", magic)
 
-	if magic == 0x00010000 {
-		// No-op.
+	if magic == 0x00010000 || magic == 0x4f54544f {
+		// TrueType, or "OTTO" of the CFF outlines.
 	} else if magic == 0x74746366 {
 		if saved_index != 0 {
 			err = errors.New("INVALID: recursive TTC.")
@@ -114,6 +133,9 @@ func parse_impl(ttf_bytes []byte, index int) (font *Font, err error) {
 		length := int(octets_to_u32(ttf_bytes, table_offset+12))
 
 		switch title {
+		case "CFF ", "CFF2":
+			new_font.cff, err = read_table(ttf_bytes, begin, length)
+
 		case "cmap":
 			new_font.cmap, err = read_table(ttf_bytes, begin, length)
 
@@ -146,6 +168,12 @@ func parse_impl(ttf_bytes []byte, index int) (font *Font, err error) {
 		case "hhea":
 			new_font.hhea, err = read_table(ttf_bytes, begin, length)
 
+		case "name":
+			new_font.name, err = read_table(ttf_bytes, begin, length)
+
+		case "OS/2":
+			new_font.os2, err = read_table(ttf_bytes, begin, length)
+
 		}
 
 		if err != nil {
@@ -169,10 +197,23 @@ func parse_impl(ttf_bytes []byte, index int) (font *Font, err error) {
 		return
 	}
 
+	if new_font.cff != nil {
+		if new_font.cff_font, err = parse_cff(new_font.cff); err != nil {
+			return
+		}
+	} else if new_font.glyf == nil || new_font.loca == nil {
+		err = errors.New("INVALID: no glyf or CFF outlines.")
+		return
+	}
+
 	if err = new_font.parse_kern(); err != nil {
 		return
 	}
 
+	new_font.parse_os2()
+	new_font.parse_name()
+	new_font.parse_style()
+
 	return new_font, nil
 }
 
@@ -316,7 +357,7 @@ func (f *Font) parse_cmap() error {
 		// So, the next two bytes is part of version segment and should be 0.
 		expect_zero, offset := octets_to_u16(f.cmap, offset), offset+2
 		if expect_zero != 0 {
-			msg := fmt.Sprint("UNSUPPORT or INVALID: cmap format version %x",
+			msg := fmt.Sprintf("UNSUPPORT or INVALID: cmap format version %x",
 				f.cmap[offset-4:offset])
 			return errors.New(msg)
 		}
@@ -454,7 +495,12 @@ func (font *Font) parse_kern() error {
 }
 
 // https://developer.apple.com/fonts/TTRefMan/RM06/Chap6maxp.html
+// The version 0.5 of the CFF fonts has only the number of the glyphs.
 func (font *Font) parse_maxp() error {
+	if len(font.maxp) >= 6 && octets_to_u32(font.maxp, 0) == 0x00005000 {
+		font.glyph_num = int(octets_to_u16(font.maxp, 4))
+		return nil
+	}
 	if len(font.maxp) != 32 {
 		msg := fmt.Sprintf("INVALID: bad maxp length %v", len(font.maxp))
 		return errors.New(msg)
@@ -526,7 +572,9 @@ func (f *Font) parse_hhea() error {
 		return errors.New(msg)
 	}
 
-	// TODO(coding): complete this.
+	f.ascent = int32(int16(octets_to_u16(f.hhea, 4)))
+	f.descent = -int32(int16(octets_to_u16(f.hhea, 6)))
+	f.line_gap = int32(int16(octets_to_u16(f.hhea, 8)))
 	f.hmetric_num = int(octets_to_u16(f.hhea, 34))
 	if f.hmetric_num*4+(f.glyph_num-f.hmetric_num)*2 != len(f.hmtx) {
 		msg := fmt.Sprintf("INVALID: Bad hmtx length %v", len(f.hmtx))
@@ -536,6 +584,64 @@ func (f *Font) parse_hhea() error {
 	return nil
 }
 
+// https://docs.microsoft.com/typography/opentype/spec/os2
+// The OS/2 section is optional, the typo metrics replace the ones of the hhea
+// if USE_TYPO_METRICS is set or the hhea has none. The cap height and the
+// x height of the versions before 2 are the heights of 'H' and 'x'.
+func (f *Font) parse_os2() {
+	if len(f.os2) >= 78 {
+		fs_selection := octets_to_u16(f.os2, 62)
+		if fs_selection&(1<<7) != 0 || f.ascent == 0 && f.descent == 0 {
+			f.ascent = int32(int16(octets_to_u16(f.os2, 68)))
+			f.descent = -int32(int16(octets_to_u16(f.os2, 70)))
+			f.line_gap = int32(int16(octets_to_u16(f.os2, 72)))
+		}
+		if f.ascent == 0 && f.descent == 0 {
+			f.ascent = int32(octets_to_u16(f.os2, 74))
+			f.descent = int32(octets_to_u16(f.os2, 76))
+		}
+	}
+	if len(f.os2) >= 90 && octets_to_u16(f.os2, 0) >= 2 {
+		f.x_height = int32(int16(octets_to_u16(f.os2, 86)))
+		f.cap_height = int32(int16(octets_to_u16(f.os2, 88)))
+	}
+	if f.cap_height == 0 {
+		f.cap_height = f.unscaled_bounds(f.Index('H')).YMax
+	}
+	if f.x_height == 0 {
+		f.x_height = f.unscaled_bounds(f.Index('x')).YMax
+	}
+}
+
+// unscaled_bounds returns the bounds of the glyph in the glyf section, or the
+// ones of its points in the CFF.
+func (f *Font) unscaled_bounds(idx uint16) Bounds {
+	if int(idx) >= f.glyph_num {
+		return Bounds{}
+	}
+	if f.cff_font != nil {
+		return f.cff_font.bounds(idx)
+	}
+	var g0, g1 uint32
+	if f.loca_offset_format == kLocaOffsetFormatShort {
+		g0 = 2 * uint32(octets_to_u16(f.loca, 2*int(idx)))
+		g1 = 2 * uint32(octets_to_u16(f.loca, 2*int(idx)+2))
+	} else {
+		g0 = octets_to_u32(f.loca, 4*int(idx))
+		g1 = octets_to_u32(f.loca, 4*int(idx)+4)
+	}
+	if g1 < g0+10 || int(g1) > len(f.glyf) {
+		return Bounds{}
+	}
+	glyf := f.glyf[g0:g1]
+	return Bounds{
+		XMin: int32(int16(octets_to_u16(glyf, 2))),
+		YMin: int32(int16(octets_to_u16(glyf, 4))),
+		XMax: int32(int16(octets_to_u16(glyf, 6))),
+		YMax: int32(int16(octets_to_u16(glyf, 8))),
+	}
+}
+
 func (font *Font) scale(x int32) int32 {
 	if x >= 0 {
 		x += font.units_per_em / 2
@@ -568,6 +674,27 @@ func (f *Font) FUnitsPerEm() int32 {
 	return f.units_per_em
 }
 
+// A Metrics holds the vertical metrics of a Font. The Descent is the distance
+// below the baseline, and the LineGap is the space between the lines.
+type Metrics struct {
+	Ascent    int32
+	Descent   int32
+	LineGap   int32
+	CapHeight int32
+	XHeight   int32
+}
+
+// Metrics returns the vertical metrics of the font at the given scale.
+func (f *Font) Metrics(scale int32) Metrics {
+	return Metrics{
+		Ascent:    f.scale(scale * f.ascent),
+		Descent:   f.scale(scale * f.descent),
+		LineGap:   f.scale(scale * f.line_gap),
+		CapHeight: f.scale(scale * f.cap_height),
+		XHeight:   f.scale(scale * f.x_height),
+	}
+}
+
 // Bounds returns the union of a Font's glyphs' bounds.
 func (f *Font) Bounds(scale int32) Bounds {
 	b := f.bounds
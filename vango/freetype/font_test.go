@@ -57,6 +57,11 @@ func TestParse(t *testing.T) {
 	if got, want := font.Kerning(fupe, i0, i1), int32(-144); got != want {
 		t.Errorf("Kerning: got %v, want %v", got, want)
 	}
+	// The hhea metrics, and the heights of 'H' and 'x' since the OS/2 has
+	// none.
+	if got, want := font.Metrics(fupe), (Metrics{2033, 432, 0, 1480, 1086}); got != want {
+		t.Errorf("Metrics: got %v, want %v", got, want)
+	}
 
 	g := NewGlyph()
 	err = g.Load(font, fupe, i0, nil)
@@ -90,6 +95,38 @@ func TestParse(t *testing.T) {
 	}
 }
 
+func TestParseName(t *testing.T) {
+	font, _, err := parseTestdataFont("luxisr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := map[uint16]string{
+		NameIDFamily:            "Luxi Sans",
+		NameIDSubfamily:         "Regular",
+		NameIDFullName:          "Luxi Sans Regular",
+		NameIDPostScriptName:    "LuxiSans",
+		NameIDTypographicFamily: "",
+	}
+	for id, want := range names {
+		if got := font.Name(id); got != want {
+			t.Errorf("Name(%d): got %q, want %q", id, got, want)
+		}
+	}
+	if font.Family() != "Luxi Sans" || font.Subfamily() != "Regular" {
+		t.Errorf("Family, Subfamily: got %q, %q", font.Family(), font.Subfamily())
+	}
+	if got, want := font.Style(), (Style{Weight: 400, Width: 5}); got != want {
+		t.Errorf("Style: got %+v, want %+v", got, want)
+	}
+
+	if len(g_mac_roman) != 128 {
+		t.Errorf("the Mac OS Roman table has %d characters", len(g_mac_roman))
+	}
+	if got := decode_mac_roman([]byte("Caf\x8e \xca\xf0")); got != "Café \u00a0\uf8ff" {
+		t.Errorf("decode_mac_roman: got %q", got)
+	}
+}
+
 func TestIndex(t *testing.T) {
 	testCases := map[string]map[rune]uint16{
 		"luxisr": {
@@ -326,3 +363,22 @@ func TestScalingWithHinting(t *testing.T) {
 	os.Remove("./log.txt")
 	testScaling(t, &exec_t{})
 }
+
+func TestLoadHinted(t *testing.T) {
+	font, _, err := parseTestdataFont("luxisr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g0, g1 := NewGlyph(), NewGlyph()
+	for i := uint16(1); i < 8; i++ {
+		if err := g0.Load(font, 12*64, i, &exec_t{}); err != nil {
+			t.Fatal(err)
+		}
+		if err := g1.LoadHinted(font, 12*64, i); err != nil {
+			t.Fatal(err)
+		}
+		if !scalingTestEquals(g0.AllPoints, g1.AllPoints) {
+			t.Errorf("glyph #%d:\ngot  %v\nwant %v", i, g1.AllPoints, g0.AllPoints)
+		}
+	}
+}
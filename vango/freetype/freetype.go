@@ -63,11 +63,24 @@ func (c *Context) drawContour(pt_array []FontPoint, dx, dy Fix32) {
 
 	c.rast.Start(start)
 	q0, on0 := start, true
+	// The two control points of a cubic curve of a CFF glyph before its end.
+	var cubic [2]RastPoint
+	cubic_num := 0
 	for _, pt := range pt_array[1:] {
 		q := RastPoint{
 			X: dx + Fix32(pt.X<<2),
 			Y: dy - Fix32(pt.Y<<2),
 		}
+		if pt.Flag&FlagCubic != 0 && cubic_num < 2 {
+			cubic[cubic_num] = q
+			cubic_num++
+			continue
+		}
+		if cubic_num == 2 {
+			c.rast.Add3(cubic[0], cubic[1], q)
+			q0, on0, cubic_num = q, true, 0
+			continue
+		}
 		on := pt.Flag&0x01 != 0
 		if on {
 			if on0 {
@@ -89,7 +102,9 @@ func (c *Context) drawContour(pt_array []FontPoint, dx, dy Fix32) {
 		q0, on0 = q, on
 	}
 	// Close the curve.
-	if on0 {
+	if cubic_num == 2 {
+		c.rast.Add3(cubic[0], cubic[1], start)
+	} else if on0 {
 		c.rast.Add1(start)
 	} else {
 		c.rast.Add2(q0, start)
@@ -138,6 +138,10 @@ type Adder interface {
 
 type Path []Fix32
 
+// kPathClosed is the last value of the start of a sub path closed by Close,
+// it's 0 for the open ones.
+const kPathClosed = -1
+
 func (p Path) String() string {
 	s := ""
 	for i := 0; i < len(p); {
@@ -221,6 +225,32 @@ func (p *Path) Add3(pt0, pt1, pt2 RastPoint) {
 	(*p)[n+7] = 3
 }
 
+// Close marks the current sub path closed, so it's stroked with a join at the
+// start instead of two caps. The line back to the start isn't added, the
+// stroke adds it if the sub path ends elsewhere.
+func (p *Path) Close() {
+	for i := len(*p) - 1; i >= 0; {
+		switch (*p)[i] {
+		case 0, kPathClosed:
+			(*p)[i] = kPathClosed
+			return
+		case 1:
+			i -= 4
+		case 2:
+			i -= 6
+		case 3:
+			i -= 8
+		default:
+			panic("FONT geom bad path")
+		}
+	}
+}
+
+// Closed returns whether the sub path starting at |i| is closed by Close.
+func (p Path) Closed(i int) bool {
+	return p[i+3] == kPathClosed
+}
+
 func (p *Path) AddPath(p0 Path) {
 	n0, n1 := len(*p), len(p0)
 	p.grow(n1)
@@ -247,22 +277,22 @@ func reverse_add_path(adder Adder, path Path) {
 	i := len(path) - 1
 	for {
 		switch path[i] {
-		case 0:
+		case 0, kPathClosed:
 			return
 		case 1:
 			i -= 4
-			path.Add1(RastPoint{path[i-2], path[i-1]})
+			adder.Add1(RastPoint{path[i-2], path[i-1]})
 		case 2:
 			i -= 6
 			pt0 := RastPoint{path[i+2], path[i+3]}
 			pt1 := RastPoint{path[i-2], path[i-1]}
-			path.Add2(pt0, pt1)
+			adder.Add2(pt0, pt1)
 		case 3:
 			i -= 8
 			pt0 := RastPoint{path[i+4], path[i+5]}
 			pt1 := RastPoint{path[i+2], path[i+3]}
 			pt2 := RastPoint{path[i-2], path[i-1]}
-			path.Add3(pt0, pt1, pt2)
+			adder.Add3(pt0, pt1, pt2)
 		default:
 			panic("FONT geom bad path")
 		}
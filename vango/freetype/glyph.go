@@ -6,6 +6,7 @@ package freetype
 
 import (
 	"errors"
+	"math"
 
 	// "log"
 )
@@ -18,6 +19,14 @@ type FontPoint struct {
 	Flag uint32
 }
 
+// The flags of the FontPoints. A point off the curve is the control point of
+// a quadratic Bézier curve, or with FlagCubic, one of the two of a cubic one
+// of a CFF glyph.
+const (
+	kFlagOnCurve uint32 = 1 << 0
+	FlagCubic    uint32 = 1 << 8
+)
+
 // An HMetric holds the horizontal metrics of a single glyph.
 type HMetric struct {
 	AdvanceWidth    int32
@@ -47,6 +56,9 @@ type Glyph struct {
 	exec  *exec_t
 	scale int32
 
+	// hinter is the execer of LoadHinted, kept for the next glyphs.
+	hinter *exec_t
+
 	// pp1x is the x co-ordinate of the first phantom point.
 	pp1x int32
 
@@ -91,6 +103,12 @@ func (g *Glyph) Load(font *Font, scale int32, idx uint16, exec *exec_t) error {
 	g.pp1x = 0
 	g.is_metrics_set = false
 
+	if font.cff_font != nil {
+		// The CFF glyphs have no TrueType instructions.
+		g.exec = nil
+		return g.load_cff(idx)
+	}
+
 	if exec != nil {
 		// log.Printf("F %v", g.AllPoints)
 		if err := exec.init(font, scale); err != nil {
@@ -111,6 +129,16 @@ func (g *Glyph) Load(font *Font, scale int32, idx uint16, exec *exec_t) error {
 	return nil
 }
 
+// LoadHinted loads a glyph like Load, execed by the Font's bytecode
+// instructions with an execer the Glyph keeps, so the instructions of the
+// font and the scale run once for a series of glyphs.
+func (g *Glyph) LoadHinted(font *Font, scale int32, idx uint16) error {
+	if g.hinter == nil {
+		g.hinter = new(exec_t)
+	}
+	return g.Load(font, scale, idx, g.hinter)
+}
+
 func (g *Glyph) load_impl(recursion int32, idx uint16, use_my_metrics bool) (err error) {
 	// The recursion limit here is arbitrary, but defends against malformed
 	// glyphs.
@@ -223,6 +251,50 @@ func (g *Glyph) load_impl(recursion int32, idx uint16, use_my_metrics bool) (err
 	return nil
 }
 
+// load_cff loads the cubic contours of the glyph |idx| of a CFF font. The
+// origin of a CFF glyph is the one of its metrics, and its bounds are the
+// ones of its points.
+func (g *Glyph) load_cff(idx uint16) error {
+	points, ends, err := g.font.cff_font.outline(idx)
+	if err != nil {
+		return err
+	}
+	if len(points) == 0 {
+		return nil
+	}
+
+	scale := float64(g.scale) / float64(g.font.units_per_em)
+	np0 := len(g.AllPoints)
+	for _, pt := range points {
+		g.AllPoints = append(g.AllPoints, FontPoint{
+			X:    int32(math.Floor(pt.x*scale + 0.5)),
+			Y:    int32(math.Floor(pt.y*scale + 0.5)),
+			Flag: pt.flag,
+		})
+	}
+	for _, e := range ends {
+		g.EndIndexArray = append(g.EndIndexArray, np0+e)
+	}
+
+	g.Rect = Bounds{XMin: g.AllPoints[np0].X, YMin: g.AllPoints[np0].Y,
+		XMax: g.AllPoints[np0].X, YMax: g.AllPoints[np0].Y}
+	for _, pt := range g.AllPoints[np0+1:] {
+		if pt.X < g.Rect.XMin {
+			g.Rect.XMin = pt.X
+		}
+		if pt.Y < g.Rect.YMin {
+			g.Rect.YMin = pt.Y
+		}
+		if pt.X > g.Rect.XMax {
+			g.Rect.XMax = pt.X
+		}
+		if pt.Y > g.Rect.YMax {
+			g.Rect.YMax = pt.Y
+		}
+	}
+	return nil
+}
+
 // kLoadOffset is the initial offset for load_simple and load_compound. The
 // first 1- bytes are the number of contours and the bounding box.
 const kLoadOffset = 10
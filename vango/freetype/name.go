@@ -0,0 +1,167 @@
+// Copyright 2012 The Freetype-Go Authors. All rights reserved.
+// Use of this source code is governed by your choice of either the
+// FreeType License or the GNU General Public License version 2 (or
+// any later version), both of which can be found in the LICENSE file.
+
+package freetype
+
+import (
+	"unicode/utf16"
+)
+
+// The IDs of the strings in the name section.
+// https://docs.microsoft.com/typography/opentype/spec/name#name-ids
+const (
+	NameIDCopyright            uint16 = 0
+	NameIDFamily               uint16 = 1
+	NameIDSubfamily            uint16 = 2
+	NameIDUniqueID             uint16 = 3
+	NameIDFullName             uint16 = 4
+	NameIDVersion              uint16 = 5
+	NameIDPostScriptName       uint16 = 6
+	NameIDTypographicFamily    uint16 = 16
+	NameIDTypographicSubfamily uint16 = 17
+)
+
+// A Style is the weight, the width and the slant of a Font, from the OS/2
+// section, or the macStyle of the head section without it.
+type Style struct {
+	// Weight is from 1 to 1000, 400 is regular and 700 is bold.
+	Weight int
+	// Width is from 1 for ultra-condensed to 9 for ultra-expanded, 5 is
+	// normal.
+	Width   int
+	Italic  bool
+	Oblique bool
+}
+
+// g_mac_roman is the characters from 0x80 to 0xff of the Mac OS Roman
+// encoding, the one of the Macintosh names.
+var g_mac_roman = []rune("ÄÅÇÉÑÖÜáàâäãåçéèêëíìîïñóòôöõúùûü" +
+	"†°¢£§•¶ß®©™´¨≠ÆØ∞±≤≥¥µ∂∑∏π∫ªºΩæø" +
+	"¿¡¬√ƒ≈∆«»… ÀÃÕŒœ–—“”‘’÷◊ÿŸ⁄€‹›ﬁﬂ" +
+	"‡·‚„‰ÂÊÁËÈÍÎÏÌÓÔÒÚÛÙıˆ˜¯˘˙˚¸˝˛ˇ")
+
+// https://docs.microsoft.com/typography/opentype/spec/name
+// Each name keeps the string of the best platform, the English of Windows,
+// then the other languages of Windows, then Unicode, then Macintosh. The
+// section is optional, the broken records are skipped.
+func (f *Font) parse_name() {
+	if len(f.name) < 6 {
+		return
+	}
+	count := int(octets_to_u16(f.name, 2))
+	storage := int(octets_to_u16(f.name, 4))
+	if len(f.name) < 6+12*count {
+		count = (len(f.name) - 6) / 12
+	}
+
+	f.names = make(map[uint16]string)
+	ranks := make(map[uint16]int)
+	for i := 0; i < count; i++ {
+		r := f.name[6+12*i:]
+		platform, encoding := octets_to_u16(r, 0), octets_to_u16(r, 2)
+		lang, id := octets_to_u16(r, 4), octets_to_u16(r, 6)
+		begin := storage + int(octets_to_u16(r, 10))
+		end := begin + int(octets_to_u16(r, 8))
+		if end > len(f.name) {
+			continue
+		}
+
+		rank := 0
+		switch {
+		case platform == 3 && (encoding == 1 || encoding == 10) && lang == 0x409:
+			rank = 4
+		case platform == 3 && (encoding <= 1 || encoding == 10):
+			rank = 3
+		case platform == 0:
+			rank = 2
+		case platform == 1 && encoding == 0:
+			rank = 1
+		}
+		if rank <= ranks[id] {
+			continue
+		}
+		if rank == 1 {
+			f.names[id] = decode_mac_roman(f.name[begin:end])
+		} else {
+			f.names[id] = decode_utf16(f.name[begin:end])
+		}
+		ranks[id] = rank
+	}
+}
+
+// https://docs.microsoft.com/typography/opentype/spec/os2
+// The weights of the old fonts from 1 to 9 are scaled to the hundreds.
+func (f *Font) parse_style() {
+	// The macStyle of the head, bit 0 is bold and bit 1 is italic.
+	mac_style := octets_to_u16(f.head, 44)
+	f.style = Style{Weight: 400, Width: 5, Italic: mac_style&2 != 0}
+	if mac_style&1 != 0 {
+		f.style.Weight = 700
+	}
+	if len(f.os2) < 64 {
+		return
+	}
+
+	if weight := int(octets_to_u16(f.os2, 4)); 1 <= weight && weight <= 9 {
+		f.style.Weight = weight * 100
+	} else if weight > 0 {
+		f.style.Weight = weight
+	}
+	if width := int(octets_to_u16(f.os2, 6)); 1 <= width && width <= 9 {
+		f.style.Width = width
+	}
+	// Bit 0 of the fsSelection is italic, and bit 9 is oblique.
+	fs_selection := octets_to_u16(f.os2, 62)
+	f.style.Italic = fs_selection&1 != 0
+	f.style.Oblique = fs_selection&(1<<9) != 0
+}
+
+func decode_utf16(b []byte) string {
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = octets_to_u16(b, 2*i)
+	}
+	return string(utf16.Decode(u))
+}
+
+func decode_mac_roman(b []byte) string {
+	r := make([]rune, len(b))
+	for i, c := range b {
+		if c < 0x80 {
+			r[i] = rune(c)
+		} else {
+			r[i] = g_mac_roman[c-0x80]
+		}
+	}
+	return string(r)
+}
+
+// Name returns the string of |id| in the name section, or "" without it.
+func (f *Font) Name(id uint16) string {
+	return f.names[id]
+}
+
+// Family returns the typographic family name of the font, or the family
+// name without it. The faces of a family share it.
+func (f *Font) Family() string {
+	if name := f.names[NameIDTypographicFamily]; name != "" {
+		return name
+	}
+	return f.names[NameIDFamily]
+}
+
+// Subfamily returns the typographic subfamily name of the font like "Bold
+// Italic", or the subfamily name without it.
+func (f *Font) Subfamily() string {
+	if name := f.names[NameIDTypographicSubfamily]; name != "" {
+		return name
+	}
+	return f.names[NameIDSubfamily]
+}
+
+// Style returns the weight, the width and the slant of the font.
+func (f *Font) Style() Style {
+	return f.style
+}
@@ -102,10 +102,11 @@ func (r *Rast) set_cell(xi, yi int) {
 // and from y0f to y1f fractional vertical units within that scanline.
 func (r *Rast) scan(yi int, x0, y0f, x1, y1f Fix32) {
 	// Break the 24.8 fixed point X co-ordinates into integral and fractional
-	// parts.
-	x0i := int(x0) / 256
+	// parts. The shift floors, so the fractional part is in [0, 256) even for
+	// the negative co-ordinates.
+	x0i := int(x0 >> 8)
 	x0f := x0 - Fix32(256*x0i)
-	x1i := int(x1) / 256
+	x1i := int(x1 >> 8)
 	x1f := x1 - Fix32(256*x1i)
 
 	// A perfectly horizontal scan.
@@ -184,7 +185,7 @@ func (r *Rast) scan(yi int, x0, y0f, x1, y1f Fix32) {
 }
 
 func (r *Rast) Start(pt RastPoint) {
-	r.set_cell(int(pt.X/256), int(pt.Y/256))
+	r.set_cell(int(pt.X>>8), int(pt.Y>>8))
 	r.curr_pen_pos = pt
 }
 
@@ -194,9 +195,9 @@ func (r *Rast) Add1(pt RastPoint) {
 	x1, y1 := pt.X, pt.Y
 	dx, dy := x1-x0, y1-y0
 	// Break the 24.8 fixed point Y co-ordinates into integral and fractional parts.
-	y0i := int(y0) / 256
+	y0i := int(y0 >> 8)
 	y0f := y0 - Fix32(256*y0i)
-	y1i := int(y1) / 256
+	y1i := int(y1 >> 8)
 	y1f := y1 - Fix32(256*y1i)
 
 	if y0i == y1i {
@@ -214,7 +215,7 @@ func (r *Rast) Add1(pt RastPoint) {
 			edge0, edge1, yi_delta = 256, 0, -1
 		}
 
-		x0i, yi := int(x0)/256, y0i
+		x0i, yi := int(x0>>8), y0i
 		x0f_times_2 := (int(x0) - (256 * x0i)) * 2
 		// Do the first pixel.
 		dcover := int(edge1 - y0f)
@@ -262,7 +263,7 @@ func (r *Rast) Add1(pt RastPoint) {
 		x, yi := x0, y0i
 		r.scan(yi, x, y0f, x+x_delta, edge1)
 		x, yi = x+x_delta, yi+yi_delta
-		r.set_cell(int(x)/256, yi)
+		r.set_cell(int(x>>8), yi)
 		if yi != y1i {
 			// Do all the intermediate scanlines.
 			p = 256 * dx
@@ -282,7 +283,7 @@ func (r *Rast) Add1(pt RastPoint) {
 				}
 				r.scan(yi, x, edge0, x+x_delta, edge1)
 				x, yi = x+x_delta, yi+yi_delta
-				r.set_cell(int(x)/256, yi)
+				r.set_cell(int(x>>8), yi)
 			}
 		}
 		// Do the last scanline.
@@ -355,7 +356,7 @@ func (r *Rast) Add3(pt0, pt1, pt2 RastPoint) {
 
 	dev_2 := max_abs(pos.X-3*(pt0.X+pt1.X)+pt2.X, pos.Y-3*(pt0.Y+pt1.Y)+pt2.Y) /
 		Fix32(r.split_scale_2)
-	dev_3 := max_abs(pos.X-2*pt0.X+pt1.X, pos.Y-2*pt0.Y+pt1.Y) /
+	dev_3 := max_abs(pos.X-2*pt0.X+pt2.X, pos.Y-2*pt0.Y+pt2.Y) /
 		Fix32(r.split_scale_3)
 
 	split_num := 0
@@ -384,24 +385,29 @@ func (r *Rast) Add3(pt0, pt1, pt2 RastPoint) {
 		st := split_stack[idx]
 		pt := point_stack[3*idx:]
 		if st > 0 {
+			// Split the cubic curve p[0:4] into an equivalent set of two
+			// shorter curves: p[0:4] and p[3:7]. The new p[6] is the old p[3],
+			// and p[0] is unchanged.
 			x01, y01 := (pt[0].X+pt[1].X)/2, (pt[0].Y+pt[1].Y)/2
 			x12, y12 := (pt[1].X+pt[2].X)/2, (pt[1].Y+pt[2].Y)/2
-			x23, y23 := (pt[2].X+pt[3].X)/2, (pt[2].X+pt[3].X)/2
+			x23, y23 := (pt[2].X+pt[3].X)/2, (pt[2].Y+pt[3].Y)/2
 			pt[6].X, pt[6].Y = pt[3].X, pt[3].Y
 			pt[5].X, pt[5].Y = x23, y23
 			pt[1].X, pt[1].Y = x01, y01
 			pt[2].X, pt[2].Y = (x01+x12)/2, (y01+y12)/2
 			pt[4].X, pt[4].Y = (x12+x23)/2, (y12+y23)/2
-			pt[3].X, pt[3].Y = (x12+x23)/2, (y12+y23)/2
+			pt[3].X, pt[3].Y = (pt[2].X+pt[4].X)/2, (pt[2].Y+pt[4].Y)/2
 
 			split_stack[idx] = st - 1
 			idx++
 			split_stack[idx] = st - 1
 		} else {
-			//mid_x := (pt[0].X + 3*(pt[1].X+pt[2].X) + pt[3].X) / 8
-			//mid_y := (pt[1].Y + 3*(pt[1].Y+pt[2].Y) + pt[3].Y) / 8
-			//r.Add1(RastPoint{mid_x, mid_y})
-			//r.Add1(pt[0])
+			// Replace the level-0 cubic with a two-linear-piece
+			// approximation.
+			mid_x := (pt[0].X + 3*(pt[1].X+pt[2].X) + pt[3].X) / 8
+			mid_y := (pt[0].Y + 3*(pt[1].Y+pt[2].Y) + pt[3].Y) / 8
+			r.Add1(RastPoint{mid_x, mid_y})
+			r.Add1(pt[0])
 			idx--
 		}
 	}
@@ -413,18 +419,19 @@ func (r *Rast) AddPath(path Path) {
 		case 0:
 			pt := RastPoint{path[i+1], path[i+2]}
 			r.Start(pt)
+			i += 4
 		case 1:
 			pt := RastPoint{path[i+1], path[i+2]}
 			r.Add1(pt)
 			i += 4
 		case 2:
 			pt0 := RastPoint{path[i+1], path[i+2]}
-			pt1 := RastPoint{path[i+2], path[i+3]}
+			pt1 := RastPoint{path[i+3], path[i+4]}
 			r.Add2(pt0, pt1)
 			i += 6
 		case 3:
 			pt0 := RastPoint{path[i+1], path[i+2]}
-			pt1 := RastPoint{path[i+2], path[i+3]}
+			pt1 := RastPoint{path[i+3], path[i+4]}
 			pt2 := RastPoint{path[i+5], path[i+6]}
 			r.Add3(pt0, pt1, pt2)
 			i += 8
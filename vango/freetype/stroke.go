@@ -5,6 +5,8 @@
 
 package freetype
 
+import "math"
+
 const kEpsilon = 16384
 
 type Capper interface {
@@ -65,6 +67,7 @@ func round_joiner(lhs, rhs Adder, half_width Fix32, pivot, pt0, pt1 RastPoint) {
 	dot := pt0.Rotate(90).Dot(pt1)
 	if dot >= 0 {
 		add_arc(lhs, pivot, pt0, pt1)
+		rhs.Add1(pivot.Sub(pt1))
 	} else {
 		lhs.Add1(pivot.Add(pt1))
 		add_arc(rhs, pivot, pt0.Neg(), pt1.Neg())
@@ -79,6 +82,46 @@ func bevel_joiner(lhs, rhs Adder, half_width Fix32, pivot, pt0, pt1 RastPoint) {
 	rhs.Add1(pivot.Sub(pt1))
 }
 
+// MiterJoiner adds miter joins with the default miter limit 10 to a stroked
+// path.
+var MiterJoiner Joiner = NewMiterJoiner(10)
+
+// NewMiterJoiner returns a Joiner that adds miter joins. If the ratio of the
+// miter length to the stroke width exceeds |limit|, a bevel join is used
+// instead.
+func NewMiterJoiner(limit float64) Joiner {
+	return JoinerFunc(func(lhs, rhs Adder, half_width Fix32, pivot, pt0, pt1 RastPoint) {
+		miter_joiner(lhs, rhs, half_width, pivot, pt0, pt1, limit)
+	})
+}
+
+func miter_joiner(lhs, rhs Adder, half_width Fix32, pivot, pt0, pt1 RastPoint, limit float64) {
+	// The miter point is on the bisector of the two normals pt0 and pt1. The
+	// length of the bisector b = pt0 + pt1 is 2*w*cos(θ/2), and the miter
+	// length is w/cos(θ/2), so the miter vector is b*2*w*w/(b·b).
+	b := pt0.Add(pt1)
+	bb := float64(b.Dot(b))
+	w := float64(half_width)
+	if bb < kEpsilon || 4*w*w > limit*limit*bb {
+		bevel_joiner(lhs, rhs, half_width, pivot, pt0, pt1)
+		return
+	}
+
+	k := 2 * w * w / bb
+	miter := RastPoint{Fix32(float64(b.X) * k), Fix32(float64(b.Y) * k)}
+
+	dot := pt0.Rotate(90).Dot(pt1)
+	if dot >= 0 {
+		lhs.Add1(pivot.Add(miter))
+		lhs.Add1(pivot.Add(pt1))
+		rhs.Add1(pivot.Sub(pt1))
+	} else {
+		lhs.Add1(pivot.Add(pt1))
+		rhs.Add1(pivot.Sub(miter))
+		rhs.Add1(pivot.Sub(pt1))
+	}
+}
+
 // |add_arc| adds a circular arc from pivot + pt0 to pivot + pt1 to p. The shorter of
 // the two possible arcs is taken, i.e. the one spanning <= 180 degrees. The two
 // vectors pt0 and pt1 must be of equal length.
@@ -180,7 +223,7 @@ type stroke_state_t struct {
 	normal_pt  RastPoint
 }
 
-func (s stroke_state_t) add_non_curvy2(arg_pt0, arg_pt1 RastPoint) {
+func (s *stroke_state_t) add_non_curvy2(arg_pt0, arg_pt1 RastPoint) {
 	const kMaxDepth = 5
 	var depth_stack [kMaxDepth + 1]int
 	var point_stack [2*kMaxDepth + 3]RastPoint
@@ -239,7 +282,6 @@ func (s stroke_state_t) add_non_curvy2(arg_pt0, arg_pt1 RastPoint) {
 
 		normal_pt0 = normal_pt2
 	}
-	panic("FONT unreachable.")
 }
 
 func (s *stroke_state_t) Add1(pt RastPoint) {
@@ -298,9 +340,17 @@ func (s *stroke_state_t) Add2(pt1, pt2 RastPoint) {
 			add_arc(s.adder, mid012, norm01, ptz)
 			add_arc(s.adder, mid012, ptz, norm12)
 		}
+		s.adder.Add1(mid012.Add(norm12))
+		s.adder.Add1(pt2.Add(norm12))
 
-		s.adder.Add1(mid012.Sub(norm12))
-		s.adder.Add1(pt2.Sub(norm12))
+		s.path.Add1(mid012.Sub(norm01))
+		if !arc {
+			ptz := norm01.Rotate(90)
+			add_arc(&s.path, mid012, norm01.Neg(), ptz)
+			add_arc(&s.path, mid012, ptz, norm12.Neg())
+		}
+		s.path.Add1(mid012.Sub(norm12))
+		s.path.Add1(pt2.Sub(norm12))
 
 		s.recent_pt, s.normal_pt = pt2, norm12
 		return
@@ -310,8 +360,58 @@ func (s *stroke_state_t) Add2(pt1, pt2 RastPoint) {
 	s.add_non_curvy2(mid12, pt2)
 }
 
-func (s *stroke_state_t) Add3(pt2, pt3, pt4 RastPoint) {
-	panic("font raster stroke_state_t NOT IMPLEMENT for cubic segments.")
+// Add3 strokes a cubic segment. The cubic is split into n pieces and each
+// piece is approximated by a quadratic, which is then stroked by Add2. The
+// error of the approximation is about √3/36 * |p0 - 3p1 + 3p2 - p3| / n³,
+// and n is chosen to keep it under a quarter pixel.
+func (s *stroke_state_t) Add3(pt1, pt2, pt3 RastPoint) {
+	pt0 := s.recent_pt
+	d := RastPoint{
+		pt0.X - 3*pt1.X + 3*pt2.X - pt3.X,
+		pt0.Y - 3*pt1.Y + 3*pt2.Y - pt3.Y,
+	}
+	n := int(math.Ceil(math.Cbrt(float64(d.Len()) * 0.048 / 64)))
+	if n < 1 {
+		n = 1
+	} else if n > 16 {
+		n = 16
+	}
+
+	at := func(t float64) (x, y float64) {
+		u := 1 - t
+		a, b, c, d := u*u*u, 3*u*u*t, 3*u*t*t, t*t*t
+		x = a*float64(pt0.X) + b*float64(pt1.X) + c*float64(pt2.X) + d*float64(pt3.X)
+		y = a*float64(pt0.Y) + b*float64(pt1.Y) + c*float64(pt2.Y) + d*float64(pt3.Y)
+		return
+	}
+
+	// The derivative of the cubic, to get the tangents at the ends of pieces.
+	tangent := func(t float64) (x, y float64) {
+		u := 1 - t
+		a, b, c := 3*u*u, 6*u*t, 3*t*t
+		x = a*float64(pt1.X-pt0.X) + b*float64(pt2.X-pt1.X) + c*float64(pt3.X-pt2.X)
+		y = a*float64(pt1.Y-pt0.Y) + b*float64(pt2.Y-pt1.Y) + c*float64(pt3.Y-pt2.Y)
+		return
+	}
+
+	h := 1 / float64(n)
+	for i := 0; i < n; i++ {
+		t0, t1 := float64(i)*h, float64(i+1)*h
+		x0, y0 := at(t0)
+		x1, y1 := at(t1)
+		dx0, dy0 := tangent(t0)
+		dx1, dy1 := tangent(t1)
+		// The control point of the quadratic piece is the intersection of
+		// the two end tangents, approximated by the average of the control
+		// points the two tangents imply.
+		cx := ((x0 + dx0*h/2) + (x1 - dx1*h/2)) / 2
+		cy := ((y0 + dy0*h/2) + (y1 - dy1*h/2)) / 2
+		end := RastPoint{Fix32(x1), Fix32(y1)}
+		if i == n-1 {
+			end = pt3
+		}
+		s.Add2(RastPoint{Fix32(cx), Fix32(cy)}, end)
+	}
 }
 
 func (s *stroke_state_t) stroke(path Path) {
@@ -330,7 +430,7 @@ func (s *stroke_state_t) stroke(path Path) {
 		case 3:
 			pt0 := RastPoint{path[i+1], path[i+2]}
 			pt1 := RastPoint{path[i+3], path[i+4]}
-			pt2 := RastPoint{path[i+4], path[i+5]}
+			pt2 := RastPoint{path[i+5], path[i+6]}
 			s.Add3(pt0, pt1, pt2)
 			i += 8
 		default:
@@ -342,12 +442,37 @@ func (s *stroke_state_t) stroke(path Path) {
 		return
 	}
 
+	// A closed path is stroked as two closed contours joined at the start
+	// point, without any caps.
+	if path.Closed(0) {
+		if pivot := path.first_point(); pivot != path.last_point() {
+			s.Add1(pivot)
+		}
+		s.close(path)
+		return
+	}
+
 	s.capper.Cap(s.adder, s.half_width, path.last_point(), s.normal_pt.Neg())
 	reverse_add_path(s.adder, s.path)
 	pivot := path.first_point()
 	s.capper.Cap(s.adder, s.half_width, pivot, pivot.Sub(RastPoint{s.path[1], s.path[2]}))
 }
 
+func (s *stroke_state_t) close(path Path) {
+	// The normal of the first segment is the vector from the first point of
+	// the right hand side path to the first point of the path.
+	pivot := path.first_point()
+	normal_pt := pivot.Sub(RastPoint{s.path[1], s.path[2]})
+	s.joiner.Join(s.adder, &s.path, s.half_width, pivot, s.normal_pt, normal_pt)
+
+	// The left hand side contour is closed by the join. Now walk the right
+	// hand side backwards as the inner contour.
+	s.adder.Start(s.path.last_point())
+	reverse_add_path(s.adder, s.path)
+}
+
+// Stroke adds the outline of |path| stroked with |width| to |adder|. The
+// outline should be filled with the non-zero winding rule.
 func Stroke(adder Adder, path Path, width Fix32, capper Capper, joiner Joiner) {
 	if len(path) == 0 {
 		return
@@ -367,7 +492,7 @@ func Stroke(adder Adder, path Path, width Fix32, capper Capper, joiner Joiner) {
 
 	s := stroke_state_t{
 		adder:      adder,
-		half_width: width,
+		half_width: width / 2,
 		capper:     capper,
 		joiner:     joiner,
 	}
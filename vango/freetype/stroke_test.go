@@ -0,0 +1,99 @@
+// Copyright 2012 The Freetype-Go Authors. All rights reserved.
+// Use of this source code is governed by your choice of either the
+// FreeType License or the GNU General Public License version 2 (or
+// any later version), both of which can be found in the LICENSE file.
+
+package freetype
+
+import (
+	"testing"
+)
+
+// square_path returns the outline of the square of |size| at the origin,
+// back at the start but not closed.
+func square_path(size float64) Path {
+	var path Path
+	path.Start(pt(0, 0))
+	path.Add1(pt(size, 0))
+	path.Add1(pt(size, size))
+	path.Add1(pt(0, size))
+	path.Add1(pt(0, 0))
+	return path
+}
+
+func TestPathClose(t *testing.T) {
+	path := square_path(10)
+	if path.Closed(0) {
+		t.Errorf("the path back at the start is closed")
+	}
+	path.Close()
+	if !path.Closed(0) {
+		t.Errorf("the path isn't closed by Close")
+	}
+
+	// Close marks the current sub path only.
+	n := len(path)
+	path.Start(pt(20, 0))
+	path.Add1(pt(30, 0))
+	path.Close()
+	if !path.Closed(0) || !path.Closed(n) {
+		t.Errorf("the sub paths: got %v", path)
+	}
+	path.Start(pt(40, 0))
+	if path.Closed(len(path) - 4) {
+		t.Errorf("the new sub path is closed")
+	}
+}
+
+func TestStrokeClosed(t *testing.T) {
+	// The open path back at the start has the caps, it's stroked as one
+	// contour. The closed one is the outer and the inner contours.
+	var open Path
+	Stroke(&open, square_path(10), fix(2), SquareCapper, BevelJoiner)
+	if firsts, _ := sub_paths(open); len(firsts) != 1 {
+		t.Errorf("the open path: got %v contours, want 1", len(firsts))
+	}
+	if !has_point(open, pt(-1, 1)) {
+		t.Errorf("the open path has no square cap at the start: %v", open)
+	}
+
+	closed_path := square_path(10)
+	closed_path.Close()
+	var closed Path
+	Stroke(&closed, closed_path, fix(2), SquareCapper, BevelJoiner)
+	if firsts, _ := sub_paths(closed); len(firsts) != 2 {
+		t.Errorf("the closed path: got %v contours, want 2", len(firsts))
+	}
+	if has_point(closed, pt(-1, 1)) {
+		t.Errorf("the closed path has a cap at the start: %v", closed)
+	}
+
+	// The closed path ending elsewhere is stroked with the line back.
+	var triangle Path
+	triangle.Start(pt(0, 0))
+	triangle.Add1(pt(10, 0))
+	triangle.Add1(pt(10, 10))
+	triangle.Close()
+	var want Path
+	Stroke(&want, triangle, fix(2), SquareCapper, BevelJoiner)
+	triangle.Add1(pt(0, 0))
+	triangle.Close()
+	var got Path
+	Stroke(&got, triangle, fix(2), SquareCapper, BevelJoiner)
+	if got.String() != want.String() {
+		t.Errorf("the closed path ending elsewhere:\ngot  %v\nwant %v", got, want)
+	}
+}
+
+func has_point(p Path, want RastPoint) bool {
+	for i := 0; i < len(p); {
+		n := map[Fix32]int{0: 4, 1: 4, 2: 6, 3: 8}[p[i]]
+		for k := i + 1; k+1 < i+n-1; k += 2 {
+			if (RastPoint{p[k], p[k+1]}) == want {
+				return true
+			}
+		}
+		i += n
+	}
+	return false
+}
@@ -0,0 +1,218 @@
+// Copyright 2014 By Jshi. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vango
+
+import (
+	"gwk/vango/freetype"
+	"image"
+	"sync"
+)
+
+const (
+	// kAtlasSize is the width and the height of an atlas page. The glyphs
+	// larger than a page get a page of their own.
+	kAtlasSize = 256
+
+	// kDefaultGlyphCacheBudget is the bytes of the atlas pages kept by
+	// default, 64 pages.
+	kDefaultGlyphCacheBudget = 64 * kAtlasSize * kAtlasSize
+)
+
+// glyph_key_t is a glyph rasterized by a Font, |fx| and |fy| are the buckets
+// of the subpixel offset.
+type glyph_key_t struct {
+	face    *freetype.Font
+	scale   int32
+	fx, fy  uint8
+	hinting bool
+	glyph   uint16
+}
+
+// glyph_entry_t is the mask of a glyph in an atlas page, and its top left
+// relative to the origin of the glyph.
+type glyph_entry_t struct {
+	mask   *image.Alpha
+	offset image.Point
+	page   *atlas_page_t
+}
+
+// atlas_shelf_t is a row of the glyphs of about the same height in a page,
+// filled from the left.
+type atlas_shelf_t struct {
+	y, height, x int
+}
+
+// atlas_page_t is an A8 canvas the masks of the glyphs are packed in, by
+// shelves from the top.
+type atlas_page_t struct {
+	pix     *image.Alpha
+	shelves []atlas_shelf_t
+	keys    []glyph_key_t
+	// used is the tick of the last lookup of a glyph of the page.
+	used uint64
+}
+
+// glyph_cache_t is the glyphs rasterized by all the Fonts. The pages are
+// evicted least recently used first when they take more bytes than the
+// budget. The pixels of an evicted page are never reused, so the masks
+// returned before stay valid. It's safe for concurrent use.
+type glyph_cache_t struct {
+	lock   sync.Mutex
+	budget int
+	bytes  int
+	tick   uint64
+	glyphs map[glyph_key_t]glyph_entry_t
+	pages  []*atlas_page_t
+}
+
+var g_glyph_cache = new_glyph_cache(kDefaultGlyphCacheBudget)
+
+func new_glyph_cache(budget int) *glyph_cache_t {
+	return &glyph_cache_t{
+		budget: budget,
+		glyphs: make(map[glyph_key_t]glyph_entry_t),
+	}
+}
+
+// SetGlyphCacheBudget sets the bytes of the atlas pages of the glyphs shared
+// by all the Fonts, and evicts the pages over it, all of them for zero. The
+// page of the glyph just rasterized is kept even if it's over the budget.
+func SetGlyphCacheBudget(bytes int) {
+	g_glyph_cache.set_budget(bytes)
+}
+
+func (c *glyph_cache_t) set_budget(bytes int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.budget = bytes
+	c.evict(nil)
+}
+
+// get returns the glyph of |key| and marks its page used.
+func (c *glyph_cache_t) get(key glyph_key_t) (glyph_entry_t, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	e, ok := c.glyphs[key]
+	if ok {
+		c.tick++
+		e.page.used = c.tick
+	}
+	return e, ok
+}
+
+// put copies |mask| into a page as the glyph of |key|. If another goroutine
+// put the glyph first, it returns that one.
+func (c *glyph_cache_t) put(key glyph_key_t, mask *image.Alpha, offset image.Point) glyph_entry_t {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if e, ok := c.glyphs[key]; ok {
+		return e
+	}
+
+	w, h := mask.Bounds().Dx(), mask.Bounds().Dy()
+	page, pt := c.alloc(w, h)
+	r := image.Rectangle{pt, pt.Add(image.Pt(w, h))}
+	for y := 0; y < h; y++ {
+		i0 := page.pix.PixOffset(r.Min.X, r.Min.Y+y)
+		copy(page.pix.Pix[i0:i0+w], mask.Pix[mask.PixOffset(mask.Rect.Min.X, mask.Rect.Min.Y+y):])
+	}
+
+	c.tick++
+	page.used = c.tick
+	page.keys = append(page.keys, key)
+	e := glyph_entry_t{mask: atlas_view(page.pix, r), offset: offset, page: page}
+	c.glyphs[key] = e
+	c.evict(page)
+	return e
+}
+
+// alloc returns the page and the top left of a free |w| by |h| rectangle.
+func (c *glyph_cache_t) alloc(w, h int) (*atlas_page_t, image.Point) {
+	if (w == 0 || h == 0) && len(c.pages) > 0 {
+		// The empty glyphs like the space take no room.
+		return c.pages[len(c.pages)-1], image.ZP
+	}
+	if w > kAtlasSize || h > kAtlasSize {
+		page := c.add_page(w, h)
+		page.shelves = append(page.shelves, atlas_shelf_t{height: h, x: w})
+		return page, image.ZP
+	}
+	for _, page := range c.pages {
+		if pt, ok := page.alloc(w, h); ok {
+			return page, pt
+		}
+	}
+	page := c.add_page(kAtlasSize, kAtlasSize)
+	pt, _ := page.alloc(w, h)
+	return page, pt
+}
+
+func (c *glyph_cache_t) add_page(w, h int) *atlas_page_t {
+	page := &atlas_page_t{pix: image.NewAlpha(image.Rect(0, 0, w, h))}
+	c.pages = append(c.pages, page)
+	c.bytes += w * h
+	return page
+}
+
+// evict drops the least recently used pages but |keep| until the pages fit
+// the budget.
+func (c *glyph_cache_t) evict(keep *atlas_page_t) {
+	for c.bytes > c.budget && len(c.pages) > 0 {
+		lru := -1
+		for i, page := range c.pages {
+			if page != keep && (lru < 0 || page.used < c.pages[lru].used) {
+				lru = i
+			}
+		}
+		if lru < 0 {
+			return
+		}
+		page := c.pages[lru]
+		for _, key := range page.keys {
+			delete(c.glyphs, key)
+		}
+		c.bytes -= len(page.pix.Pix)
+		c.pages = append(c.pages[:lru], c.pages[lru+1:]...)
+	}
+}
+
+// alloc finds a shelf with the room for |w| by |h|, not much higher, or
+// opens a new shelf below the others.
+func (p *atlas_page_t) alloc(w, h int) (image.Point, bool) {
+	size := p.pix.Bounds().Size()
+	for i := range p.shelves {
+		s := &p.shelves[i]
+		if h <= s.height && s.height <= h+h/4+2 && s.x+w <= size.X {
+			pt := image.Pt(s.x, s.y)
+			s.x += w
+			return pt, true
+		}
+	}
+
+	y := 0
+	if n := len(p.shelves); n > 0 {
+		y = p.shelves[n-1].y + p.shelves[n-1].height
+	}
+	if y+h > size.Y || w > size.X {
+		return image.ZP, false
+	}
+	p.shelves = append(p.shelves, atlas_shelf_t{y: y, height: h, x: w})
+	return image.Pt(0, y), true
+}
+
+// atlas_view returns the |r| of |pix| as a mask with the top left at (0, 0),
+// sharing the pixels.
+func atlas_view(pix *image.Alpha, r image.Rectangle) *image.Alpha {
+	i0 := pix.PixOffset(r.Min.X, r.Min.Y)
+	i1 := i0
+	if !r.Empty() {
+		i1 = pix.PixOffset(r.Max.X-1, r.Max.Y-1) + 1
+	}
+	return &image.Alpha{
+		Pix:    pix.Pix[i0:i1:i1],
+		Stride: pix.Stride,
+		Rect:   image.Rect(0, 0, r.Dx(), r.Dy()),
+	}
+}
@@ -0,0 +1,181 @@
+package vango
+
+import (
+	"bytes"
+	"gwk/vango/freetype"
+	"image"
+	"sync"
+	"testing"
+)
+
+// new_test_mask returns a |w| by |h| mask filled with |v|.
+func new_test_mask(w, h int, v byte) *image.Alpha {
+	mask := image.NewAlpha(image.Rect(0, 0, w, h))
+	for i := range mask.Pix {
+		mask.Pix[i] = v
+	}
+	return mask
+}
+
+func same_mask(a, b *image.Alpha) bool {
+	if a.Bounds() != b.Bounds() {
+		return false
+	}
+	for y := 0; y < a.Bounds().Dy(); y++ {
+		ra := a.Pix[a.PixOffset(0, y):][:a.Bounds().Dx()]
+		rb := b.Pix[b.PixOffset(0, y):][:b.Bounds().Dx()]
+		if !bytes.Equal(ra, rb) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestGlyphCacheShared(t *testing.T) {
+	load_test_font(t)
+	f0, f1 := NewFont(), NewFont()
+	f0.SetFontSize(20)
+	f1.SetFontSize(20)
+
+	pt := freetype.RastPoint{X: 10<<8 + 0x50, Y: 30 << 8}
+	glyph := f0.Index('g')
+	m0, o0, err := f0.GlyphAt(glyph, pt)
+	if err != nil {
+		t.Fatalf("GlyphAt: %v", err)
+	}
+	m1, o1, err := f1.GlyphAt(glyph, pt.Add(freetype.RastPoint{X: 5 << 8}))
+	if err != nil {
+		t.Fatalf("GlyphAt: %v", err)
+	}
+	if &m0.Pix[0] != &m1.Pix[0] {
+		t.Errorf("the fonts of the same face and size don't share the mask")
+	}
+	if o1 != o0.Add(image.Pt(5, 0)) {
+		t.Errorf("offset: got %v, want %v", o1, o0.Add(image.Pt(5, 0)))
+	}
+
+	want, _, err := f0.rasterize(f0.font, glyph, 0x40, 0)
+	if err != nil {
+		t.Fatalf("rasterize: %v", err)
+	}
+	if !same_mask(m0, want) {
+		t.Errorf("the mask in the atlas differs from the rasterized one")
+	}
+
+	// Another size and the hinting are other glyphs.
+	f1.SetFontSize(21)
+	if m, _, _ := f1.GlyphAt(glyph, pt); &m.Pix[0] == &m0.Pix[0] {
+		t.Errorf("the fonts of different sizes share the mask")
+	}
+	f0.SetHinting(true)
+	if m, _, _ := f0.GlyphAt(glyph, pt); &m.Pix[0] == &m0.Pix[0] {
+		t.Errorf("the hinted and unhinted glyphs share the mask")
+	}
+}
+
+func TestGlyphCacheNoCollisions(t *testing.T) {
+	c := new_glyph_cache(kDefaultGlyphCacheBudget)
+	// The glyphs 256 apart took the same slot of the cache of a Font.
+	for i := 0; i < 4; i++ {
+		key := glyph_key_t{glyph: uint16(0x4e00 + 256*i)}
+		c.put(key, new_test_mask(8, 8, byte(i+1)), image.Pt(i, 0))
+	}
+	for i := 0; i < 4; i++ {
+		e, ok := c.get(glyph_key_t{glyph: uint16(0x4e00 + 256*i)})
+		if !ok {
+			t.Fatalf("glyph %d is evicted", i)
+		}
+		if e.offset != image.Pt(i, 0) || !same_mask(e.mask, new_test_mask(8, 8, byte(i+1))) {
+			t.Errorf("glyph %d: got the mask of another glyph", i)
+		}
+	}
+	if len(c.pages) != 1 {
+		t.Errorf("pages: got %d, want 1", len(c.pages))
+	}
+}
+
+func TestGlyphCacheEviction(t *testing.T) {
+	c := new_glyph_cache(2 * kAtlasSize * kAtlasSize)
+	key := func(i int) glyph_key_t { return glyph_key_t{glyph: uint16(i)} }
+
+	// 4 masks of 100x100 fill a page.
+	var masks []*image.Alpha
+	for i := 0; i < 8; i++ {
+		masks = append(masks, c.put(key(i), new_test_mask(100, 100, byte(i+1)), image.ZP).mask)
+	}
+	if len(c.pages) != 2 || c.bytes != c.budget {
+		t.Fatalf("pages: got %d of %d bytes", len(c.pages), c.bytes)
+	}
+
+	// The first page is used last, so the second one is evicted.
+	c.get(key(0))
+	c.put(key(8), new_test_mask(100, 100, 9), image.ZP)
+	if len(c.pages) != 2 || c.bytes > c.budget {
+		t.Fatalf("pages: got %d of %d bytes", len(c.pages), c.bytes)
+	}
+	for i := 0; i <= 8; i++ {
+		_, ok := c.get(key(i))
+		if want := i < 4 || i == 8; ok != want {
+			t.Errorf("glyph %d cached: got %v, want %v", i, ok, want)
+		}
+	}
+	// The masks of the evicted page are still valid.
+	if !same_mask(masks[5], new_test_mask(100, 100, 6)) {
+		t.Errorf("the mask of the evicted page is overwritten")
+	}
+
+	// The glyph larger than a page gets its own.
+	e := c.put(key(9), new_test_mask(300, 20, 10), image.ZP)
+	if e.page.pix.Bounds().Dx() != 300 || !same_mask(e.mask, new_test_mask(300, 20, 10)) {
+		t.Errorf("the large glyph: got the page %v", e.page.pix.Bounds())
+	}
+	if c.bytes > c.budget {
+		t.Errorf("bytes: got %d, over the budget %d", c.bytes, c.budget)
+	}
+
+	c.set_budget(0)
+	if len(c.pages) != 0 || len(c.glyphs) != 0 || c.bytes != 0 {
+		t.Errorf("the zero budget keeps %d pages of %d glyphs", len(c.pages), len(c.glyphs))
+	}
+	// The glyph over the budget is kept till the next one.
+	c.put(key(10), new_test_mask(8, 8, 11), image.ZP)
+	if _, ok := c.get(key(10)); !ok {
+		t.Errorf("the glyph over the budget is evicted")
+	}
+}
+
+func TestGlyphCacheConcurrent(t *testing.T) {
+	load_test_font(t)
+	text := "The quick brown fox jumps over the lazy dog 0123456789"
+
+	var wg sync.WaitGroup
+	errs := make(chan string, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(size float64) {
+			defer wg.Done()
+			f := NewFont()
+			f.SetFontSize(size)
+			for _, r := range text {
+				glyph := f.Index(r)
+				for fx := freetype.Fix32(0); fx < 256; fx += 64 {
+					mask, _, err := f.GlyphAt(glyph, freetype.RastPoint{X: fx})
+					if err != nil {
+						errs <- err.Error()
+						return
+					}
+					want, _, _ := f.rasterize(f.font, glyph, fx, 0)
+					if !same_mask(mask, want) {
+						errs <- "the mask of " + string(r) + " differs"
+						return
+					}
+				}
+			}
+		}(float64(10 + i%4))
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
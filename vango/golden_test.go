@@ -0,0 +1,105 @@
+package vango_test
+
+import (
+	"gwk/vango"
+	"gwk/vango/freetype"
+	"gwk/vango/vangotest"
+	"image"
+	"io/ioutil"
+	"math"
+	"testing"
+)
+
+func new_golden_context(w, h int) (*vango.CanvasContext, *vango.Canvas) {
+	canvas := vango.NewCanvas(w, h)
+	ctxt := vango.NewContext()
+	ctxt.SetCanvas(canvas)
+	ctxt.DrawColor(0xff, 0xff, 0xff)
+	return ctxt, canvas
+}
+
+func TestGoldenDrawText(t *testing.T) {
+	bytes, err := ioutil.ReadFile("./freetype/exp/data/luxisr.ttf")
+	if err != nil {
+		t.Skipf("no test font: %v", err)
+	}
+	face, err := freetype.ParseFont(bytes)
+	if err != nil {
+		t.Fatalf("ParseFont: %v", err)
+	}
+
+	ctxt, canvas := new_golden_context(160, 60)
+	ctxt.SetFontFace(face)
+	ctxt.SetFontSize(16)
+	ctxt.SetFontColor(0x20, 0x20, 0x80)
+	ctxt.DrawText("Hello, vango!", image.Rect(4, 4, 160, 30))
+
+	ctxt.Translate(20, 30)
+	ctxt.Rotate(math.Pi / 16)
+	ctxt.SetFontRGBA(0x80, 0, 0, 0xc0)
+	ctxt.DrawText("Rotated", image.Rect(0, 0, 120, 30))
+
+	vangotest.AssertGolden(t, "draw_text", canvas, 2)
+}
+
+func TestGoldenAlphaBlend(t *testing.T) {
+	src := vango.NewCanvas(20, 20)
+	sc := vango.NewContext()
+	sc.SetCanvas(src)
+	sc.SetFillRGBA(0, 0x80, 0xff, 0xa0)
+	sc.FillEllipse(10, 10, 9, 9)
+
+	ctxt, canvas := new_golden_context(80, 40)
+	ctxt.SetFillColor(0xff, 0xc0, 0)
+	ctxt.FillRect(image.Rect(10, 10, 70, 30))
+	ctxt.AlphaBlend(0, 0, src, src.LocalBounds())
+	ctxt.SetGlobalAlpha(0.5)
+	ctxt.AlphaBlend(20, 10, src, src.LocalBounds())
+	ctxt.SetGlobalAlpha(1)
+	ctxt.Translate(50, 5)
+	ctxt.Scale(1.5, 1.5)
+	ctxt.AlphaBlend(0, 0, src, src.LocalBounds())
+
+	vangotest.AssertGolden(t, "alpha_blend", canvas, 2)
+}
+
+func TestGoldenShapes(t *testing.T) {
+	ctxt, canvas := new_golden_context(120, 60)
+	gradient := vango.NewLinearGradient(0, 0, 0, 50)
+	gradient.AddColorStop(0, 0x60, 0x90, 0xd0, 0xff)
+	gradient.AddColorStop(1, 0x20, 0x40, 0x80, 0xff)
+	ctxt.SetFillPaint(gradient)
+	ctxt.FillRoundedRect(image.Rect(5, 5, 55, 55), vango.UniformRadii(8))
+
+	ctxt.SetStrokeColor(0xc0, 0x20, 0x20)
+	ctxt.SetLineWidth(3)
+	ctxt.SetLineDash([]float64{6, 4})
+	ctxt.StrokeEllipse(88, 30, 25, 20)
+	ctxt.SetLineDash(nil)
+	ctxt.SetFillRGBA(0x20, 0xa0, 0x20, 0x80)
+	ctxt.Pie(88, 30, 18, 14, 0, 4)
+
+	vangotest.AssertGolden(t, "shapes", canvas, 2)
+}
+
+func TestGoldenFilters(t *testing.T) {
+	ctxt, canvas := new_golden_context(120, 60)
+	ctxt.DrawShadow(image.Rect(10, 10, 50, 45), vango.UniformRadii(6),
+		vango.Shadow{DX: 3, DY: 4, Blur: 5, Color: vango.NewSolidPaint(0, 0, 0, 0x80)})
+	ctxt.SetFillColor(0xf0, 0xf0, 0xf0)
+	ctxt.FillRoundedRect(image.Rect(10, 10, 50, 45), vango.UniformRadii(6))
+
+	photo := canvas.SubCanvas(image.Rect(65, 5, 115, 55))
+	pc := vango.NewContext()
+	pc.SetCanvas(photo)
+	gradient := vango.NewLinearGradient(0, 0, 50, 0)
+	gradient.AddColorStop(0, 0xe0, 0x40, 0x20, 0xff)
+	gradient.AddColorStop(1, 0x20, 0x60, 0xe0, 0xff)
+	pc.SetFillPaint(gradient)
+	pc.FillRect(image.Rect(0, 0, 50, 50))
+	vango.ApplyColorMatrix(photo.SubCanvas(image.Rect(0, 25, 50, 50)), vango.SepiaColorMatrix(1))
+	vango.GaussianBlur(photo.SubCanvas(image.Rect(25, 0, 50, 50)), 3)
+	vango.InnerShadow(photo, vango.Shadow{DX: 2, DY: 2, Blur: 3, Color: vango.NewSolidPaint(0, 0, 0, 0xa0)})
+
+	vangotest.AssertGolden(t, "filters", canvas, 2)
+}
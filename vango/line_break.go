@@ -0,0 +1,321 @@
+// Copyright 2014 By Jshi. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vango
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// lb_class_t is the line breaking class of a character in UAX #14,
+// http://www.unicode.org/reports/tr14/
+type lb_class_t int
+
+const (
+	kLbAL  lb_class_t = iota // Alphabetic, and the classes resolved to it.
+	kLbBA                    // Break after.
+	kLbBB                    // Break before.
+	kLbB2                    // Break on either side, but not between.
+	kLbBK                    // Mandatory break.
+	kLbCB                    // Contingent break.
+	kLbCL                    // Close punctuation.
+	kLbCM                    // Combining mark.
+	kLbCP                    // Close parenthesis.
+	kLbCR                    // Carriage return.
+	kLbEX                    // Exclamation or interrogation.
+	kLbGL                    // Non breaking glue.
+	kLbHY                    // Hyphen.
+	kLbID                    // Ideographic.
+	kLbIN                    // Inseparable.
+	kLbIS                    // Infix numeric separator.
+	kLbLF                    // Line feed.
+	kLbNL                    // Next line.
+	kLbNS                    // Non starter.
+	kLbNU                    // Numeric.
+	kLbOP                    // Open punctuation.
+	kLbPO                    // Postfix numeric.
+	kLbPR                    // Prefix numeric.
+	kLbQU                    // Quotation.
+	kLbRI                    // Regional indicator.
+	kLbSP                    // Space.
+	kLbSY                    // Symbols allowing break after.
+	kLbWJ                    // Word joiner.
+	kLbZW                    // Zero width space.
+	kLbZWJ                   // Zero width joiner.
+)
+
+// line_break_t is a position in the text where a line can start, in bytes.
+// The line must start there if the break is mandatory.
+type line_break_t struct {
+	pos       int
+	mandatory bool
+}
+
+// g_lb_ascii_classes is the classes of the ASCII characters.
+var g_lb_ascii_classes = [128]lb_class_t{
+	'\t': kLbBA, '\n': kLbLF, '\v': kLbBK, '\f': kLbBK, '\r': kLbCR,
+	' ': kLbSP, '!': kLbEX, '"': kLbQU, '#': kLbAL, '$': kLbPR, '%': kLbPO,
+	'&': kLbAL, '\'': kLbQU, '(': kLbOP, ')': kLbCP, '*': kLbAL, '+': kLbPR,
+	',': kLbIS, '-': kLbHY, '.': kLbIS, '/': kLbSY, ':': kLbIS, ';': kLbIS,
+	'<': kLbAL, '=': kLbAL, '>': kLbAL, '?': kLbEX, '@': kLbAL, '[': kLbOP,
+	'\\': kLbPR, ']': kLbCP, '^': kLbAL, '_': kLbAL, '`': kLbAL, '{': kLbOP,
+	'|': kLbBA, '}': kLbCL, '~': kLbAL,
+}
+
+// line_break_class returns the class of |r| after the rule LB1, from the
+// general categories and the scripts for the characters not listed. The
+// complex context scripts like Thai are alphabetic, without the dictionary
+// they would need.
+func line_break_class(r rune) lb_class_t {
+	if r < 0x80 {
+		if '0' <= r && r <= '9' {
+			return kLbNU
+		}
+		if (r < ' ' || r == 0x7f) && g_lb_ascii_classes[r] == kLbAL {
+			return kLbCM // The other controls.
+		}
+		return g_lb_ascii_classes[r]
+	}
+
+	switch r {
+	case 0x85:
+		return kLbNL
+	case 0x2028, 0x2029:
+		return kLbBK
+	case 0xa0, 0x202f, 0x2007, 0x2011, 0x0f0c, 0x034f:
+		return kLbGL
+	case 0x200b:
+		return kLbZW
+	case 0x200d:
+		return kLbZWJ
+	case 0x2060, 0xfeff:
+		return kLbWJ
+	case 0xad, 0x2010, 0x2012, 0x2013, 0x1680, 0x3000:
+		return kLbBA
+	case 0x2000, 0x2001, 0x2002, 0x2003, 0x2004, 0x2005, 0x2006, 0x2008,
+		0x2009, 0x200a, 0x205f:
+		return kLbBA
+	case 0xb4, 0x2c8, 0x2cc, 0x2df:
+		return kLbBB
+	case 0x2014:
+		return kLbB2
+	case 0x2024, 0x2025, 0x2026, 0xfe19:
+		return kLbIN
+	case 0xfffc:
+		return kLbCB
+	case 0xa2, 0xb0, 0x2030, 0x2031, 0x2032, 0x2033, 0x2103, 0xff05, 0xffe0:
+		return kLbPO
+	case 0xb1, 0x2116, 0x2212, 0x2213:
+		return kLbPR
+	case 0xa1, 0xbf:
+		return kLbOP
+	case 0x3001, 0x3002, 0xff0c, 0xff0e, 0xfe50, 0xfe52:
+		return kLbCL
+	case 0xff01, 0xff1f:
+		return kLbEX
+	case 0xff1a, 0xff1b, 0x30fb, 0x30fc, 0x3005, 0x303b, 0x309d, 0x309e,
+		0x30fd, 0x30fe, 0x2047, 0x2048, 0x2049, 0x203c, 0x203d:
+		return kLbNS
+	case 0xab, 0xbb, 0x2018, 0x2019, 0x201b, 0x201c, 0x201d, 0x201f, 0x2039,
+		0x203a, 0x275b, 0x275c, 0x275d, 0x275e:
+		return kLbQU
+	}
+
+	switch {
+	case 0x1f1e6 <= r && r <= 0x1f1ff:
+		return kLbRI
+	case is_small_kana(r):
+		// The small kana are the conditional Japanese starters, CJ resolved
+		// to NS by the strict breaking.
+		return kLbNS
+	case unicode.Is(unicode.Mn, r), unicode.Is(unicode.Mc, r), unicode.Is(unicode.Me, r),
+		unicode.Is(unicode.Cc, r), unicode.Is(unicode.Variation_Selector, r):
+		return kLbCM
+	case unicode.Is(unicode.Nd, r):
+		return kLbNU
+	case unicode.Is(unicode.Ps, r):
+		return kLbOP
+	case unicode.Is(unicode.Pe, r):
+		return kLbCL
+	case unicode.Is(unicode.Pi, r), unicode.Is(unicode.Pf, r):
+		return kLbQU
+	case unicode.Is(unicode.Sc, r):
+		return kLbPR
+	case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul,
+		unicode.Yi, unicode.Bopomofo):
+		return kLbID
+	case 0x3000 <= r && r <= 0x33ff, 0xff00 <= r && r <= 0xffef, 0xfe30 <= r && r <= 0xfe4f:
+		// The CJK symbols and the fullwidth forms.
+		return kLbID
+	case 0x1f000 <= r && r <= 0x1faff, 0x2600 <= r && r <= 0x27bf:
+		// The pictographs and the emoji.
+		return kLbID
+	}
+	return kLbAL
+}
+
+func is_small_kana(r rune) bool {
+	switch r {
+	case 0x3041, 0x3043, 0x3045, 0x3047, 0x3049, 0x3063, 0x3083, 0x3085, 0x3087,
+		0x308e, 0x3095, 0x3096, 0x30a1, 0x30a3, 0x30a5, 0x30a7, 0x30a9, 0x30c3,
+		0x30e3, 0x30e5, 0x30e7, 0x30ee, 0x30f5, 0x30f6:
+		return true
+	}
+	return 0x31f0 <= r && r <= 0x31ff
+}
+
+// line_breaks returns the break opportunities of |text| by the rules of UAX
+// #14, ending with the mandatory break at the end of the text. The rules of
+// Hangul syllables, emoji modifiers and Hebrew letters are left out, and the
+// numbers are approximated by the pairs of their classes.
+func line_breaks(text string) []line_break_t {
+	var breaks []line_break_t
+	if text == "" {
+		return breaks
+	}
+
+	r, size := utf8.DecodeRuneInString(text)
+	prev := line_break_class(r)
+	if prev == kLbCM || prev == kLbZWJ {
+		prev = kLbAL // LB10
+	}
+	// The class before the spaces, for the rules of LB8 and LB14 to LB17.
+	before_sp := prev
+	// The count of regional indicators in a row, for LB30a.
+	ri_count := 0
+	if prev == kLbRI {
+		ri_count = 1
+	}
+	prev_zwj := r == 0x200d
+
+	for pos := size; pos < len(text); pos += size {
+		r, size = utf8.DecodeRuneInString(text[pos:])
+		cur := line_break_class(r)
+
+		brk, mandatory := lb_pair(prev, before_sp, cur, ri_count, prev_zwj)
+		if brk {
+			breaks = append(breaks, line_break_t{pos, mandatory})
+		}
+
+		// LB9, the marks take the class of their base.
+		if (cur == kLbCM || cur == kLbZWJ) && !brk && !lb_is_break_or_space(prev) {
+			prev_zwj = cur == kLbZWJ
+			continue
+		}
+		if cur == kLbCM || cur == kLbZWJ {
+			cur = kLbAL // LB10
+		}
+
+		if cur == kLbRI {
+			ri_count++
+		} else {
+			ri_count = 0
+		}
+		if cur != kLbSP {
+			before_sp = cur
+		}
+		prev, prev_zwj = cur, r == 0x200d
+	}
+	return append(breaks, line_break_t{len(text), true})
+}
+
+func lb_is_break_or_space(c lb_class_t) bool {
+	switch c {
+	case kLbBK, kLbCR, kLbLF, kLbNL, kLbSP, kLbZW:
+		return true
+	}
+	return false
+}
+
+// lb_pair returns if a line can break between the classes |prev| and |cur|,
+// and if it must. |before_sp| is the class before the spaces ending at
+// |prev|, or |prev| itself.
+func lb_pair(prev, before_sp, cur lb_class_t, ri_count int, prev_zwj bool) (brk, mandatory bool) {
+	switch {
+	// LB4, LB5
+	case prev == kLbCR && cur == kLbLF:
+		return false, false
+	case prev == kLbBK || prev == kLbCR || prev == kLbLF || prev == kLbNL:
+		return true, true
+	// LB6, LB7
+	case cur == kLbBK || cur == kLbCR || cur == kLbLF || cur == kLbNL:
+		return false, false
+	case cur == kLbSP || cur == kLbZW:
+		return false, false
+	// LB8, LB8a
+	case before_sp == kLbZW:
+		return true, false
+	case prev_zwj:
+		return false, false
+	// LB9, the marks following a base.
+	case (cur == kLbCM || cur == kLbZWJ) && !lb_is_break_or_space(prev):
+		return false, false
+	}
+	if cur == kLbCM || cur == kLbZWJ {
+		cur = kLbAL // LB10
+	}
+
+	switch {
+	// LB11, LB12, LB12a
+	case cur == kLbWJ || prev == kLbWJ:
+		return false, false
+	case prev == kLbGL:
+		return false, false
+	case cur == kLbGL && prev != kLbSP && prev != kLbBA && prev != kLbHY:
+		return false, false
+	// LB13
+	case cur == kLbCL || cur == kLbCP || cur == kLbEX || cur == kLbIS || cur == kLbSY:
+		return false, false
+	// LB14 to LB17, over the spaces.
+	case before_sp == kLbOP:
+		return false, false
+	case before_sp == kLbQU && cur == kLbOP:
+		return false, false
+	case (before_sp == kLbCL || before_sp == kLbCP) && cur == kLbNS:
+		return false, false
+	case before_sp == kLbB2 && cur == kLbB2:
+		return false, false
+	// LB18
+	case prev == kLbSP:
+		return true, false
+	// LB19, LB20
+	case cur == kLbQU || prev == kLbQU:
+		return false, false
+	case cur == kLbCB || prev == kLbCB:
+		return true, false
+	// LB21, LB22
+	case cur == kLbBA || cur == kLbHY || cur == kLbNS || prev == kLbBB:
+		return false, false
+	case cur == kLbIN:
+		return false, false
+	// LB23 to LB25
+	case prev == kLbAL && cur == kLbNU, prev == kLbNU && cur == kLbAL:
+		return false, false
+	case prev == kLbPR && cur == kLbID, prev == kLbID && cur == kLbPO:
+		return false, false
+	case (prev == kLbPR || prev == kLbPO) && cur == kLbAL,
+		prev == kLbAL && (cur == kLbPR || cur == kLbPO):
+		return false, false
+	case (prev == kLbPR || prev == kLbPO) && (cur == kLbNU || cur == kLbOP),
+		(prev == kLbOP || prev == kLbHY) && cur == kLbNU,
+		prev == kLbNU && (cur == kLbNU || cur == kLbPO || cur == kLbPR),
+		(prev == kLbSY || prev == kLbIS) && cur == kLbNU,
+		(prev == kLbCL || prev == kLbCP) && (cur == kLbPO || cur == kLbPR):
+		return false, false
+	// LB28 to LB30
+	case prev == kLbAL && cur == kLbAL:
+		return false, false
+	case prev == kLbIS && cur == kLbAL:
+		return false, false
+	case (prev == kLbAL || prev == kLbNU) && cur == kLbOP,
+		prev == kLbCP && (cur == kLbAL || cur == kLbNU):
+		return false, false
+	// LB30a, the flags are pairs.
+	case prev == kLbRI && cur == kLbRI && ri_count%2 == 1:
+		return false, false
+	}
+	// LB31
+	return true, false
+}
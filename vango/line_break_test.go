@@ -0,0 +1,60 @@
+package vango
+
+import (
+	"reflect"
+	"testing"
+)
+
+// split_lines cuts |text| at its break opportunities, the mandatory breaks
+// are marked by "!".
+func split_lines(text string) []string {
+	var pieces []string
+	start := 0
+	for _, b := range line_breaks(text) {
+		piece := text[start:b.pos]
+		if b.mandatory {
+			piece += "!"
+		}
+		pieces = append(pieces, piece)
+		start = b.pos
+	}
+	return pieces
+}
+
+func TestLineBreaks(t *testing.T) {
+	cases := []struct {
+		text string
+		want []string
+	}{
+		{"", nil},
+		{"hello", []string{"hello!"}},
+		{"hello  world", []string{"hello  ", "world!"}},
+		// No break before the closing punctuation, after the opening one, or
+		// inside the numbers.
+		{"(a, b). c!", []string{"(a, ", "b). ", "c!!"}},
+		{"$1,000.50 and 20%", []string{"$1,000.50 ", "and ", "20%!"}},
+		// The break after the hyphen, but not before a number.
+		{"well-known -5", []string{"well-", "known ", "-5!"}},
+		// The hard breaks, CR LF is one break.
+		{"a\nb\r\nc\r", []string{"a\n!", "b\r\n!", "c\r!"}},
+		{"a b", []string{"a !", "b!"}},
+		// The non breaking space and the word joiner glue, the zero width
+		// space breaks.
+		{"a b c⁠d e​f", []string{"a b ", "c⁠d ", "e​", "f!"}},
+		// The ideographs break between each other, but not before the
+		// closing punctuation or the small kana.
+		{"日本語。ちょっと", []string{"日", "本", "語。", "ちょっ", "と!"}},
+		{"漢字abc", []string{"漢", "字", "abc!"}},
+		// The combining marks stay with their base.
+		{"é ́x", []string{"é ", "́x!"}},
+		// The quotes don't break on either side.
+		{"say \"hi\" now", []string{"say ", "\"hi\" ", "now!"}},
+		// The flags are the pairs of regional indicators.
+		{"\U0001F1EF\U0001F1F5\U0001F1FA\U0001F1F8", []string{"\U0001F1EF\U0001F1F5", "\U0001F1FA\U0001F1F8!"}},
+	}
+	for _, c := range cases {
+		if got := split_lines(c.text); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("line breaks of %q: got %q, want %q", c.text, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright 2014 By Jshi. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vango
+
+import (
+	"image"
+	"math"
+)
+
+// Matrix is a 2x3 affine matrix. It maps the point (x, y) to
+// (A*x + C*y + E, B*x + D*y + F), the same layout as the html canvas and svg.
+type Matrix struct {
+	A, B, C, D, E, F float64
+}
+
+func IdentityMatrix() Matrix {
+	return Matrix{1, 0, 0, 1, 0, 0}
+}
+
+func TranslateMatrix(tx, ty float64) Matrix {
+	return Matrix{1, 0, 0, 1, tx, ty}
+}
+
+func ScaleMatrix(sx, sy float64) Matrix {
+	return Matrix{sx, 0, 0, sy, 0, 0}
+}
+
+// RotateMatrix returns the matrix rotating by |angle| in radians. A positive
+// angle rotates clockwise on the screen, since the y axis points down.
+func RotateMatrix(angle float64) Matrix {
+	s, c := math.Sincos(angle)
+	return Matrix{c, s, -s, c, 0, 0}
+}
+
+// Multiply returns m * n, which maps a point by n first, then by m.
+func (m Matrix) Multiply(n Matrix) Matrix {
+	return Matrix{
+		A: m.A*n.A + m.C*n.B,
+		B: m.B*n.A + m.D*n.B,
+		C: m.A*n.C + m.C*n.D,
+		D: m.B*n.C + m.D*n.D,
+		E: m.A*n.E + m.C*n.F + m.E,
+		F: m.B*n.E + m.D*n.F + m.F,
+	}
+}
+
+func (m Matrix) Determinant() float64 {
+	return m.A*m.D - m.B*m.C
+}
+
+// Invert returns the inverse of m. The ok is false if m is singular.
+func (m Matrix) Invert() (inv Matrix, ok bool) {
+	det := m.Determinant()
+	if det == 0 || math.IsNaN(det) || math.IsInf(det, 0) {
+		return Matrix{}, false
+	}
+	inv.A = m.D / det
+	inv.B = -m.B / det
+	inv.C = -m.C / det
+	inv.D = m.A / det
+	inv.E = (m.C*m.F - m.D*m.E) / det
+	inv.F = (m.B*m.E - m.A*m.F) / det
+	return inv, true
+}
+
+func (m Matrix) TransformPoint(x, y float64) (float64, float64) {
+	return m.A*x + m.C*y + m.E, m.B*x + m.D*y + m.F
+}
+
+// TransformVector maps (dx, dy) without the translation.
+func (m Matrix) TransformVector(dx, dy float64) (float64, float64) {
+	return m.A*dx + m.C*dy, m.B*dx + m.D*dy
+}
+
+func (m Matrix) IsIdentity() bool {
+	return m == IdentityMatrix()
+}
+
+// is_translation reports whether m only translates.
+func (m Matrix) is_translation() bool {
+	return m.A == 1 && m.B == 0 && m.C == 0 && m.D == 1
+}
+
+// int_translation returns the offset if m only translates by whole pixels,
+// where the pixels can be copied without resampling.
+func (m Matrix) int_translation() (dx, dy int, ok bool) {
+	if !m.is_translation() || m.E != math.Floor(m.E) || m.F != math.Floor(m.F) {
+		return 0, 0, false
+	}
+	return int(m.E), int(m.F), true
+}
+
+// inverse_bounds returns the bounds of the points m maps into |rect|, empty
+// if m is singular.
+func (m Matrix) inverse_bounds(rect image.Rectangle) image.Rectangle {
+	inv, ok := m.Invert()
+	if !ok || rect.Empty() {
+		return image.ZR
+	}
+	x0, y0 := math.Inf(1), math.Inf(1)
+	x1, y1 := math.Inf(-1), math.Inf(-1)
+	for _, p := range []image.Point{rect.Min, {rect.Max.X, rect.Min.Y}, rect.Max, {rect.Min.X, rect.Max.Y}} {
+		x, y := inv.TransformPoint(float64(p.X), float64(p.Y))
+		x0, y0 = math.Min(x0, x), math.Min(y0, y)
+		x1, y1 = math.Max(x1, x), math.Max(y1, y)
+	}
+	// Clamped, since a tiny scale maps the rect far away.
+	clamp := func(v float64) int { return int(math.Max(-1<<30, math.Min(1<<30, v))) }
+	return image.Rect(clamp(math.Floor(x0)), clamp(math.Floor(y0)), clamp(math.Ceil(x1)), clamp(math.Ceil(y1)))
+}
+
+// scale_factor returns the average scale of m, used to scale the lengths like
+// the line width.
+func (m Matrix) scale_factor() float64 {
+	return math.Sqrt(math.Abs(m.Determinant()))
+}
@@ -0,0 +1,74 @@
+// Copyright 2014 By Jshi. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vango
+
+import (
+	"image"
+)
+
+// There is no native window system for the headless backend. The native
+// context is just the in-memory canvas which plays the role of the screen.
+type NativeContext *Canvas
+
+type NativeCanvas struct {
+	*Canvas
+}
+
+func NewNativeCanvas(bounds image.Rectangle) *NativeCanvas {
+	var w, h = bounds.Dx(), bounds.Dy()
+	if w <= 0 || h <= 0 {
+		w, h = 1, 1
+	}
+
+	var c NativeCanvas
+	c.Canvas = NewCanvas(w, h)
+	return &c
+}
+
+func (c *NativeCanvas) Opaque() bool {
+	return true
+}
+
+func (c *NativeCanvas) Release() bool {
+	return true
+}
+
+func (c *NativeCanvas) BeginPaint() NativeContext {
+	return NativeContext(c.Canvas)
+}
+
+func (c *NativeCanvas) EndPaint() {
+	return
+}
+
+func (c *NativeCanvas) BlitToNativeContext(nc NativeContext, x int, y int, srcRc *image.Rectangle) {
+	var dst = (*Canvas)(nc)
+	if dst == nil {
+		return
+	}
+
+	if srcRc == nil {
+		var tempRc = image.Rect(0, 0, c.W(), c.H())
+		srcRc = &tempRc
+	}
+
+	// The copy rect in the src canvas and the dst canvas.
+	r0 := srcRc.Intersect(c.LocalBounds())
+	r1 := r0.Sub(srcRc.Min).Add(image.Pt(x, y)).Intersect(dst.LocalBounds())
+	if r1.Empty() {
+		return
+	}
+	r0.Min = r0.Min.Add(r1.Min.Sub(image.Pt(x, y)))
+
+	i0, i1 := c.PixOffset(r0.Min.X, r0.Min.Y), dst.PixOffset(r1.Min.X, r1.Min.Y)
+	s0, s1 := c.Stride(), dst.Stride()
+	p0, p1 := c.Pix(), dst.Pix()
+
+	for y := 0; y < r1.Dy(); y++ {
+		copy(p1[i1:i1+r1.Dx()*4], p0[i0:i0+r1.Dx()*4])
+		i0 = i0 + s0
+		i1 = i1 + s1
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright 2014 By Jshi. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vango
+
+import (
+	"errors"
+	"image"
+)
+
+// Insets are the distances from the four edges of a rect.
+type Insets struct {
+	Left, Top, Right, Bottom int
+}
+
+// Inset returns |rect| shrunk by the insets.
+func (in Insets) Inset(rect image.Rectangle) image.Rectangle {
+	return image.Rect(rect.Min.X+in.Left, rect.Min.Y+in.Top,
+		rect.Max.X-in.Right, rect.Max.Y-in.Bottom)
+}
+
+type NinePatchMode int
+
+// The modes decide how the edges and the center fill their rects.
+const (
+	NinePatchStretch NinePatchMode = iota // Scales the parts to the rects.
+	NinePatchTile                         // Repeats the parts at their size.
+)
+
+// NinePatch is an image split into nine parts by the |Insets|. The corners
+// are drawn at their size, the edges and the center fill the rest by the
+// |Mode|. The |Padding| is where the content goes in the drawn rect.
+type NinePatch struct {
+	Canvas  *Canvas
+	Insets  Insets
+	Padding Insets
+	Mode    NinePatchMode
+}
+
+// NewNinePatch returns the nine patch of |canvas| split by |insets|, the
+// padding is the insets too.
+func NewNinePatch(canvas *Canvas, insets Insets) *NinePatch {
+	return &NinePatch{Canvas: canvas, Insets: insets, Padding: insets}
+}
+
+// NinePatchFromCanvas returns the nine patch of the Android convention. The
+// canvas has a 1 pixel border, where the black pixels on the top and the left
+// mark the stretched part, and the ones on the bottom and the right mark the
+// content. The border is cut off from the returned canvas.
+func NinePatchFromCanvas(canvas *Canvas) (*NinePatch, error) {
+	w, h := canvas.W(), canvas.H()
+	if w < 3 || h < 3 {
+		return nil, errors.New("vango nine patch smaller than its border")
+	}
+	if canvas.Format() != PixelFormatBGRA8 {
+		canvas = canvas.ConvertTo(PixelFormatBGRA8)
+	}
+
+	// The black pixels are the marks, the transparent ones are not.
+	is_mark := func(x, y int) bool {
+		p := canvas.Pix()[canvas.PixOffset(x, y):]
+		return p[3] == 0xff && p[0] == 0 && p[1] == 0 && p[2] == 0
+	}
+
+	// marks returns the first and the end of the marks on the border line,
+	// in the coordinate without the border. The marks apart are taken as one
+	// span from the first to the last.
+	marks := func(n int, at func(i int) bool) (int, int, bool) {
+		first, end := -1, -1
+		for i := 1; i < n-1; i++ {
+			if at(i) {
+				if first < 0 {
+					first = i - 1
+				}
+				end = i
+			}
+		}
+		return first, end, first >= 0
+	}
+
+	np := &NinePatch{Canvas: canvas.SubCanvas(image.Rect(1, 1, w-1, h-1))}
+	cw, ch := w-2, h-2
+
+	x0, x1, ok_x := marks(w, func(i int) bool { return is_mark(i, 0) })
+	y0, y1, ok_y := marks(h, func(i int) bool { return is_mark(0, i) })
+	if !ok_x || !ok_y {
+		return nil, errors.New("vango nine patch without stretch marks")
+	}
+	np.Insets = Insets{x0, y0, cw - x1, ch - y1}
+
+	// The content is the stretched part if it isn't marked.
+	np.Padding = np.Insets
+	if x0, x1, ok := marks(w, func(i int) bool { return is_mark(i, h-1) }); ok {
+		np.Padding.Left, np.Padding.Right = x0, cw-x1
+	}
+	if y0, y1, ok := marks(h, func(i int) bool { return is_mark(w-1, i) }); ok {
+		np.Padding.Top, np.Padding.Bottom = y0, ch-y1
+	}
+	return np, nil
+}
+
+// Size returns the size of the nine patch image.
+func (np *NinePatch) Size() image.Point {
+	return np.Canvas.LocalBounds().Size()
+}
+
+// ContentRect returns the rect of the content when the nine patch is drawn
+// in |rect|.
+func (np *NinePatch) ContentRect(rect image.Rectangle) image.Rectangle {
+	return np.Padding.Inset(rect)
+}
+
+// split_nine returns the lines splitting |rect| into nine parts by the
+// insets. The insets are scaled down if the rect is too small for them.
+func split_nine(rect image.Rectangle, in Insets) (xs, ys [4]int) {
+	fit := func(a, b, n int) (int, int) {
+		if a+b > n && a+b > 0 {
+			a = a * n / (a + b)
+			b = n - a
+		}
+		return a, b
+	}
+	l, r := fit(in.Left, in.Right, rect.Dx())
+	t, b := fit(in.Top, in.Bottom, rect.Dy())
+	xs = [4]int{rect.Min.X, rect.Min.X + l, rect.Max.X - r, rect.Max.X}
+	ys = [4]int{rect.Min.Y, rect.Min.Y + t, rect.Max.Y - b, rect.Max.Y}
+	return xs, ys
+}
+
+// DrawNinePatch draws |np| in the |rect| in the user space. The corners keep
+// their size, unless the rect is smaller than them, the edges and the center
+// are stretched or tiled by the mode of the nine patch.
+func (c *CanvasContext) DrawNinePatch(np *NinePatch, rect image.Rectangle) {
+	draw_nine_patch(c, np, rect)
+}
+
+// draw_nine_patch draws |np| by the image draws of |c|.
+func draw_nine_patch(c Context, np *NinePatch, rect image.Rectangle) {
+	if np == nil || np.Canvas == nil || rect.Empty() {
+		return
+	}
+
+	sx, sy := split_nine(np.Canvas.LocalBounds(), np.Insets)
+	dx, dy := split_nine(rect, np.Insets)
+	for j := 0; j < 3; j++ {
+		for i := 0; i < 3; i++ {
+			src := image.Rect(sx[i], sy[j], sx[i+1], sy[j+1])
+			dst := image.Rect(dx[i], dy[j], dx[i+1], dy[j+1])
+			if src.Empty() || dst.Empty() {
+				continue
+			}
+			// The corners are never tiled.
+			if np.Mode == NinePatchTile && (i == 1 || j == 1) {
+				draw_tiled(c, dst, np.Canvas, src)
+			} else {
+				c.DrawStretch(dst, np.Canvas, src)
+			}
+		}
+	}
+}
+
+// draw_tiled repeats the |src_rect| of |src| from the top left of |dst_rect|,
+// clipped to |dst_rect|.
+func draw_tiled(c Context, dst_rect image.Rectangle, src *Canvas, src_rect image.Rectangle) {
+	c.SaveState()
+	defer c.RestoreState()
+
+	c.ClipRect(dst_rect)
+	w, h := src_rect.Dx(), src_rect.Dy()
+	for y := dst_rect.Min.Y; y < dst_rect.Max.Y; y += h {
+		for x := dst_rect.Min.X; x < dst_rect.Max.X; x += w {
+			c.AlphaBlend(x, y, src, src_rect)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package vango
+
+import (
+	"image"
+	"testing"
+)
+
+func set_pixel(c *Canvas, x, y int, b, g, r, a byte) {
+	copy(c.Pix()[c.PixOffset(x, y):], []byte{b, g, r, a})
+}
+
+func TestNinePatchFromCanvas(t *testing.T) {
+	canvas := NewCanvas(12, 12)
+	for i := 4; i < 8; i++ {
+		set_pixel(canvas, i, 0, 0, 0, 0, 0xff) // stretch x 3..7
+		set_pixel(canvas, 0, i, 0, 0, 0, 0xff) // stretch y 3..7
+	}
+	for i := 2; i < 10; i++ {
+		set_pixel(canvas, i, 11, 0, 0, 0, 0xff) // content x 1..9
+	}
+
+	np, err := NinePatchFromCanvas(canvas)
+	if err != nil {
+		t.Fatalf("NinePatchFromCanvas: %v", err)
+	}
+	if np.Size() != image.Pt(10, 10) {
+		t.Errorf("the border isn't cut off: got size %v", np.Size())
+	}
+	if np.Insets != (Insets{3, 3, 3, 3}) {
+		t.Errorf("Insets: got %v, want {3 3 3 3}", np.Insets)
+	}
+	if np.Padding != (Insets{1, 3, 1, 3}) {
+		t.Errorf("Padding: got %v, want {1 3 1 3}", np.Padding)
+	}
+	if got := np.ContentRect(image.Rect(0, 0, 50, 20)); got != image.Rect(1, 3, 49, 17) {
+		t.Errorf("ContentRect: got %v", got)
+	}
+
+	if _, err := NinePatchFromCanvas(NewCanvas(12, 12)); err == nil {
+		t.Errorf("NinePatchFromCanvas without the marks: got no error")
+	}
+}
+
+func TestDrawNinePatch(t *testing.T) {
+	// Red corners of 2x2, green elsewhere.
+	src := NewCanvas(6, 6)
+	for y := 0; y < 6; y++ {
+		for x := 0; x < 6; x++ {
+			if (x < 2 || x >= 4) && (y < 2 || y >= 4) {
+				set_pixel(src, x, y, 0, 0, 0xff, 0xff)
+			} else {
+				set_pixel(src, x, y, 0, 0xff, 0, 0xff)
+			}
+		}
+	}
+	np := NewNinePatch(src, Insets{2, 2, 2, 2})
+
+	ctxt, canvas := new_test_context(30, 20)
+	ctxt.DrawNinePatch(np, image.Rect(5, 0, 25, 20))
+	for _, pt := range []image.Point{{5, 0}, {6, 1}, {24, 19}, {23, 18}, {24, 0}, {5, 19}} {
+		if !is_painted(canvas, pt.X, pt.Y) {
+			t.Errorf("the corner at %v isn't red", pt)
+		}
+	}
+	for _, pt := range []image.Point{{7, 2}, {15, 10}, {15, 0}, {5, 10}, {22, 17}} {
+		if _, g, _, _ := pixel_at(canvas, pt.X, pt.Y); g != 0xff {
+			t.Errorf("the edge or the center at %v isn't green", pt)
+		}
+	}
+	if _, _, _, a := pixel_at(canvas, 4, 10); a != 0 {
+		t.Errorf("outside the rect is painted")
+	}
+
+	// A rect smaller than the corners shrinks them.
+	ctxt, canvas = new_test_context(4, 4)
+	ctxt.DrawNinePatch(np, image.Rect(0, 0, 3, 3))
+	if !is_painted(canvas, 0, 0) || !is_painted(canvas, 2, 2) {
+		t.Errorf("the shrunk corners aren't red")
+	}
+}
+
+func TestDrawNinePatchTile(t *testing.T) {
+	// The center is a red column and a blue column.
+	src := NewCanvas(4, 3)
+	for y := 0; y < 3; y++ {
+		set_pixel(src, 1, y, 0, 0, 0xff, 0xff)
+		set_pixel(src, 2, y, 0xff, 0, 0, 0xff)
+	}
+	np := NewNinePatch(src, Insets{1, 1, 1, 1})
+	np.Mode = NinePatchTile
+
+	ctxt, canvas := new_test_context(12, 3)
+	ctxt.DrawNinePatch(np, canvas.LocalBounds())
+	for x := 1; x < 11; x++ {
+		r, _, b, _ := pixel_at(canvas, x, 1)
+		if want := x%2 == 1; (r == 0xff) != want || (b == 0xff) == want {
+			t.Errorf("the tile at x=%v: got r=%v b=%v", x, r, b)
+		}
+	}
+}
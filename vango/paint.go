@@ -0,0 +1,329 @@
+// Copyright 2014 By Jshi. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vango
+
+import (
+	"math"
+	"sort"
+)
+
+// Paint is the source of the colors for the fills, the strokes and the
+// text. It's one of SolidPaint, *LinearGradient, *RadialGradient and
+// *ConicGradient. The coordinates of a gradient are in the user space when
+// it's used to draw, so it follows the current transform.
+type Paint interface {
+	new_shader(m Matrix, dither bool) shader_t
+}
+
+// shader_t computes the colors of a paint in the canvas coordinate.
+type shader_t interface {
+	// shade_row fills |row| with the colors of the pixels from (x, y) to the
+	// right. The colors are 4 bytes in the canvas order (b, g, r, a) and
+	// premultiplied.
+	shade_row(x, y int, row []byte)
+}
+
+// SolidPaint paints a single color.
+type SolidPaint struct {
+	R, G, B, A byte
+}
+
+func NewSolidPaint(r, g, b, a byte) SolidPaint {
+	return SolidPaint{r, g, b, a}
+}
+
+func (p SolidPaint) new_shader(m Matrix, dither bool) shader_t {
+	clr := premul_color(pack_color(p.R, p.G, p.B, p.A))
+	return solid_shader_t{byte(clr[0]), byte(clr[1]), byte(clr[2]), byte(clr[3])}
+}
+
+type solid_shader_t [4]byte
+
+func (s solid_shader_t) shade_row(x, y int, row []byte) {
+	for i := 0; i < len(row); i += 4 {
+		copy(row[i:i+4], s[:])
+	}
+}
+
+type SpreadMode int
+
+// The spread modes decide the colors outside the [0, 1] of a gradient.
+const (
+	SpreadPad     SpreadMode = iota // Extends the colors at the ends.
+	SpreadRepeat                    // Repeats the gradient.
+	SpreadReflect                   // Repeats the gradient and its mirror.
+)
+
+type ColorStop struct {
+	Offset     float64
+	R, G, B, A byte
+}
+
+// Gradient is the color stops and the spread mode shared by the gradients.
+type Gradient struct {
+	stops  []ColorStop
+	spread SpreadMode
+}
+
+// AddColorStop adds a color at the |offset| in [0, 1]. The stops are kept in
+// the order of the offsets. Two stops at the same offset make a hard edge.
+func (gr *Gradient) AddColorStop(offset float64, r, g, b, a byte) {
+	offset = math.Max(0, math.Min(1, offset))
+	i := sort.Search(len(gr.stops), func(i int) bool {
+		return gr.stops[i].Offset > offset
+	})
+	gr.stops = append(gr.stops, ColorStop{})
+	copy(gr.stops[i+1:], gr.stops[i:])
+	gr.stops[i] = ColorStop{offset, r, g, b, a}
+}
+
+func (gr *Gradient) ColorStops() []ColorStop {
+	return gr.stops
+}
+
+func (gr *Gradient) SetSpread(spread SpreadMode) {
+	gr.spread = spread
+}
+
+func (gr *Gradient) Spread() SpreadMode {
+	return gr.spread
+}
+
+// spread_t maps |t| into [0, 1] by the spread mode.
+func (gr *Gradient) spread_t(t float64) float64 {
+	switch gr.spread {
+	case SpreadRepeat:
+		return t - math.Floor(t)
+	case SpreadReflect:
+		t = math.Abs(t)
+		t = t - 2*math.Floor(t/2)
+		if t > 1 {
+			t = 2 - t
+		}
+		return t
+	}
+	return math.Max(0, math.Min(1, t))
+}
+
+// color_at returns the premultiplied color at |t| in [0, 1], with the
+// channels in [0, 255]. The stops are interpolated in the premultiplied
+// space, so a stop fading to transparent doesn't turn dark.
+func (gr *Gradient) color_at(t float64) (r, g, b, a float64) {
+	n := len(gr.stops)
+	if n == 0 {
+		return 0, 0, 0, 0
+	}
+
+	premul := func(s ColorStop) (float64, float64, float64, float64) {
+		a := float64(s.A)
+		return float64(s.R) * a / 255, float64(s.G) * a / 255, float64(s.B) * a / 255, a
+	}
+
+	if t <= gr.stops[0].Offset {
+		return premul(gr.stops[0])
+	}
+	if t >= gr.stops[n-1].Offset {
+		return premul(gr.stops[n-1])
+	}
+
+	i := sort.Search(n, func(i int) bool { return gr.stops[i].Offset > t }) - 1
+	s0, s1 := gr.stops[i], gr.stops[i+1]
+	k := (t - s0.Offset) / (s1.Offset - s0.Offset)
+	r0, g0, b0, a0 := premul(s0)
+	r1, g1, b1, a1 := premul(s1)
+	return r0 + k*(r1-r0), g0 + k*(g1-g0), b0 + k*(b1-b0), a0 + k*(a1-a0)
+}
+
+// kDitherMatrix is the 4x4 Bayer matrix of the ordered dithering.
+var kDitherMatrix = [4][4]float64{
+	{0, 8, 2, 10},
+	{12, 4, 14, 6},
+	{3, 11, 1, 9},
+	{15, 7, 13, 5},
+}
+
+// gradient_shader_t shades a gradient by the parameter |t_at| of the points
+// in the gradient space.
+type gradient_shader_t struct {
+	gradient *Gradient
+	inv      Matrix // from the canvas to the gradient space.
+	t_at     func(x, y float64) float64
+	dither   bool
+}
+
+func new_gradient_shader(gradient *Gradient, m Matrix, dither bool,
+	t_at func(x, y float64) float64) shader_t {
+	inv, ok := m.Invert()
+	if !ok {
+		// Nothing is visible with a singular transform.
+		return solid_shader_t{}
+	}
+	return &gradient_shader_t{gradient, inv, t_at, dither}
+}
+
+func (s *gradient_shader_t) shade_row(x, y int, row []byte) {
+	for i := 0; i < len(row); i, x = i+4, x+1 {
+		u, v := s.inv.TransformPoint(float64(x)+0.5, float64(y)+0.5)
+		t := s.gradient.spread_t(s.t_at(u, v))
+		r, g, b, a := s.gradient.color_at(t)
+
+		d := 0.5
+		if s.dither {
+			d = (kDitherMatrix[y&3][x&3] + 0.5) / 16
+		}
+
+		// Dither the premultiplied channels, a channel never exceeds the
+		// alpha.
+		pa := math.Min(255, math.Floor(a+d))
+		row[i+3] = byte(pa)
+		row[i+0] = byte(math.Min(pa, math.Floor(b+d)))
+		row[i+1] = byte(math.Min(pa, math.Floor(g+d)))
+		row[i+2] = byte(math.Min(pa, math.Floor(r+d)))
+	}
+}
+
+// LinearGradient changes the color along the line from (X0, Y0) to (X1, Y1).
+type LinearGradient struct {
+	Gradient
+	X0, Y0, X1, Y1 float64
+}
+
+func NewLinearGradient(x0, y0, x1, y1 float64) *LinearGradient {
+	return &LinearGradient{X0: x0, Y0: y0, X1: x1, Y1: y1}
+}
+
+func (lg *LinearGradient) new_shader(m Matrix, dither bool) shader_t {
+	dx, dy := lg.X1-lg.X0, lg.Y1-lg.Y0
+	l := dx*dx + dy*dy
+	return new_gradient_shader(&lg.Gradient, m, dither, func(x, y float64) float64 {
+		if l == 0 {
+			return 0
+		}
+		return ((x-lg.X0)*dx + (y-lg.Y0)*dy) / l
+	})
+}
+
+// RadialGradient changes the color from the focal point to the circle at
+// (CX, CY) with the radius R. The focal point is the center by default.
+type RadialGradient struct {
+	Gradient
+	CX, CY, R float64
+	FX, FY    float64
+}
+
+func NewRadialGradient(cx, cy, r float64) *RadialGradient {
+	return &RadialGradient{CX: cx, CY: cy, R: r, FX: cx, FY: cy}
+}
+
+func (rg *RadialGradient) SetFocalPoint(fx, fy float64) {
+	rg.FX, rg.FY = fx, fy
+}
+
+func (rg *RadialGradient) new_shader(m Matrix, dither bool) shader_t {
+	r := math.Abs(rg.R)
+	fx, fy := rg.FX, rg.FY
+
+	// Like svg, the focal point outside the circle is moved onto it.
+	ex, ey := fx-rg.CX, fy-rg.CY
+	if d := math.Hypot(ex, ey); d > r*0.999 {
+		k := r * 0.999 / d
+		ex, ey = ex*k, ey*k
+		fx, fy = rg.CX+ex, rg.CY+ey
+	}
+	cc := ex*ex + ey*ey - r*r
+
+	return new_gradient_shader(&rg.Gradient, m, dither, func(x, y float64) float64 {
+		// The t of (x, y) is |p - f| / |q - f|, where q is where the ray from
+		// the focal point through p meets the circle.
+		dx, dy := x-fx, y-fy
+		a := dx*dx + dy*dy
+		if a == 0 || r == 0 {
+			return 0
+		}
+		b := ex*dx + ey*dy
+		s := (-b + math.Sqrt(b*b-a*cc)) / a
+		if s <= 0 {
+			return 1
+		}
+		return 1 / s
+	})
+}
+
+// ConicGradient changes the color around (CX, CY), clockwise from the
+// |Angle| in radians.
+type ConicGradient struct {
+	Gradient
+	CX, CY, Angle float64
+}
+
+func NewConicGradient(cx, cy, angle float64) *ConicGradient {
+	return &ConicGradient{CX: cx, CY: cy, Angle: angle}
+}
+
+func (cg *ConicGradient) new_shader(m Matrix, dither bool) shader_t {
+	return new_gradient_shader(&cg.Gradient, m, dither, func(x, y float64) float64 {
+		t := (math.Atan2(y-cg.CY, x-cg.CX) - cg.Angle) / (2 * math.Pi)
+		return t - math.Floor(t)
+	})
+}
+
+// SetFillPaint sets the paint used by Fill and FillRect. SetFillColor sets a
+// solid paint.
+func (c *CanvasContext) SetFillPaint(paint Paint) {
+	c.fill_paint = paint
+}
+
+func (c *CanvasContext) FillPaint() Paint {
+	if c.fill_paint == nil {
+		r, g, b, a := unpack_rgba(c.fill_color)
+		return SolidPaint{r, g, b, a}
+	}
+	return c.fill_paint
+}
+
+// SetStrokePaint sets the paint used by Stroke and StrokeRect.
+func (c *CanvasContext) SetStrokePaint(paint Paint) {
+	c.stroke_paint = paint
+}
+
+func (c *CanvasContext) StrokePaint() Paint {
+	if c.stroke_paint == nil {
+		r, g, b, a := unpack_rgba(c.stroke_color)
+		return SolidPaint{r, g, b, a}
+	}
+	return c.stroke_paint
+}
+
+// SetFontPaint sets the paint used by DrawText.
+func (c *CanvasContext) SetFontPaint(paint Paint) {
+	c.font_paint = paint
+}
+
+func (c *CanvasContext) FontPaint() Paint {
+	if c.font_paint == nil {
+		r, g, b, a := unpack_rgba(c.font_color)
+		return SolidPaint{r, g, b, a}
+	}
+	return c.font_paint
+}
+
+// SetDither turns on the ordered dithering of the gradients, which hides the
+// banding of the slow gradients.
+func (c *CanvasContext) SetDither(dither bool) {
+	c.dither = dither
+}
+
+func (c *CanvasContext) Dither() bool {
+	return c.dither
+}
+
+// shader_of returns the shader of |paint| through the current transform, nil
+// for the solid color of the packed color.
+func (c *CanvasContext) shader_of(paint Paint) shader_t {
+	if paint == nil {
+		return nil
+	}
+	return paint.new_shader(c.transform, c.dither)
+}
@@ -0,0 +1,156 @@
+package vango
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+func TestGradientSpread(t *testing.T) {
+	var gr Gradient
+	for _, c := range []struct {
+		spread SpreadMode
+		t      float64
+		want   float64
+	}{
+		{SpreadPad, -0.5, 0},
+		{SpreadPad, 1.5, 1},
+		{SpreadRepeat, 1.25, 0.25},
+		{SpreadRepeat, -0.25, 0.75},
+		{SpreadReflect, 1.25, 0.75},
+		{SpreadReflect, -0.25, 0.25},
+		{SpreadReflect, 2.25, 0.25},
+	} {
+		gr.SetSpread(c.spread)
+		if got := gr.spread_t(c.t); math.Abs(got-c.want) > 1e-9 {
+			t.Errorf("spread %v t %v: got %v, want %v", c.spread, c.t, got, c.want)
+		}
+	}
+}
+
+func TestGradientColorStops(t *testing.T) {
+	var gr Gradient
+	gr.AddColorStop(1, 0, 0, 0xff, 0xff)
+	gr.AddColorStop(0, 0xff, 0, 0, 0xff)
+	gr.AddColorStop(0.5, 0, 0xff, 0, 0xff)
+
+	stops := gr.ColorStops()
+	if len(stops) != 3 || stops[0].Offset != 0 || stops[1].Offset != 0.5 || stops[2].Offset != 1 {
+		t.Fatalf("the stops aren't sorted: %v", stops)
+	}
+
+	r, g, b, _ := gr.color_at(0.25)
+	if math.Abs(r-127.5) > 1e-9 || math.Abs(g-127.5) > 1e-9 || b != 0 {
+		t.Errorf("color_at(0.25): got (%v, %v, %v)", r, g, b)
+	}
+
+	// Fading to transparent keeps the color in the premultiplied space.
+	var fade Gradient
+	fade.AddColorStop(0, 0xff, 0, 0, 0xff)
+	fade.AddColorStop(1, 0, 0, 0, 0)
+	s := &gradient_shader_t{&fade, IdentityMatrix(),
+		func(x, y float64) float64 { return x / 10 }, false}
+	row := make([]byte, 4)
+	s.shade_row(5, 0, row)
+	if row[2] != row[3] || row[3] < 0x70 || row[3] > 0x80 {
+		t.Errorf("the middle of the fade: got %v, want premultiplied red with half alpha", row)
+	}
+}
+
+func TestLinearGradientFill(t *testing.T) {
+	ctxt, canvas := new_test_context(100, 10)
+	gradient := NewLinearGradient(0, 0, 100, 0)
+	gradient.AddColorStop(0, 0, 0, 0, 0xff)
+	gradient.AddColorStop(1, 0xff, 0, 0, 0xff)
+	ctxt.SetFillPaint(gradient)
+	ctxt.FillRect(canvas.LocalBounds())
+
+	r0, _, _, _ := pixel_at(canvas, 5, 5)
+	r1, _, _, _ := pixel_at(canvas, 50, 5)
+	r2, _, _, _ := pixel_at(canvas, 95, 5)
+	if !(r0 < r1 && r1 < r2) || r1 < 0x78 || r1 > 0x88 {
+		t.Errorf("the gradient: got %v %v %v", r0, r1, r2)
+	}
+
+	// The gradient follows the transform.
+	ctxt, canvas = new_test_context(100, 10)
+	ctxt.Translate(50, 0)
+	ctxt.Scale(0.5, 1)
+	ctxt.SetFillPaint(gradient)
+	ctxt.FillRect(image.Rect(0, 0, 100, 10))
+	if r, _, _, _ := pixel_at(canvas, 75, 5); r < 0x78 || r > 0x88 {
+		t.Errorf("the transformed gradient: got r=%v, want about 0x80", r)
+	}
+
+	// SetFillColor replaces the paint.
+	ctxt.SetFillColor(0, 0xff, 0)
+	if _, ok := ctxt.FillPaint().(SolidPaint); !ok {
+		t.Errorf("FillPaint after SetFillColor: got %T", ctxt.FillPaint())
+	}
+}
+
+func TestRadialAndConicGradient(t *testing.T) {
+	ctxt, canvas := new_test_context(40, 40)
+	radial := NewRadialGradient(20, 20, 20)
+	radial.AddColorStop(0, 0xff, 0, 0, 0xff)
+	radial.AddColorStop(1, 0, 0, 0, 0xff)
+	ctxt.SetFillPaint(radial)
+	ctxt.FillRect(canvas.LocalBounds())
+
+	if r, _, _, _ := pixel_at(canvas, 20, 20); r < 0xf0 {
+		t.Errorf("the center of the radial gradient: got r=%v", r)
+	}
+	if r, _, _, _ := pixel_at(canvas, 30, 20); r < 0x70 || r > 0x90 {
+		t.Errorf("the middle of the radial gradient: got r=%v", r)
+	}
+	if r, _, _, _ := pixel_at(canvas, 1, 1); r != 0 {
+		t.Errorf("outside the radial gradient: got r=%v", r)
+	}
+
+	ctxt, canvas = new_test_context(40, 40)
+	conic := NewConicGradient(20, 20, 0)
+	conic.AddColorStop(0, 0, 0, 0, 0xff)
+	conic.AddColorStop(1, 0xff, 0, 0, 0xff)
+	ctxt.SetFillPaint(conic)
+	ctxt.FillRect(canvas.LocalBounds())
+
+	// Clockwise from the right: a quarter turn is down.
+	r0, _, _, _ := pixel_at(canvas, 20, 35)
+	r1, _, _, _ := pixel_at(canvas, 5, 20)
+	r2, _, _, _ := pixel_at(canvas, 20, 5)
+	if !(r0 < r1 && r1 < r2) {
+		t.Errorf("the conic gradient: got %v %v %v", r0, r1, r2)
+	}
+}
+
+func TestDither(t *testing.T) {
+	// A gradient too slow for 8 bits makes a single band without dithering.
+	gradient := NewLinearGradient(0, 0, 64, 0)
+	gradient.AddColorStop(0, 100, 100, 100, 0xff)
+	gradient.AddColorStop(1, 101, 101, 101, 0xff)
+
+	count := func(dither bool) int {
+		ctxt, canvas := new_test_context(16, 16)
+		ctxt.SetFillPaint(gradient)
+		ctxt.SetDither(dither)
+		ctxt.FillRect(canvas.LocalBounds())
+
+		n := 0
+		for y := 0; y < 16; y++ {
+			for x := 0; x < 16; x++ {
+				if r, _, _, _ := pixel_at(canvas, x, y); r == 101 {
+					n++
+				}
+			}
+		}
+		return n
+	}
+
+	// The first quarter of the gradient is a quarter of the way to 101.
+	if n := count(false); n != 0 {
+		t.Errorf("without dithering: got %v pixels of 101, want 0", n)
+	}
+	if n := count(true); n < 16 || n > 80 {
+		t.Errorf("with dithering: got %v pixels of 101, want about 32", n)
+	}
+}
@@ -0,0 +1,454 @@
+// Copyright 2014 By Jshi. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vango
+
+import (
+	"gwk/vango/freetype"
+	"image"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// TextAlign is the horizontal alignment of the lines of a Paragraph.
+type TextAlign int
+
+const (
+	TextAlignLeft TextAlign = iota
+	TextAlignRight
+	TextAlignCenter
+	// TextAlignJustify stretches the spaces of the wrapped lines to fill the
+	// width. The lines ended by a hard break are aligned to the left.
+	TextAlignJustify
+)
+
+// TextEllipsis is where the text cut by the MaxLines of a Paragraph is
+// replaced by an ellipsis.
+type TextEllipsis int
+
+const (
+	EllipsisNone TextEllipsis = iota
+	EllipsisEnd
+	EllipsisMiddle
+)
+
+// ParagraphStyle is the layout options of a Paragraph. The LineSpacing scales
+// the line height of the font, zero for 1. Zero MaxLines doesn't limit the
+// lines. The Fallbacks are the fonts of the runes the face has no glyph of,
+// by the names like SetFallbackFonts.
+type ParagraphStyle struct {
+	Align       TextAlign
+	LineSpacing float64
+	MaxLines    int
+	Ellipsis    TextEllipsis
+	Fallbacks   []string
+}
+
+// ParagraphLine is a line laid out by a Paragraph. X is the start of the line
+// and Y is its baseline, from the top left of the paragraph.
+type ParagraphLine struct {
+	Text  string
+	X     float64
+	Y     float64
+	Width float64
+
+	// start is the position of the line in the text of the paragraph.
+	start int
+	// hard is true if the line ends by a hard break, or the end of the text.
+	hard bool
+	// word_spacing is the space added to every space by the justification.
+	word_spacing float64
+}
+
+// Paragraph is the text broken into lines by the rules of UAX #14 and laid
+// out in a width. It is laid out once, then drawn and measured as many times
+// as needed.
+type Paragraph struct {
+	text      string
+	style     ParagraphStyle
+	font      *Font
+	width     float64
+	lines     []ParagraphLine
+	truncated bool
+	// size is the bounds of the lines, the width of the widest line and the
+	// height from the top of the first to the bottom of the last.
+	size_w, size_h float64
+}
+
+// NewParagraph returns |text| in the |face| of |size|, laid out without a
+// width limit, so only the hard breaks end the lines. The nil |face| is the
+// default font.
+func NewParagraph(text string, face *freetype.Font, size float64, style ParagraphStyle) *Paragraph {
+	if face == nil {
+		face = g_default_font
+	}
+	p := &Paragraph{
+		text:  text,
+		style: style,
+		font:  NewFont(),
+	}
+	p.font.SetFontFace(face)
+	p.font.SetFontSize(size)
+	p.font.SetFallbacks(fallbacks_by_names(style.Fallbacks))
+	p.Layout(0)
+	return p
+}
+
+// Text returns the text of the paragraph.
+func (p *Paragraph) Text() string {
+	return p.text
+}
+
+// Style returns the layout options of the paragraph.
+func (p *Paragraph) Style() ParagraphStyle {
+	return p.style
+}
+
+// Layout breaks the text into the lines not wider than |width|, at the break
+// opportunities, or between the characters of a word wider than |width|. The
+// |width| not above zero doesn't limit the lines.
+func (p *Paragraph) Layout(width float64) {
+	if width < 0 {
+		width = 0
+	}
+	p.width = width
+	p.lines = p.lines[:0]
+	p.truncated = false
+
+	p.break_lines()
+	if n := p.style.MaxLines; n > 0 && len(p.lines) > n {
+		p.truncate(n)
+	}
+	p.place_lines()
+}
+
+// Width returns the width of the widest line.
+func (p *Paragraph) Width() float64 {
+	return p.size_w
+}
+
+// Height returns the height from the ascent of the first line to the descent
+// of the last line.
+func (p *Paragraph) Height() float64 {
+	return p.size_h
+}
+
+// Size returns the width and the height of the paragraph, rounded up to the
+// whole pixels.
+func (p *Paragraph) Size() image.Point {
+	return image.Pt(int(math.Ceil(p.size_w)), int(math.Ceil(p.size_h)))
+}
+
+// LineCount returns the number of the lines.
+func (p *Paragraph) LineCount() int {
+	return len(p.lines)
+}
+
+// Lines returns a copy of the lines.
+func (p *Paragraph) Lines() []ParagraphLine {
+	return append([]ParagraphLine(nil), p.lines...)
+}
+
+// Truncated returns true if the lines after MaxLines are cut.
+func (p *Paragraph) Truncated() bool {
+	return p.truncated
+}
+
+// Draw draws the lines from the top left of |rect| with the font color of
+// |c|, laid out again first if the width of |rect| isn't the last width. The
+// lines below |rect| are drawn too, unless |c| clips them.
+func (p *Paragraph) Draw(c Context, rect image.Rectangle) error {
+	if width := float64(rect.Dx()); width != p.width {
+		p.Layout(width)
+	}
+	if len(p.lines) == 0 {
+		return nil
+	}
+
+	c.SaveState()
+	defer c.RestoreState()
+	c.SetFontFace(p.font.font)
+	c.SetFontSize(p.font.size)
+	c.SetFallbackFonts(p.style.Fallbacks...)
+
+	// DrawText puts the baseline below the top by the rounded ascent.
+	ascent := math.Floor(p.font.Metrics().Ascent + 0.5)
+	for _, line := range p.lines {
+		x, y := float64(rect.Min.X)+line.X, float64(rect.Min.Y)+line.Y-ascent
+		if line.word_spacing == 0 {
+			if err := p.draw_run(c, line.Text, x, y); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// The justified line is drawn by the words, moved by the spaces
+		// before them.
+		spaces := 0
+		for start := 0; start < len(line.Text); {
+			end := strings.IndexByte(line.Text[start:], ' ')
+			if end < 0 {
+				end = len(line.Text)
+			} else {
+				end += start
+			}
+			if end > start {
+				word_x := x + p.advance(line.Text[:start]) + float64(spaces)*line.word_spacing
+				if err := p.draw_run(c, line.Text[start:end], word_x, y); err != nil {
+					return err
+				}
+			}
+			if end < len(line.Text) {
+				spaces++
+			}
+			start = end + 1
+		}
+	}
+	return nil
+}
+
+func (p *Paragraph) draw_run(c Context, text string, x, y float64) error {
+	c.SaveState()
+	defer c.RestoreState()
+	c.Translate(x, y)
+	_, err := c.DrawText(text, image.Rect(0, 0, 0, 0))
+	return err
+}
+
+// break_lines fills the lines of the text by the greedy line breaking, the
+// line takes all the break opportunities that fit the width.
+func (p *Paragraph) break_lines() {
+	breaks := line_breaks(p.text)
+	start := 0
+	fit := -1 // The last break of the line, which fits the width.
+	for i := 0; i < len(breaks); {
+		b := breaks[i]
+		line := trim_line_end(p.text[start:b.pos])
+		if p.width == 0 || p.advance(line) <= p.width {
+			if b.mandatory {
+				p.lines = append(p.lines, ParagraphLine{Text: line, start: start, hard: true})
+				start, fit = b.pos, -1
+			} else {
+				fit = b.pos
+			}
+			i++
+			continue
+		}
+
+		if fit > start {
+			p.lines = append(p.lines, ParagraphLine{Text: trim_line_end(p.text[start:fit]), start: start})
+			start, fit = fit, -1
+			continue
+		}
+
+		// No break fits, the word is broken by the characters.
+		end := start + p.fit_prefix(p.text[start:b.pos], p.width)
+		line = p.text[start:end]
+		hard := false
+		if end == b.pos {
+			// The last piece of the word ends by the break.
+			line, hard = trim_line_end(line), b.mandatory
+			i++
+		}
+		p.lines = append(p.lines, ParagraphLine{Text: line, start: start, hard: hard})
+		start = end
+	}
+}
+
+// truncate keeps the first |n| lines, the last of which ends by the ellipsis
+// if the style has one.
+func (p *Paragraph) truncate(n int) {
+	p.truncated = true
+	last := p.lines[n-1]
+	p.lines = p.lines[:n]
+	if p.style.Ellipsis == EllipsisNone {
+		return
+	}
+
+	ellipsis := p.ellipsis()
+	if p.width == 0 {
+		// Without the width, the last line only shows it's continued.
+		p.lines[n-1] = ParagraphLine{Text: last.Text + ellipsis, start: last.start, hard: true}
+		return
+	}
+
+	// The rest of the text is joined into one line, from which the ellipsis
+	// line is cut.
+	rest := strings.Map(func(r rune) rune {
+		switch line_break_class(r) {
+		case kLbBK, kLbCR, kLbLF, kLbNL:
+			return ' '
+		}
+		return r
+	}, p.text[last.start:])
+	width := p.width - p.advance(ellipsis)
+
+	var text string
+	if p.style.Ellipsis == EllipsisMiddle {
+		text = p.middle_ellipsis(rest, ellipsis, width)
+	} else {
+		text = trim_line_end(rest[:p.fit_prefix(rest, width)]) + ellipsis
+	}
+	p.lines[n-1] = ParagraphLine{Text: text, start: last.start, hard: true}
+}
+
+// middle_ellipsis returns the head and the tail of |text| around |ellipsis|,
+// with the head and the tail not wider than |width| together. The narrower one
+// grows first, so they stay balanced.
+func (p *Paragraph) middle_ellipsis(text, ellipsis string, width float64) string {
+	head, tail := 0, len(text)
+	head_w, tail_w := 0.0, 0.0
+	for head < tail {
+		grown := false
+		for _, grow_head := range [2]bool{head_w <= tail_w, head_w > tail_w} {
+			if grow_head {
+				end := head + next_cluster(text[head:tail])
+				if w := p.advance(text[:end]); w+tail_w <= width {
+					head, head_w, grown = end, w, true
+					break
+				}
+			} else {
+				start := tail - prev_cluster(text[head:tail])
+				if w := p.advance(text[start:]); head_w+w <= width {
+					tail, tail_w, grown = start, w, true
+					break
+				}
+			}
+		}
+		if !grown {
+			break
+		}
+	}
+	return trim_line_end(text[:head]) + ellipsis + strings.TrimLeft(text[tail:], " ")
+}
+
+// place_lines aligns the lines and puts their baselines, then measures the
+// paragraph.
+func (p *Paragraph) place_lines() {
+	m := p.font.Metrics()
+	spacing := p.style.LineSpacing
+	if spacing == 0 {
+		spacing = 1
+	}
+	line_height := m.LineHeight() * spacing
+
+	p.size_w = 0
+	for i := range p.lines {
+		line := &p.lines[i]
+		line.Width = p.advance(line.Text)
+		line.Y = math.Floor(m.Ascent + float64(i)*line_height + 0.5)
+		p.size_w = math.Max(p.size_w, line.Width)
+	}
+
+	align_width := p.width
+	if align_width == 0 {
+		align_width = p.size_w
+	}
+	for i := range p.lines {
+		line := &p.lines[i]
+		extra := align_width - line.Width
+		switch p.style.Align {
+		case TextAlignRight:
+			line.X = extra
+		case TextAlignCenter:
+			line.X = extra / 2
+		case TextAlignJustify:
+			if spaces := strings.Count(line.Text, " "); !line.hard && spaces > 0 && extra > 0 {
+				line.word_spacing = extra / float64(spaces)
+				line.Width = align_width
+			}
+		}
+	}
+	if p.style.Align == TextAlignJustify {
+		for _, line := range p.lines {
+			p.size_w = math.Max(p.size_w, line.Width)
+		}
+	}
+
+	p.size_h = 0
+	if n := len(p.lines); n > 0 {
+		p.size_h = m.Ascent + m.Descent + float64(n-1)*line_height
+	}
+}
+
+// fit_prefix returns the length of the longest prefix of |text| not wider
+// than |width|, which isn't cut before a combining mark. The prefix has one
+// character at least, so the lines always advance.
+func (p *Paragraph) fit_prefix(text string, width float64) int {
+	end := next_cluster(text)
+	for end < len(text) {
+		next := end + next_cluster(text[end:])
+		if p.advance(text[:next]) > width {
+			break
+		}
+		end = next
+	}
+	return end
+}
+
+// advance returns the width of |text| drawn by DrawText.
+func (p *Paragraph) advance(text string) float64 {
+	if p.font.font == nil || text == "" {
+		return 0
+	}
+	end, _ := p.font.walk_text(text, freetype.RastPoint{}, nil)
+	return float64(end.X) / 256
+}
+
+// ellipsis returns the ellipsis character, or three dots if the font hasn't
+// the glyph.
+func (p *Paragraph) ellipsis() string {
+	if p.font.font != nil {
+		if _, glyph := p.font.face_of('…'); glyph == 0 {
+			return "..."
+		}
+	}
+	return "…"
+}
+
+// next_cluster returns the length of the first character of |text| with the
+// combining marks after it.
+func next_cluster(text string) int {
+	_, n := utf8.DecodeRuneInString(text)
+	for n < len(text) {
+		r, size := utf8.DecodeRuneInString(text[n:])
+		if c := line_break_class(r); c != kLbCM && c != kLbZWJ || r < 0x80 {
+			break
+		}
+		n += size
+	}
+	return n
+}
+
+// prev_cluster returns the length of the last character of |text| with the
+// combining marks after it.
+func prev_cluster(text string) int {
+	n := 0
+	for n < len(text) {
+		r, size := utf8.DecodeLastRuneInString(text[:len(text)-n])
+		n += size
+		if c := line_break_class(r); c != kLbCM && c != kLbZWJ || r < 0x80 {
+			break
+		}
+	}
+	return n
+}
+
+// trim_line_end returns |line| without the spaces and the breaks at its end,
+// which hang out of the line.
+func trim_line_end(line string) string {
+	return strings.TrimRightFunc(line, is_line_end_space)
+}
+
+func is_line_end_space(r rune) bool {
+	if r == '\t' {
+		return true
+	}
+	switch line_break_class(r) {
+	case kLbSP, kLbZW, kLbBK, kLbCR, kLbLF, kLbNL:
+		return true
+	}
+	return false
+}
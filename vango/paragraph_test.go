@@ -0,0 +1,235 @@
+package vango
+
+import (
+	"image"
+	"math"
+	"strings"
+	"testing"
+)
+
+const kTestParagraphText = "The quick brown fox jumps over the lazy dog"
+
+func new_test_paragraph(t *testing.T, text string, style ParagraphStyle) *Paragraph {
+	load_test_font(t)
+	return NewParagraph(text, nil, 12, style)
+}
+
+func paragraph_texts(p *Paragraph) []string {
+	var texts []string
+	for _, line := range p.Lines() {
+		texts = append(texts, line.Text)
+	}
+	return texts
+}
+
+func TestParagraphWrap(t *testing.T) {
+	p := new_test_paragraph(t, kTestParagraphText, ParagraphStyle{})
+	if got := paragraph_texts(p); len(got) != 1 || got[0] != kTestParagraphText {
+		t.Fatalf("the lines without the width: got %q", got)
+	}
+
+	width := p.advance("The quick brown") + 1
+	p.Layout(width)
+	lines := paragraph_texts(p)
+	if len(lines) < 3 || lines[0] != "The quick brown" {
+		t.Fatalf("the wrapped lines: got %q", lines)
+	}
+	if got := strings.Join(lines, " "); got != kTestParagraphText {
+		t.Errorf("the lines lost the text: got %q", got)
+	}
+	for i, line := range p.Lines() {
+		if line.Width > width {
+			t.Errorf("line %d %q is wider than %v: %v", i, line.Text, width, line.Width)
+		}
+		// Every line is greedy, the next word doesn't fit.
+		if i+1 < len(lines) {
+			next := lines[i] + " " + strings.Fields(lines[i+1])[0]
+			if p.advance(next) <= width {
+				t.Errorf("line %d %q could take the next word", i, line.Text)
+			}
+		}
+	}
+
+	// The hard breaks end the lines, the empty lines are kept.
+	p = new_test_paragraph(t, "one\n\ntwo  \r\nthree", ParagraphStyle{})
+	if got := strings.Join(paragraph_texts(p), "|"); got != "one||two|three" {
+		t.Errorf("the hard breaks: got %q", got)
+	}
+
+	// The word wider than the width is broken by the characters.
+	p = new_test_paragraph(t, "nnnnnnnnnn", ParagraphStyle{})
+	p.Layout(p.advance("nnnn") + 0.5)
+	if got := strings.Join(paragraph_texts(p), "|"); got != "nnnn|nnnn|nn" {
+		t.Errorf("the emergency breaks: got %q", got)
+	}
+	p.Layout(1)
+	if got := p.LineCount(); got != 10 {
+		t.Errorf("one character a line: got %d lines", got)
+	}
+}
+
+func TestParagraphAlign(t *testing.T) {
+	text := "aaa bbb ccc ddd eee\nfff"
+	p := new_test_paragraph(t, text, ParagraphStyle{})
+	width := p.advance("aaa bbb ccc") + 2
+
+	for _, align := range []TextAlign{TextAlignLeft, TextAlignRight, TextAlignCenter, TextAlignJustify} {
+		p = new_test_paragraph(t, text, ParagraphStyle{Align: align})
+		p.Layout(width)
+		lines := p.Lines()
+		if got := strings.Join(paragraph_texts(p), "|"); got != "aaa bbb ccc|ddd eee|fff" {
+			t.Fatalf("align %d: got the lines %q", align, got)
+		}
+		for i, line := range lines {
+			var want float64
+			switch align {
+			case TextAlignRight:
+				want = width - line.Width
+			case TextAlignCenter:
+				want = (width - line.Width) / 2
+			}
+			if line.X != want {
+				t.Errorf("align %d line %d: got x %v, want %v", align, i, line.X, want)
+			}
+		}
+		if align != TextAlignJustify {
+			continue
+		}
+
+		// The wrapped lines fill the width, the last one of the paragraph
+		// and the one before the hard break don't.
+		if lines[0].Width != width || lines[0].word_spacing != (width-p.advance("aaa bbb ccc"))/2 {
+			t.Errorf("the justified line: got %+v", lines[0])
+		}
+		if lines[1].word_spacing != 0 || lines[2].word_spacing != 0 {
+			t.Errorf("the hard lines are justified: got %+v", lines[1:])
+		}
+		if p.Width() != width {
+			t.Errorf("the justified width: got %v, want %v", p.Width(), width)
+		}
+	}
+}
+
+func TestParagraphEllipsis(t *testing.T) {
+	p := new_test_paragraph(t, kTestParagraphText, ParagraphStyle{})
+	width := p.advance("The quick brown fox")
+
+	p = new_test_paragraph(t, kTestParagraphText, ParagraphStyle{MaxLines: 2})
+	p.Layout(width)
+	if got := paragraph_texts(p); len(got) != 2 || !p.Truncated() || strings.Contains(got[1], "…") {
+		t.Errorf("MaxLines without the ellipsis: got %q", got)
+	}
+
+	p = new_test_paragraph(t, kTestParagraphText, ParagraphStyle{MaxLines: 2, Ellipsis: EllipsisEnd})
+	p.Layout(width)
+	lines := p.Lines()
+	if len(lines) != 2 || !strings.HasSuffix(lines[1].Text, "…") {
+		t.Fatalf("the end ellipsis: got %q", paragraph_texts(p))
+	}
+	// The last line takes as much of the rest as fits, by the characters.
+	rest := strings.TrimPrefix(kTestParagraphText, lines[0].Text+" ")
+	head := strings.TrimSuffix(lines[1].Text, "…")
+	if !strings.HasPrefix(rest, head) || len(head) <= len("jumps over the") {
+		t.Errorf("the end ellipsis: got %q of %q", head, rest)
+	}
+	if lines[1].Width > width {
+		t.Errorf("the ellipsis line is wider than %v: %v", width, lines[1].Width)
+	}
+
+	p = new_test_paragraph(t, kTestParagraphText, ParagraphStyle{MaxLines: 1, Ellipsis: EllipsisMiddle})
+	p.Layout(width)
+	lines = p.Lines()
+	if len(lines) != 1 {
+		t.Fatalf("the middle ellipsis: got %q", paragraph_texts(p))
+	}
+	parts := strings.Split(lines[0].Text, "…")
+	if len(parts) != 2 || !strings.HasPrefix(kTestParagraphText, parts[0]) ||
+		!strings.HasSuffix(kTestParagraphText, parts[1]) || lines[0].Width > width {
+		t.Errorf("the middle ellipsis: got %q", lines[0].Text)
+	}
+	// The head and the tail are balanced.
+	if d := p.advance(parts[0]) - p.advance(parts[1]); math.Abs(d) > p.advance("W")+p.advance(" ") {
+		t.Errorf("the middle ellipsis is not balanced: %q", lines[0].Text)
+	}
+
+	// Without the width, the hard lines are cut.
+	p = new_test_paragraph(t, "one\ntwo\nthree", ParagraphStyle{MaxLines: 2, Ellipsis: EllipsisEnd})
+	if got := strings.Join(paragraph_texts(p), "|"); got != "one|two…" {
+		t.Errorf("the ellipsis of the hard lines: got %q", got)
+	}
+
+	// The text that fits isn't truncated.
+	p = new_test_paragraph(t, "short", ParagraphStyle{MaxLines: 1, Ellipsis: EllipsisEnd})
+	p.Layout(width)
+	if got := paragraph_texts(p); p.Truncated() || len(got) != 1 || got[0] != "short" {
+		t.Errorf("the short text: got %q", got)
+	}
+}
+
+func TestParagraphSize(t *testing.T) {
+	p := new_test_paragraph(t, "one\ntwo\nthree", ParagraphStyle{LineSpacing: 1.5})
+	m := p.font.Metrics()
+	line_height := m.LineHeight() * 1.5
+
+	lines := p.Lines()
+	for i, line := range lines {
+		if want := math.Floor(m.Ascent + float64(i)*line_height + 0.5); line.Y != want {
+			t.Errorf("the baseline of line %d: got %v, want %v", i, line.Y, want)
+		}
+	}
+	if want := m.Ascent + m.Descent + 2*line_height; !near(p.Height(), want) {
+		t.Errorf("Height: got %v, want %v", p.Height(), want)
+	}
+	if want := p.advance("three"); p.Width() != want {
+		t.Errorf("Width: got %v, want %v", p.Width(), want)
+	}
+	if want := image.Pt(int(math.Ceil(p.Width())), int(math.Ceil(p.Height()))); p.Size() != want {
+		t.Errorf("Size: got %v, want %v", p.Size(), want)
+	}
+
+	if p := new_test_paragraph(t, "", ParagraphStyle{}); p.LineCount() != 0 || p.Height() != 0 {
+		t.Errorf("the empty paragraph: got %d lines of %v", p.LineCount(), p.Height())
+	}
+}
+
+func TestParagraphDraw(t *testing.T) {
+	load_test_font(t)
+	ctxt, canvas := new_test_context(120, 60)
+	ctxt.SetFontColor(0, 0, 0)
+
+	p := NewParagraph("left\nright", nil, 12, ParagraphStyle{Align: TextAlignRight})
+	rect := image.Rect(10, 5, 110, 60)
+	if err := p.Draw(ctxt, rect); err != nil {
+		t.Fatalf("Draw: %v", err)
+	}
+	if p.width != 100 {
+		t.Errorf("Draw didn't lay out to the rect: %v", p.width)
+	}
+
+	// Each line is painted in its own band, at the right of the rect.
+	ascent := math.Floor(p.font.Metrics().Ascent + 0.5)
+	for i, line := range p.Lines() {
+		m := p.font.MeasureText(line.Text)
+		origin := image.Pt(rect.Min.X+int(line.X), rect.Min.Y+int(line.Y))
+		band := m.InkBounds.Add(origin).Inset(-1)
+		painted := image.ZR
+		for y := rect.Min.Y + int(line.Y-ascent); y < rect.Min.Y+int(line.Y+p.font.Metrics().Descent); y++ {
+			for x := 0; x < canvas.W(); x++ {
+				if _, _, _, a := pixel_at(canvas, x, y); a != 0 {
+					painted = painted.Union(image.Rect(x, y, x+1, y+1))
+				}
+			}
+		}
+		if painted.Empty() || !painted.In(band) {
+			t.Errorf("line %d painted %v out of %v", i, painted, band)
+		}
+		if painted.Max.X < rect.Max.X-3 {
+			t.Errorf("line %d is not at the right: %v", i, painted)
+		}
+	}
+
+	// The state of the context is kept.
+	if ctxt.CurrentTransform() != IdentityMatrix() || ctxt.StateDepth() != 0 {
+		t.Errorf("Draw left the transform %v", ctxt.CurrentTransform())
+	}
+}
@@ -0,0 +1,351 @@
+// Copyright 2014 By Jshi. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vango
+
+import (
+	"gwk/vango/freetype"
+	"image"
+)
+
+type FillRule int
+
+const (
+	FillRuleNonZero FillRule = iota
+	FillRuleEvenOdd
+)
+
+type LineCap int
+
+const (
+	LineCapButt LineCap = iota
+	LineCapRound
+	LineCapSquare
+)
+
+type LineJoin int
+
+const (
+	LineJoinMiter LineJoin = iota
+	LineJoinRound
+	LineJoinBevel
+)
+
+// to_fix32 converts the pixel coordinate to the 24.8 fixed point used by the
+// rasterizer.
+func to_fix32(f float64) freetype.Fix32 {
+	return freetype.Fix32(f * 256)
+}
+
+func to_rast_point(x, y float64) freetype.RastPoint {
+	return freetype.RastPoint{X: to_fix32(x), Y: to_fix32(y)}
+}
+
+// device_point maps (x, y) in the user space to the rasterizer by the current
+// transform. The path is kept in the device space, so changing the transform
+// doesn't move the points already added.
+func (c *CanvasContext) device_point(x, y float64) freetype.RastPoint {
+	return to_rast_point(c.transform.TransformPoint(x, y))
+}
+
+// BeginPath clears the current path.
+func (c *CanvasContext) BeginPath() {
+	c.path.Clear()
+	c.has_current_point, c.path_closed = false, false
+}
+
+// MoveTo begins a new sub path at (x, y).
+func (c *CanvasContext) MoveTo(x, y float64) {
+	pt := c.device_point(x, y)
+	c.path.Start(pt)
+	c.start_point, c.current_point = pt, pt
+	c.has_current_point, c.path_closed = true, false
+}
+
+// continue_path starts a new sub path at the start point if the current one
+// is closed, so the next segment doesn't reopen it.
+func (c *CanvasContext) continue_path() {
+	if c.path_closed {
+		c.path.Start(c.start_point)
+		c.path_closed = false
+	}
+}
+
+// LineTo adds a straight line from the current point to (x, y). If there is
+// no current point, it's the same as MoveTo.
+func (c *CanvasContext) LineTo(x, y float64) {
+	if !c.has_current_point {
+		c.MoveTo(x, y)
+		return
+	}
+	c.continue_path()
+	pt := c.device_point(x, y)
+	c.path.Add1(pt)
+	c.current_point = pt
+}
+
+// QuadTo adds a quadratic Bézier curve with the control point (cx, cy) from
+// the current point to (x, y).
+func (c *CanvasContext) QuadTo(cx, cy, x, y float64) {
+	if !c.has_current_point {
+		c.MoveTo(cx, cy)
+	}
+	c.continue_path()
+	pt := c.device_point(x, y)
+	c.path.Add2(c.device_point(cx, cy), pt)
+	c.current_point = pt
+}
+
+// CubicTo adds a cubic Bézier curve with the control points (c1x, c1y) and
+// (c2x, c2y) from the current point to (x, y).
+func (c *CanvasContext) CubicTo(c1x, c1y, c2x, c2y, x, y float64) {
+	if !c.has_current_point {
+		c.MoveTo(c1x, c1y)
+	}
+	c.continue_path()
+	pt := c.device_point(x, y)
+	c.path.Add3(c.device_point(c1x, c1y), c.device_point(c2x, c2y), pt)
+	c.current_point = pt
+}
+
+// ClosePath adds a straight line back to the start of the current sub path
+// and closes it. A closed sub path is stroked with a join instead of two caps,
+// the segments added next start a new sub path at the same point.
+func (c *CanvasContext) ClosePath() {
+	if !c.has_current_point || c.path_closed {
+		return
+	}
+	if c.current_point != c.start_point {
+		c.path.Add1(c.start_point)
+	}
+	c.path.Close()
+	c.current_point, c.path_closed = c.start_point, true
+}
+
+func (c *CanvasContext) SetFillRule(rule FillRule) {
+	c.fill_rule = rule
+}
+
+func (c *CanvasContext) FillRule() FillRule {
+	return c.fill_rule
+}
+
+func (c *CanvasContext) SetLineWidth(width float64) {
+	c.line_width = width
+}
+
+func (c *CanvasContext) LineWidth() float64 {
+	return c.line_width
+}
+
+func (c *CanvasContext) SetLineCap(line_cap LineCap) {
+	c.line_cap = line_cap
+}
+
+func (c *CanvasContext) SetLineJoin(line_join LineJoin) {
+	c.line_join = line_join
+}
+
+// SetMiterLimit sets the max ratio of the miter length to the line width.
+// Joins exceeding it are drawn as bevel joins.
+func (c *CanvasContext) SetMiterLimit(limit float64) {
+	c.miter_limit = limit
+}
+
+// Fill fills the current path with the fill paint and the fill rule. Every
+// sub path is implicitly closed.
+func (c *CanvasContext) Fill() {
+	if c.canvas == nil || len(c.path) == 0 {
+		return
+	}
+
+	rast := c.prepare_rast()
+	rast.UseNonZeroWinding = c.fill_rule == FillRuleNonZero
+	add_closed_path(rast, c.path)
+	rast.Rast(c.new_span_drawer(c.fill_color, c.fill_paint))
+}
+
+// Stroke strokes the current path with the stroke paint, the line width, the
+// line cap and the line join. The line width is scaled by the average scale
+// of the current transform.
+func (c *CanvasContext) Stroke() {
+	if c.canvas == nil || len(c.path) == 0 || c.line_width <= 0 {
+		return
+	}
+
+	rast := c.prepare_rast()
+	rast.UseNonZeroWinding = true
+	width := to_fix32(c.line_width * c.transform.scale_factor())
+	freetype.Stroke(rast, c.dashed(c.path), width, c.capper(), c.joiner())
+	rast.Rast(c.new_span_drawer(c.stroke_color, c.stroke_paint))
+}
+
+// SetLineDash sets the lengths of the dashes and the gaps in turn used by the
+// strokes, repeated twice if the count is odd. An empty pattern, a negative
+// length or all zero lengths make the strokes solid.
+func (c *CanvasContext) SetLineDash(dashes []float64) {
+	c.line_dash = append([]float64(nil), dashes...)
+}
+
+func (c *CanvasContext) LineDash() []float64 {
+	return append([]float64(nil), c.line_dash...)
+}
+
+// SetLineDashOffset sets where the dash pattern starts along every sub path.
+// Changing it over time makes the marching ants.
+func (c *CanvasContext) SetLineDashOffset(offset float64) {
+	c.line_dash_offset = offset
+}
+
+func (c *CanvasContext) LineDashOffset() float64 {
+	return c.line_dash_offset
+}
+
+// dashed returns the dashes of |path| in the device space, or |path| if the
+// strokes are solid. The pattern is scaled like the line width.
+func (c *CanvasContext) dashed(path freetype.Path) freetype.Path {
+	if len(c.line_dash) == 0 {
+		return path
+	}
+	k := c.transform.scale_factor()
+	dashes := make([]freetype.Fix32, len(c.line_dash))
+	for i, l := range c.line_dash {
+		dashes[i] = to_fix32(l * k)
+	}
+	return freetype.Dash(path, dashes, to_fix32(c.line_dash_offset*k))
+}
+
+func (c *CanvasContext) capper() freetype.Capper {
+	switch c.line_cap {
+	case LineCapRound:
+		return freetype.RoundCapper
+	case LineCapSquare:
+		return freetype.SquareCapper
+	}
+	return freetype.ButtCapper
+}
+
+func (c *CanvasContext) joiner() freetype.Joiner {
+	switch c.line_join {
+	case LineJoinRound:
+		return freetype.RoundJoiner
+	case LineJoinBevel:
+		return freetype.BevelJoiner
+	}
+	return freetype.NewMiterJoiner(c.miter_limit)
+}
+
+// prepare_rast resets the rasterizer to the size of the current canvas.
+func (c *CanvasContext) prepare_rast() *freetype.Rast {
+	w, h := c.canvas.W(), c.canvas.H()
+	if c.rast_width != w || c.rast_height != h {
+		c.rast.SetBounds(w, h)
+		c.rast_width, c.rast_height = w, h
+	} else {
+		c.rast.Clear()
+	}
+	return c.rast
+}
+
+// add_closed_path adds |path| to |adder|, closing every sub path with a line
+// back to its start point.
+func add_closed_path(adder freetype.Adder, path freetype.Path) {
+	var start, last freetype.RastPoint
+	started := false
+
+	close := func() {
+		if started && last != start {
+			adder.Add1(start)
+		}
+	}
+
+	for i := 0; i < len(path); {
+		switch path[i] {
+		case 0:
+			close()
+			start = freetype.RastPoint{X: path[i+1], Y: path[i+2]}
+			last, started = start, true
+			adder.Start(start)
+			i += 4
+		case 1:
+			last = freetype.RastPoint{X: path[i+1], Y: path[i+2]}
+			adder.Add1(last)
+			i += 4
+		case 2:
+			pt0 := freetype.RastPoint{X: path[i+1], Y: path[i+2]}
+			last = freetype.RastPoint{X: path[i+3], Y: path[i+4]}
+			adder.Add2(pt0, last)
+			i += 6
+		case 3:
+			pt0 := freetype.RastPoint{X: path[i+1], Y: path[i+2]}
+			pt1 := freetype.RastPoint{X: path[i+3], Y: path[i+4]}
+			last = freetype.RastPoint{X: path[i+5], Y: path[i+6]}
+			adder.Add3(pt0, pt1, last)
+			i += 8
+		default:
+			panic("vango bad path")
+		}
+	}
+	close()
+}
+
+// canvas_span_drawer_t composites the spans from the rasterizer into the
+// canvas with a solid color or a paint.
+type canvas_span_drawer_t struct {
+	ctxt   *CanvasContext
+	bounds image.Rectangle // the clip bounds.
+	clr    [4]uint32       // premultiplied.
+	shader shader_t        // nil for the solid color.
+	row    []byte
+}
+
+func (c *CanvasContext) new_span_drawer(clr uint32, paint Paint) *canvas_span_drawer_t {
+	return &canvas_span_drawer_t{
+		ctxt:   c,
+		bounds: c.clip_bounds(),
+		clr:    premul_color(clr),
+		shader: c.shader_of(paint),
+	}
+}
+
+func (d *canvas_span_drawer_t) Draw(span_array []freetype.Span, done bool) {
+	l := d.bounds
+
+	for _, s := range span_array {
+		if s.Y < l.Min.Y || s.Y >= l.Max.Y {
+			continue
+		}
+		if s.X0 < l.Min.X {
+			s.X0 = l.Min.X
+		}
+		if s.X1 > l.Max.X {
+			s.X1 = l.Max.X
+		}
+		if s.X0 >= s.X1 {
+			continue
+		}
+
+		var colors []byte
+		if d.shader != nil {
+			n := (s.X1 - s.X0) * 4
+			if cap(d.row) < n {
+				d.row = make([]byte, n)
+			}
+			colors = d.row[:n]
+			d.shader.shade_row(s.X0, s.Y, colors)
+		}
+
+		// The 8 bits coverage of the span.
+		d.ctxt.blend_span(s.X0, s.Y, s.X1-s.X0, d.clr, colors, nil, s.A>>24)
+	}
+}
+
+// unpack_color splits the color packed by the Set*Color methods.
+func unpack_color(clr uint32) (r, g, b byte) {
+	return byte(clr >> 24 & 0xff), byte(clr >> 16 & 0xff), byte(clr >> 8 & 0xff)
+}
+
+func unpack_rgba(clr uint32) (r, g, b, a byte) {
+	return byte(clr >> 24), byte(clr >> 16), byte(clr >> 8), byte(clr)
+}
@@ -0,0 +1,247 @@
+package vango
+
+import (
+	"image"
+	"testing"
+)
+
+func pixel_at(c *Canvas, x, y int) (r, g, b, a byte) {
+	i := c.PixOffset(x, y)
+	p := c.Pix()
+	return p[i+2], p[i+1], p[i+0], p[i+3]
+}
+
+func is_painted(c *Canvas, x, y int) bool {
+	r, _, _, _ := pixel_at(c, x, y)
+	return r > 0x80
+}
+
+func new_test_context(w, h int) (*CanvasContext, *Canvas) {
+	canvas := NewCanvas(w, h)
+	ctxt := NewContext()
+	ctxt.SetCanvas(canvas)
+	ctxt.SetFillColor(0xff, 0, 0)
+	ctxt.SetStrokeColor(0xff, 0, 0)
+	return ctxt, canvas
+}
+
+func TestFillPath(t *testing.T) {
+	ctxt, canvas := new_test_context(40, 40)
+	ctxt.BeginPath()
+	ctxt.MoveTo(10, 10)
+	ctxt.LineTo(30, 10)
+	ctxt.LineTo(30, 30)
+	ctxt.LineTo(10, 30)
+	ctxt.Fill()
+
+	if r, _, _, a := pixel_at(canvas, 20, 20); r != 0xff || a != 0xff {
+		t.Errorf("inside: got r=%v a=%v, want 0xff 0xff", r, a)
+	}
+	if is_painted(canvas, 5, 20) || is_painted(canvas, 31, 20) {
+		t.Errorf("outside is painted")
+	}
+}
+
+func TestFillCurve(t *testing.T) {
+	ctxt, canvas := new_test_context(40, 40)
+	// A circle of radius 10 at (20, 20) by four cubics.
+	const k = 10 * 0.5523
+	ctxt.BeginPath()
+	ctxt.MoveTo(30, 20)
+	ctxt.CubicTo(30, 20+k, 20+k, 30, 20, 30)
+	ctxt.CubicTo(20-k, 30, 10, 20+k, 10, 20)
+	ctxt.CubicTo(10, 20-k, 20-k, 10, 20, 10)
+	ctxt.CubicTo(20+k, 10, 30, 20-k, 30, 20)
+	ctxt.Fill()
+
+	if !is_painted(canvas, 20, 20) || !is_painted(canvas, 27, 20) {
+		t.Errorf("inside the circle isn't painted")
+	}
+	if is_painted(canvas, 12, 12) || is_painted(canvas, 28, 28) {
+		t.Errorf("outside the circle is painted")
+	}
+}
+
+func TestFillRule(t *testing.T) {
+	for _, rule := range []FillRule{FillRuleNonZero, FillRuleEvenOdd} {
+		ctxt, canvas := new_test_context(40, 40)
+		ctxt.SetFillRule(rule)
+		ctxt.BeginPath()
+		ctxt.MoveTo(5, 5)
+		ctxt.LineTo(35, 5)
+		ctxt.LineTo(35, 35)
+		ctxt.LineTo(5, 35)
+		ctxt.ClosePath()
+		ctxt.MoveTo(15, 15)
+		ctxt.LineTo(25, 15)
+		ctxt.LineTo(25, 25)
+		ctxt.LineTo(15, 25)
+		ctxt.ClosePath()
+		ctxt.Fill()
+
+		if !is_painted(canvas, 10, 10) {
+			t.Errorf("rule %v: the ring isn't painted", rule)
+		}
+		if got, want := is_painted(canvas, 20, 20), rule == FillRuleNonZero; got != want {
+			t.Errorf("rule %v: the hole painted = %v, want %v", rule, got, want)
+		}
+	}
+}
+
+func TestStrokeCaps(t *testing.T) {
+	ctxt, canvas := new_test_context(40, 20)
+	ctxt.SetLineWidth(4)
+	ctxt.SetLineCap(LineCapButt)
+	ctxt.BeginPath()
+	ctxt.MoveTo(10, 10)
+	ctxt.LineTo(30, 10)
+	ctxt.Stroke()
+
+	if !is_painted(canvas, 20, 9) || !is_painted(canvas, 20, 10) {
+		t.Errorf("the line isn't painted")
+	}
+	if is_painted(canvas, 20, 13) || is_painted(canvas, 8, 10) {
+		t.Errorf("the butt line is too wide or too long")
+	}
+
+	ctxt, canvas = new_test_context(40, 20)
+	ctxt.SetLineWidth(4)
+	ctxt.SetLineCap(LineCapSquare)
+	ctxt.BeginPath()
+	ctxt.MoveTo(10, 10)
+	ctxt.LineTo(30, 10)
+	ctxt.Stroke()
+
+	if !is_painted(canvas, 8, 10) || !is_painted(canvas, 31, 10) {
+		t.Errorf("the square caps aren't painted")
+	}
+}
+
+func TestStrokeClosedPath(t *testing.T) {
+	for _, join := range []LineJoin{LineJoinMiter, LineJoinRound, LineJoinBevel} {
+		ctxt, canvas := new_test_context(40, 40)
+		ctxt.SetLineWidth(2)
+		ctxt.SetLineJoin(join)
+		ctxt.BeginPath()
+		ctxt.MoveTo(10, 10)
+		ctxt.LineTo(30, 10)
+		ctxt.LineTo(30, 30)
+		ctxt.LineTo(10, 30)
+		ctxt.ClosePath()
+		ctxt.Stroke()
+
+		if !is_painted(canvas, 20, 10) || !is_painted(canvas, 10, 20) {
+			t.Errorf("join %v: the edges aren't painted", join)
+		}
+		if is_painted(canvas, 20, 20) {
+			t.Errorf("join %v: the inside of the stroke is painted", join)
+		}
+	}
+}
+
+func TestLineDash(t *testing.T) {
+	ctxt, canvas := new_test_context(40, 10)
+	ctxt.SetLineWidth(2)
+	ctxt.SetLineCap(LineCapButt)
+	ctxt.SetLineDash([]float64{5, 5})
+	ctxt.BeginPath()
+	ctxt.MoveTo(0, 5)
+	ctxt.LineTo(40, 5)
+	ctxt.Stroke()
+
+	for x := 0; x < 40; x++ {
+		if want := (x/5)%2 == 0; is_painted(canvas, x, 5) != want {
+			t.Errorf("x=%v: got painted %v, want %v", x, !want, want)
+		}
+	}
+
+	// The offset shifts the dashes, and the dashes are scaled with the
+	// transform.
+	ctxt, canvas = new_test_context(40, 10)
+	ctxt.Scale(2, 2)
+	ctxt.SetLineDash([]float64{5, 5})
+	ctxt.SetLineDashOffset(2.5)
+	ctxt.BeginPath()
+	ctxt.MoveTo(0, 2.5)
+	ctxt.LineTo(20, 2.5)
+	ctxt.Stroke()
+	if !is_painted(canvas, 2, 5) || is_painted(canvas, 10, 5) || !is_painted(canvas, 20, 5) || is_painted(canvas, 30, 5) {
+		t.Errorf("the scaled dashes with the offset are wrong")
+	}
+
+	// StrokeRect is dashed too, and the pattern is a part of the state.
+	ctxt, canvas = new_test_context(40, 40)
+	ctxt.SaveState()
+	ctxt.SetLineDash([]float64{4, 4})
+	ctxt.StrokeRect(image.Rect(0, 0, 39, 39))
+	ctxt.RestoreState()
+	if len(ctxt.LineDash()) != 0 {
+		t.Errorf("RestoreState: got the dashes %v", ctxt.LineDash())
+	}
+	painted := 0
+	for x := 0; x < 39; x++ {
+		if is_painted(canvas, x, 0) {
+			painted++
+		}
+	}
+	if painted < 16 || painted > 24 {
+		t.Errorf("the dashed StrokeRect: got %v painted of 39", painted)
+	}
+}
+
+func TestStrokeOpenPathBackAtStart(t *testing.T) {
+	// The open triangle back at its start has the square caps there, the
+	// closed one has the bevel join instead.
+	for _, closed := range []bool{false, true} {
+		ctxt, canvas := new_test_context(40, 40)
+		ctxt.SetLineWidth(4)
+		ctxt.SetLineCap(LineCapSquare)
+		ctxt.SetLineJoin(LineJoinBevel)
+		ctxt.BeginPath()
+		ctxt.MoveTo(10, 10)
+		ctxt.LineTo(30, 10)
+		ctxt.LineTo(30, 30)
+		ctxt.LineTo(10, 10)
+		if closed {
+			ctxt.ClosePath()
+		}
+		ctxt.Stroke()
+
+		if painted := is_painted(canvas, 8, 10); painted == closed {
+			t.Errorf("closed %v: the cap at the start painted: got %v", closed, painted)
+		}
+	}
+}
+
+func TestClosePathStartsNewSubPath(t *testing.T) {
+	ctxt, canvas := new_test_context(40, 40)
+	ctxt.SetLineWidth(2)
+	ctxt.BeginPath()
+	ctxt.MoveTo(10, 10)
+	ctxt.LineTo(30, 10)
+	ctxt.LineTo(30, 30)
+	ctxt.ClosePath()
+	ctxt.LineTo(10, 30)
+	ctxt.Stroke()
+
+	// The line after ClosePath starts at the start point in a new sub path,
+	// the closed one is kept closed.
+	n := 0
+	for i := 0; i < len(ctxt.path); i += 4 {
+		if ctxt.path[i] == 0 {
+			if n == 0 && !ctxt.path.Closed(i) {
+				t.Errorf("the first sub path isn't closed")
+			}
+			if n == 1 && (ctxt.path[i+1] != to_fix32(10) || ctxt.path[i+2] != to_fix32(10)) {
+				t.Errorf("the second sub path starts at %v", ctxt.path[i+1:i+3])
+			}
+			n++
+		}
+	}
+	if n != 2 {
+		t.Errorf("got %v sub paths, want 2: %v", n, ctxt.path)
+	}
+	if !is_painted(canvas, 10, 20) || !is_painted(canvas, 20, 10) {
+		t.Errorf("the lines aren't painted")
+	}
+}
@@ -0,0 +1,556 @@
+// Copyright 2014 By Jshi. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vango
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"gwk/vango/freetype"
+	"image"
+	"image/png"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// The objects of a pdf document known before the pages.
+const (
+	kPdfCatalogObject   = 1
+	kPdfPagesObject     = 2
+	kPdfResourcesObject = 3
+)
+
+// PDFWriter is the Context writing the draws as the pages of a pdf document,
+// one pixel to one point. The texts are the outlines of the glyphs marked with
+// the text, so it can be searched and copied, and the images are embedded as
+// the png data. The gradients with the reflect or the repeat spread, or the
+// alpha changing along them, are rasterized.
+type PDFWriter struct {
+	*vector_context_t
+	device *pdf_device_t
+	w      io.Writer
+}
+
+// NewPDFWriter returns the PDFWriter of a document of the pages of |width| x
+// |height| points, written to |w| by Close. The first page is begun.
+func NewPDFWriter(w io.Writer, width, height int) *PDFWriter {
+	device := &pdf_device_t{
+		width:    width,
+		height:   height,
+		objects:  make([][]byte, kPdfResourcesObject),
+		gstates:  make(map[float64]string),
+		images:   make(map[pdf_image_key_t]string),
+		patterns: make(map[string]int),
+		xobjects: make(map[string]int),
+	}
+	device.begin_page()
+	return &PDFWriter{new_vector_context(device, width, height), device, w}
+}
+
+// NewPage ends the current page and begins the next one, the graphics state
+// and the path are reset.
+func (p *PDFWriter) NewPage() {
+	if p.w == nil {
+		return
+	}
+	p.device.end_page()
+	p.device.begin_page()
+	p.reset()
+}
+
+// Close ends the current page and writes the document. The writer draws
+// nothing after it.
+func (p *PDFWriter) Close() error {
+	if p.w == nil {
+		return nil
+	}
+	w := p.w
+	p.w = nil
+	d := p.device
+	d.end_page()
+	d.closed = true
+	if d.err != nil {
+		return d.err
+	}
+	return d.write_to(w)
+}
+
+// pdf_device_t writes the draws as the content streams of the pages. The
+// resources of all the pages are in one dictionary.
+type pdf_device_t struct {
+	width, height int
+	closed        bool
+	err           error
+
+	// The bodies of the objects, the object n is objects[n-1].
+	objects [][]byte
+	pages   []int
+	content bytes.Buffer // of the current page.
+
+	gstates  map[float64]string // The names of the alphas.
+	images   map[pdf_image_key_t]string
+	patterns map[string]int
+	xobjects map[string]int
+}
+
+// pdf_image_key_t identifies an image XObject, the interpolation is a part of
+// the image in the pdf.
+type pdf_image_key_t struct {
+	image  *Canvas
+	smooth bool
+}
+
+// add_object adds the object of |body|, and returns its number.
+func (d *pdf_device_t) add_object(body []byte) int {
+	d.objects = append(d.objects, body)
+	return len(d.objects)
+}
+
+// add_stream adds the stream object of |data| with the entries of |dict|.
+func (d *pdf_device_t) add_stream(dict string, data []byte) int {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "<< %s /Length %d >>\nstream\n", dict, len(data))
+	b.Write(data)
+	b.WriteString("\nendstream")
+	return d.add_object(b.Bytes())
+}
+
+// begin_page flips the y axis, so the content is in the device space.
+func (d *pdf_device_t) begin_page() {
+	d.content.Reset()
+	fmt.Fprintf(&d.content, "1 0 0 -1 0 %d cm\n", d.height)
+}
+
+func (d *pdf_device_t) end_page() {
+	contents := d.add_stream("", d.content.Bytes())
+	page := d.add_object([]byte(fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %d %d] "+
+		"/Resources %d 0 R /Contents %d 0 R >>",
+		kPdfPagesObject, d.width, d.height, kPdfResourcesObject, contents)))
+	d.pages = append(d.pages, page)
+	d.content.Reset()
+}
+
+// write_to writes the objects, the cross reference table and the trailer.
+func (d *pdf_device_t) write_to(w io.Writer) error {
+	d.objects[kPdfCatalogObject-1] = []byte(fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", kPdfPagesObject))
+
+	var kids bytes.Buffer
+	for i, page := range d.pages {
+		if i > 0 {
+			kids.WriteByte(' ')
+		}
+		fmt.Fprintf(&kids, "%d 0 R", page)
+	}
+	d.objects[kPdfPagesObject-1] = []byte(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>",
+		kids.String(), len(d.pages)))
+
+	var res bytes.Buffer
+	gstates := make([]string, 0, len(d.gstates))
+	for alpha, name := range d.gstates {
+		gstates = append(gstates, fmt.Sprintf(" /%s << /ca %s /CA %s >>", name, pdf_num(alpha), pdf_num(alpha)))
+	}
+	sort.Strings(gstates)
+	res.WriteString("<< /ExtGState <<" + strings.Join(gstates, ""))
+	res.WriteString(" >> /Pattern <<" + pdf_refs(d.patterns))
+	res.WriteString(" >> /XObject <<" + pdf_refs(d.xobjects) + " >> >>")
+	d.objects[kPdfResourcesObject-1] = res.Bytes()
+
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(d.objects))
+	for i, body := range d.objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n", i+1)
+		b.Write(body)
+		b.WriteString("\nendobj\n")
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(d.objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n",
+		len(d.objects)+1, kPdfCatalogObject, xref)
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// pdf_refs returns the entries of the references by the names, sorted.
+func pdf_refs(refs map[string]int) string {
+	entries := make([]string, 0, len(refs))
+	for name, n := range refs {
+		entries = append(entries, fmt.Sprintf(" /%s %d 0 R", name, n))
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, "")
+}
+
+// can_paint returns true for the solid paints, and the linear and the radial
+// gradients padded at the ends with the same alpha along them, since the
+// shadings of pdf have no alpha.
+func (d *pdf_device_t) can_paint(paint Paint) bool {
+	var gr *Gradient
+	switch p := paint.(type) {
+	case SolidPaint:
+		return true
+	case *LinearGradient:
+		gr = &p.Gradient
+	case *RadialGradient:
+		gr = &p.Gradient
+	default:
+		return false
+	}
+	stops := gr.ColorStops()
+	if len(stops) == 0 || gr.Spread() != SpreadPad {
+		return false
+	}
+	for _, stop := range stops {
+		if stop.A != stops[0].A {
+			return false
+		}
+	}
+	return true
+}
+
+func (d *pdf_device_t) draw(v *vector_draw_t) {
+	if d.closed || d.err != nil {
+		return
+	}
+	c := &d.content
+	c.WriteString("q\n")
+	d.clip(v.clip)
+
+	if v.image != nil {
+		d.draw_image(v)
+		c.WriteString("Q\n")
+		return
+	}
+
+	if v.text != "" {
+		c.WriteString("/Span << /ActualText <FEFF")
+		for _, u := range utf16.Encode([]rune(v.text)) {
+			fmt.Fprintf(c, "%04X", u)
+		}
+		c.WriteString("> >> BDC\n")
+	}
+
+	alpha := v.alpha * d.paint(v.paint, v.paint_transform, v.stroke != nil)
+	d.set_alpha(alpha)
+	if v.stroke == nil {
+		pdf_path(c, v.path, false)
+		if v.even_odd {
+			c.WriteString("f*\n")
+		} else {
+			c.WriteString("f\n")
+		}
+	} else {
+		d.stroke_style(v.stroke)
+		pdf_path(c, v.path, true)
+		c.WriteString("S\n")
+	}
+
+	if v.text != "" {
+		c.WriteString("EMC\n")
+	}
+	c.WriteString("Q\n")
+}
+
+// clip intersects the clip of the saved state with the paths of |clip|.
+func (d *pdf_device_t) clip(clip *vector_clip_t) {
+	if clip == nil {
+		return
+	}
+	d.clip(clip.parent)
+	if len(clip.path) == 0 {
+		// Nothing is inside the empty path.
+		d.content.WriteString("0 0 0 0 re\n")
+	}
+	pdf_path(&d.content, clip.path, false)
+	if clip.even_odd {
+		d.content.WriteString("W* n\n")
+	} else {
+		d.content.WriteString("W n\n")
+	}
+}
+
+func (d *pdf_device_t) set_alpha(alpha float64) {
+	if alpha >= 1 {
+		return
+	}
+	alpha = math.Floor(alpha*1e4+0.5) / 1e4
+	name, ok := d.gstates[alpha]
+	if !ok {
+		name = "GS" + strconv.Itoa(len(d.gstates))
+		d.gstates[alpha] = name
+	}
+	fmt.Fprintf(&d.content, "/%s gs\n", name)
+}
+
+// paint sets the color or the pattern of the fill, or of the stroke if
+// |stroke| is true, and returns the alpha of the paint.
+func (d *pdf_device_t) paint(paint Paint, m Matrix, stroke bool) float64 {
+	c := &d.content
+	var gr *Gradient
+	var shading string
+	switch p := paint.(type) {
+	case SolidPaint:
+		op := "rg"
+		if stroke {
+			op = "RG"
+		}
+		fmt.Fprintf(c, "%s %s %s %s\n", pdf_num(float64(p.R)/0xff), pdf_num(float64(p.G)/0xff),
+			pdf_num(float64(p.B)/0xff), op)
+		return float64(p.A) / 0xff
+	case *LinearGradient:
+		gr = &p.Gradient
+		shading = fmt.Sprintf("/ShadingType 2 /Coords [%s %s %s %s]",
+			pdf_num(p.X0), pdf_num(p.Y0), pdf_num(p.X1), pdf_num(p.Y1))
+	case *RadialGradient:
+		gr = &p.Gradient
+		// Like the rasterizer, the focal point outside the circle is moved
+		// into it.
+		r := math.Abs(p.R)
+		fx, fy := p.FX, p.FY
+		if l := math.Hypot(fx-p.CX, fy-p.CY); l > r*0.999 {
+			k := r * 0.999 / l
+			fx, fy = p.CX+(fx-p.CX)*k, p.CY+(fy-p.CY)*k
+		}
+		shading = fmt.Sprintf("/ShadingType 3 /Coords [%s %s 0 %s %s %s]",
+			pdf_num(fx), pdf_num(fy), pdf_num(p.CX), pdf_num(p.CY), pdf_num(r))
+	default:
+		return 0
+	}
+
+	// The matrix of a pattern maps to the page, not to the current space.
+	flip := Matrix{1, 0, 0, -1, 0, float64(d.height)}
+	m = flip.Multiply(m)
+	n := d.add_object([]byte(fmt.Sprintf("<< /Type /Pattern /PatternType 2 /Matrix [%s] "+
+		"/Shading << %s /ColorSpace /DeviceRGB /Function %s /Extend [true true] >> >>",
+		pdf_matrix(m), shading, pdf_function(gr.ColorStops()))))
+	name := "P" + strconv.Itoa(len(d.patterns))
+	d.patterns[name] = n
+	if stroke {
+		fmt.Fprintf(c, "/Pattern CS /%s SCN\n", name)
+	} else {
+		fmt.Fprintf(c, "/Pattern cs /%s scn\n", name)
+	}
+	return float64(gr.ColorStops()[0].A) / 0xff
+}
+
+// pdf_function returns the function of the colors of the stops, from 0 to 1.
+func pdf_function(stops []ColorStop) string {
+	rgb := func(stop ColorStop) string {
+		return pdf_num(float64(stop.R)/0xff) + " " + pdf_num(float64(stop.G)/0xff) + " " +
+			pdf_num(float64(stop.B)/0xff)
+	}
+	interpolate := func(s0, s1 ColorStop) string {
+		return "<< /FunctionType 2 /Domain [0 1] /C0 [" + rgb(s0) + "] /C1 [" + rgb(s1) + "] /N 1 >>"
+	}
+
+	// The colors before the first stop and after the last one are theirs.
+	first, last := stops[0], stops[len(stops)-1]
+	if first.Offset > 0 {
+		first.Offset = 0
+		stops = append([]ColorStop{first}, stops...)
+	}
+	if last.Offset < 1 {
+		last.Offset = 1
+		stops = append(stops, last)
+	}
+	if len(stops) == 2 {
+		return interpolate(stops[0], stops[1])
+	}
+
+	var functions, bounds, encode bytes.Buffer
+	for i := 0; i+1 < len(stops); i++ {
+		functions.WriteString(interpolate(stops[i], stops[i+1]) + " ")
+		encode.WriteString("0 1 ")
+		if i > 0 {
+			bounds.WriteString(pdf_num(stops[i].Offset) + " ")
+		}
+	}
+	return fmt.Sprintf("<< /FunctionType 3 /Domain [0 1] /Functions [%s] /Bounds [%s] /Encode [%s] >>",
+		bytes.TrimSpace(functions.Bytes()), bytes.TrimSpace(bounds.Bytes()), bytes.TrimSpace(encode.Bytes()))
+}
+
+func (d *pdf_device_t) stroke_style(stroke *vector_stroke_t) {
+	c := &d.content
+	fmt.Fprintf(c, "%s w %d J %d j %s M\n", pdf_num(stroke.width), stroke.line_cap, stroke.line_join,
+		pdf_num(math.Max(1, stroke.miter_limit)))
+	if len(stroke.dashes) > 0 {
+		c.WriteString("[")
+		for i, l := range stroke.dashes {
+			if i > 0 {
+				c.WriteByte(' ')
+			}
+			c.WriteString(pdf_num(l))
+		}
+		fmt.Fprintf(c, "] %s d\n", pdf_num(stroke.dash_offset))
+	}
+}
+
+// draw_image paints the image XObject, which maps the unit square with the y
+// axis up to the image.
+func (d *pdf_device_t) draw_image(v *vector_draw_t) {
+	key := pdf_image_key_t{v.image, v.smooth}
+	name, ok := d.images[key]
+	if !ok {
+		n, err := d.add_image(v.image, v.smooth)
+		if err != nil {
+			d.err = err
+			return
+		}
+		name = "Im" + strconv.Itoa(len(d.images))
+		d.images[key] = name
+		d.xobjects[name] = n
+	}
+	w, h := float64(v.image.W()), float64(v.image.H())
+	m := v.image_transform.Multiply(Matrix{w, 0, 0, -h, 0, h})
+	d.set_alpha(v.alpha)
+	fmt.Fprintf(&d.content, "%s cm\n/%s Do\n", pdf_matrix(m), name)
+}
+
+// add_image adds the image XObject of |img| from the data of a png without
+// the alpha, and the alpha as the soft mask from the data of a gray png.
+func (d *pdf_device_t) add_image(img *Canvas, smooth bool) (int, error) {
+	w, h := img.W(), img.H()
+	var colors *image.NRGBA
+	var gray *image.Gray
+	switch src := standard_image(img).(type) {
+	case *image.Gray:
+		gray = src
+	case *image.NRGBA:
+		colors = src
+	}
+
+	var mask *image.Gray
+	if colors != nil {
+		for i := 3; i < len(colors.Pix); i += 4 {
+			if colors.Pix[i] != 0xff {
+				mask = image.NewGray(colors.Rect)
+				break
+			}
+		}
+		// The copy is opaque, so the png has no alpha.
+		opaque := image.NewNRGBA(colors.Rect)
+		for i := 0; i < len(colors.Pix); i += 4 {
+			copy(opaque.Pix[i:i+3], colors.Pix[i:i+3])
+			opaque.Pix[i+3] = 0xff
+			if mask != nil {
+				mask.Pix[i/4] = colors.Pix[i+3]
+			}
+		}
+		colors = opaque
+	}
+
+	interpolate := ""
+	if smooth {
+		interpolate = " /Interpolate true"
+	}
+	smask := ""
+	if mask != nil {
+		data, err := png_idat(mask)
+		if err != nil {
+			return 0, err
+		}
+		n := d.add_stream(pdf_image_dict(w, h, 1)+interpolate, data)
+		smask = fmt.Sprintf(" /SMask %d 0 R", n)
+	}
+
+	var data []byte
+	var err error
+	n_colors := 3
+	if colors != nil {
+		data, err = png_idat(colors)
+	} else {
+		data, err = png_idat(gray)
+		n_colors = 1
+	}
+	if err != nil {
+		return 0, err
+	}
+	return d.add_stream(pdf_image_dict(w, h, n_colors)+interpolate+smask, data), nil
+}
+
+// pdf_image_dict returns the entries of the image of the png data, which is
+// decoded by the png predictors.
+func pdf_image_dict(w, h, colors int) string {
+	space := "/DeviceRGB"
+	if colors == 1 {
+		space = "/DeviceGray"
+	}
+	return fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace %s "+
+		"/BitsPerComponent 8 /Filter /FlateDecode "+
+		"/DecodeParms << /Predictor 15 /Colors %d /BitsPerComponent 8 /Columns %d >>",
+		w, h, space, colors, w)
+}
+
+// png_idat returns the zlib data of the IDAT chunks of |img| encoded as png,
+// the rows filtered by the png predictors.
+func png_idat(img image.Image) ([]byte, error) {
+	var b bytes.Buffer
+	if err := png.Encode(&b, img); err != nil {
+		return nil, err
+	}
+	var data []byte
+	p := b.Bytes()[8:] // The signature.
+	for len(p) >= 12 {
+		n := int(binary.BigEndian.Uint32(p))
+		if len(p) < 12+n {
+			break
+		}
+		if string(p[4:8]) == "IDAT" {
+			data = append(data, p[8:8+n]...)
+		}
+		p = p[12+n:]
+	}
+	return data, nil
+}
+
+// pdf_path writes the path operators of |path|, the sub paths closed by
+// ClosePath are closed in pdf too if |close| is true.
+func pdf_path(b *bytes.Buffer, path freetype.Path, close bool) {
+	xy := func(pt freetype.RastPoint) (float64, float64) { return float64(pt.X) / 256, float64(pt.Y) / 256 }
+	var lx, ly float64
+	walk_path(path, func(op int, pts []freetype.RastPoint, closed bool) {
+		var f [6]float64
+		for i, pt := range pts {
+			f[2*i], f[2*i+1] = xy(pt)
+		}
+		switch op {
+		case 0:
+			fmt.Fprintf(b, "%s %s m\n", pdf_num(f[0]), pdf_num(f[1]))
+		case 1:
+			fmt.Fprintf(b, "%s %s l\n", pdf_num(f[0]), pdf_num(f[1]))
+		case 2:
+			// The quadratic curve is the cubic one with the control points
+			// 2/3 of the way to the control point.
+			cx, cy, x, y := f[0], f[1], f[2], f[3]
+			f = [6]float64{lx + (cx-lx)*2/3, ly + (cy-ly)*2/3, x + (cx-x)*2/3, y + (cy-y)*2/3, x, y}
+			fallthrough
+		case 3:
+			fmt.Fprintf(b, "%s %s %s %s %s %s c\n", pdf_num(f[0]), pdf_num(f[1]),
+				pdf_num(f[2]), pdf_num(f[3]), pdf_num(f[4]), pdf_num(f[5]))
+		}
+		lx, ly = xy(pts[len(pts)-1])
+		if close && closed {
+			b.WriteString("h\n")
+		}
+	})
+}
+
+// pdf_num formats |f| with at most 4 decimals, pdf has no exponents.
+func pdf_num(f float64) string {
+	return svg_num(f)
+}
+
+func pdf_matrix(m Matrix) string {
+	return pdf_num(m.A) + " " + pdf_num(m.B) + " " + pdf_num(m.C) + " " +
+		pdf_num(m.D) + " " + pdf_num(m.E) + " " + pdf_num(m.F)
+}
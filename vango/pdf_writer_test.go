@@ -0,0 +1,178 @@
+package vango
+
+import (
+	"bytes"
+	"compress/zlib"
+	"image"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var g_pdf_object_re = regexp.MustCompile(`(?s)(\d+) 0 obj\n(.*?)\nendobj\n`)
+
+// parse_pdf_objects checks the cross reference table of |data|, and returns
+// the bodies of the objects by their numbers.
+func parse_pdf_objects(t *testing.T, data []byte) map[int][]byte {
+	s := string(data)
+	if !strings.HasPrefix(s, "%PDF-1.4\n") || !strings.HasSuffix(s, "%%EOF\n") {
+		t.Fatalf("the header or the trailer is missing")
+	}
+	i := strings.LastIndex(s, "startxref\n")
+	xref, err := strconv.Atoi(strings.Fields(s[i+len("startxref\n"):])[0])
+	if err != nil || !strings.HasPrefix(s[xref:], "xref\n") {
+		t.Fatalf("startxref: got %v, %v", xref, err)
+	}
+
+	objects := make(map[int][]byte)
+	for _, m := range g_pdf_object_re.FindAllSubmatchIndex(data, -1) {
+		n, _ := strconv.Atoi(s[m[2]:m[3]])
+		objects[n] = data[m[4]:m[5]]
+	}
+	lines := strings.Split(s[xref:], "\n")
+	count, _ := strconv.Atoi(strings.Fields(lines[1])[1])
+	if count != len(objects)+1 {
+		t.Fatalf("xref: got %v entries for %v objects", count, len(objects))
+	}
+	for n := 1; n < count; n++ {
+		offset, _ := strconv.Atoi(strings.Fields(lines[2+n])[0])
+		if !strings.HasPrefix(s[offset:], strconv.Itoa(n)+" 0 obj\n") {
+			t.Errorf("the offset of the object %v: got %v", n, offset)
+		}
+	}
+	return objects
+}
+
+var g_pdf_stream_re = regexp.MustCompile(`(?s)^<< (.*) /Length (\d+) >>\nstream\n(.*)\nendstream$`)
+
+func pdf_stream(t *testing.T, body []byte) (string, []byte) {
+	m := g_pdf_stream_re.FindSubmatch(body)
+	if m == nil {
+		t.Fatalf("not a stream: %q", body)
+	}
+	if n, _ := strconv.Atoi(string(m[2])); n != len(m[3]) {
+		t.Errorf("the length of the stream: got %v, want %v", n, len(m[3]))
+	}
+	return string(m[1]), m[3]
+}
+
+func TestPDFWriter(t *testing.T) {
+	load_test_font(t)
+	var buf bytes.Buffer
+	w := NewPDFWriter(&buf, 80, 60)
+	draw_vector_test_scene(w, new_test_sprite())
+	// The gradient of the scene has the alpha changing, so it's an image.
+	gradient := NewRadialGradient(40, 30, 20)
+	gradient.AddColorStop(0, 0xff, 0, 0, 0xff)
+	gradient.AddColorStop(0.5, 0, 0xff, 0, 0xff)
+	gradient.AddColorStop(1, 0, 0, 0xff, 0xff)
+	w.SetFillPaint(gradient)
+	w.FillRect(image.Rect(20, 10, 60, 50))
+	w.NewPage()
+	w.SetFillColor(0xff, 0, 0)
+	w.FillRect(image.Rect(10, 10, 20, 20))
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	objects := parse_pdf_objects(t, buf.Bytes())
+	var pages [][]byte
+	for n := 1; n <= len(objects); n++ {
+		if bytes.HasPrefix(objects[n], []byte("<< /Type /Page ")) {
+			pages = append(pages, objects[n])
+		}
+	}
+	if len(pages) != 2 || !bytes.Contains(objects[kPdfPagesObject], []byte("/Count 2")) {
+		t.Fatalf("pages: got %q", pages)
+	}
+	contents := func(page []byte) string {
+		m := regexp.MustCompile(`/Contents (\d+) 0 R`).FindSubmatch(page)
+		n, _ := strconv.Atoi(string(m[1]))
+		_, data := pdf_stream(t, objects[n])
+		return string(data)
+	}
+
+	first := contents(pages[0])
+	for _, want := range []string{
+		"1 0 0 -1 0 60 cm\n",
+		"0.1255 0.251 0.3765 rg\n",
+		"/Pattern cs /P0 scn\n",
+		"[4 2] 0 d\n",
+		"/Span << /ActualText <FEFF00480069> >> BDC\n",
+		"W n\n",
+		"/Im0 Do\n",
+		"/Im1 Do\n",
+	} {
+		if !strings.Contains(first, want) {
+			t.Errorf("the content of the first page has no %q:\n%s", want, first)
+		}
+	}
+	if strings.Count(first, "q\n") != strings.Count(first, "Q\n") {
+		t.Errorf("the states aren't balanced:\n%s", first)
+	}
+	if second := contents(pages[1]); second != "1 0 0 -1 0 60 cm\nq\n1 0 0 rg\n10 10 m\n20 10 l\n20 20 l\n10 20 l\n10 10 l\nf\nQ\n" {
+		t.Errorf("the content of the second page: got %q", second)
+	}
+
+	res := string(objects[kPdfResourcesObject])
+	ref := func(name string) []byte {
+		m := regexp.MustCompile(`/` + name + ` (\d+) 0 R`).FindStringSubmatch(res)
+		if m == nil {
+			t.Fatalf("the resources have no %v: %v", name, res)
+		}
+		n, _ := strconv.Atoi(m[1])
+		return objects[n]
+	}
+	pattern := string(ref("P0"))
+	if !strings.Contains(pattern, "/ShadingType 3") || !strings.Contains(pattern, "/FunctionType 3") {
+		t.Errorf("the pattern: got %v", pattern)
+	}
+
+	// The image of the sprite is in the png rows of rgb, and the alpha is in
+	// the soft mask.
+	dict, data := pdf_stream(t, ref("Im1"))
+	if !strings.Contains(dict, "/Width 8 /Height 8 /ColorSpace /DeviceRGB") {
+		t.Errorf("the image: got %v", dict)
+	}
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("the image data: %v", err)
+	}
+	rows, err := ioutil.ReadAll(r)
+	if err != nil || len(rows) != 8*(1+3*8) {
+		t.Errorf("the image rows: got %v bytes, %v", len(rows), err)
+	}
+}
+
+func TestPDFWriterImageFilter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewPDFWriter(&buf, 40, 20)
+	sprite := new_test_sprite()
+	w.DrawCanvas(0, 0, sprite, sprite.LocalBounds())
+	w.SetImageFilter(FilterNearest)
+	w.DrawCanvas(10, 0, sprite, sprite.LocalBounds())
+	w.SetImageFilter(FilterBilinear)
+	w.DrawCanvas(20, 0, sprite, sprite.LocalBounds())
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	objects := parse_pdf_objects(t, buf.Bytes())
+	res := string(objects[kPdfResourcesObject])
+	for name, want := range map[string]bool{"Im0": true, "Im1": false} {
+		m := regexp.MustCompile(`/` + name + ` (\d+) 0 R`).FindStringSubmatch(res)
+		if m == nil {
+			t.Fatalf("the resources have no %v: %v", name, res)
+		}
+		n, _ := strconv.Atoi(m[1])
+		dict, _ := pdf_stream(t, objects[n])
+		if got := strings.Contains(dict, "/Interpolate true"); got != want {
+			t.Errorf("the interpolation of %v: got %v, want %v", name, got, want)
+		}
+	}
+	if strings.Contains(res, "/Im2 ") {
+		t.Errorf("the smooth draws don't share the image: %v", res)
+	}
+}
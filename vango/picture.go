@@ -0,0 +1,1078 @@
+// Copyright 2014 By Jshi. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vango
+
+import (
+	"bytes"
+	"fmt"
+	"gwk/vango/freetype"
+	"image"
+	"math"
+	"strings"
+)
+
+type picture_op_code_t byte
+
+// The op codes of the recorded calls, named after the methods of Context.
+// The values are a part of the encoded pictures, only add to the end.
+const (
+	kOpSaveState picture_op_code_t = iota
+	kOpRestoreState
+	kOpTranslate
+	kOpScale
+	kOpRotate
+	kOpTransform
+	kOpSetTransform
+	kOpResetTransform
+	kOpClipRect
+	kOpClipPath
+	kOpResetClip
+	kOpSetStrokeRGBA
+	kOpSetFillRGBA
+	kOpSetFontRGBA
+	kOpSetStrokePaint
+	kOpSetFillPaint
+	kOpSetFontPaint
+	kOpSetFontSize
+	kOpSetFont
+	kOpSetFontFace
+	kOpSetDither
+	kOpSetGlobalAlpha
+	kOpSetCompositeOp
+	kOpSetImageFilter
+	kOpSetFillRule
+	kOpSetLineWidth
+	kOpSetLineCap
+	kOpSetLineJoin
+	kOpSetMiterLimit
+	kOpSetLineDash
+	kOpSetLineDashOffset
+	kOpBeginPath
+	kOpMoveTo
+	kOpLineTo
+	kOpQuadTo
+	kOpCubicTo
+	kOpClosePath
+	kOpFill
+	kOpStroke
+	kOpDrawText
+	kOpDrawColor
+	kOpDrawAlpha
+	kOpDrawRGBA
+	kOpDrawCanvas
+	kOpAlphaBlend
+	kOpDrawStretch
+	kOpDrawNinePatch
+	kOpFillRect
+	kOpStrokeRect
+	kOpDrawShadow
+	kOpFillRoundedRect
+	kOpStrokeRoundedRect
+	kOpFillEllipse
+	kOpStrokeEllipse
+	kOpArc
+	kOpPie
+	kOpStrokePie
+	kOpPolygon
+	kOpStrokePolygon
+	kOpPolyline
+	kOpSetFallbackFonts
+	kOpCount
+)
+
+// picture_op_info_t describes the arguments of an op. |nargs| is -1 if the
+// count varies, like the points of a polygon.
+type picture_op_info_t struct {
+	name  string
+	nargs int
+	text  bool
+	image bool
+	paint bool
+}
+
+var g_picture_op_infos = [kOpCount]picture_op_info_t{
+	kOpSaveState:         {"SaveState", 0, false, false, false},
+	kOpRestoreState:      {"RestoreState", 0, false, false, false},
+	kOpTranslate:         {"Translate", 2, false, false, false},
+	kOpScale:             {"Scale", 2, false, false, false},
+	kOpRotate:            {"Rotate", 1, false, false, false},
+	kOpTransform:         {"Transform", 6, false, false, false},
+	kOpSetTransform:      {"SetTransform", 6, false, false, false},
+	kOpResetTransform:    {"ResetTransform", 0, false, false, false},
+	kOpClipRect:          {"ClipRect", 4, false, false, false},
+	kOpClipPath:          {"ClipPath", 0, false, false, false},
+	kOpResetClip:         {"ResetClip", 0, false, false, false},
+	kOpSetStrokeRGBA:     {"SetStrokeRGBA", 4, false, false, false},
+	kOpSetFillRGBA:       {"SetFillRGBA", 4, false, false, false},
+	kOpSetFontRGBA:       {"SetFontRGBA", 4, false, false, false},
+	kOpSetStrokePaint:    {"SetStrokePaint", 0, false, false, true},
+	kOpSetFillPaint:      {"SetFillPaint", 0, false, false, true},
+	kOpSetFontPaint:      {"SetFontPaint", 0, false, false, true},
+	kOpSetFontSize:       {"SetFontSize", 1, false, false, false},
+	kOpSetFont:           {"SetFont", 0, true, false, false},
+	kOpSetFontFace:       {"SetFontFace", 0, false, false, false},
+	kOpSetDither:         {"SetDither", 1, false, false, false},
+	kOpSetGlobalAlpha:    {"SetGlobalAlpha", 1, false, false, false},
+	kOpSetCompositeOp:    {"SetCompositeOp", 1, false, false, false},
+	kOpSetImageFilter:    {"SetImageFilter", 1, false, false, false},
+	kOpSetFillRule:       {"SetFillRule", 1, false, false, false},
+	kOpSetLineWidth:      {"SetLineWidth", 1, false, false, false},
+	kOpSetLineCap:        {"SetLineCap", 1, false, false, false},
+	kOpSetLineJoin:       {"SetLineJoin", 1, false, false, false},
+	kOpSetMiterLimit:     {"SetMiterLimit", 1, false, false, false},
+	kOpSetLineDash:       {"SetLineDash", -1, false, false, false},
+	kOpSetLineDashOffset: {"SetLineDashOffset", 1, false, false, false},
+	kOpBeginPath:         {"BeginPath", 0, false, false, false},
+	kOpMoveTo:            {"MoveTo", 2, false, false, false},
+	kOpLineTo:            {"LineTo", 2, false, false, false},
+	kOpQuadTo:            {"QuadTo", 4, false, false, false},
+	kOpCubicTo:           {"CubicTo", 6, false, false, false},
+	kOpClosePath:         {"ClosePath", 0, false, false, false},
+	kOpFill:              {"Fill", 0, false, false, false},
+	kOpStroke:            {"Stroke", 0, false, false, false},
+	kOpDrawText:          {"DrawText", 4, true, false, false},
+	kOpDrawColor:         {"DrawColor", 3, false, false, false},
+	kOpDrawAlpha:         {"DrawAlpha", 2, false, true, false},
+	kOpDrawRGBA:          {"DrawRGBA", 2, false, true, false},
+	kOpDrawCanvas:        {"DrawCanvas", 2, false, true, false},
+	kOpAlphaBlend:        {"AlphaBlend", 2, false, true, false},
+	kOpDrawStretch:       {"DrawStretch", 4, false, true, false},
+	kOpDrawNinePatch:     {"DrawNinePatch", 13, false, true, false},
+	kOpFillRect:          {"FillRect", 4, false, false, false},
+	kOpStrokeRect:        {"StrokeRect", 4, false, false, false},
+	kOpDrawShadow:        {"DrawShadow", 15, false, false, false},
+	kOpFillRoundedRect:   {"FillRoundedRect", 8, false, false, false},
+	kOpStrokeRoundedRect: {"StrokeRoundedRect", 8, false, false, false},
+	kOpFillEllipse:       {"FillEllipse", 4, false, false, false},
+	kOpStrokeEllipse:     {"StrokeEllipse", 4, false, false, false},
+	kOpArc:               {"Arc", 6, false, false, false},
+	kOpPie:               {"Pie", 6, false, false, false},
+	kOpStrokePie:         {"StrokePie", 6, false, false, false},
+	kOpPolygon:           {"Polygon", -1, false, false, false},
+	kOpStrokePolygon:     {"StrokePolygon", -1, false, false, false},
+	kOpPolyline:          {"Polyline", -1, false, false, false},
+	kOpSetFallbackFonts:  {"SetFallbackFonts", 0, true, false, false},
+}
+
+// picture_op_t is one recorded call. The images are the copies of the drawn
+// parts, the paints are the copies at the time of the call.
+type picture_op_t struct {
+	code  picture_op_code_t
+	args  []float64
+	text  string
+	image *Canvas
+	paint Paint
+	face  *freetype.Font
+}
+
+// Picture is the immutable list of the draws recorded by a Recorder. It can
+// be drawn many times onto any Context, from any goroutine as long as the
+// Context is used by one goroutine at a time.
+type Picture struct {
+	ops    []picture_op_t
+	cull   image.Rectangle
+	bounds image.Rectangle
+
+	// The font measures the texts for the bounds.
+	font *Font
+}
+
+// Bounds returns the bounds of the pixels the picture may draw, in the
+// coordinate it was recorded in. It's conservative, the picture draws nothing
+// outside of it, but may not reach all of it.
+func (p *Picture) Bounds() image.Rectangle {
+	return p.bounds
+}
+
+// CullRect returns the rect given to NewRecorder.
+func (p *Picture) CullRect() image.Rectangle {
+	return p.cull
+}
+
+// String returns the recorded calls one per line, for the debugging.
+func (p *Picture) String() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Picture %v\n", p.bounds)
+	for _, op := range p.ops {
+		info := &g_picture_op_infos[op.code]
+		fmt.Fprintf(&buf, "%v%v", info.name, op.args)
+		if info.text {
+			fmt.Fprintf(&buf, " %q", op.text)
+		}
+		if op.image != nil {
+			fmt.Fprintf(&buf, " %v %vx%v", op.image.Format(), op.image.W(), op.image.H())
+		}
+		if op.paint != nil {
+			fmt.Fprintf(&buf, " %T", op.paint)
+		}
+		buf.WriteByte('\n')
+	}
+	return buf.String()
+}
+
+// DrawPicture draws the picture through the current transform and clip.
+func (c *CanvasContext) DrawPicture(p *Picture) {
+	p.Replay(c, IdentityMatrix(), p.cull)
+}
+
+// replay_keeper_t is the contexts which keep their current path over the
+// replay, and can put their clip back for ResetClip in the picture.
+type replay_keeper_t interface {
+	with_path(build func(), draw func())
+	save_clip() interface{}
+	restore_clip(clip interface{})
+}
+
+// replay_base_t is where the picture is replayed, SetTransform,
+// ResetTransform and ResetClip in the picture are relative to it.
+type replay_base_t struct {
+	transform Matrix
+	clip      image.Rectangle // In the coordinate of the picture.
+	keeper    replay_keeper_t
+	kept_clip interface{}
+}
+
+func (b *replay_base_t) reset_clip(c Context) {
+	if b.keeper != nil {
+		b.keeper.restore_clip(b.kept_clip)
+		return
+	}
+	// The clip of the caller can't be put back, only the one of the picture.
+	m := c.CurrentTransform()
+	c.ResetClip()
+	c.SetTransform(b.transform)
+	c.ClipRect(b.clip)
+	c.SetTransform(m)
+}
+
+// Replay draws the picture onto |c| through |m| after the current transform,
+// limited by the current clip and |clip| in the coordinate of the picture.
+// The state of |c| is kept, and the current path too by CanvasContext and the
+// vector writers. SetTransform, ResetTransform and ResetClip in the picture
+// are relative to where it's replayed.
+func (p *Picture) Replay(c Context, m Matrix, clip image.Rectangle) {
+	depth := c.StateDepth()
+	c.SaveState()
+	c.Transform(m)
+	c.ClipRect(clip)
+	base := &replay_base_t{transform: c.CurrentTransform(), clip: clip}
+
+	replay := func() {
+		for i := range p.ops {
+			p.ops[i].replay(c, base, depth+1)
+		}
+	}
+	if keeper, ok := c.(replay_keeper_t); ok {
+		base.keeper, base.kept_clip = keeper, keeper.save_clip()
+		keeper.with_path(replay, func() {})
+	} else {
+		replay()
+	}
+
+	// The unbalanced SaveState calls of the picture are undone too.
+	for c.StateDepth() > depth {
+		c.RestoreState()
+	}
+}
+
+// replay calls the Context method of the op. The states below |depth| are
+// never restored.
+func (op *picture_op_t) replay(c Context, base *replay_base_t, depth int) {
+	a := op.args
+	i := func(k int) int { return int(a[k]) }
+	b := func(k int) byte { return byte(a[k]) }
+	rect := func(k int) image.Rectangle { return image.Rect(i(k), i(k+1), i(k+2), i(k+3)) }
+	points := func() []image.Point {
+		pts := make([]image.Point, len(a)/2)
+		for k := range pts {
+			pts[k] = image.Pt(i(2*k), i(2*k+1))
+		}
+		return pts
+	}
+
+	switch op.code {
+	case kOpSaveState:
+		c.SaveState()
+	case kOpRestoreState:
+		if c.StateDepth() > depth {
+			c.RestoreState()
+		}
+	case kOpTranslate:
+		c.Translate(a[0], a[1])
+	case kOpScale:
+		c.Scale(a[0], a[1])
+	case kOpRotate:
+		c.Rotate(a[0])
+	case kOpTransform:
+		c.Transform(Matrix{a[0], a[1], a[2], a[3], a[4], a[5]})
+	case kOpSetTransform:
+		c.SetTransform(base.transform.Multiply(Matrix{a[0], a[1], a[2], a[3], a[4], a[5]}))
+	case kOpResetTransform:
+		c.SetTransform(base.transform)
+	case kOpClipRect:
+		c.ClipRect(rect(0))
+	case kOpClipPath:
+		c.ClipPath()
+	case kOpResetClip:
+		base.reset_clip(c)
+	case kOpSetStrokeRGBA:
+		c.SetStrokeRGBA(b(0), b(1), b(2), b(3))
+	case kOpSetFillRGBA:
+		c.SetFillRGBA(b(0), b(1), b(2), b(3))
+	case kOpSetFontRGBA:
+		c.SetFontRGBA(b(0), b(1), b(2), b(3))
+	case kOpSetStrokePaint:
+		c.SetStrokePaint(op.paint)
+	case kOpSetFillPaint:
+		c.SetFillPaint(op.paint)
+	case kOpSetFontPaint:
+		c.SetFontPaint(op.paint)
+	case kOpSetFontSize:
+		c.SetFontSize(a[0])
+	case kOpSetFont:
+		c.SetFont(op.text)
+	case kOpSetFontFace:
+		c.SetFontFace(op.face)
+	case kOpSetDither:
+		c.SetDither(a[0] != 0)
+	case kOpSetGlobalAlpha:
+		c.SetGlobalAlpha(a[0])
+	case kOpSetCompositeOp:
+		c.SetCompositeOp(CompositeOp(a[0]))
+	case kOpSetImageFilter:
+		c.SetImageFilter(Filter(a[0]))
+	case kOpSetFillRule:
+		c.SetFillRule(FillRule(a[0]))
+	case kOpSetLineWidth:
+		c.SetLineWidth(a[0])
+	case kOpSetLineCap:
+		c.SetLineCap(LineCap(a[0]))
+	case kOpSetLineJoin:
+		c.SetLineJoin(LineJoin(a[0]))
+	case kOpSetMiterLimit:
+		c.SetMiterLimit(a[0])
+	case kOpSetLineDash:
+		c.SetLineDash(a)
+	case kOpSetLineDashOffset:
+		c.SetLineDashOffset(a[0])
+	case kOpBeginPath:
+		c.BeginPath()
+	case kOpMoveTo:
+		c.MoveTo(a[0], a[1])
+	case kOpLineTo:
+		c.LineTo(a[0], a[1])
+	case kOpQuadTo:
+		c.QuadTo(a[0], a[1], a[2], a[3])
+	case kOpCubicTo:
+		c.CubicTo(a[0], a[1], a[2], a[3], a[4], a[5])
+	case kOpClosePath:
+		c.ClosePath()
+	case kOpFill:
+		c.Fill()
+	case kOpStroke:
+		c.Stroke()
+	case kOpDrawText:
+		c.DrawText(op.text, rect(0))
+	case kOpDrawColor:
+		c.DrawColor(b(0), b(1), b(2))
+	case kOpDrawAlpha:
+		src := op.image
+		c.DrawAlpha(i(0), i(1), &image.Alpha{Pix: src.Pix(), Stride: src.Stride(),
+			Rect: src.LocalBounds()}, src.LocalBounds())
+	case kOpDrawRGBA:
+		// The image is kept as BGRA8, the red and the blue are swapped back.
+		src := op.image.ConvertTo(PixelFormatBGRA8)
+		pix := src.Pix()
+		for k := 0; k < len(pix); k += 4 {
+			pix[k+0], pix[k+2] = pix[k+2], pix[k+0]
+		}
+		c.DrawRGBA(i(0), i(1), &image.RGBA{Pix: pix, Stride: src.Stride(),
+			Rect: src.LocalBounds()}, src.LocalBounds())
+	case kOpDrawCanvas:
+		c.DrawCanvas(i(0), i(1), op.image, op.image.LocalBounds())
+	case kOpAlphaBlend:
+		c.AlphaBlend(i(0), i(1), op.image, op.image.LocalBounds())
+	case kOpDrawStretch:
+		c.DrawStretch(rect(0), op.image, op.image.LocalBounds())
+	case kOpDrawNinePatch:
+		np := &NinePatch{
+			Canvas:  op.image,
+			Insets:  Insets{i(4), i(5), i(6), i(7)},
+			Padding: Insets{i(8), i(9), i(10), i(11)},
+			Mode:    NinePatchMode(a[12]),
+		}
+		c.DrawNinePatch(np, rect(0))
+	case kOpFillRect:
+		c.FillRect(rect(0))
+	case kOpStrokeRect:
+		c.StrokeRect(rect(0))
+	case kOpDrawShadow:
+		c.DrawShadow(rect(0), CornerRadii{a[4], a[5], a[6], a[7]}, Shadow{
+			DX: i(8), DY: i(9), Blur: a[10], Color: SolidPaint{b(11), b(12), b(13), b(14)},
+		})
+	case kOpFillRoundedRect:
+		c.FillRoundedRect(rect(0), CornerRadii{a[4], a[5], a[6], a[7]})
+	case kOpStrokeRoundedRect:
+		c.StrokeRoundedRect(rect(0), CornerRadii{a[4], a[5], a[6], a[7]})
+	case kOpFillEllipse:
+		c.FillEllipse(a[0], a[1], a[2], a[3])
+	case kOpStrokeEllipse:
+		c.StrokeEllipse(a[0], a[1], a[2], a[3])
+	case kOpArc:
+		c.Arc(a[0], a[1], a[2], a[3], a[4], a[5])
+	case kOpPie:
+		c.Pie(a[0], a[1], a[2], a[3], a[4], a[5])
+	case kOpStrokePie:
+		c.StrokePie(a[0], a[1], a[2], a[3], a[4], a[5])
+	case kOpPolygon:
+		c.Polygon(points())
+	case kOpStrokePolygon:
+		c.StrokePolygon(points())
+	case kOpPolyline:
+		c.Polyline(points())
+	case kOpSetFallbackFonts:
+		if op.text == "" {
+			c.SetFallbackFonts()
+		} else {
+			c.SetFallbackFonts(strings.Split(op.text, "\n")...)
+		}
+	}
+}
+
+// recorder_state_t is the part of the graphics state the Recorder follows to
+// find the bounds of the draws.
+type recorder_state_t struct {
+	transform    Matrix
+	clip         image.Rectangle // In the coordinate of the picture.
+	font_face    *freetype.Font
+	font_size    float64
+	global_alpha float64
+	line_width   float64
+	line_join    LineJoin
+	miter_limit  float64
+
+	font_fallbacks []*freetype.Font
+}
+
+// Recorder records the calls of the Context methods it has into a Picture,
+// instead of drawing them. The methods are the same as the ones of Context.
+type Recorder struct {
+	recorder_state_t
+	stack []recorder_state_t
+
+	ops    []picture_op_t
+	cull   image.Rectangle
+	bounds image.Rectangle
+
+	// The font measures the texts for the bounds.
+	font *Font
+
+	// The bounds of the current path in the coordinate of the picture.
+	path_bounds [4]float64
+	has_path    bool
+
+	// The copies of the drawn images, so the same part of a canvas is kept
+	// once.
+	images map[recorder_image_key_t]*Canvas
+}
+
+type recorder_image_key_t struct {
+	src  *Canvas
+	rect image.Rectangle
+}
+
+// NewRecorder returns a Recorder of the draws limited to |cull|, like the
+// canvas the picture is going to be drawn to.
+func NewRecorder(cull image.Rectangle) *Recorder {
+	r := &Recorder{cull: cull, images: make(map[recorder_image_key_t]*Canvas)}
+	r.font = NewFont()
+	r.transform = IdentityMatrix()
+	r.clip = cull
+	r.font_face = r.font.font
+	r.font_size = r.font.size
+	r.global_alpha = 1
+	r.line_width = 1
+	r.miter_limit = 10
+	return r
+}
+
+// Finish returns the picture of the recorded calls. The recorder can be used
+// for the next picture, starting over with the initial state.
+func (r *Recorder) Finish() *Picture {
+	p := &Picture{ops: r.ops, cull: r.cull, bounds: r.bounds}
+	*r = *NewRecorder(r.cull)
+	return p
+}
+
+func (r *Recorder) record(code picture_op_code_t, args ...float64) *picture_op_t {
+	r.ops = append(r.ops, picture_op_t{code: code, args: args})
+	return &r.ops[len(r.ops)-1]
+}
+
+// add_bounds adds the |rect| in the coordinate of the picture to the bounds,
+// limited by the clip.
+func (r *Recorder) add_bounds(rect image.Rectangle) {
+	r.bounds = r.bounds.Union(rect.Intersect(r.clip))
+}
+
+// device_rect returns the bounds of the rect in the user space transformed,
+// outset by |outset| pixels.
+func (r *Recorder) device_rect(x0, y0, x1, y1, outset float64) image.Rectangle {
+	fx0, fy0 := math.Inf(1), math.Inf(1)
+	fx1, fy1 := math.Inf(-1), math.Inf(-1)
+	for _, pt := range [4][2]float64{{x0, y0}, {x1, y0}, {x0, y1}, {x1, y1}} {
+		x, y := r.transform.TransformPoint(pt[0], pt[1])
+		fx0, fy0 = math.Min(fx0, x), math.Min(fy0, y)
+		fx1, fy1 = math.Max(fx1, x), math.Max(fy1, y)
+	}
+	return image.Rect(int(math.Floor(fx0-outset)), int(math.Floor(fy0-outset)),
+		int(math.Ceil(fx1+outset)), int(math.Ceil(fy1+outset)))
+}
+
+// stroke_outset returns how far a stroke reaches out of its path, in pixels.
+func (r *Recorder) stroke_outset() float64 {
+	k := math.Sqrt2 // The corners of the square caps.
+	if r.line_join == LineJoinMiter {
+		k = math.Max(k, r.miter_limit)
+	}
+	return r.line_width / 2 * r.transform.scale_factor() * k
+}
+
+func (r *Recorder) add_path_point(x, y float64) {
+	x, y = r.transform.TransformPoint(x, y)
+	if !r.has_path {
+		r.path_bounds = [4]float64{x, y, x, y}
+		r.has_path = true
+		return
+	}
+	b := &r.path_bounds
+	b[0], b[1] = math.Min(b[0], x), math.Min(b[1], y)
+	b[2], b[3] = math.Max(b[2], x), math.Max(b[3], y)
+}
+
+// path_rect returns the bounds of the current path outset by |outset|.
+func (r *Recorder) path_rect(outset float64) image.Rectangle {
+	if !r.has_path {
+		return image.Rectangle{}
+	}
+	b := r.path_bounds
+	return image.Rect(int(math.Floor(b[0]-outset)), int(math.Floor(b[1]-outset)),
+		int(math.Ceil(b[2]+outset)), int(math.Ceil(b[3]+outset)))
+}
+
+// image_of returns the copy of the |rect| of |src| recorded once.
+func (r *Recorder) image_of(src *Canvas, rect image.Rectangle) *Canvas {
+	rect = rect.Intersect(src.LocalBounds())
+	key := recorder_image_key_t{src, rect}
+	if img, ok := r.images[key]; ok {
+		return img
+	}
+	img := src.SubCanvas(rect).ConvertTo(src.Format())
+	r.images[key] = img
+	return img
+}
+
+// copy_paint returns the copy of the gradients, which can be changed after.
+func copy_paint(paint Paint) Paint {
+	copy_gradient := func(gr Gradient) Gradient {
+		gr.stops = append([]ColorStop(nil), gr.stops...)
+		return gr
+	}
+	switch p := paint.(type) {
+	case *LinearGradient:
+		lg := *p
+		lg.Gradient = copy_gradient(p.Gradient)
+		return &lg
+	case *RadialGradient:
+		rg := *p
+		rg.Gradient = copy_gradient(p.Gradient)
+		return &rg
+	case *ConicGradient:
+		cg := *p
+		cg.Gradient = copy_gradient(p.Gradient)
+		return &cg
+	}
+	return paint
+}
+
+func (r *Recorder) SaveState() {
+	r.stack = append(r.stack, r.recorder_state_t)
+	r.record(kOpSaveState)
+}
+
+func (r *Recorder) RestoreState() {
+	if n := len(r.stack); n > 0 {
+		r.recorder_state_t = r.stack[n-1]
+		r.stack = r.stack[:n-1]
+		r.font.SetFontFace(r.font_face)
+		r.font.SetFontSize(r.font_size)
+		r.font.SetFallbacks(r.font_fallbacks)
+	}
+	r.record(kOpRestoreState)
+}
+
+func (r *Recorder) StateDepth() int {
+	return len(r.stack)
+}
+
+func (r *Recorder) Translate(tx, ty float64) {
+	r.transform = r.transform.Multiply(TranslateMatrix(tx, ty))
+	r.record(kOpTranslate, tx, ty)
+}
+
+func (r *Recorder) Scale(sx, sy float64) {
+	r.transform = r.transform.Multiply(ScaleMatrix(sx, sy))
+	r.record(kOpScale, sx, sy)
+}
+
+func (r *Recorder) Rotate(angle float64) {
+	r.transform = r.transform.Multiply(RotateMatrix(angle))
+	r.record(kOpRotate, angle)
+}
+
+func (r *Recorder) Transform(m Matrix) {
+	r.transform = r.transform.Multiply(m)
+	r.record(kOpTransform, m.A, m.B, m.C, m.D, m.E, m.F)
+}
+
+func (r *Recorder) SetTransform(m Matrix) {
+	r.transform = m
+	r.record(kOpSetTransform, m.A, m.B, m.C, m.D, m.E, m.F)
+}
+
+func (r *Recorder) ResetTransform() {
+	r.transform = IdentityMatrix()
+	r.record(kOpResetTransform)
+}
+
+func (r *Recorder) CurrentTransform() Matrix {
+	return r.transform
+}
+
+func (r *Recorder) ClipRect(rect image.Rectangle) {
+	r.clip = r.clip.Intersect(r.device_rect(float64(rect.Min.X), float64(rect.Min.Y),
+		float64(rect.Max.X), float64(rect.Max.Y), 0))
+	r.record(kOpClipRect, float64(rect.Min.X), float64(rect.Min.Y), float64(rect.Max.X), float64(rect.Max.Y))
+}
+
+func (r *Recorder) ClipPath() {
+	r.clip = r.clip.Intersect(r.path_rect(1))
+	r.record(kOpClipPath)
+}
+
+func (r *Recorder) ResetClip() {
+	r.clip = r.cull
+	r.record(kOpResetClip)
+}
+
+func (r *Recorder) SetStrokeColor(red, g, b byte) {
+	r.SetStrokeRGBA(red, g, b, 0xff)
+}
+
+func (r *Recorder) SetStrokeRGBA(red, g, b, a byte) {
+	r.record(kOpSetStrokeRGBA, float64(red), float64(g), float64(b), float64(a))
+}
+
+func (r *Recorder) SetFillColor(red, g, b byte) {
+	r.SetFillRGBA(red, g, b, 0xff)
+}
+
+func (r *Recorder) SetFillRGBA(red, g, b, a byte) {
+	r.record(kOpSetFillRGBA, float64(red), float64(g), float64(b), float64(a))
+}
+
+func (r *Recorder) SetFontColor(red, g, b byte) {
+	r.SetFontRGBA(red, g, b, 0xff)
+}
+
+func (r *Recorder) SetFontRGBA(red, g, b, a byte) {
+	r.record(kOpSetFontRGBA, float64(red), float64(g), float64(b), float64(a))
+}
+
+func (r *Recorder) SetStrokePaint(paint Paint) {
+	r.record(kOpSetStrokePaint).paint = copy_paint(paint)
+}
+
+func (r *Recorder) SetFillPaint(paint Paint) {
+	r.record(kOpSetFillPaint).paint = copy_paint(paint)
+}
+
+func (r *Recorder) SetFontPaint(paint Paint) {
+	r.record(kOpSetFontPaint).paint = copy_paint(paint)
+}
+
+func (r *Recorder) SetFontSize(size float64) {
+	r.font_size = size
+	r.font.SetFontSize(size)
+	r.record(kOpSetFontSize, size)
+}
+
+func (r *Recorder) FontSize() float64 {
+	return r.font_size
+}
+
+// SetFont records the font by |font_name|, so the picture with it is played
+// with the font matched by the context it's played to.
+func (r *Recorder) SetFont(font_name string) {
+	if face, size := font_by_name(font_name); face != nil {
+		r.font_face = face
+		r.font.SetFontFace(face)
+		if size > 0 {
+			r.font_size = size
+			r.font.SetFontSize(size)
+		}
+	}
+	r.record(kOpSetFont).text = font_name
+}
+
+// SetFallbackFonts records the fallback fonts by the names, like SetFont.
+func (r *Recorder) SetFallbackFonts(font_names ...string) {
+	r.font_fallbacks = fallbacks_by_names(font_names)
+	r.font.SetFallbacks(r.font_fallbacks)
+	r.record(kOpSetFallbackFonts).text = strings.Join(font_names, "\n")
+}
+
+// SetFontFace records the font by the pointer, the picture with it can't be
+// encoded.
+func (r *Recorder) SetFontFace(face *freetype.Font) {
+	r.font_face = face
+	r.font.SetFontFace(face)
+	r.record(kOpSetFontFace).face = face
+}
+
+func (r *Recorder) SetDither(dither bool) {
+	v := 0.0
+	if dither {
+		v = 1
+	}
+	r.record(kOpSetDither, v)
+}
+
+func (r *Recorder) SetGlobalAlpha(alpha float64) {
+	r.global_alpha = math.Max(0, math.Min(1, alpha))
+	r.record(kOpSetGlobalAlpha, alpha)
+}
+
+func (r *Recorder) GlobalAlpha() float64 {
+	return r.global_alpha
+}
+
+func (r *Recorder) SetCompositeOp(op CompositeOp) {
+	r.record(kOpSetCompositeOp, float64(op))
+}
+
+func (r *Recorder) SetImageFilter(filter Filter) {
+	r.record(kOpSetImageFilter, float64(filter))
+}
+
+func (r *Recorder) SetFillRule(rule FillRule) {
+	r.record(kOpSetFillRule, float64(rule))
+}
+
+func (r *Recorder) SetLineWidth(width float64) {
+	r.line_width = width
+	r.record(kOpSetLineWidth, width)
+}
+
+func (r *Recorder) LineWidth() float64 {
+	return r.line_width
+}
+
+func (r *Recorder) SetLineCap(line_cap LineCap) {
+	r.record(kOpSetLineCap, float64(line_cap))
+}
+
+func (r *Recorder) SetLineJoin(line_join LineJoin) {
+	r.line_join = line_join
+	r.record(kOpSetLineJoin, float64(line_join))
+}
+
+func (r *Recorder) SetMiterLimit(limit float64) {
+	r.miter_limit = limit
+	r.record(kOpSetMiterLimit, limit)
+}
+
+func (r *Recorder) SetLineDash(dashes []float64) {
+	r.record(kOpSetLineDash, append([]float64(nil), dashes...)...)
+}
+
+func (r *Recorder) SetLineDashOffset(offset float64) {
+	r.record(kOpSetLineDashOffset, offset)
+}
+
+func (r *Recorder) BeginPath() {
+	r.has_path = false
+	r.record(kOpBeginPath)
+}
+
+func (r *Recorder) MoveTo(x, y float64) {
+	r.add_path_point(x, y)
+	r.record(kOpMoveTo, x, y)
+}
+
+func (r *Recorder) LineTo(x, y float64) {
+	r.add_path_point(x, y)
+	r.record(kOpLineTo, x, y)
+}
+
+func (r *Recorder) QuadTo(cx, cy, x, y float64) {
+	r.add_path_point(cx, cy)
+	r.add_path_point(x, y)
+	r.record(kOpQuadTo, cx, cy, x, y)
+}
+
+func (r *Recorder) CubicTo(c1x, c1y, c2x, c2y, x, y float64) {
+	r.add_path_point(c1x, c1y)
+	r.add_path_point(c2x, c2y)
+	r.add_path_point(x, y)
+	r.record(kOpCubicTo, c1x, c1y, c2x, c2y, x, y)
+}
+
+func (r *Recorder) ClosePath() {
+	r.record(kOpClosePath)
+}
+
+func (r *Recorder) Fill() {
+	r.add_bounds(r.path_rect(1))
+	r.record(kOpFill)
+}
+
+func (r *Recorder) Stroke() {
+	r.add_bounds(r.path_rect(r.stroke_outset() + 1))
+	r.record(kOpStroke)
+}
+
+func (r *Recorder) FontMetrics() FontMetrics {
+	return r.font.Metrics()
+}
+
+func (r *Recorder) MeasureText(text string) TextMetrics {
+	return r.font.MeasureText(text)
+}
+
+// DrawText records the text, and returns the pen position after it like
+// CanvasContext.DrawText. The bounds are the ink bounds of the glyphs, or
+// guessed by the font size without a font.
+func (r *Recorder) DrawText(text string, rect image.Rectangle) (freetype.RastPoint, error) {
+	r.record(kOpDrawText, float64(rect.Min.X), float64(rect.Min.Y),
+		float64(rect.Max.X), float64(rect.Max.Y)).text = text
+	if r.font.font == nil {
+		pt := freetype.Point(rect.Min.X, rect.Min.Y+int(r.font_size))
+		end := pt.Add(freetype.Point(len([]rune(text))*int(r.font_size), 0))
+		x0, x1, y := float64(pt.X)/256, float64(end.X)/256, float64(pt.Y)/256
+		r.add_bounds(r.device_rect(x0-1, y-r.font_size*1.5, x1+r.font_size/2, y+r.font_size, 1))
+		return end, nil
+	}
+
+	pt := r.font.text_origin(rect)
+	end, err := r.font.walk_text(text, pt, nil)
+	if err != nil {
+		return freetype.RastPoint{}, err
+	}
+	if ink := r.font.MeasureText(text).InkBounds; !ink.Empty() {
+		ink = ink.Add(image.Pt(int(pt.X>>8), int(pt.Y>>8)))
+		r.add_bounds(r.device_rect(float64(ink.Min.X), float64(ink.Min.Y),
+			float64(ink.Max.X), float64(ink.Max.Y), 1))
+	}
+	return end, nil
+}
+
+func (r *Recorder) DrawColor(red, g, b byte) {
+	r.add_bounds(r.clip)
+	r.record(kOpDrawColor, float64(red), float64(g), float64(b))
+}
+
+// add_int_image_bounds adds the bounds of an image at (x, y), translated by
+// the whole pixels of the transform like the draws of Context which don't
+// resample.
+func (r *Recorder) add_int_image_bounds(x, y int, size image.Point) {
+	dx, dy, _ := r.transform.int_translation()
+	r.add_bounds(image.Rectangle{image.Pt(x+dx, y+dy), image.Pt(x+dx, y+dy).Add(size)})
+}
+
+func (r *Recorder) DrawImage(x, y int, src image.Image, rect image.Rectangle) {
+	if alpha, ok := src.(*image.Alpha); ok {
+		r.DrawAlpha(x, y, alpha, rect)
+	}
+}
+
+func (r *Recorder) DrawAlpha(x, y int, src *image.Alpha, rect image.Rectangle) {
+	rect = rect.Intersect(src.Bounds())
+	img := CanvasFromImage(src.SubImage(rect))
+	r.add_int_image_bounds(x, y, rect.Size())
+	r.record(kOpDrawAlpha, float64(x), float64(y)).image = img
+}
+
+func (r *Recorder) DrawNRGBA(x, y int, src *image.NRGBA, rect image.Rectangle) {
+	img := CanvasFromImage(src.SubImage(rect.Intersect(src.Bounds())))
+	r.AlphaBlend(x, y, img, img.LocalBounds())
+}
+
+func (r *Recorder) DrawRGBA(x, y int, src *image.RGBA, rect image.Rectangle) {
+	rect = rect.Intersect(src.Bounds())
+	img := CanvasFromImage(src.SubImage(rect))
+	r.add_int_image_bounds(x, y, rect.Size())
+	r.record(kOpDrawRGBA, float64(x), float64(y)).image = img
+}
+
+func (r *Recorder) DrawCanvas(x, y int, src *Canvas, rect image.Rectangle) {
+	img := r.image_of(src, rect)
+	r.add_int_image_bounds(x, y, img.LocalBounds().Size())
+	r.record(kOpDrawCanvas, float64(x), float64(y)).image = img
+}
+
+func (r *Recorder) AlphaBlend(x, y int, src *Canvas, rect image.Rectangle) {
+	img := r.image_of(src, rect)
+	w, h := float64(img.W()), float64(img.H())
+	r.add_bounds(r.device_rect(float64(x), float64(y), float64(x)+w, float64(y)+h, 1))
+	r.record(kOpAlphaBlend, float64(x), float64(y)).image = img
+}
+
+func (r *Recorder) DrawStretch(dst_rect image.Rectangle, src *Canvas, src_rect image.Rectangle) {
+	r.add_rect_bounds(dst_rect, 1)
+	r.record(kOpDrawStretch, rect_args(dst_rect)...).image = r.image_of(src, src_rect)
+}
+
+func (r *Recorder) DrawNinePatch(np *NinePatch, rect image.Rectangle) {
+	if np == nil || np.Canvas == nil {
+		return
+	}
+	r.add_rect_bounds(rect, 1)
+	in, pad := np.Insets, np.Padding
+	args := append(rect_args(rect),
+		float64(in.Left), float64(in.Top), float64(in.Right), float64(in.Bottom),
+		float64(pad.Left), float64(pad.Top), float64(pad.Right), float64(pad.Bottom),
+		float64(np.Mode))
+	r.record(kOpDrawNinePatch, args...).image = r.image_of(np.Canvas, np.Canvas.LocalBounds())
+}
+
+// DrawPicture records the ops of |p| like it's replayed here, except the
+// current path isn't kept over them.
+func (r *Recorder) DrawPicture(p *Picture) {
+	p.Replay(r, IdentityMatrix(), p.cull)
+}
+
+func (r *Recorder) FillRect(rect image.Rectangle) {
+	r.add_rect_bounds(rect, 1)
+	r.record(kOpFillRect, rect_args(rect)...)
+}
+
+func (r *Recorder) StrokeRect(rect image.Rectangle) {
+	r.add_rect_bounds(rect, r.stroke_outset()+1)
+	r.record(kOpStrokeRect, rect_args(rect)...)
+}
+
+func (r *Recorder) DrawShadow(rect image.Rectangle, radii CornerRadii, shadow Shadow) {
+	r.add_bounds(shadow.Bounds(r.device_rect(float64(rect.Min.X), float64(rect.Min.Y),
+		float64(rect.Max.X), float64(rect.Max.Y), 1)))
+	clr := shadow.Color
+	args := append(rect_args(rect), radii_args(radii)...)
+	args = append(args, float64(shadow.DX), float64(shadow.DY), shadow.Blur,
+		float64(clr.R), float64(clr.G), float64(clr.B), float64(clr.A))
+	r.record(kOpDrawShadow, args...)
+}
+
+func (r *Recorder) FillRoundedRect(rect image.Rectangle, radii CornerRadii) {
+	r.add_rect_bounds(rect, 1)
+	r.record(kOpFillRoundedRect, append(rect_args(rect), radii_args(radii)...)...)
+}
+
+func (r *Recorder) StrokeRoundedRect(rect image.Rectangle, radii CornerRadii) {
+	r.add_rect_bounds(rect, r.stroke_outset()+1)
+	r.record(kOpStrokeRoundedRect, append(rect_args(rect), radii_args(radii)...)...)
+}
+
+func (r *Recorder) FillEllipse(cx, cy, rx, ry float64) {
+	r.add_bounds(r.device_rect(cx-rx, cy-ry, cx+rx, cy+ry, 1))
+	r.record(kOpFillEllipse, cx, cy, rx, ry)
+}
+
+func (r *Recorder) StrokeEllipse(cx, cy, rx, ry float64) {
+	r.add_bounds(r.device_rect(cx-rx, cy-ry, cx+rx, cy+ry, r.stroke_outset()+1))
+	r.record(kOpStrokeEllipse, cx, cy, rx, ry)
+}
+
+// The arcs and the pies are bounded by their whole ellipses.
+
+func (r *Recorder) Arc(cx, cy, rx, ry, start, sweep float64) {
+	r.add_bounds(r.device_rect(cx-rx, cy-ry, cx+rx, cy+ry, r.stroke_outset()+1))
+	r.record(kOpArc, cx, cy, rx, ry, start, sweep)
+}
+
+func (r *Recorder) Pie(cx, cy, rx, ry, start, sweep float64) {
+	r.add_bounds(r.device_rect(cx-rx, cy-ry, cx+rx, cy+ry, 1))
+	r.record(kOpPie, cx, cy, rx, ry, start, sweep)
+}
+
+func (r *Recorder) StrokePie(cx, cy, rx, ry, start, sweep float64) {
+	r.add_bounds(r.device_rect(cx-rx, cy-ry, cx+rx, cy+ry, r.stroke_outset()+1))
+	r.record(kOpStrokePie, cx, cy, rx, ry, start, sweep)
+}
+
+func (r *Recorder) Polygon(points []image.Point) {
+	r.add_points_bounds(points, 1)
+	r.record(kOpPolygon, points_args(points)...)
+}
+
+func (r *Recorder) StrokePolygon(points []image.Point) {
+	r.add_points_bounds(points, r.stroke_outset()+1)
+	r.record(kOpStrokePolygon, points_args(points)...)
+}
+
+func (r *Recorder) Polyline(points []image.Point) {
+	r.add_points_bounds(points, r.stroke_outset()+1)
+	r.record(kOpPolyline, points_args(points)...)
+}
+
+func (r *Recorder) add_rect_bounds(rect image.Rectangle, outset float64) {
+	if rect.Empty() {
+		return
+	}
+	r.add_bounds(r.device_rect(float64(rect.Min.X), float64(rect.Min.Y),
+		float64(rect.Max.X), float64(rect.Max.Y), outset))
+}
+
+func (r *Recorder) add_points_bounds(points []image.Point, outset float64) {
+	if len(points) == 0 {
+		return
+	}
+	b := image.Rectangle{points[0], points[0]}
+	for _, pt := range points[1:] {
+		b.Min.X, b.Min.Y = min_int(b.Min.X, pt.X), min_int(b.Min.Y, pt.Y)
+		b.Max.X, b.Max.Y = max_int(b.Max.X, pt.X), max_int(b.Max.Y, pt.Y)
+	}
+	r.add_bounds(r.device_rect(float64(b.Min.X), float64(b.Min.Y),
+		float64(b.Max.X), float64(b.Max.Y), outset))
+}
+
+func rect_args(rect image.Rectangle) []float64 {
+	return []float64{float64(rect.Min.X), float64(rect.Min.Y), float64(rect.Max.X), float64(rect.Max.Y)}
+}
+
+func radii_args(radii CornerRadii) []float64 {
+	return []float64{radii.TopLeft, radii.TopRight, radii.BottomRight, radii.BottomLeft}
+}
+
+func points_args(points []image.Point) []float64 {
+	args := make([]float64, 0, 2*len(points))
+	for _, pt := range points {
+		args = append(args, float64(pt.X), float64(pt.Y))
+	}
+	return args
+}
+
+func min_int(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max_int(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,429 @@
+// Copyright 2014 By Jshi. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vango
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"image"
+	"io"
+	"math"
+)
+
+// The encoded picture is the magic, the version, and the flate compressed
+// body of the rects, the images and the ops. The integers are varints, the
+// args of the ops are the zigzag varints shifted left by one if they're
+// whole numbers, otherwise 1 and the 8 bytes of the float64.
+const (
+	kPictureMagic   = "VPIC"
+	kPictureVersion = 1
+
+	// The limits of the sides and the pixels of one decoded image, and of
+	// the rects of the ops, against the corrupted sizes.
+	kPictureMaxImageSide = 1 << 14
+	kPictureMaxImageSize = 1 << 24
+
+	// The limit of a count, it only keeps the int in range.
+	kPictureMaxCount = 1 << 28
+)
+
+// The kinds of the encoded paints.
+const (
+	kPaintSolid = iota
+	kPaintLinear
+	kPaintRadial
+	kPaintConic
+)
+
+var (
+	errPictureFontFace = errors.New("vango picture with a font face can't be encoded")
+	errPictureMagic    = errors.New("vango picture bad magic")
+	errPictureVersion  = errors.New("vango picture unknown version")
+	errPictureCorrupt  = errors.New("vango picture corrupted")
+)
+
+// EncodePicture writes the picture to |w| in the compact binary format read
+// by DecodePicture. The picture with SetFontFace can't be encoded, since the
+// font isn't a part of it, use SetFont instead.
+func EncodePicture(w io.Writer, p *Picture) error {
+	if _, err := io.WriteString(w, kPictureMagic); err != nil {
+		return err
+	}
+	var buf [binary.MaxVarintLen64]byte
+	if _, err := w.Write(buf[:binary.PutUvarint(buf[:], kPictureVersion)]); err != nil {
+		return err
+	}
+
+	fw, err := flate.NewWriter(w, flate.DefaultCompression)
+	if err != nil {
+		return err
+	}
+	pw := &picture_writer_t{w: fw}
+
+	// The images are written once, the ops refer to them by the index.
+	images := make(map[*Canvas]int)
+	var list []*Canvas
+	for _, op := range p.ops {
+		if op.code == kOpSetFontFace {
+			return errPictureFontFace
+		}
+		if op.image != nil {
+			if _, ok := images[op.image]; !ok {
+				images[op.image] = len(list)
+				list = append(list, op.image)
+			}
+		}
+	}
+
+	pw.rect(p.cull)
+	pw.rect(p.bounds)
+	pw.uvarint(uint64(len(list)))
+	for _, img := range list {
+		pw.byte(byte(img.Format()))
+		pw.uvarint(uint64(img.W()))
+		pw.uvarint(uint64(img.H()))
+		n := img.W() * img.Format().BytesPerPixel()
+		for y := 0; y < img.H(); y++ {
+			pw.write(img.Pix()[img.PixOffset(0, y):][:n])
+		}
+	}
+
+	pw.uvarint(uint64(len(p.ops)))
+	for _, op := range p.ops {
+		info := &g_picture_op_infos[op.code]
+		pw.byte(byte(op.code))
+		if info.nargs < 0 {
+			pw.uvarint(uint64(len(op.args)))
+		}
+		for _, v := range op.args {
+			pw.arg(v)
+		}
+		if info.text {
+			pw.uvarint(uint64(len(op.text)))
+			pw.write([]byte(op.text))
+		}
+		if info.image {
+			pw.uvarint(uint64(images[op.image]))
+		}
+		if info.paint {
+			pw.paint(op.paint)
+		}
+	}
+
+	if pw.err != nil {
+		return pw.err
+	}
+	return fw.Close()
+}
+
+// DecodePicture reads the picture written by EncodePicture.
+func DecodePicture(r io.Reader) (*Picture, error) {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(kPictureMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != kPictureMagic {
+		return nil, errPictureMagic
+	}
+	if version, err := binary.ReadUvarint(br); err != nil || version != kPictureVersion {
+		return nil, errPictureVersion
+	}
+
+	pr := &picture_reader_t{r: bufio.NewReader(flate.NewReader(br))}
+	p := &Picture{}
+	p.cull = pr.rect()
+	p.bounds = pr.rect()
+
+	// The counts aren't trusted up front, the slices grow with the values
+	// actually read.
+	var images []*Canvas
+	for i, n := 0, pr.count(); i < n; i++ {
+		format := PixelFormat(pr.byte())
+		w, h := pr.uvarint(), pr.uvarint()
+		if pr.err != nil || format > PixelFormatGray8 ||
+			w > kPictureMaxImageSide || h > kPictureMaxImageSide || w*h > kPictureMaxImageSize {
+			return nil, errPictureCorrupt
+		}
+		// The pixels are read before the canvas is made of them, so a
+		// cut off image fails without allocating its size.
+		stride := int(w) * format.BytesPerPixel()
+		pix := pr.bytes(stride * int(h))
+		if pr.err != nil {
+			return nil, errPictureCorrupt
+		}
+		images = append(images, &Canvas{format: format, bounds: image.Rect(0, 0, int(w), int(h)), stride: stride, pix: pix})
+	}
+	if pr.err != nil {
+		return nil, errPictureCorrupt
+	}
+
+	for i, n := 0, pr.count(); i < n; i++ {
+		var op picture_op_t
+		op.code = picture_op_code_t(pr.byte())
+		if pr.err != nil || op.code >= kOpCount || op.code == kOpSetFontFace {
+			return nil, errPictureCorrupt
+		}
+		info := &g_picture_op_infos[op.code]
+		nargs := info.nargs
+		if nargs < 0 {
+			nargs = pr.count()
+		}
+		for j := 0; j < nargs && pr.err == nil; j++ {
+			op.args = append(op.args, pr.arg())
+		}
+		if info.text {
+			op.text = pr.text()
+		}
+		if info.image {
+			k := pr.count()
+			if k >= len(images) {
+				return nil, errPictureCorrupt
+			}
+			op.image = images[k]
+		}
+		if info.paint {
+			op.paint = pr.paint()
+		}
+		if pr.err != nil || !valid_op_rect(&op, p.cull) {
+			return nil, errPictureCorrupt
+		}
+		p.ops = append(p.ops, op)
+	}
+
+	// The body ends right after the ops, anything else than the end of the
+	// flate stream is a cut off or a corrupted file.
+	if pr.err != nil {
+		return nil, errPictureCorrupt
+	}
+	if pr.byte(); pr.err != io.EOF {
+		return nil, errPictureCorrupt
+	}
+	return p, nil
+}
+
+// picture_writer_t writes the values, it keeps the first error and ignores
+// the writes after.
+type picture_writer_t struct {
+	w   io.Writer
+	err error
+	buf [binary.MaxVarintLen64]byte
+}
+
+func (pw *picture_writer_t) write(b []byte) {
+	if pw.err == nil {
+		_, pw.err = pw.w.Write(b)
+	}
+}
+
+func (pw *picture_writer_t) byte(b byte) {
+	pw.write([]byte{b})
+}
+
+func (pw *picture_writer_t) uvarint(v uint64) {
+	pw.write(pw.buf[:binary.PutUvarint(pw.buf[:], v)])
+}
+
+func (pw *picture_writer_t) varint(v int64) {
+	pw.write(pw.buf[:binary.PutVarint(pw.buf[:], v)])
+}
+
+func (pw *picture_writer_t) rect(r image.Rectangle) {
+	pw.varint(int64(r.Min.X))
+	pw.varint(int64(r.Min.Y))
+	pw.varint(int64(r.Max.X))
+	pw.varint(int64(r.Max.Y))
+}
+
+func (pw *picture_writer_t) arg(v float64) {
+	if v == math.Trunc(v) && math.Abs(v) < 1<<52 && !(v == 0 && math.Signbit(v)) {
+		i := int64(v)
+		pw.uvarint(uint64(i<<1^i>>63) << 1)
+		return
+	}
+	pw.uvarint(1)
+	binary.LittleEndian.PutUint64(pw.buf[:8], math.Float64bits(v))
+	pw.write(pw.buf[:8])
+}
+
+func (pw *picture_writer_t) gradient(gr *Gradient, args ...float64) {
+	for _, v := range args {
+		pw.arg(v)
+	}
+	pw.byte(byte(gr.spread))
+	pw.uvarint(uint64(len(gr.stops)))
+	for _, stop := range gr.stops {
+		pw.arg(stop.Offset)
+		pw.write([]byte{stop.R, stop.G, stop.B, stop.A})
+	}
+}
+
+func (pw *picture_writer_t) paint(paint Paint) {
+	switch p := paint.(type) {
+	case *LinearGradient:
+		pw.byte(kPaintLinear)
+		pw.gradient(&p.Gradient, p.X0, p.Y0, p.X1, p.Y1)
+	case *RadialGradient:
+		pw.byte(kPaintRadial)
+		pw.gradient(&p.Gradient, p.CX, p.CY, p.R, p.FX, p.FY)
+	case *ConicGradient:
+		pw.byte(kPaintConic)
+		pw.gradient(&p.Gradient, p.CX, p.CY, p.Angle)
+	case SolidPaint:
+		pw.byte(kPaintSolid)
+		pw.write([]byte{p.R, p.G, p.B, p.A})
+	default:
+		// A nil paint goes back to the color.
+		pw.byte(0xff)
+	}
+}
+
+// picture_reader_t reads the values, it keeps the first error and returns
+// the zero values after.
+type picture_reader_t struct {
+	r   *bufio.Reader
+	err error
+}
+
+func (pr *picture_reader_t) read(b []byte) {
+	if pr.err == nil {
+		_, pr.err = io.ReadFull(pr.r, b)
+	}
+}
+
+func (pr *picture_reader_t) byte() byte {
+	if pr.err != nil {
+		return 0
+	}
+	b, err := pr.r.ReadByte()
+	pr.err = err
+	return b
+}
+
+func (pr *picture_reader_t) uvarint() uint64 {
+	if pr.err != nil {
+		return 0
+	}
+	v, err := binary.ReadUvarint(pr.r)
+	pr.err = err
+	return v
+}
+
+// count reads a count of the values following. A corrupted count may be far
+// more than the bytes left could hold, so it only bounds the int, the reader
+// must not allocate by it.
+func (pr *picture_reader_t) count() int {
+	v := pr.uvarint()
+	if v > kPictureMaxCount {
+		pr.err = errPictureCorrupt
+		return 0
+	}
+	return int(v)
+}
+
+// text reads a string of a count of bytes.
+func (pr *picture_reader_t) text() string {
+	return string(pr.bytes(pr.count()))
+}
+
+// bytes reads |n| bytes into a buffer grown with the bytes actually read.
+func (pr *picture_reader_t) bytes(n int) []byte {
+	if pr.err != nil {
+		return nil
+	}
+	var buf bytes.Buffer
+	if m, err := io.CopyN(&buf, pr.r, int64(n)); m != int64(n) {
+		pr.err = err
+		return nil
+	}
+	return buf.Bytes()
+}
+
+func (pr *picture_reader_t) varint() int64 {
+	if pr.err != nil {
+		return 0
+	}
+	v, err := binary.ReadVarint(pr.r)
+	pr.err = err
+	return v
+}
+
+func (pr *picture_reader_t) rect() image.Rectangle {
+	x0, y0 := int(pr.varint()), int(pr.varint())
+	x1, y1 := int(pr.varint()), int(pr.varint())
+	return image.Rect(x0, y0, x1, y1)
+}
+
+// arg reads an arg of an op, the NaNs and the infinities are corrupted.
+func (pr *picture_reader_t) arg() float64 {
+	tag := pr.uvarint()
+	if tag&1 == 0 {
+		u := tag >> 1
+		return float64(int64(u>>1) ^ -int64(u&1))
+	}
+	var b [8]byte
+	pr.read(b[:])
+	v := math.Float64frombits(binary.LittleEndian.Uint64(b[:]))
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		pr.err = errPictureCorrupt
+		return 0
+	}
+	return v
+}
+
+// valid_op_rect returns whether the rect of |op|, for the ops of a rect,
+// isn't inverted, overlaps |cull| and has the sides of an image at most.
+func valid_op_rect(op *picture_op_t, cull image.Rectangle) bool {
+	switch op.code {
+	case kOpDrawStretch, kOpDrawNinePatch, kOpFillRect, kOpStrokeRect, kOpDrawShadow,
+		kOpFillRoundedRect, kOpStrokeRoundedRect:
+	default:
+		return true
+	}
+	a := op.args
+	x0, y0, x1, y1 := a[0], a[1], a[2], a[3]
+	if x0 > x1 || y0 > y1 || x1-x0 > kPictureMaxImageSide || y1-y0 > kPictureMaxImageSide {
+		return false
+	}
+	return x1 >= float64(cull.Min.X) && x0 <= float64(cull.Max.X) &&
+		y1 >= float64(cull.Min.Y) && y0 <= float64(cull.Max.Y)
+}
+
+func (pr *picture_reader_t) gradient(gr *Gradient) {
+	gr.spread = SpreadMode(pr.byte())
+	for i, n := 0, pr.count(); i < n && pr.err == nil; i++ {
+		stop := ColorStop{Offset: pr.arg()}
+		var c [4]byte
+		pr.read(c[:])
+		stop.R, stop.G, stop.B, stop.A = c[0], c[1], c[2], c[3]
+		gr.stops = append(gr.stops, stop)
+	}
+}
+
+func (pr *picture_reader_t) paint() Paint {
+	switch pr.byte() {
+	case kPaintSolid:
+		var c [4]byte
+		pr.read(c[:])
+		return SolidPaint{c[0], c[1], c[2], c[3]}
+	case kPaintLinear:
+		lg := NewLinearGradient(pr.arg(), pr.arg(), pr.arg(), pr.arg())
+		pr.gradient(&lg.Gradient)
+		return lg
+	case kPaintRadial:
+		rg := NewRadialGradient(pr.arg(), pr.arg(), pr.arg())
+		rg.SetFocalPoint(pr.arg(), pr.arg())
+		pr.gradient(&rg.Gradient)
+		return rg
+	case kPaintConic:
+		cg := NewConicGradient(pr.arg(), pr.arg(), pr.arg())
+		pr.gradient(&cg.Gradient)
+		return cg
+	case 0xff:
+		return nil
+	}
+	pr.err = errPictureCorrupt
+	return nil
+}
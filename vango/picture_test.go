@@ -0,0 +1,372 @@
+package vango
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"image"
+	"math"
+	"runtime"
+	"testing"
+)
+
+func new_test_sprite() *Canvas {
+	sprite := NewCanvas(8, 8)
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			set_pixel(sprite, x, y, byte(x*32), byte(y*32), 0x80, 0xff)
+		}
+	}
+	return sprite
+}
+
+func draw_picture_test_scene(d Context, sprite *Canvas) {
+	d.SetFillColor(0x20, 0x40, 0x60)
+	d.FillRect(image.Rect(2, 2, 30, 20))
+
+	gradient := NewLinearGradient(0, 0, 40, 0)
+	gradient.AddColorStop(0, 0xff, 0, 0, 0xff)
+	gradient.AddColorStop(1, 0, 0, 0xff, 0x80)
+	d.SetFillPaint(gradient)
+	d.FillEllipse(40, 20, 12, 8)
+
+	d.SaveState()
+	d.Translate(10, 30)
+	d.Rotate(0.3)
+	d.SetStrokeColor(0, 0xc0, 0)
+	d.SetLineWidth(3)
+	d.SetLineDash([]float64{4, 2})
+	d.BeginPath()
+	d.MoveTo(0, 0)
+	d.CubicTo(10, -10, 20, 10, 30, 0)
+	d.Stroke()
+	d.RestoreState()
+
+	d.SetGlobalAlpha(0.5)
+	d.AlphaBlend(50, 5, sprite, sprite.LocalBounds())
+	d.DrawStretch(image.Rect(50, 30, 62, 46), sprite, image.Rect(2, 2, 6, 6))
+	d.SetGlobalAlpha(1)
+	d.SetFillRGBA(0xff, 0xff, 0, 0xc0)
+	d.Polygon([]image.Point{{5, 40}, {20, 35}, {15, 50}})
+	d.DrawShadow(image.Rect(25, 40, 40, 50), UniformRadii(3),
+		Shadow{DX: 1, DY: 2, Blur: 2, Color: NewSolidPaint(0, 0, 0, 0x80)})
+}
+
+func assert_same_canvas(t *testing.T, name string, got, want *Canvas) {
+	result, err := Compare(got, want, 0)
+	if err != nil {
+		t.Fatalf("%v: %v", name, err)
+	}
+	if result.Mismatched != 0 {
+		t.Errorf("%v: %v pixels mismatched", name, result.Mismatched)
+	}
+}
+
+func TestPictureReplay(t *testing.T) {
+	sprite := new_test_sprite()
+	want_ctxt, want := new_test_context(80, 60)
+	draw_picture_test_scene(want_ctxt, sprite)
+
+	recorder := NewRecorder(image.Rect(0, 0, 80, 60))
+	draw_picture_test_scene(recorder, sprite)
+	picture := recorder.Finish()
+
+	ctxt, got := new_test_context(80, 60)
+	ctxt.DrawPicture(picture)
+	assert_same_canvas(t, "DrawPicture", got, want)
+
+	// The sprite and the gradient are copied when they're recorded.
+	set_pixel(sprite, 3, 3, 0, 0, 0, 0)
+	ctxt, got = new_test_context(80, 60)
+	ctxt.DrawPicture(picture)
+	assert_same_canvas(t, "DrawPicture after the sprite changed", got, want)
+}
+
+func TestPictureReplayTransformAndClip(t *testing.T) {
+	sprite := new_test_sprite()
+	m := TranslateMatrix(20, 10).Multiply(ScaleMatrix(1.5, 1.5))
+	clip := image.Rect(0, 0, 45, 40)
+
+	want_ctxt, want := new_test_context(160, 120)
+	want_ctxt.SetFillColor(0, 0, 0xff)
+	want_ctxt.Transform(m)
+	want_ctxt.ClipRect(clip)
+	draw_picture_test_scene(want_ctxt, sprite)
+
+	recorder := NewRecorder(image.Rect(0, 0, 80, 60))
+	draw_picture_test_scene(recorder, sprite)
+	picture := recorder.Finish()
+
+	ctxt, got := new_test_context(160, 120)
+	ctxt.SetFillColor(0, 0, 0xff)
+	picture.Replay(ctxt, m, clip)
+	assert_same_canvas(t, "Replay", got, want)
+
+	if ctxt.CurrentTransform() != IdentityMatrix() || ctxt.Clip() != nil {
+		t.Errorf("the transform and the clip aren't restored")
+	}
+	if ctxt.FillPaint() != NewSolidPaint(0, 0, 0xff, 0xff) {
+		t.Errorf("the fill paint isn't restored: got %v", ctxt.FillPaint())
+	}
+}
+
+func TestPictureUnbalancedStates(t *testing.T) {
+	recorder := NewRecorder(image.Rect(0, 0, 10, 10))
+	recorder.RestoreState()
+	recorder.SaveState()
+	recorder.Translate(5, 5)
+	picture := recorder.Finish()
+
+	ctxt, _ := new_test_context(10, 10)
+	ctxt.SaveState()
+	ctxt.Translate(1, 1)
+	ctxt.DrawPicture(picture)
+	if ctxt.StateDepth() != 1 || ctxt.CurrentTransform() != TranslateMatrix(1, 1) {
+		t.Errorf("the state after replay: depth %v, transform %v",
+			ctxt.StateDepth(), ctxt.CurrentTransform())
+	}
+}
+
+func TestRecorderDrawText(t *testing.T) {
+	load_test_font(t)
+	ctxt, _ := new_test_context(100, 40)
+	want, err := ctxt.DrawText("Hello", image.Rect(10, 10, 90, 30))
+	if err != nil {
+		t.Fatalf("DrawText: %v", err)
+	}
+
+	// The pen is measured like the canvas, and the bounds follow it.
+	recorder := NewRecorder(image.Rect(0, 0, 100, 40))
+	recorder.SaveState()
+	if recorder.StateDepth() != 1 {
+		t.Errorf("StateDepth: got %v, want 1", recorder.StateDepth())
+	}
+	got, err := recorder.DrawText("Hello", image.Rect(10, 10, 90, 30))
+	recorder.RestoreState()
+	if err != nil || got != want {
+		t.Errorf("the pen after the text: got %v %v, want %v", got, err, want)
+	}
+	b := recorder.Finish().Bounds()
+	if x := int(want.X >> 8); b.Min.X > 10 || b.Max.X < x || b.Max.X > x+12 {
+		t.Errorf("bounds of the text: got %v, the pen at %v", b, x)
+	}
+}
+
+func TestPictureBounds(t *testing.T) {
+	recorder := NewRecorder(image.Rect(0, 0, 100, 100))
+	if b := recorder.Finish().Bounds(); !b.Empty() {
+		t.Errorf("empty picture bounds: got %v", b)
+	}
+
+	recorder.FillRect(image.Rect(10, 10, 20, 20))
+	recorder.Translate(50, 50)
+	recorder.FillEllipse(0, 0, 5, 5)
+	if b := recorder.Finish().Bounds(); b != image.Rect(9, 9, 56, 56) {
+		t.Errorf("bounds: got %v, want (9,9)-(56,56)", b)
+	}
+
+	// The draws are limited by the clip and the cull rect.
+	recorder.ClipRect(image.Rect(0, 0, 30, 30))
+	recorder.DrawColor(0xff, 0, 0)
+	recorder.ResetClip()
+	recorder.FillRect(image.Rect(90, 90, 200, 200))
+	if b := recorder.Finish().Bounds(); b != image.Rect(0, 0, 100, 100) {
+		t.Errorf("bounds: got %v, want (0,0)-(100,100)", b)
+	}
+
+	recorder.Rotate(math.Pi / 4)
+	recorder.SetLineWidth(4)
+	recorder.SetLineJoin(LineJoinRound)
+	recorder.BeginPath()
+	recorder.MoveTo(10, 0)
+	recorder.LineTo(20, 0)
+	recorder.Stroke()
+	b := recorder.Finish().Bounds()
+	if !image.Rect(5, 5, 16, 16).In(b) || b.Dx() > 30 {
+		t.Errorf("bounds of the rotated stroke: got %v", b)
+	}
+}
+
+func TestPictureEncode(t *testing.T) {
+	sprite := new_test_sprite()
+	recorder := NewRecorder(image.Rect(0, 0, 80, 60))
+	draw_picture_test_scene(recorder, sprite)
+	recorder.DrawAlpha(60, 50, image.NewAlpha(image.Rect(0, 0, 4, 4)), image.Rect(0, 0, 4, 4))
+	recorder.SetFont("default")
+	picture := recorder.Finish()
+
+	var buf bytes.Buffer
+	if err := EncodePicture(&buf, picture); err != nil {
+		t.Fatalf("EncodePicture: %v", err)
+	}
+	decoded, err := DecodePicture(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("DecodePicture: %v", err)
+	}
+	if decoded.Bounds() != picture.Bounds() || decoded.CullRect() != picture.CullRect() {
+		t.Errorf("decoded bounds: got %v %v", decoded.Bounds(), decoded.CullRect())
+	}
+	if decoded.String() != picture.String() {
+		t.Errorf("decoded ops:\n%v\nwant:\n%v", decoded, picture)
+	}
+
+	want_ctxt, want := new_test_context(80, 60)
+	want_ctxt.DrawPicture(picture)
+	ctxt, got := new_test_context(80, 60)
+	ctxt.DrawPicture(decoded)
+	assert_same_canvas(t, "decoded picture", got, want)
+
+	data := buf.Bytes()
+	if _, err := DecodePicture(bytes.NewReader(data[:len(data)/2])); err == nil {
+		t.Errorf("DecodePicture of the truncated data: got no error")
+	}
+	if _, err := DecodePicture(bytes.NewReader([]byte("PNG"))); err == nil {
+		t.Errorf("DecodePicture of the bad magic: got no error")
+	}
+
+	recorder.SetFontFace(nil)
+	if err := EncodePicture(&buf, recorder.Finish()); err == nil {
+		t.Errorf("EncodePicture with a font face: got no error")
+	}
+}
+
+// encode_test_picture returns the encoded picture of the body written by
+// |body|, the flate stream is left without its end unless |finish|.
+func encode_test_picture(t *testing.T, finish bool, body func(pw *picture_writer_t)) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(kPictureMagic)
+	var v [binary.MaxVarintLen64]byte
+	buf.Write(v[:binary.PutUvarint(v[:], kPictureVersion)])
+	fw, _ := flate.NewWriter(&buf, flate.DefaultCompression)
+	pw := &picture_writer_t{w: fw}
+	pw.rect(image.Rect(0, 0, 4, 4))
+	pw.rect(image.Rect(0, 0, 4, 4))
+	body(pw)
+	if finish {
+		fw.Close()
+	} else {
+		fw.Flush()
+	}
+	if pw.err != nil {
+		t.Fatalf("encode: %v", pw.err)
+	}
+	return buf.Bytes()
+}
+
+func TestPictureDecodeCorrupt(t *testing.T) {
+	image_gray := func(pw *picture_writer_t) {
+		pw.uvarint(1)
+		pw.byte(byte(PixelFormatGray8))
+		pw.uvarint(2)
+		pw.uvarint(2)
+		pw.write([]byte{1, 2, 3, 4})
+	}
+	if _, err := DecodePicture(bytes.NewReader(encode_test_picture(t, true, func(pw *picture_writer_t) {
+		image_gray(pw)
+		pw.uvarint(0)
+	}))); err != nil {
+		t.Fatalf("DecodePicture of an image and no ops: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		finish bool
+		body   func(pw *picture_writer_t)
+	}{
+		{"the overflowing image size", true, func(pw *picture_writer_t) {
+			pw.uvarint(1)
+			pw.byte(byte(PixelFormatGray8))
+			pw.uvarint(1 << 62)
+			pw.uvarint(4)
+		}},
+		{"the data cut off after the images", true, image_gray},
+		{"the image cut off", true, func(pw *picture_writer_t) {
+			pw.uvarint(1)
+			pw.byte(byte(PixelFormatRGBA8))
+			pw.uvarint(kPictureMaxImageSide)
+			pw.uvarint(kPictureMaxImageSize / kPictureMaxImageSide)
+			pw.write([]byte{1, 2, 3, 4})
+		}},
+		{"the image too large", true, func(pw *picture_writer_t) {
+			pw.uvarint(1)
+			pw.byte(byte(PixelFormatGray8))
+			pw.uvarint(kPictureMaxImageSide + 1)
+			pw.uvarint(1)
+		}},
+		{"the NaN arg", true, func(pw *picture_writer_t) {
+			pw.uvarint(0)
+			pw.uvarint(1)
+			pw.byte(byte(kOpSetLineWidth))
+			pw.arg(math.NaN())
+		}},
+		{"the rect outside the cull", true, func(pw *picture_writer_t) {
+			pw.uvarint(0)
+			pw.uvarint(1)
+			pw.byte(byte(kOpFillRect))
+			for _, v := range []float64{10, 10, 20, 20} {
+				pw.arg(v)
+			}
+		}},
+		{"the huge rect", true, func(pw *picture_writer_t) {
+			pw.uvarint(0)
+			pw.uvarint(1)
+			pw.byte(byte(kOpFillRect))
+			for _, v := range []float64{0, 0, 1 << 30, 4} {
+				pw.arg(v)
+			}
+		}},
+		{"the flate stream cut off after the ops", false, func(pw *picture_writer_t) {
+			image_gray(pw)
+			pw.uvarint(0)
+		}},
+		{"the huge count of images", true, func(pw *picture_writer_t) {
+			pw.uvarint(kPictureMaxImageSize)
+		}},
+		{"the huge count of ops", true, func(pw *picture_writer_t) {
+			pw.uvarint(0)
+			pw.uvarint(kPictureMaxImageSize)
+			pw.byte(byte(kOpBeginPath))
+		}},
+		{"the huge count of args", true, func(pw *picture_writer_t) {
+			pw.uvarint(0)
+			pw.uvarint(1)
+			pw.byte(byte(kOpSetLineDash))
+			pw.uvarint(kPictureMaxImageSize)
+			pw.arg(1)
+		}},
+		{"the huge count of text", true, func(pw *picture_writer_t) {
+			pw.uvarint(0)
+			pw.uvarint(1)
+			pw.byte(byte(kOpDrawText))
+			for i := 0; i < 4; i++ {
+				pw.arg(0)
+			}
+			pw.uvarint(kPictureMaxImageSize)
+			pw.write([]byte("text"))
+		}},
+	}
+	for _, test := range tests {
+		data := encode_test_picture(t, test.finish, test.body)
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		if _, err := DecodePicture(bytes.NewReader(data)); err == nil {
+			t.Errorf("DecodePicture of %s: got no error", test.name)
+		}
+		runtime.ReadMemStats(&after)
+		if n := after.TotalAlloc - before.TotalAlloc; n > 4<<20 {
+			t.Errorf("DecodePicture of %s: allocated %d bytes", test.name, n)
+		}
+	}
+
+	// The NaN blur of a shadow is recorded, but not decoded into a picture
+	// panicking on replay.
+	recorder := NewRecorder(image.Rect(0, 0, 20, 20))
+	recorder.DrawShadow(image.Rect(2, 2, 10, 10), CornerRadii{}, Shadow{Blur: math.NaN()})
+	var buf bytes.Buffer
+	if err := EncodePicture(&buf, recorder.Finish()); err != nil {
+		t.Fatalf("EncodePicture: %v", err)
+	}
+	if _, err := DecodePicture(&buf); err == nil {
+		t.Errorf("DecodePicture of the NaN blur: got no error")
+	}
+}
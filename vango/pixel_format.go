@@ -0,0 +1,141 @@
+// Copyright 2014 By Jshi. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vango
+
+import (
+	"image/color"
+)
+
+// PixelFormat is the layout of the pixels of a canvas.
+type PixelFormat int
+
+const (
+	// 4 bytes in the order (b, g, r, a), premultiplied by alpha. It's the
+	// default format, the one a Context draws to and the native canvases use.
+	PixelFormatBGRA8 PixelFormat = iota
+	// 4 bytes in the order (r, g, b, a), not premultiplied, like image.NRGBA.
+	PixelFormatRGBA8
+	// 1 byte of alpha, like image.Alpha.
+	PixelFormatA8
+	// 1 byte of gray, opaque, like image.Gray.
+	PixelFormatGray8
+)
+
+// BytesPerPixel returns the size of one pixel in bytes.
+func (f PixelFormat) BytesPerPixel() int {
+	switch f {
+	case PixelFormatA8, PixelFormatGray8:
+		return 1
+	}
+	return 4
+}
+
+func (f PixelFormat) String() string {
+	switch f {
+	case PixelFormatBGRA8:
+		return "BGRA8"
+	case PixelFormatRGBA8:
+		return "RGBA8"
+	case PixelFormatA8:
+		return "A8"
+	case PixelFormatGray8:
+		return "Gray8"
+	}
+	return "PixelFormat(?)"
+}
+
+// ColorModel returns the color model of the standard library matching the
+// format.
+func (f PixelFormat) ColorModel() color.Model {
+	switch f {
+	case PixelFormatRGBA8:
+		return color.NRGBAModel
+	case PixelFormatA8:
+		return color.AlphaModel
+	case PixelFormatGray8:
+		return color.GrayModel
+	}
+	return color.RGBAModel
+}
+
+// read_pixel returns the pixel |p| in the format |f| as the premultiplied
+// color in the canvas order (b, g, r, a).
+func read_pixel(f PixelFormat, p []byte) [4]uint32 {
+	switch f {
+	case PixelFormatRGBA8:
+		a := uint32(p[3])
+		return [4]uint32{div255(uint32(p[2]) * a), div255(uint32(p[1]) * a), div255(uint32(p[0]) * a), a}
+	case PixelFormatA8:
+		return [4]uint32{0, 0, 0, uint32(p[0])}
+	case PixelFormatGray8:
+		g := uint32(p[0])
+		return [4]uint32{g, g, g, 0xff}
+	}
+	return [4]uint32{uint32(p[0]), uint32(p[1]), uint32(p[2]), uint32(p[3])}
+}
+
+// write_pixel stores the premultiplied color |clr| in the canvas order to the
+// pixel |p| in the format |f|. The gray is the luminance of the color over
+// black, like color.GrayModel.
+func write_pixel(f PixelFormat, p []byte, clr [4]uint32) {
+	switch f {
+	case PixelFormatRGBA8:
+		a := clr[3]
+		if a == 0 {
+			p[0], p[1], p[2], p[3] = 0, 0, 0, 0
+			return
+		}
+		unpremul := func(c uint32) byte {
+			c = (c*0xff + a/2) / a
+			if c > 0xff {
+				c = 0xff
+			}
+			return byte(c)
+		}
+		p[0], p[1], p[2], p[3] = unpremul(clr[2]), unpremul(clr[1]), unpremul(clr[0]), byte(a)
+	case PixelFormatA8:
+		p[0] = byte(clr[3])
+	case PixelFormatGray8:
+		p[0] = byte((19595*clr[2] + 38470*clr[1] + 7471*clr[0] + 1<<15) >> 16)
+	default:
+		p[0], p[1], p[2], p[3] = byte(clr[0]), byte(clr[1]), byte(clr[2]), byte(clr[3])
+	}
+}
+
+// convert_row converts |n| pixels from |src| in the format |f0| to |dst| in
+// the format |f1|.
+func convert_row(f1 PixelFormat, dst []byte, f0 PixelFormat, src []byte, n int) {
+	if f0 == f1 {
+		copy(dst[:n*f1.BytesPerPixel()], src)
+		return
+	}
+	b0, b1 := f0.BytesPerPixel(), f1.BytesPerPixel()
+	for i := 0; i < n; i++ {
+		write_pixel(f1, dst[i*b1:], read_pixel(f0, src[i*b0:]))
+	}
+}
+
+// color_to_pixel returns the color |c| of the standard library as the
+// premultiplied color in the canvas order.
+func color_to_pixel(c color.Color) [4]uint32 {
+	r, g, b, a := c.RGBA()
+	return [4]uint32{b >> 8, g >> 8, r >> 8, a >> 8}
+}
+
+// pixel_to_color returns the premultiplied color |clr| in the canvas order as
+// the color of the format |f| in the standard library.
+func pixel_to_color(f PixelFormat, clr [4]uint32) color.Color {
+	var p [4]byte
+	write_pixel(f, p[:], clr)
+	switch f {
+	case PixelFormatRGBA8:
+		return color.NRGBA{p[0], p[1], p[2], p[3]}
+	case PixelFormatA8:
+		return color.Alpha{p[0]}
+	case PixelFormatGray8:
+		return color.Gray{p[0]}
+	}
+	return color.RGBA{p[2], p[1], p[0], p[3]}
+}
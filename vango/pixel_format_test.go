@@ -0,0 +1,174 @@
+package vango
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestPixelFormatConvert(t *testing.T) {
+	canvas := NewCanvas(2, 1)
+	copy(canvas.Pix(), []byte{0, 0, 0x80, 0x80, 0xff, 0xff, 0xff, 0xff})
+
+	rgba := canvas.ConvertTo(PixelFormatRGBA8)
+	if rgba.Format() != PixelFormatRGBA8 || rgba.Stride() != 8 {
+		t.Fatalf("ConvertTo RGBA8: got %v stride %v", rgba.Format(), rgba.Stride())
+	}
+	if p := rgba.Pix()[:4]; p[0] != 0xff || p[1] != 0 || p[2] != 0 || p[3] != 0x80 {
+		t.Errorf("RGBA8 isn't unpremultiplied: got %v", p)
+	}
+
+	back := rgba.ConvertTo(PixelFormatBGRA8)
+	for i, b := range canvas.Pix() {
+		if back.Pix()[i] != b {
+			t.Fatalf("RGBA8 to BGRA8: got %v, want %v", back.Pix(), canvas.Pix())
+		}
+	}
+
+	a8 := canvas.ConvertTo(PixelFormatA8)
+	if a8.Stride() != 2 || a8.Pix()[0] != 0x80 || a8.Pix()[1] != 0xff {
+		t.Errorf("ConvertTo A8: got %v", a8.Pix())
+	}
+
+	gray := canvas.ConvertTo(PixelFormatGray8)
+	if !gray.Opaque() || gray.Pix()[1] != 0xff {
+		t.Errorf("ConvertTo Gray8: got %v", gray.Pix())
+	}
+	if p := gray.ConvertTo(PixelFormatBGRA8).Pix(); p[4] != 0xff || p[7] != 0xff {
+		t.Errorf("Gray8 to BGRA8: got %v", p)
+	}
+}
+
+func TestCanvasImage(t *testing.T) {
+	var _ draw.Image = (*Canvas)(nil)
+
+	canvas := NewCanvas(10, 10)
+	sub := canvas.SubCanvas(image.Rect(5, 5, 10, 10))
+	draw.Draw(sub, sub.Bounds(), image.NewUniform(color.NRGBA{0xff, 0, 0, 0x80}), image.ZP, draw.Src)
+
+	if r, g, b, a := pixel_at(canvas, 7, 7); !near_byte(r, 0x80) || g != 0 || b != 0 || !near_byte(a, 0x80) {
+		t.Errorf("draw.Draw to the canvas: got (%v, %v, %v, %v)", r, g, b, a)
+	}
+	if _, _, _, a := pixel_at(canvas, 2, 2); a != 0 {
+		t.Errorf("draw.Draw outside the sub canvas: got alpha %v", a)
+	}
+
+	// At is in the coordinate of Bounds.
+	if c, ok := sub.At(7, 7).(color.RGBA); !ok || !near_byte(c.R, 0x80) {
+		t.Errorf("At: got %v", sub.At(7, 7))
+	}
+	if c := sub.At(2, 2).(color.RGBA); c.A != 0 {
+		t.Errorf("At outside the bounds: got %v", c)
+	}
+
+	a8 := NewCanvasWithFormat(4, 4, PixelFormatA8)
+	a8.Set(1, 1, color.Alpha{0x40})
+	if a8.ColorModel() != color.AlphaModel || a8.At(1, 1) != (color.Alpha{0x40}) {
+		t.Errorf("A8 At: got %v", a8.At(1, 1))
+	}
+}
+
+func TestCanvasFromImage(t *testing.T) {
+	rgba := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	rgba.Set(0, 0, color.RGBA{0x80, 0, 0, 0x80})
+	nrgba := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	nrgba.Set(0, 0, color.NRGBA{0xff, 0, 0, 0x80})
+
+	for _, img := range []image.Image{rgba, nrgba} {
+		canvas := CanvasFromImage(img)
+		if canvas.Format() != PixelFormatBGRA8 || canvas.Opaque() {
+			t.Errorf("CanvasFromImage(%T): got %v, opaque %v", img, canvas.Format(), canvas.Opaque())
+		}
+		if r, _, b, a := pixel_at(canvas, 0, 0); r != 0x80 || b != 0 || a != 0x80 {
+			t.Errorf("CanvasFromImage(%T): got r=%v b=%v a=%v", img, r, b, a)
+		}
+	}
+
+	alpha := image.NewAlpha(image.Rect(0, 0, 2, 2))
+	if canvas := CanvasFromImage(alpha); canvas.Format() != PixelFormatA8 {
+		t.Errorf("CanvasFromImage(*image.Alpha): got %v", canvas.Format())
+	}
+
+	// The sub image starts at the min of its bounds.
+	sub := rgba.SubImage(image.Rect(1, 1, 2, 2))
+	rgba.Set(1, 1, color.RGBA{0, 0, 0x40, 0xff})
+	if canvas := CanvasFromImage(sub); canvas.Bounds() != image.Rect(0, 0, 1, 1) || !canvas.Opaque() {
+		t.Errorf("CanvasFromImage of the sub image: got %v, opaque %v", canvas.Bounds(), canvas.Opaque())
+	} else if r, _, b, _ := pixel_at(canvas, 0, 0); r != 0 || b != 0x40 {
+		t.Errorf("CanvasFromImage of the sub image: got r=%v b=%v", r, b)
+	}
+}
+
+func TestCanvasFromImageTypes(t *testing.T) {
+	rect := image.Rect(1, 1, 5, 3)
+	ycbcr := image.NewYCbCr(rect, image.YCbCrSubsampleRatio420)
+	for i := range ycbcr.Y {
+		ycbcr.Y[i] = 0x80
+	}
+	for i := range ycbcr.Cb {
+		ycbcr.Cb[i], ycbcr.Cr[i] = 0x80, 0x80
+	}
+	nycbcra := image.NewNYCbCrA(rect, image.YCbCrSubsampleRatio444)
+	for i := range nycbcra.Y {
+		nycbcra.Y[i], nycbcra.Cb[i], nycbcra.Cr[i], nycbcra.A[i] = 0x80, 0x80, 0x80, 0x80
+	}
+	paletted := image.NewPaletted(rect, color.Palette{color.NRGBA{0x80, 0x80, 0x80, 0xff}})
+	gray16 := image.NewGray16(rect)
+	cmyk := image.NewCMYK(rect)
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			gray16.Set(x, y, color.Gray{0x80})
+			cmyk.Set(x, y, color.CMYK{0, 0, 0, 0x7f})
+		}
+	}
+
+	for _, test := range []struct {
+		img    image.Image
+		gray   byte
+		alpha  byte
+		opaque bool
+	}{
+		{ycbcr, 0x80, 0xff, true},
+		{nycbcra, 0x40, 0x80, false},
+		{paletted, 0x80, 0xff, true},
+		{gray16, 0x80, 0xff, true},
+		{cmyk, 0x80, 0xff, true},
+	} {
+		canvas := CanvasFromImage(test.img)
+		if canvas.Format() != PixelFormatBGRA8 || canvas.Bounds() != image.Rect(0, 0, 4, 2) || canvas.Opaque() != test.opaque {
+			t.Errorf("CanvasFromImage(%T): got %v %v, opaque %v", test.img, canvas.Format(), canvas.Bounds(), canvas.Opaque())
+			continue
+		}
+		if r, g, b, a := pixel_at(canvas, 3, 1); r != test.gray || g != test.gray || b != test.gray || a != test.alpha {
+			t.Errorf("CanvasFromImage(%T): got %v %v %v %v", test.img, r, g, b, a)
+		}
+	}
+
+	gray := image.NewGray(rect)
+	if canvas := CanvasFromImage(gray); canvas.Format() != PixelFormatGray8 || canvas.Bounds() != image.Rect(0, 0, 4, 2) {
+		t.Errorf("CanvasFromImage(*image.Gray): got %v %v", canvas.Format(), canvas.Bounds())
+	}
+	a8 := NewCanvasWithFormat(2, 2, PixelFormatA8)
+	if canvas := CanvasFromImage(a8); canvas == a8 || canvas.Format() != PixelFormatA8 {
+		t.Errorf("CanvasFromImage(*Canvas): got %v", canvas.Format())
+	}
+}
+
+func TestContextCanvasFormat(t *testing.T) {
+	ctxt := NewContext()
+	ctxt.SetCanvas(NewCanvasWithFormat(4, 4, PixelFormatGray8))
+	ctxt.FillRect(image.Rect(0, 0, 4, 4))
+
+	// An A8 source is drawn in black.
+	ctxt, canvas := new_test_context(4, 4)
+	ctxt.FillRect(canvas.LocalBounds())
+	mask := NewCanvasWithFormat(4, 4, PixelFormatA8)
+	for i := range mask.Pix() {
+		mask.Pix()[i] = 0xff
+	}
+	ctxt.AlphaBlend(0, 0, mask, mask.LocalBounds())
+	if r, _, _, a := pixel_at(canvas, 1, 1); r != 0 || a != 0xff {
+		t.Errorf("AlphaBlend of A8: got r=%v a=%v, want black", r, a)
+	}
+}
@@ -0,0 +1,230 @@
+// Copyright 2014 By Jshi. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vango
+
+import (
+	"image"
+	"sort"
+)
+
+// Region is a set of pixels as the rectangles in the bands. The rectangles
+// don't overlap, they are sorted by y then x, the ones in the same band have
+// the same top and bottom, and the adjacent bands with the same spans are
+// merged. The zero Region is empty. The operations return new regions, like
+// the ones of image.Rectangle.
+type Region struct {
+	rects []image.Rectangle
+}
+
+// NewRegion returns the union of |rects|.
+func NewRegion(rects ...image.Rectangle) Region {
+	var r Region
+	for _, rect := range rects {
+		r = r.UnionRect(rect)
+	}
+	return r
+}
+
+// Empty returns true if the region has no pixel.
+func (r Region) Empty() bool {
+	return len(r.rects) == 0
+}
+
+// Rects returns a copy of the rectangles of the region, in the bands from the
+// top.
+func (r Region) Rects() []image.Rectangle {
+	return append([]image.Rectangle(nil), r.rects...)
+}
+
+// Bounds returns the smallest rectangle containing the region.
+func (r Region) Bounds() image.Rectangle {
+	if r.Empty() {
+		return image.ZR
+	}
+	bounds := r.rects[0]
+	for _, rect := range r.rects[1:] {
+		bounds = bounds.Union(rect)
+	}
+	return bounds
+}
+
+// Contains returns true if the pixel of |p| is in the region.
+func (r Region) Contains(p image.Point) bool {
+	for _, rect := range r.rects {
+		if rect.Min.Y > p.Y {
+			break
+		}
+		if p.In(rect) {
+			return true
+		}
+	}
+	return false
+}
+
+// ContainsRect returns true if all the pixels of |rect| are in the region.
+func (r Region) ContainsRect(rect image.Rectangle) bool {
+	return NewRegion(rect).Subtract(r).Empty()
+}
+
+// Translate returns the region moved by (dx, dy).
+func (r Region) Translate(dx, dy int) Region {
+	rects := make([]image.Rectangle, len(r.rects))
+	for i, rect := range r.rects {
+		rects[i] = rect.Add(image.Pt(dx, dy))
+	}
+	return Region{rects}
+}
+
+// Union returns the pixels in |r| or |o|.
+func (r Region) Union(o Region) Region {
+	return region_op(r.rects, o.rects, func(a, b bool) bool { return a || b })
+}
+
+// Intersect returns the pixels in both |r| and |o|.
+func (r Region) Intersect(o Region) Region {
+	return region_op(r.rects, o.rects, func(a, b bool) bool { return a && b })
+}
+
+// Subtract returns the pixels in |r| but not in |o|.
+func (r Region) Subtract(o Region) Region {
+	return region_op(r.rects, o.rects, func(a, b bool) bool { return a && !b })
+}
+
+// UnionRect returns the pixels in |r| or |rect|.
+func (r Region) UnionRect(rect image.Rectangle) Region {
+	return r.Union(region_of_rect(rect))
+}
+
+// IntersectRect returns the pixels of |r| in |rect|.
+func (r Region) IntersectRect(rect image.Rectangle) Region {
+	return r.Intersect(region_of_rect(rect))
+}
+
+// SubtractRect returns the pixels of |r| out of |rect|.
+func (r Region) SubtractRect(rect image.Rectangle) Region {
+	return r.Subtract(region_of_rect(rect))
+}
+
+func region_of_rect(rect image.Rectangle) Region {
+	if rect.Empty() {
+		return Region{}
+	}
+	return Region{[]image.Rectangle{rect}}
+}
+
+// region_span_t is the pixels [x0, x1) in a band.
+type region_span_t struct {
+	x0, x1 int
+}
+
+// region_op combines the bands of |a| and |b| by |op| of the membership of the
+// pixels. The edges of both regions split them into the same bands first, the
+// spans of each band are then combined by the edges of the spans.
+func region_op(a, b []image.Rectangle, op func(in_a, in_b bool) bool) Region {
+	ys := make([]int, 0, 2*(len(a)+len(b)))
+	for _, rect := range a {
+		ys = append(ys, rect.Min.Y, rect.Max.Y)
+	}
+	for _, rect := range b {
+		ys = append(ys, rect.Min.Y, rect.Max.Y)
+	}
+	ys = sort_unique(ys)
+
+	var rects []image.Rectangle
+	var last []region_span_t // The spans of the last band in |rects|.
+	last_y1 := 0
+	for i := 0; i+1 < len(ys); i++ {
+		y0, y1 := ys[i], ys[i+1]
+		spans := combine_spans(region_spans(a, y0, y1), region_spans(b, y0, y1), op)
+		if len(spans) == 0 {
+			continue
+		}
+
+		// The band is merged into the last one with the same spans.
+		if last_y1 == y0 && equal_spans(spans, last) {
+			for j := len(rects) - len(last); j < len(rects); j++ {
+				rects[j].Max.Y = y1
+			}
+		} else {
+			for _, span := range spans {
+				rects = append(rects, image.Rect(span.x0, y0, span.x1, y1))
+			}
+		}
+		last, last_y1 = spans, y1
+	}
+	return Region{rects}
+}
+
+// region_spans returns the spans of the rectangles covering the band
+// [y0, y1), which no edge of the rectangles splits.
+func region_spans(rects []image.Rectangle, y0, y1 int) []region_span_t {
+	var spans []region_span_t
+	for _, rect := range rects {
+		if rect.Min.Y <= y0 && y1 <= rect.Max.Y {
+			spans = append(spans, region_span_t{rect.Min.X, rect.Max.X})
+		}
+	}
+	return spans
+}
+
+// combine_spans returns the merged spans between the edges of |a| and |b|,
+// for which |op| is true.
+func combine_spans(a, b []region_span_t, op func(in_a, in_b bool) bool) []region_span_t {
+	xs := make([]int, 0, 2*(len(a)+len(b)))
+	for _, span := range a {
+		xs = append(xs, span.x0, span.x1)
+	}
+	for _, span := range b {
+		xs = append(xs, span.x0, span.x1)
+	}
+	xs = sort_unique(xs)
+
+	var spans []region_span_t
+	i, j := 0, 0
+	for k := 0; k+1 < len(xs); k++ {
+		x0, x1 := xs[k], xs[k+1]
+		for i < len(a) && a[i].x1 <= x0 {
+			i++
+		}
+		for j < len(b) && b[j].x1 <= x0 {
+			j++
+		}
+		in_a := i < len(a) && a[i].x0 <= x0
+		in_b := j < len(b) && b[j].x0 <= x0
+		if !op(in_a, in_b) {
+			continue
+		}
+		if n := len(spans); n > 0 && spans[n-1].x1 == x0 {
+			spans[n-1].x1 = x1
+		} else {
+			spans = append(spans, region_span_t{x0, x1})
+		}
+	}
+	return spans
+}
+
+func equal_spans(a, b []region_span_t) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sort_unique(v []int) []int {
+	sort.Ints(v)
+	n := 0
+	for i, x := range v {
+		if i == 0 || x != v[n-1] {
+			v[n] = x
+			n++
+		}
+	}
+	return v[:n]
+}
@@ -0,0 +1,107 @@
+package vango
+
+import (
+	"image"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestRegionBands(t *testing.T) {
+	// Two rects at the opposite corners don't cover the pixels between them.
+	r := NewRegion(image.Rect(0, 0, 10, 10), image.Rect(90, 90, 100, 100))
+	if got := r.Rects(); len(got) != 2 || r.Contains(image.Pt(50, 50)) {
+		t.Errorf("the corners: got %v", got)
+	}
+	if r.Bounds() != image.Rect(0, 0, 100, 100) {
+		t.Errorf("Bounds: got %v", r.Bounds())
+	}
+
+	// The overlapping rects are split into the bands.
+	r = NewRegion(image.Rect(0, 0, 20, 20), image.Rect(10, 10, 30, 30))
+	want := []image.Rectangle{
+		image.Rect(0, 0, 20, 10),
+		image.Rect(0, 10, 30, 20),
+		image.Rect(10, 20, 30, 30),
+	}
+	if got := r.Rects(); !reflect.DeepEqual(got, want) {
+		t.Errorf("the overlapping rects: got %v, want %v", got, want)
+	}
+
+	// The adjacent bands of the same spans are merged.
+	r = NewRegion(image.Rect(0, 0, 10, 5), image.Rect(0, 5, 10, 10), image.Rect(10, 0, 20, 10))
+	if got := r.Rects(); !reflect.DeepEqual(got, []image.Rectangle{image.Rect(0, 0, 20, 10)}) {
+		t.Errorf("the adjacent rects: got %v", got)
+	}
+
+	// A hole.
+	r = NewRegion(image.Rect(0, 0, 30, 30)).SubtractRect(image.Rect(10, 10, 20, 20))
+	want = []image.Rectangle{
+		image.Rect(0, 0, 30, 10),
+		image.Rect(0, 10, 10, 20),
+		image.Rect(20, 10, 30, 20),
+		image.Rect(0, 20, 30, 30),
+	}
+	if got := r.Rects(); !reflect.DeepEqual(got, want) {
+		t.Errorf("the hole: got %v, want %v", got, want)
+	}
+	if r.Contains(image.Pt(15, 15)) || !r.Contains(image.Pt(5, 15)) || r.Contains(image.Pt(30, 5)) {
+		t.Errorf("Contains of the hole")
+	}
+	if r.ContainsRect(image.Rect(5, 5, 15, 15)) || !r.ContainsRect(image.Rect(0, 0, 30, 10)) {
+		t.Errorf("ContainsRect of the hole")
+	}
+	if got := r.IntersectRect(image.Rect(5, 12, 25, 14)).Rects(); !reflect.DeepEqual(got,
+		[]image.Rectangle{image.Rect(5, 12, 10, 14), image.Rect(20, 12, 25, 14)}) {
+		t.Errorf("IntersectRect: got %v", got)
+	}
+	if got := r.Translate(5, -5).Bounds(); got != image.Rect(5, -5, 35, 25) {
+		t.Errorf("Translate: got %v", got)
+	}
+	if !r.SubtractRect(image.Rect(0, 0, 30, 30)).Empty() || !NewRegion(image.Rect(5, 5, 5, 10)).Empty() {
+		t.Errorf("the empty regions")
+	}
+}
+
+// TestRegionPixels checks the operations of the random regions pixel by pixel.
+func TestRegionPixels(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	random_region := func() Region {
+		var r Region
+		for i := 0; i < 4; i++ {
+			x, y := rng.Intn(16), rng.Intn(16)
+			rect := image.Rect(x, y, x+1+rng.Intn(8), y+1+rng.Intn(8))
+			if rng.Intn(3) == 0 {
+				r = r.SubtractRect(rect)
+			} else {
+				r = r.UnionRect(rect)
+			}
+		}
+		return r
+	}
+
+	for n := 0; n < 100; n++ {
+		a, b := random_region(), random_region()
+		union, intersect, subtract := a.Union(b), a.Intersect(b), a.Subtract(b)
+		for _, r := range []Region{a, b, union, intersect, subtract} {
+			rects := r.Rects()
+			for i := 1; i < len(rects); i++ {
+				p, q := rects[i-1], rects[i]
+				if q.Min.Y < p.Min.Y || q.Min.Y == p.Min.Y && (q.Min.X < p.Max.X || q.Max.Y != p.Max.Y) ||
+					q.Min.Y > p.Min.Y && q.Min.Y < p.Max.Y {
+					t.Fatalf("the rects aren't banded: %v", rects)
+				}
+			}
+		}
+		for y := -1; y < 25; y++ {
+			for x := -1; x < 25; x++ {
+				p := image.Pt(x, y)
+				in_a, in_b := a.Contains(p), b.Contains(p)
+				if union.Contains(p) != (in_a || in_b) || intersect.Contains(p) != (in_a && in_b) ||
+					subtract.Contains(p) != (in_a && !in_b) {
+					t.Fatalf("the pixel %v of %v and %v", p, a.Rects(), b.Rects())
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright 2014 By Jshi. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vango
+
+import (
+	"image"
+	"math"
+)
+
+// CornerRadii are the radii of the four corners of a rounded rect.
+type CornerRadii struct {
+	TopLeft, TopRight, BottomRight, BottomLeft float64
+}
+
+// UniformRadii returns the radii of |r| for all the corners.
+func UniformRadii(r float64) CornerRadii {
+	return CornerRadii{r, r, r, r}
+}
+
+// The shape primitives below draw through the rasterizer like Fill and
+// Stroke, with the current transform, paints and line style. They don't
+// change the current path. The angles are in radians, clockwise from the
+// right like the y axis pointing down, a negative sweep goes counterclockwise.
+
+func (c *CanvasContext) FillRoundedRect(rect image.Rectangle, radii CornerRadii) {
+	c.with_path(func() { add_rounded_rect(c, rect, radii) }, c.Fill)
+}
+
+func (c *CanvasContext) StrokeRoundedRect(rect image.Rectangle, radii CornerRadii) {
+	c.with_path(func() { add_rounded_rect(c, rect, radii) }, c.Stroke)
+}
+
+// FillEllipse fills the ellipse at (cx, cy) with the radii |rx| and |ry|.
+func (c *CanvasContext) FillEllipse(cx, cy, rx, ry float64) {
+	c.with_path(func() { add_ellipse(c, cx, cy, rx, ry) }, c.Fill)
+}
+
+func (c *CanvasContext) StrokeEllipse(cx, cy, rx, ry float64) {
+	c.with_path(func() { add_ellipse(c, cx, cy, rx, ry) }, c.Stroke)
+}
+
+// Arc strokes the arc of the ellipse at (cx, cy) from the angle |start| by
+// the angle |sweep|.
+func (c *CanvasContext) Arc(cx, cy, rx, ry, start, sweep float64) {
+	c.with_path(func() { add_arc(c, cx, cy, rx, ry, start, sweep, false) }, c.Stroke)
+}
+
+// Pie fills the slice of the ellipse at (cx, cy) from the angle |start| by
+// the angle |sweep|, a full turn fills the ellipse.
+func (c *CanvasContext) Pie(cx, cy, rx, ry, start, sweep float64) {
+	c.with_path(func() { add_pie(c, cx, cy, rx, ry, start, sweep) }, c.Fill)
+}
+
+// StrokePie strokes the outline of the slice drawn by Pie.
+func (c *CanvasContext) StrokePie(cx, cy, rx, ry, start, sweep float64) {
+	c.with_path(func() { add_pie(c, cx, cy, rx, ry, start, sweep) }, c.Stroke)
+}
+
+// Polygon fills the polygon of |points| with the fill rule.
+func (c *CanvasContext) Polygon(points []image.Point) {
+	c.with_path(func() { add_polyline(c, points, true) }, c.Fill)
+}
+
+// StrokePolygon strokes the closed outline of the polygon of |points|.
+func (c *CanvasContext) StrokePolygon(points []image.Point) {
+	c.with_path(func() { add_polyline(c, points, true) }, c.Stroke)
+}
+
+// Polyline strokes the open line through |points|.
+func (c *CanvasContext) Polyline(points []image.Point) {
+	c.with_path(func() { add_polyline(c, points, false) }, c.Stroke)
+}
+
+// path_builder_t is the path methods of the contexts, which the shapes are
+// built by.
+type path_builder_t interface {
+	MoveTo(x, y float64)
+	LineTo(x, y float64)
+	CubicTo(c1x, c1y, c2x, c2y, x, y float64)
+	ClosePath()
+}
+
+// with_path calls |build| on an empty path and |draw| to draw it, then puts
+// the current path back.
+func (c *CanvasContext) with_path(build func(), draw func()) {
+	path, start, current, has, closed := c.path, c.start_point, c.current_point, c.has_current_point, c.path_closed
+	c.path, c.has_current_point, c.path_closed = nil, false, false
+	build()
+	draw()
+	c.path, c.start_point, c.current_point, c.has_current_point, c.path_closed = path, start, current, has, closed
+}
+
+// add_rounded_rect adds the closed rounded rect. Like css, the radii are
+// scaled down together if the adjacent ones exceed the side between them.
+func add_rounded_rect(c path_builder_t, rect image.Rectangle, radii CornerRadii) {
+	if rect.Empty() {
+		return
+	}
+	x0, y0 := float64(rect.Min.X), float64(rect.Min.Y)
+	x1, y1 := float64(rect.Max.X), float64(rect.Max.Y)
+	w, h := x1-x0, y1-y0
+
+	tl, tr := math.Max(0, radii.TopLeft), math.Max(0, radii.TopRight)
+	br, bl := math.Max(0, radii.BottomRight), math.Max(0, radii.BottomLeft)
+	k := 1.0
+	for _, f := range []float64{w / (tl + tr), h / (tr + br), w / (br + bl), h / (bl + tl)} {
+		if f < k {
+			k = f
+		}
+	}
+	tl, tr, br, bl = tl*k, tr*k, br*k, bl*k
+
+	c.MoveTo(x0+tl, y0)
+	c.LineTo(x1-tr, y0)
+	add_arc_segments(c, x1-tr, y0+tr, tr, tr, -math.Pi/2, math.Pi/2)
+	c.LineTo(x1, y1-br)
+	add_arc_segments(c, x1-br, y1-br, br, br, 0, math.Pi/2)
+	c.LineTo(x0+bl, y1)
+	add_arc_segments(c, x0+bl, y1-bl, bl, bl, math.Pi/2, math.Pi/2)
+	c.LineTo(x0, y0+tl)
+	add_arc_segments(c, x0+tl, y0+tl, tl, tl, math.Pi, math.Pi/2)
+	c.ClosePath()
+}
+
+func add_ellipse(c path_builder_t, cx, cy, rx, ry float64) {
+	add_arc(c, cx, cy, rx, ry, 0, 2*math.Pi, false)
+	c.ClosePath()
+}
+
+// add_arc adds the arc as a new sub path, or connected from the current point
+// by a line if |connect| is true.
+func add_arc(c path_builder_t, cx, cy, rx, ry, start, sweep float64, connect bool) {
+	x, y := cx+rx*math.Cos(start), cy+ry*math.Sin(start)
+	if connect {
+		c.LineTo(x, y)
+	} else {
+		c.MoveTo(x, y)
+	}
+	add_arc_segments(c, cx, cy, rx, ry, start, sweep)
+}
+
+func add_pie(c path_builder_t, cx, cy, rx, ry, start, sweep float64) {
+	if math.Abs(sweep) >= 2*math.Pi {
+		add_ellipse(c, cx, cy, rx, ry)
+		return
+	}
+	c.MoveTo(cx, cy)
+	add_arc(c, cx, cy, rx, ry, start, sweep, true)
+	c.ClosePath()
+}
+
+// add_arc_segments adds the arc from the current point, which is at the
+// |start| angle, as cubic curves of at most a quarter turn each.
+func add_arc_segments(c path_builder_t, cx, cy, rx, ry, start, sweep float64) {
+	if sweep > 2*math.Pi {
+		sweep = 2 * math.Pi
+	} else if sweep < -2*math.Pi {
+		sweep = -2 * math.Pi
+	}
+	if sweep == 0 || (rx == 0 && ry == 0) {
+		return
+	}
+
+	n := int(math.Ceil(math.Abs(sweep)/(math.Pi/2) - 1e-9))
+	step := sweep / float64(n)
+	// The distance of the control points along the tangents.
+	k := 4.0 / 3 * math.Tan(step/4)
+
+	a0 := start
+	cos0, sin0 := math.Cos(a0), math.Sin(a0)
+	for i := 0; i < n; i++ {
+		a1 := a0 + step
+		cos1, sin1 := math.Cos(a1), math.Sin(a1)
+		c.CubicTo(
+			cx+rx*(cos0-k*sin0), cy+ry*(sin0+k*cos0),
+			cx+rx*(cos1+k*sin1), cy+ry*(sin1-k*cos1),
+			cx+rx*cos1, cy+ry*sin1)
+		a0, cos0, sin0 = a1, cos1, sin1
+	}
+}
+
+// add_polyline adds the line through |points|, closed if |closed| is true.
+func add_polyline(c path_builder_t, points []image.Point, closed bool) {
+	for i, pt := range points {
+		if i == 0 {
+			c.MoveTo(float64(pt.X), float64(pt.Y))
+		} else {
+			c.LineTo(float64(pt.X), float64(pt.Y))
+		}
+	}
+	if closed {
+		c.ClosePath()
+	}
+}
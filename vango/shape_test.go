@@ -0,0 +1,120 @@
+package vango
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+func TestRoundedRect(t *testing.T) {
+	ctxt, canvas := new_test_context(40, 40)
+	ctxt.FillRoundedRect(image.Rect(0, 0, 40, 40), CornerRadii{20, 0, 10, 0})
+
+	if is_painted(canvas, 1, 1) || !is_painted(canvas, 38, 1) || is_painted(canvas, 39, 39) || !is_painted(canvas, 1, 38) {
+		t.Errorf("the corners aren't rounded by their radii")
+	}
+	if !is_painted(canvas, 20, 20) || !is_painted(canvas, 36, 36) {
+		t.Errorf("the inside isn't painted")
+	}
+
+	// The radii too large for the rect are scaled down.
+	ctxt, canvas = new_test_context(40, 40)
+	ctxt.FillRoundedRect(image.Rect(0, 10, 40, 30), UniformRadii(100))
+	if !is_painted(canvas, 20, 20) || is_painted(canvas, 1, 11) {
+		t.Errorf("the oversized radii: got a wrong shape")
+	}
+
+	// The stroke keeps the current path.
+	ctxt, canvas = new_test_context(40, 40)
+	ctxt.BeginPath()
+	ctxt.MoveTo(0, 0)
+	ctxt.LineTo(10, 0)
+	ctxt.SetLineWidth(2)
+	ctxt.StrokeRoundedRect(image.Rect(10, 10, 30, 30), UniformRadii(4))
+	if len(ctxt.path) == 0 || !ctxt.has_current_point {
+		t.Errorf("StrokeRoundedRect changed the current path")
+	}
+	if !is_painted(canvas, 20, 10) || is_painted(canvas, 20, 20) {
+		t.Errorf("the stroke of the rounded rect isn't on its edge")
+	}
+}
+
+func TestEllipse(t *testing.T) {
+	ctxt, canvas := new_test_context(60, 40)
+	ctxt.FillEllipse(30, 20, 25, 15)
+
+	if !is_painted(canvas, 30, 20) || !is_painted(canvas, 8, 20) || !is_painted(canvas, 30, 7) {
+		t.Errorf("the inside of the ellipse isn't painted")
+	}
+	if is_painted(canvas, 8, 8) || is_painted(canvas, 30, 37) {
+		t.Errorf("outside the ellipse is painted")
+	}
+
+	// The area is about pi*rx*ry.
+	n := 0
+	for y := 0; y < 40; y++ {
+		for x := 0; x < 60; x++ {
+			if is_painted(canvas, x, y) {
+				n++
+			}
+		}
+	}
+	if want := math.Pi * 25 * 15; math.Abs(float64(n)-want) > want*0.03 {
+		t.Errorf("the area of the ellipse: got %v, want about %v", n, want)
+	}
+
+	ctxt, canvas = new_test_context(60, 40)
+	ctxt.SetLineWidth(2)
+	ctxt.StrokeEllipse(30, 20, 10, 10)
+	if !is_painted(canvas, 40, 20) || is_painted(canvas, 30, 20) {
+		t.Errorf("the stroke of the circle isn't on its edge")
+	}
+}
+
+func TestArcAndPie(t *testing.T) {
+	// The quarter from the right to the bottom, clockwise.
+	ctxt, canvas := new_test_context(40, 40)
+	ctxt.Pie(20, 20, 15, 15, 0, math.Pi/2)
+	if !is_painted(canvas, 25, 25) {
+		t.Errorf("the pie doesn't cover the bottom right quarter")
+	}
+	if is_painted(canvas, 15, 25) || is_painted(canvas, 25, 15) || is_painted(canvas, 15, 15) {
+		t.Errorf("the pie covers the other quarters")
+	}
+
+	// A negative sweep goes counterclockwise to the top.
+	ctxt, canvas = new_test_context(40, 40)
+	ctxt.SetLineWidth(3)
+	ctxt.Arc(20, 20, 15, 15, 0, -math.Pi/2)
+	p := 15 / math.Sqrt2
+	if !is_painted(canvas, int(20+p), int(20-p)) {
+		t.Errorf("the arc isn't painted in the top right quarter")
+	}
+	if is_painted(canvas, int(20+p), int(20+p)) || is_painted(canvas, 20, 20) {
+		t.Errorf("the arc is painted outside its sweep")
+	}
+}
+
+func TestPolygon(t *testing.T) {
+	ctxt, canvas := new_test_context(40, 40)
+	triangle := []image.Point{{0, 0}, {40, 0}, {0, 40}}
+	ctxt.Polygon(triangle)
+	if !is_painted(canvas, 5, 5) || is_painted(canvas, 35, 35) {
+		t.Errorf("the polygon isn't filled")
+	}
+
+	ctxt, canvas = new_test_context(40, 40)
+	ctxt.SetLineWidth(2)
+	ctxt.Polyline(triangle)
+	if !is_painted(canvas, 20, 0) || !is_painted(canvas, 20, 20) {
+		t.Errorf("the polyline isn't stroked")
+	}
+	if is_painted(canvas, 0, 20) || is_painted(canvas, 10, 10) {
+		t.Errorf("the polyline is closed or filled")
+	}
+
+	ctxt.StrokePolygon(triangle)
+	if !is_painted(canvas, 0, 20) {
+		t.Errorf("the polygon outline isn't closed")
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2014 By Jshi. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vango
+
+import (
+	"gwk/vango/freetype"
+	"log"
+)
+
+// context_state_t is the graphics state of a Context. SaveState pushes a copy
+// of it, and RestoreState pops it back. The current path and the canvas are
+// not a part of the state.
+type context_state_t struct {
+	stroke_color uint32
+	fill_color   uint32
+	font_color   uint32
+	// The paints replace the colors if they aren't nil.
+	stroke_paint Paint
+	fill_paint   Paint
+	font_paint   Paint
+	dither       bool
+	font_face    *freetype.Font
+	font_size    float64
+	global_alpha float64
+	transform    Matrix
+	clip         *Clip
+	composite_op CompositeOp
+	image_filter Filter
+	// The fallback fonts are never changed once set, like the dash pattern.
+	font_fallbacks []*freetype.Font
+
+	fill_rule   FillRule
+	line_width  float64
+	line_cap    LineCap
+	line_join   LineJoin
+	miter_limit float64
+	// The dash pattern is never changed once set, so the saved states can
+	// share it.
+	line_dash        []float64
+	line_dash_offset float64
+}
+
+// SaveState pushes the current graphics state onto the state stack.
+func (c *CanvasContext) SaveState() {
+	c.state_stack = append(c.state_stack, c.context_state_t)
+}
+
+// RestoreState pops the graphics state saved by the last SaveState. It's a
+// no-op if the state stack is empty.
+func (c *CanvasContext) RestoreState() {
+	n := len(c.state_stack)
+	if n == 0 {
+		log.Printf("WARNING: RestoreState without SaveState.")
+		return
+	}
+
+	c.context_state_t = c.state_stack[n-1]
+	c.state_stack = c.state_stack[:n-1]
+
+	// The font renderer keeps its own copy of the face and the size.
+	c.font.SetFontFace(c.font_face)
+	c.font.SetFontSize(c.font_size)
+	c.font.SetFallbacks(c.font_fallbacks)
+}
+
+// StateDepth returns the number of the saved graphics states.
+func (c *CanvasContext) StateDepth() int {
+	return len(c.state_stack)
+}
+
+// SetGlobalAlpha sets the alpha in [0, 1] applied to everything drawn.
+func (c *CanvasContext) SetGlobalAlpha(alpha float64) {
+	if alpha < 0 {
+		alpha = 0
+	} else if alpha > 1 {
+		alpha = 1
+	}
+	c.global_alpha = alpha
+}
+
+func (c *CanvasContext) GlobalAlpha() float64 {
+	return c.global_alpha
+}
+
+// global_alpha_8 returns the global alpha in [0, 255].
+func (c *CanvasContext) global_alpha_8() uint32 {
+	return uint32(c.global_alpha*0xff + 0.5)
+}
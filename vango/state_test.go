@@ -0,0 +1,70 @@
+package vango
+
+import (
+	"image"
+	"testing"
+)
+
+func TestSaveRestoreState(t *testing.T) {
+	ctxt, canvas := new_test_context(20, 20)
+	ctxt.SetLineWidth(3)
+
+	ctxt.SaveState()
+	ctxt.SetFillColor(0, 0xff, 0)
+	ctxt.SetLineWidth(7)
+	ctxt.SetFontSize(30)
+
+	ctxt.SaveState()
+	ctxt.SetGlobalAlpha(0.5)
+	ctxt.SetFillRule(FillRuleEvenOdd)
+	if ctxt.StateDepth() != 2 {
+		t.Errorf("state depth: got %v, want 2", ctxt.StateDepth())
+	}
+
+	ctxt.RestoreState()
+	if ctxt.GlobalAlpha() != 1 || ctxt.FillRule() != FillRuleNonZero {
+		t.Errorf("inner restore: got alpha %v rule %v", ctxt.GlobalAlpha(), ctxt.FillRule())
+	}
+	if ctxt.LineWidth() != 7 {
+		t.Errorf("inner restore: got line width %v, want 7", ctxt.LineWidth())
+	}
+
+	ctxt.RestoreState()
+	if ctxt.LineWidth() != 3 {
+		t.Errorf("outer restore: got line width %v, want 3", ctxt.LineWidth())
+	}
+	if ctxt.FontSize() != 12 {
+		t.Errorf("outer restore: got font size %v, want 12", ctxt.FontSize())
+	}
+
+	ctxt.FillRect(image.Rect(0, 0, 20, 20))
+	if r, g, _, _ := pixel_at(canvas, 10, 10); r != 0xff || g != 0 {
+		t.Errorf("fill color after restore: got r=%v g=%v, want red", r, g)
+	}
+
+	// An unbalanced RestoreState is ignored.
+	ctxt.RestoreState()
+	if ctxt.StateDepth() != 0 || ctxt.LineWidth() != 3 {
+		t.Errorf("unbalanced restore changed the state")
+	}
+}
+
+func TestGlobalAlpha(t *testing.T) {
+	ctxt, canvas := new_test_context(40, 20)
+	ctxt.SetGlobalAlpha(0.5)
+
+	ctxt.FillRect(image.Rect(0, 0, 20, 20))
+	if r, _, _, _ := pixel_at(canvas, 10, 10); r < 0x7e || r > 0x81 {
+		t.Errorf("FillRect: got r=%v, want about 0x80", r)
+	}
+
+	ctxt.BeginPath()
+	ctxt.MoveTo(20, 0)
+	ctxt.LineTo(40, 0)
+	ctxt.LineTo(40, 20)
+	ctxt.LineTo(20, 20)
+	ctxt.Fill()
+	if r, _, _, _ := pixel_at(canvas, 30, 10); r < 0x7e || r > 0x81 {
+		t.Errorf("Fill: got r=%v, want about 0x80", r)
+	}
+}
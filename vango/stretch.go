@@ -0,0 +1,244 @@
+// Copyright 2014 By Jshi. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vango
+
+import (
+	"image"
+	"math"
+)
+
+// Filter is the resampling filter of the scaled images.
+type Filter int
+
+const (
+	FilterNearest  Filter = iota // The nearest pixel, fast and blocky.
+	FilterBilinear               // The linear filter, the default.
+	FilterBicubic                // The Catmull-Rom cubic filter.
+	FilterLanczos                // The Lanczos filter with 3 lobes, sharp.
+)
+
+// SetImageFilter sets the filter used by DrawStretch.
+func (c *CanvasContext) SetImageFilter(filter Filter) {
+	c.image_filter = filter
+}
+
+func (c *CanvasContext) ImageFilter() Filter {
+	return c.image_filter
+}
+
+// radius returns the support of the filter for the magnification.
+func (f Filter) radius() float64 {
+	switch f {
+	case FilterNearest:
+		return 0.5
+	case FilterBicubic:
+		return 2
+	case FilterLanczos:
+		return 3
+	}
+	return 1
+}
+
+// weight returns the value of the filter kernel at |x|.
+func (f Filter) weight(x float64) float64 {
+	x = math.Abs(x)
+	switch f {
+	case FilterNearest:
+		if x < 0.5 {
+			return 1
+		}
+		return 0
+	case FilterBicubic:
+		// Catmull-Rom, the cubic with a = -0.5.
+		if x < 1 {
+			return (1.5*x-2.5)*x*x + 1
+		} else if x < 2 {
+			return ((-0.5*x+2.5)*x-4)*x + 2
+		}
+		return 0
+	case FilterLanczos:
+		if x == 0 {
+			return 1
+		} else if x >= 3 {
+			return 0
+		}
+		px := math.Pi * x
+		return 3 * math.Sin(px) * math.Sin(px/3) / (px * px)
+	}
+	if x < 1 {
+		return 1 - x
+	}
+	return 0
+}
+
+// filter_weights_t is the source pixels and their weights of each destination
+// pixel on one axis.
+type filter_weights_t struct {
+	start  []int       // the first source pixel.
+	weight [][]float64 // the weights of the source pixels from start.
+}
+
+// new_filter_weights returns the weights to scale |n0| source pixels to |n1|
+// destination pixels, for the destination pixels in [i0, i1). The source
+// pixels outside [0, n0) are clamped to the edges.
+func new_filter_weights(filter Filter, n0, n1, i0, i1 int) *filter_weights_t {
+	scale := float64(n0) / float64(n1)
+	// The filter is widened to cover all the source pixels when scaled down.
+	k := math.Max(scale, 1)
+	radius := filter.radius() * k
+
+	fw := &filter_weights_t{make([]int, i1-i0), make([][]float64, i1-i0)}
+	for i := i0; i < i1; i++ {
+		center := (float64(i)+0.5)*scale - 0.5
+		if filter == FilterNearest {
+			j := int((float64(i) + 0.5) * scale)
+			if j >= n0 {
+				j = n0 - 1
+			}
+			fw.start[i-i0], fw.weight[i-i0] = j, []float64{1}
+			continue
+		}
+
+		j0 := int(math.Ceil(center - radius))
+		j1 := int(math.Floor(center + radius))
+		lo, hi := j0, j1
+		if lo < 0 {
+			lo = 0
+		}
+		if hi > n0-1 {
+			hi = n0 - 1
+		}
+
+		w := make([]float64, hi-lo+1)
+		sum := 0.0
+		for j := j0; j <= j1; j++ {
+			v := filter.weight((float64(j) - center) / k)
+			jj := j
+			if jj < lo {
+				jj = lo
+			} else if jj > hi {
+				jj = hi
+			}
+			w[jj-lo] += v
+			sum += v
+		}
+		if sum != 0 {
+			for j := range w {
+				w[j] /= sum
+			}
+		}
+		fw.start[i-i0], fw.weight[i-i0] = lo, w
+	}
+	return fw
+}
+
+// stretch_rows scales the |rect| of |src| to the size (w, h), and calls
+// |emit| with the premultiplied BGRA row of each destination pixel in the
+// |visible| rect of [0, w) x [0, h). The row starts at visible.Min.X.
+func stretch_rows(src *Canvas, rect image.Rectangle, w, h int, filter Filter,
+	visible image.Rectangle, emit func(y int, row []byte)) {
+	visible = visible.Intersect(image.Rect(0, 0, w, h))
+	rect = rect.Intersect(src.LocalBounds())
+	if visible.Empty() || rect.Empty() {
+		return
+	}
+	if src.Format() != PixelFormatBGRA8 {
+		src = src.SubCanvas(rect).ConvertTo(PixelFormatBGRA8)
+		rect = src.LocalBounds()
+	}
+
+	sw, sh := rect.Dx(), rect.Dy()
+	fx := new_filter_weights(filter, sw, w, visible.Min.X, visible.Max.X)
+	fy := new_filter_weights(filter, sh, h, visible.Min.Y, visible.Max.Y)
+	vw := visible.Dx()
+
+	// The source rows needed by the visible rows.
+	r0, r1 := fy.start[0], 0
+	for i, ws := range fy.weight {
+		if s := fy.start[i]; s < r0 {
+			r0 = s
+		}
+		if e := fy.start[i] + len(ws); e > r1 {
+			r1 = e
+		}
+	}
+
+	// The horizontal pass of the source rows.
+	p := src.Pix()
+	horz := make([]float64, (r1-r0)*vw*4)
+	for y := r0; y < r1; y++ {
+		i0 := src.PixOffset(rect.Min.X, rect.Min.Y+y)
+		out := horz[(y-r0)*vw*4:]
+		for x := 0; x < vw; x++ {
+			var acc [4]float64
+			o := i0 + fx.start[x]*4
+			for _, k := range fx.weight[x] {
+				acc[0] += float64(p[o+0]) * k
+				acc[1] += float64(p[o+1]) * k
+				acc[2] += float64(p[o+2]) * k
+				acc[3] += float64(p[o+3]) * k
+				o += 4
+			}
+			copy(out[x*4:x*4+4], acc[:])
+		}
+	}
+
+	// The vertical pass. The overshoot of the sharp filters is clamped, so a
+	// color never exceeds its alpha.
+	row := make([]byte, vw*4)
+	for y := visible.Min.Y; y < visible.Max.Y; y++ {
+		i := y - visible.Min.Y
+		for x := 0; x < vw; x++ {
+			var acc [4]float64
+			o := (fy.start[i]-r0)*vw*4 + x*4
+			for _, k := range fy.weight[i] {
+				acc[0] += horz[o+0] * k
+				acc[1] += horz[o+1] * k
+				acc[2] += horz[o+2] * k
+				acc[3] += horz[o+3] * k
+				o += vw * 4
+			}
+			a := math.Max(0, math.Min(255, acc[3]))
+			row[x*4+3] = byte(a + 0.5)
+			for j := 0; j < 3; j++ {
+				row[x*4+j] = byte(math.Max(0, math.Min(a, acc[j])) + 0.5)
+			}
+		}
+		emit(y, row)
+	}
+}
+
+// DrawStretch scales the |src_rect| of |src| to the |dst_rect| in the user
+// space with the image filter, and composites it like AlphaBlend. The filter
+// is used if the transform only translates by whole pixels, otherwise the
+// image is resampled through the transform like AlphaBlend.
+func (c *CanvasContext) DrawStretch(dst_rect image.Rectangle, src *Canvas, src_rect image.Rectangle) {
+	src_rect = src_rect.Intersect(src.LocalBounds())
+	if c.canvas == nil || dst_rect.Empty() || src_rect.Empty() {
+		return
+	}
+
+	dx, dy, ok := c.transform.int_translation()
+	if !ok {
+		if src.Format() != PixelFormatBGRA8 {
+			src = src.SubCanvas(src_rect).ConvertTo(PixelFormatBGRA8)
+			src_rect = src.LocalBounds()
+		}
+		m := c.transform
+		c.transform = m.Multiply(TranslateMatrix(float64(dst_rect.Min.X), float64(dst_rect.Min.Y))).
+			Multiply(ScaleMatrix(float64(dst_rect.Dx())/float64(src_rect.Dx()),
+				float64(dst_rect.Dy())/float64(src_rect.Dy())))
+		c.draw_transformed(0, 0, &sample_src_t{src.Pix(), src.Stride(),
+			src.PixOffset(src_rect.Min.X, src_rect.Min.Y), src_rect.Dx(), src_rect.Dy(), false})
+		c.transform = m
+		return
+	}
+
+	dr := dst_rect.Add(image.Pt(dx, dy))
+	visible := dr.Intersect(c.clip_bounds()).Sub(dr.Min)
+	stretch_rows(src, src_rect, dr.Dx(), dr.Dy(), c.image_filter, visible, func(y int, row []byte) {
+		c.blend_span(dr.Min.X+visible.Min.X, dr.Min.Y+y, visible.Dx(), [4]uint32{}, row, nil, 0xff)
+	})
+}
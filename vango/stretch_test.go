@@ -0,0 +1,121 @@
+package vango
+
+import (
+	"image"
+	"testing"
+)
+
+func TestDrawStretchNearest(t *testing.T) {
+	// A 2x2 checker of red and transparent.
+	src := NewCanvas(2, 2)
+	for _, pt := range []image.Point{{0, 0}, {1, 1}} {
+		copy(src.Pix()[src.PixOffset(pt.X, pt.Y):], []byte{0, 0, 0xff, 0xff})
+	}
+
+	ctxt, canvas := new_test_context(8, 8)
+	ctxt.SetImageFilter(FilterNearest)
+	ctxt.DrawStretch(canvas.LocalBounds(), src, src.LocalBounds())
+
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			want := (x < 4) == (y < 4)
+			if is_painted(canvas, x, y) != want {
+				t.Fatalf("(%v, %v): got painted %v, want %v", x, y, !want, want)
+			}
+		}
+	}
+}
+
+func TestDrawStretchAlpha(t *testing.T) {
+	// Red next to transparent black: the scaled edge fades without turning
+	// dark, for all the filters.
+	src := NewCanvas(2, 1)
+	copy(src.Pix(), []byte{0, 0, 0xff, 0xff})
+
+	for _, filter := range []Filter{FilterNearest, FilterBilinear, FilterBicubic, FilterLanczos} {
+		ctxt, canvas := new_test_context(16, 4)
+		ctxt.SetImageFilter(filter)
+		ctxt.DrawStretch(canvas.LocalBounds(), src, src.LocalBounds())
+
+		partial := false
+		for x := 0; x < 16; x++ {
+			r, g, b, a := pixel_at(canvas, x, 1)
+			if r != a || g != 0 || b != 0 {
+				t.Fatalf("filter %v at x=%v: got (%v, %v, %v, %v), want premultiplied red", filter, x, r, g, b, a)
+			}
+			if a > 0 && a < 0xff {
+				partial = true
+			}
+		}
+		if partial != (filter != FilterNearest) {
+			t.Errorf("filter %v: the edge is blended %v", filter, partial)
+		}
+		if r, _, _, _ := pixel_at(canvas, 0, 1); r < 0xf0 {
+			t.Errorf("filter %v: the red end got r=%v", filter, r)
+		}
+	}
+}
+
+func TestDrawStretchDown(t *testing.T) {
+	// A constant color stays constant, and the stripes average out.
+	src := NewCanvas(100, 100)
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 100; x++ {
+			v := byte(0)
+			if x%2 == 0 {
+				v = 0xff
+			}
+			copy(src.Pix()[src.PixOffset(x, y):], []byte{v, v, v, 0xff})
+		}
+	}
+
+	for _, filter := range []Filter{FilterBilinear, FilterBicubic, FilterLanczos} {
+		ctxt, canvas := new_test_context(10, 10)
+		ctxt.SetImageFilter(filter)
+		ctxt.DrawStretch(canvas.LocalBounds(), src, src.LocalBounds())
+		if r, _, _, a := pixel_at(canvas, 5, 5); r < 0x70 || r > 0x90 || a != 0xff {
+			t.Errorf("filter %v: got r=%v a=%v, want gray", filter, r, a)
+		}
+	}
+}
+
+func TestDrawStretchClip(t *testing.T) {
+	src := NewCanvas(4, 4)
+	for i := 0; i < len(src.Pix()); i += 4 {
+		copy(src.Pix()[i:], []byte{0, 0, 0xff, 0xff})
+	}
+
+	ctxt, canvas := new_test_context(40, 40)
+	ctxt.ClipRect(image.Rect(0, 0, 20, 40))
+	ctxt.SetImageFilter(FilterLanczos)
+	ctxt.DrawStretch(image.Rect(-10, 10, 60, 30), src, src.LocalBounds())
+
+	if !is_painted(canvas, 0, 20) || !is_painted(canvas, 19, 20) {
+		t.Errorf("inside the clip isn't painted")
+	}
+	if is_painted(canvas, 20, 20) || is_painted(canvas, 10, 5) {
+		t.Errorf("outside the clip or the rect is painted")
+	}
+
+	// The transformed stretch stays inside the transformed rect.
+	ctxt, canvas = new_test_context(40, 40)
+	ctxt.Translate(0.5, 0)
+	ctxt.DrawStretch(image.Rect(10, 10, 30, 30), src, src.LocalBounds())
+	if !is_painted(canvas, 20, 20) || is_painted(canvas, 5, 20) || is_painted(canvas, 35, 20) {
+		t.Errorf("the transformed stretch isn't in the rect")
+	}
+}
+
+func TestCanvasStretchDraw(t *testing.T) {
+	src := NewCanvas(3, 3)
+	for i := 0; i < len(src.Pix()); i += 4 {
+		copy(src.Pix()[i:], []byte{0, 0, 0xff, 0xff})
+	}
+
+	// The edges are painted too.
+	dst := NewCanvas(10, 10)
+	dst.StretchDraw(image.Rect(0, 0, 10, 10), src)
+	if !is_painted(dst, 0, 0) || !is_painted(dst, 9, 9) {
+		t.Errorf("StretchDraw misses the edges")
+	}
+}
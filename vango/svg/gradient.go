@@ -0,0 +1,174 @@
+// Copyright 2014 By Jshi. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package svg
+
+import (
+	"gwk/vango"
+	"math"
+	"strings"
+)
+
+// The limit of the href chain of the gradients, against the cycles.
+const kMaxGradientHrefs = 8
+
+// gradient_t is a resolved linearGradient or radialGradient.
+type gradient_t struct {
+	// The coordinates are in the user space, not in the units of the
+	// bounding box.
+	user_space bool
+	transform  vango.Matrix
+	linear     bool
+	coords     [5]float64 // x1 y1 x2 y2, or cx cy r fx fy
+	spread     vango.SpreadMode
+	stops      []vango.ColorStop
+}
+
+// gradient returns the gradient of the |id|, or nil if there isn't one.
+// The missing attributes and stops come from the gradients of the href.
+func (doc *Document) gradient(id string) *gradient_t {
+	n := doc.ids[id]
+	if n == nil || (n.name != "linearGradient" && n.name != "radialGradient") {
+		return nil
+	}
+
+	chain := []*node_t{n}
+	for len(chain) < kMaxGradientHrefs {
+		href := strings.TrimPrefix(chain[len(chain)-1].attrs["href"], "#")
+		next := doc.ids[href]
+		if next == nil || (next.name != "linearGradient" && next.name != "radialGradient") {
+			break
+		}
+		chain = append(chain, next)
+	}
+	attr := func(name string) (string, bool) {
+		for _, n := range chain {
+			if v, ok := n.attrs[name]; ok {
+				return v, true
+			}
+		}
+		return "", false
+	}
+
+	gr := &gradient_t{linear: n.name == "linearGradient", transform: vango.IdentityMatrix()}
+	units, _ := attr("gradientUnits")
+	gr.user_space = units == "userSpaceOnUse"
+	if v, ok := attr("gradientTransform"); ok {
+		if m, ok := parse_transform(v); ok {
+			gr.transform = m
+		}
+	}
+	switch v, _ := attr("spreadMethod"); v {
+	case "reflect":
+		gr.spread = vango.SpreadReflect
+	case "repeat":
+		gr.spread = vango.SpreadRepeat
+	}
+
+	// The percentages are of the bounding box, or of the viewBox in the user
+	// space.
+	_, _, vw, vh := doc.ViewBox()
+	if !gr.user_space {
+		vw, vh = 1, 1
+	}
+	coord := func(name string, def string, percent_of float64) float64 {
+		v, ok := attr(name)
+		if !ok {
+			v = def
+		}
+		f, ok := parse_length(v, percent_of)
+		if !ok {
+			f, _ = parse_length(def, percent_of)
+		}
+		return f
+	}
+	if gr.linear {
+		gr.coords[0] = coord("x1", "0%", vw)
+		gr.coords[1] = coord("y1", "0%", vh)
+		gr.coords[2] = coord("x2", "100%", vw)
+		gr.coords[3] = coord("y2", "0%", vh)
+	} else {
+		gr.coords[0] = coord("cx", "50%", vw)
+		gr.coords[1] = coord("cy", "50%", vh)
+		gr.coords[2] = coord("r", "50%", math.Sqrt((vw*vw+vh*vh)/2))
+		// The focal point is the center by default.
+		gr.coords[3] = gr.coords[0]
+		gr.coords[4] = gr.coords[1]
+		if _, ok := attr("fx"); ok {
+			gr.coords[3] = coord("fx", "50%", vw)
+		}
+		if _, ok := attr("fy"); ok {
+			gr.coords[4] = coord("fy", "50%", vh)
+		}
+	}
+
+	for _, n := range chain {
+		if gr.stops = parse_stops(n); len(gr.stops) > 0 {
+			break
+		}
+	}
+	return gr
+}
+
+// parse_stops returns the stops of the gradient |n|. The offsets are clamped
+// to be in order.
+func parse_stops(n *node_t) []vango.ColorStop {
+	var stops []vango.ColorStop
+	last := 0.0
+	for _, child := range n.children {
+		if child.name != "stop" {
+			continue
+		}
+		offset, _ := parse_length(child.attrs["offset"], 1)
+		offset = math.Max(last, math.Min(1, offset))
+		last = offset
+
+		clr := vango.NewSolidPaint(0, 0, 0, 0xff)
+		if v, ok := child.attrs["stop-color"]; ok {
+			if c, ok := parse_color(v); ok {
+				clr = c
+			}
+		}
+		opacity := parse_opacity(child.attrs["stop-opacity"], 1)
+		stops = append(stops, vango.ColorStop{
+			Offset: offset,
+			R:      clr.R, G: clr.G, B: clr.B,
+			A: byte(float64(clr.A)*opacity + 0.5),
+		})
+	}
+	return stops
+}
+
+// paint returns the vango paint of the gradient, with the alpha of the stops
+// multiplied by |opacity|. Without the stops it paints nothing, and with one
+// it's the color of the stop.
+func (gr *gradient_t) paint(opacity float64) vango.Paint {
+	if len(gr.stops) == 0 {
+		return vango.NewSolidPaint(0, 0, 0, 0)
+	}
+	alpha := func(stop vango.ColorStop) byte {
+		return byte(float64(stop.A)*opacity + 0.5)
+	}
+	if len(gr.stops) == 1 {
+		stop := gr.stops[0]
+		return vango.NewSolidPaint(stop.R, stop.G, stop.B, alpha(stop))
+	}
+
+	var paint vango.Paint
+	var g *vango.Gradient
+	c := gr.coords
+	if gr.linear {
+		lg := vango.NewLinearGradient(c[0], c[1], c[2], c[3])
+		paint, g = lg, &lg.Gradient
+	} else {
+		rg := vango.NewRadialGradient(c[0], c[1], c[2])
+		rg.SetFocalPoint(c[3], c[4])
+		paint, g = rg, &rg.Gradient
+	}
+	g.SetSpread(gr.spread)
+	for _, stop := range gr.stops {
+		g.AddColorStop(stop.Offset, stop.R, stop.G, stop.B, alpha(stop))
+	}
+	return paint
+}
@@ -0,0 +1,374 @@
+// Copyright 2014 By Jshi. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package svg
+
+import (
+	"gwk/vango"
+	"math"
+)
+
+// The numbers of the args of the path commands.
+var g_path_command_args = map[byte]int{
+	'M': 2, 'L': 2, 'H': 1, 'V': 1, 'C': 6, 'S': 4, 'Q': 4, 'T': 2, 'A': 7, 'Z': 0,
+}
+
+// path_t is an outline of the absolute moves, lines, quads and cubics. The
+// other commands of the path data are converted to them.
+type path_t struct {
+	cmds []byte // 'M', 'L', 'Q', 'C' or 'Z'
+	pts  []float64
+}
+
+func (p *path_t) move_to(x, y float64) {
+	p.cmds = append(p.cmds, 'M')
+	p.pts = append(p.pts, x, y)
+}
+
+func (p *path_t) line_to(x, y float64) {
+	p.cmds = append(p.cmds, 'L')
+	p.pts = append(p.pts, x, y)
+}
+
+func (p *path_t) quad_to(cx, cy, x, y float64) {
+	p.cmds = append(p.cmds, 'Q')
+	p.pts = append(p.pts, cx, cy, x, y)
+}
+
+func (p *path_t) cubic_to(c1x, c1y, c2x, c2y, x, y float64) {
+	p.cmds = append(p.cmds, 'C')
+	p.pts = append(p.pts, c1x, c1y, c2x, c2y, x, y)
+}
+
+func (p *path_t) close() {
+	p.cmds = append(p.cmds, 'Z')
+}
+
+// emit adds the outline to the current path of |c|.
+func (p *path_t) emit(c vango.Context) {
+	pts := p.pts
+	for _, cmd := range p.cmds {
+		switch cmd {
+		case 'M':
+			c.MoveTo(pts[0], pts[1])
+			pts = pts[2:]
+		case 'L':
+			c.LineTo(pts[0], pts[1])
+			pts = pts[2:]
+		case 'Q':
+			c.QuadTo(pts[0], pts[1], pts[2], pts[3])
+			pts = pts[4:]
+		case 'C':
+			c.CubicTo(pts[0], pts[1], pts[2], pts[3], pts[4], pts[5])
+			pts = pts[6:]
+		case 'Z':
+			c.ClosePath()
+		}
+	}
+}
+
+// bounds returns the box of the points, the control points included, so it
+// may be a bit larger than the outline. It returns false for the empty path.
+func (p *path_t) bounds() (x0, y0, x1, y1 float64, ok bool) {
+	if len(p.pts) < 2 {
+		return 0, 0, 0, 0, false
+	}
+	x0, y0 = p.pts[0], p.pts[1]
+	x1, y1 = x0, y0
+	for i := 2; i+1 < len(p.pts); i += 2 {
+		x0, x1 = math.Min(x0, p.pts[i]), math.Max(x1, p.pts[i])
+		y0, y1 = math.Min(y0, p.pts[i+1]), math.Max(y1, p.pts[i+1])
+	}
+	return x0, y0, x1, y1, true
+}
+
+// add_arc adds the arc of the path data from (x0, y0) to (x, y), as cubics of
+// at most a quarter turn each. See the implementation notes of the svg spec,
+// F.6.5 and F.6.6.
+func (p *path_t) add_arc(x0, y0, rx, ry, rotation float64, large, sweep bool, x, y float64) {
+	if x0 == x && y0 == y {
+		return
+	}
+	rx, ry = math.Abs(rx), math.Abs(ry)
+	if rx == 0 || ry == 0 {
+		p.line_to(x, y)
+		return
+	}
+
+	phi := rotation * math.Pi / 180
+	cos_phi, sin_phi := math.Cos(phi), math.Sin(phi)
+	dx, dy := (x0-x)/2, (y0-y)/2
+	x1p := cos_phi*dx + sin_phi*dy
+	y1p := -sin_phi*dx + cos_phi*dy
+
+	// The radii too small to reach the end are scaled up.
+	if l := x1p*x1p/(rx*rx) + y1p*y1p/(ry*ry); l > 1 {
+		l = math.Sqrt(l)
+		rx, ry = rx*l, ry*l
+	}
+
+	num := rx*rx*ry*ry - rx*rx*y1p*y1p - ry*ry*x1p*x1p
+	den := rx*rx*y1p*y1p + ry*ry*x1p*x1p
+	k := 0.0
+	if num > 0 && den > 0 {
+		k = math.Sqrt(num / den)
+	}
+	if large == sweep {
+		k = -k
+	}
+	cxp, cyp := k*rx*y1p/ry, -k*ry*x1p/rx
+	cx := cos_phi*cxp - sin_phi*cyp + (x0+x)/2
+	cy := sin_phi*cxp + cos_phi*cyp + (y0+y)/2
+
+	start := math.Atan2((y1p-cyp)/ry, (x1p-cxp)/rx)
+	end := math.Atan2((-y1p-cyp)/ry, (-x1p-cxp)/rx)
+	delta := end - start
+	if sweep && delta < 0 {
+		delta += 2 * math.Pi
+	} else if !sweep && delta > 0 {
+		delta -= 2 * math.Pi
+	}
+
+	n := int(math.Ceil(math.Abs(delta)/(math.Pi/2) - 1e-9))
+	if n < 1 {
+		n = 1
+	}
+	step := delta / float64(n)
+	t := 4.0 / 3 * math.Tan(step/4)
+
+	// point maps the point of the unit circle onto the rotated ellipse.
+	point := func(ux, uy float64) (float64, float64) {
+		ex, ey := rx*ux, ry*uy
+		return cx + cos_phi*ex - sin_phi*ey, cy + sin_phi*ex + cos_phi*ey
+	}
+	a0 := start
+	cos0, sin0 := math.Cos(a0), math.Sin(a0)
+	for i := 0; i < n; i++ {
+		a1 := a0 + step
+		cos1, sin1 := math.Cos(a1), math.Sin(a1)
+		c1x, c1y := point(cos0-t*sin0, sin0+t*cos0)
+		c2x, c2y := point(cos1+t*sin1, sin1-t*cos1)
+		ex, ey := point(cos1, sin1)
+		if i == n-1 {
+			// The end is exact.
+			ex, ey = x, y
+		}
+		p.cubic_to(c1x, c1y, c2x, c2y, ex, ey)
+		a0, cos0, sin0 = a1, cos1, sin1
+	}
+}
+
+// add_ellipse adds the closed ellipse as four cubics, clockwise from the
+// right.
+func (p *path_t) add_ellipse(cx, cy, rx, ry float64) {
+	const k = 0.5522847498307936 // 4/3 * (sqrt(2) - 1)
+	kx, ky := rx*k, ry*k
+	p.move_to(cx+rx, cy)
+	p.cubic_to(cx+rx, cy+ky, cx+kx, cy+ry, cx, cy+ry)
+	p.cubic_to(cx-kx, cy+ry, cx-rx, cy+ky, cx-rx, cy)
+	p.cubic_to(cx-rx, cy-ky, cx-kx, cy-ry, cx, cy-ry)
+	p.cubic_to(cx+kx, cy-ry, cx+rx, cy-ky, cx+rx, cy)
+	p.close()
+}
+
+// add_rect adds the rect with the corners of the radii |rx| and |ry|.
+func (p *path_t) add_rect(x, y, w, h, rx, ry float64) {
+	if rx <= 0 || ry <= 0 {
+		p.move_to(x, y)
+		p.line_to(x+w, y)
+		p.line_to(x+w, y+h)
+		p.line_to(x, y+h)
+		p.close()
+		return
+	}
+	const k = 1 - 0.5522847498307936
+	kx, ky := rx*k, ry*k
+	p.move_to(x+rx, y)
+	p.line_to(x+w-rx, y)
+	p.cubic_to(x+w-kx, y, x+w, y+ky, x+w, y+ry)
+	p.line_to(x+w, y+h-ry)
+	p.cubic_to(x+w, y+h-ky, x+w-kx, y+h, x+w-rx, y+h)
+	p.line_to(x+rx, y+h)
+	p.cubic_to(x+kx, y+h, x, y+h-ky, x, y+h-ry)
+	p.line_to(x, y+ry)
+	p.cubic_to(x, y+ky, x+kx, y, x+rx, y)
+	p.close()
+}
+
+// shape_path returns the outline of the path or the basic shape |n|, or nil
+// for the other elements. The shapes without an area are nil too, since
+// they're not drawn.
+func shape_path(n *node_t) *path_t {
+	length := func(name string) float64 {
+		v, _ := parse_length(n.attrs[name], 0)
+		return v
+	}
+
+	p := &path_t{}
+	switch n.name {
+	case "path":
+		parse_path_data(p, n.attrs["d"])
+	case "rect":
+		w, h := length("width"), length("height")
+		if w <= 0 || h <= 0 {
+			return nil
+		}
+		// A missing radius is the other one, and both are at most the half
+		// of the side.
+		rx, has_rx := parse_length(n.attrs["rx"], 0)
+		ry, has_ry := parse_length(n.attrs["ry"], 0)
+		if !has_rx {
+			rx = ry
+		}
+		if !has_ry {
+			ry = rx
+		}
+		p.add_rect(length("x"), length("y"), w, h, math.Min(rx, w/2), math.Min(ry, h/2))
+	case "circle":
+		r := length("r")
+		if r <= 0 {
+			return nil
+		}
+		p.add_ellipse(length("cx"), length("cy"), r, r)
+	case "ellipse":
+		rx, ry := length("rx"), length("ry")
+		if rx <= 0 || ry <= 0 {
+			return nil
+		}
+		p.add_ellipse(length("cx"), length("cy"), rx, ry)
+	case "line":
+		p.move_to(length("x1"), length("y1"))
+		p.line_to(length("x2"), length("y2"))
+	case "polyline", "polygon":
+		pts := parse_numbers(n.attrs["points"])
+		if len(pts) < 4 {
+			return nil
+		}
+		p.move_to(pts[0], pts[1])
+		for i := 2; i+1 < len(pts); i += 2 {
+			p.line_to(pts[i], pts[i+1])
+		}
+		if n.name == "polygon" {
+			p.close()
+		}
+	default:
+		return nil
+	}
+	if len(p.cmds) == 0 {
+		return nil
+	}
+	return p
+}
+
+// parse_path_data adds the path data |d| to |p|. Like the svg spec, the data
+// is drawn up to the first error.
+func parse_path_data(p *path_t, d string) {
+	s := &scanner_t{s: d}
+	var (
+		cmd              byte
+		x, y             float64 // The current point.
+		sx, sy           float64 // The start of the subpath.
+		last_cx, last_cy float64 // The last control point, for S and T.
+		last_cmd         byte
+	)
+	for {
+		s.skip_separators()
+		if s.done() {
+			return
+		}
+		if c := s.s[s.i]; is_path_command(c) {
+			cmd = c
+			s.i++
+		} else if cmd == 0 || cmd == 'Z' || cmd == 'z' {
+			// The numbers need a command before them.
+			return
+		}
+
+		rel := cmd >= 'a'
+		ox, oy := 0.0, 0.0
+		if rel {
+			ox, oy = x, y
+		}
+		upper := cmd &^ 0x20
+
+		// The reflection of the last control point is the current point if
+		// the last command isn't of the same kind.
+		reflect := func(kinds string) (float64, float64) {
+			for i := 0; i < len(kinds); i++ {
+				if last_cmd == kinds[i] {
+					return 2*x - last_cx, 2*y - last_cy
+				}
+			}
+			return x, y
+		}
+
+		var args [7]float64
+		for i := 0; i < g_path_command_args[upper]; i++ {
+			var ok bool
+			if upper == 'A' && (i == 3 || i == 4) {
+				args[i], ok = s.flag()
+			} else {
+				args[i], ok = s.number()
+			}
+			if !ok {
+				return
+			}
+		}
+
+		switch upper {
+		case 'M':
+			x, y = ox+args[0], oy+args[1]
+			sx, sy = x, y
+			p.move_to(x, y)
+			// The pairs after a move are lines.
+			if rel {
+				cmd = 'l'
+			} else {
+				cmd = 'L'
+			}
+		case 'L':
+			x, y = ox+args[0], oy+args[1]
+			p.line_to(x, y)
+		case 'H':
+			x = ox + args[0]
+			p.line_to(x, y)
+		case 'V':
+			y = oy + args[0]
+			p.line_to(x, y)
+		case 'C':
+			last_cx, last_cy = ox+args[2], oy+args[3]
+			x, y = ox+args[4], oy+args[5]
+			p.cubic_to(ox+args[0], oy+args[1], last_cx, last_cy, x, y)
+		case 'S':
+			c1x, c1y := reflect("CS")
+			last_cx, last_cy = ox+args[0], oy+args[1]
+			x, y = ox+args[2], oy+args[3]
+			p.cubic_to(c1x, c1y, last_cx, last_cy, x, y)
+		case 'Q':
+			last_cx, last_cy = ox+args[0], oy+args[1]
+			x, y = ox+args[2], oy+args[3]
+			p.quad_to(last_cx, last_cy, x, y)
+		case 'T':
+			last_cx, last_cy = reflect("QT")
+			x, y = ox+args[0], oy+args[1]
+			p.quad_to(last_cx, last_cy, x, y)
+		case 'A':
+			ex, ey := ox+args[5], oy+args[6]
+			p.add_arc(x, y, args[0], args[1], args[2], args[3] != 0, args[4] != 0, ex, ey)
+			x, y = ex, ey
+		case 'Z':
+			p.close()
+			x, y = sx, sy
+		}
+		last_cmd = upper
+	}
+}
+
+func is_path_command(c byte) bool {
+	switch c &^ 0x20 {
+	case 'M', 'L', 'H', 'V', 'C', 'S', 'Q', 'T', 'A', 'Z':
+		return true
+	}
+	return false
+}
@@ -0,0 +1,484 @@
+// Copyright 2014 By Jshi. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package svg
+
+import (
+	"gwk/vango"
+	"math"
+	"strconv"
+	"strings"
+)
+
+type paint_kind_t int
+
+const (
+	kPaintNone paint_kind_t = iota
+	kPaintColor
+	kPaintURL
+)
+
+// paint_t is the value of the fill or the stroke.
+type paint_t struct {
+	kind  paint_kind_t
+	color vango.SolidPaint
+	url   string
+	// The color after the url, used if the url isn't found.
+	has_fallback bool
+}
+
+// style_t is the inherited properties of an element.
+type style_t struct {
+	fill, stroke   paint_t
+	fill_opacity   float64
+	stroke_opacity float64
+	fill_rule      vango.FillRule
+	stroke_width   float64
+	line_cap       vango.LineCap
+	line_join      vango.LineJoin
+	miter_limit    float64
+	dashes         []float64
+	dash_offset    float64
+	color          vango.SolidPaint // The currentColor.
+	visible        bool
+
+	// The opacity and the display aren't inherited, they're reset by
+	// inherit.
+	opacity      float64
+	display_none bool
+}
+
+// default_style returns the initial values of the svg spec.
+func default_style() style_t {
+	black := vango.NewSolidPaint(0, 0, 0, 0xff)
+	return style_t{
+		fill:           paint_t{kind: kPaintColor, color: black},
+		fill_opacity:   1,
+		stroke_opacity: 1,
+		stroke_width:   1,
+		miter_limit:    4,
+		color:          black,
+		visible:        true,
+		opacity:        1,
+	}
+}
+
+// inherit returns the style of the element of |attrs| in the element of
+// |s|. The invalid values are ignored.
+func (s style_t) inherit(attrs map[string]string) style_t {
+	s.opacity = 1
+	s.display_none = false
+
+	// The color goes first, since the paints may refer to it.
+	if v, ok := attrs["color"]; ok {
+		if clr, ok := parse_color(v); ok {
+			s.color = clr
+		}
+	}
+	for key, v := range attrs {
+		v = strings.TrimSpace(v)
+		if v == "inherit" {
+			continue
+		}
+		switch key {
+		case "fill":
+			if paint, ok := parse_paint(v, s.color); ok {
+				s.fill = paint
+			}
+		case "stroke":
+			if paint, ok := parse_paint(v, s.color); ok {
+				s.stroke = paint
+			}
+		case "fill-opacity":
+			s.fill_opacity = parse_opacity(v, s.fill_opacity)
+		case "stroke-opacity":
+			s.stroke_opacity = parse_opacity(v, s.stroke_opacity)
+		case "opacity":
+			s.opacity = parse_opacity(v, 1)
+		case "fill-rule":
+			switch v {
+			case "nonzero":
+				s.fill_rule = vango.FillRuleNonZero
+			case "evenodd":
+				s.fill_rule = vango.FillRuleEvenOdd
+			}
+		case "stroke-width":
+			if width, ok := parse_length(v, 0); ok && width >= 0 {
+				s.stroke_width = width
+			}
+		case "stroke-linecap":
+			switch v {
+			case "butt":
+				s.line_cap = vango.LineCapButt
+			case "round":
+				s.line_cap = vango.LineCapRound
+			case "square":
+				s.line_cap = vango.LineCapSquare
+			}
+		case "stroke-linejoin":
+			switch v {
+			case "miter":
+				s.line_join = vango.LineJoinMiter
+			case "round":
+				s.line_join = vango.LineJoinRound
+			case "bevel":
+				s.line_join = vango.LineJoinBevel
+			}
+		case "stroke-miterlimit":
+			if limit, err := strconv.ParseFloat(v, 64); err == nil && limit >= 1 {
+				s.miter_limit = limit
+			}
+		case "stroke-dasharray":
+			s.dashes = parse_dashes(v)
+		case "stroke-dashoffset":
+			if offset, ok := parse_length(v, 0); ok {
+				s.dash_offset = offset
+			}
+		case "display":
+			s.display_none = v == "none"
+		case "visibility":
+			s.visible = v == "visible"
+		}
+	}
+	return s
+}
+
+// parse_paint parses the fill or the stroke, like "none", "#f00", or
+// "url(#id) red".
+func parse_paint(v string, current vango.SolidPaint) (paint_t, bool) {
+	switch {
+	case v == "none":
+		return paint_t{kind: kPaintNone}, true
+	case v == "currentColor":
+		return paint_t{kind: kPaintColor, color: current}, true
+	case strings.HasPrefix(v, "url("):
+		end := strings.Index(v, ")")
+		if end < 0 {
+			return paint_t{}, false
+		}
+		paint := paint_t{kind: kPaintURL}
+		paint.url = strings.Trim(strings.TrimSpace(v[4:end]), "'\"")
+		paint.url = strings.TrimPrefix(paint.url, "#")
+		if fallback := strings.TrimSpace(v[end+1:]); fallback != "" {
+			if fallback == "currentColor" {
+				paint.color, paint.has_fallback = current, true
+			} else {
+				paint.color, paint.has_fallback = parse_color(fallback)
+			}
+		}
+		return paint, true
+	}
+	clr, ok := parse_color(v)
+	return paint_t{kind: kPaintColor, color: clr}, ok
+}
+
+// parse_opacity parses the opacity clamped in [0, 1], or returns |def|.
+func parse_opacity(v string, def float64) float64 {
+	f, err := strconv.ParseFloat(strings.TrimSuffix(v, "%"), 64)
+	if err != nil {
+		return def
+	}
+	if strings.HasSuffix(v, "%") {
+		f /= 100
+	}
+	return math.Max(0, math.Min(1, f))
+}
+
+// parse_dashes parses the dash array, the odd count is repeated twice as the
+// svg spec says. It returns nil for "none" and the invalid arrays.
+func parse_dashes(v string) []float64 {
+	if v == "none" {
+		return nil
+	}
+	var dashes []float64
+	sum := 0.0
+	for _, field := range strings.FieldsFunc(v, is_separator) {
+		l, ok := parse_length(field, 0)
+		if !ok || l < 0 {
+			return nil
+		}
+		dashes = append(dashes, l)
+		sum += l
+	}
+	if sum == 0 {
+		return nil
+	}
+	if len(dashes)%2 == 1 {
+		dashes = append(dashes, dashes...)
+	}
+	return dashes
+}
+
+// The pixels of the absolute units, at 96 dpi.
+var g_unit_pixels = map[string]float64{
+	"px": 1,
+	"pt": 96.0 / 72,
+	"pc": 16,
+	"mm": 96 / 25.4,
+	"cm": 96 / 2.54,
+	"in": 96,
+	"em": 16,
+	"ex": 8,
+}
+
+// parse_length parses the length with an optional unit. The percentage is
+// of |percent_of|. It returns false for the missing or invalid lengths.
+func parse_length(v string, percent_of float64) (float64, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, false
+	}
+	scale, div := 1.0, 1.0
+	if strings.HasSuffix(v, "%") {
+		scale, div, v = percent_of, 100, v[:len(v)-1]
+	} else if len(v) > 2 {
+		if k, ok := g_unit_pixels[v[len(v)-2:]]; ok {
+			scale, v = k, v[:len(v)-2]
+		}
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, false
+	}
+	return f * scale / div, true
+}
+
+func is_separator(c rune) bool {
+	return c == ',' || c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// scanner_t reads the numbers of the path data, the points and the
+// transforms, where the separators are optional between the numbers like
+// "1-2.5.5".
+type scanner_t struct {
+	s string
+	i int
+}
+
+func (s *scanner_t) done() bool {
+	return s.i >= len(s.s)
+}
+
+func (s *scanner_t) skip_separators() {
+	for !s.done() && is_separator(rune(s.s[s.i])) {
+		s.i++
+	}
+}
+
+// number reads the next number after the separators.
+func (s *scanner_t) number() (float64, bool) {
+	s.skip_separators()
+	start := s.i
+	if !s.done() && (s.s[s.i] == '+' || s.s[s.i] == '-') {
+		s.i++
+	}
+	digits, dot := 0, false
+	for !s.done() {
+		c := s.s[s.i]
+		if c >= '0' && c <= '9' {
+			digits++
+		} else if c == '.' && !dot {
+			dot = true
+		} else {
+			break
+		}
+		s.i++
+	}
+	if digits == 0 {
+		s.i = start
+		return 0, false
+	}
+	// The exponent, but not the "e" of an unit like "em".
+	if !s.done() && (s.s[s.i] == 'e' || s.s[s.i] == 'E') {
+		j := s.i + 1
+		if j < len(s.s) && (s.s[j] == '+' || s.s[j] == '-') {
+			j++
+		}
+		if j < len(s.s) && s.s[j] >= '0' && s.s[j] <= '9' {
+			for j < len(s.s) && s.s[j] >= '0' && s.s[j] <= '9' {
+				j++
+			}
+			s.i = j
+		}
+	}
+	f, err := strconv.ParseFloat(s.s[start:s.i], 64)
+	if err != nil {
+		s.i = start
+		return 0, false
+	}
+	return f, true
+}
+
+// flag reads the 0 or 1 of the arc flags, which may have no separator after.
+func (s *scanner_t) flag() (float64, bool) {
+	s.skip_separators()
+	if s.done() || (s.s[s.i] != '0' && s.s[s.i] != '1') {
+		return 0, false
+	}
+	s.i++
+	return float64(s.s[s.i-1] - '0'), true
+}
+
+// parse_numbers parses the list of the numbers, up to the first error.
+func parse_numbers(v string) []float64 {
+	s := &scanner_t{s: v}
+	var numbers []float64
+	for {
+		f, ok := s.number()
+		if !ok {
+			return numbers
+		}
+		numbers = append(numbers, f)
+	}
+}
+
+// parse_transform parses the transform list, like "translate(10) rotate(45
+// 5 5)". It returns false if the list is missing or invalid.
+func parse_transform(v string) (vango.Matrix, bool) {
+	m := vango.IdentityMatrix()
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return m, false
+	}
+	for v != "" {
+		open := strings.Index(v, "(")
+		end := strings.Index(v, ")")
+		if open < 0 || end < open {
+			return m, false
+		}
+		name := strings.Trim(v[:open], " \t\r\n,")
+		args := parse_numbers(v[open+1 : end])
+		v = strings.TrimLeft(v[end+1:], " \t\r\n,")
+
+		var t vango.Matrix
+		switch {
+		case name == "matrix" && len(args) == 6:
+			t = vango.Matrix{A: args[0], B: args[1], C: args[2], D: args[3], E: args[4], F: args[5]}
+		case name == "translate" && len(args) == 1:
+			t = vango.TranslateMatrix(args[0], 0)
+		case name == "translate" && len(args) == 2:
+			t = vango.TranslateMatrix(args[0], args[1])
+		case name == "scale" && len(args) == 1:
+			t = vango.ScaleMatrix(args[0], args[0])
+		case name == "scale" && len(args) == 2:
+			t = vango.ScaleMatrix(args[0], args[1])
+		case name == "rotate" && len(args) == 1:
+			t = vango.RotateMatrix(args[0] * math.Pi / 180)
+		case name == "rotate" && len(args) == 3:
+			t = vango.TranslateMatrix(args[1], args[2]).
+				Multiply(vango.RotateMatrix(args[0] * math.Pi / 180)).
+				Multiply(vango.TranslateMatrix(-args[1], -args[2]))
+		case name == "skewX" && len(args) == 1:
+			t = vango.Matrix{A: 1, C: math.Tan(args[0] * math.Pi / 180), D: 1}
+		case name == "skewY" && len(args) == 1:
+			t = vango.Matrix{A: 1, B: math.Tan(args[0] * math.Pi / 180), D: 1}
+		default:
+			return vango.IdentityMatrix(), false
+		}
+		m = m.Multiply(t)
+	}
+	return m, true
+}
+
+// The named colors of css, the basic ones and the common extended ones.
+var g_named_colors = map[string][3]byte{
+	"black":       {0x00, 0x00, 0x00},
+	"silver":      {0xc0, 0xc0, 0xc0},
+	"gray":        {0x80, 0x80, 0x80},
+	"grey":        {0x80, 0x80, 0x80},
+	"white":       {0xff, 0xff, 0xff},
+	"maroon":      {0x80, 0x00, 0x00},
+	"red":         {0xff, 0x00, 0x00},
+	"purple":      {0x80, 0x00, 0x80},
+	"fuchsia":     {0xff, 0x00, 0xff},
+	"magenta":     {0xff, 0x00, 0xff},
+	"green":       {0x00, 0x80, 0x00},
+	"lime":        {0x00, 0xff, 0x00},
+	"olive":       {0x80, 0x80, 0x00},
+	"yellow":      {0xff, 0xff, 0x00},
+	"navy":        {0x00, 0x00, 0x80},
+	"blue":        {0x00, 0x00, 0xff},
+	"teal":        {0x00, 0x80, 0x80},
+	"aqua":        {0x00, 0xff, 0xff},
+	"cyan":        {0x00, 0xff, 0xff},
+	"orange":      {0xff, 0xa5, 0x00},
+	"brown":       {0xa5, 0x2a, 0x2a},
+	"pink":        {0xff, 0xc0, 0xcb},
+	"gold":        {0xff, 0xd7, 0x00},
+	"darkgray":    {0xa9, 0xa9, 0xa9},
+	"darkgrey":    {0xa9, 0xa9, 0xa9},
+	"lightgray":   {0xd3, 0xd3, 0xd3},
+	"lightgrey":   {0xd3, 0xd3, 0xd3},
+	"dimgray":     {0x69, 0x69, 0x69},
+	"dimgrey":     {0x69, 0x69, 0x69},
+	"darkblue":    {0x00, 0x00, 0x8b},
+	"darkgreen":   {0x00, 0x64, 0x00},
+	"darkred":     {0x8b, 0x00, 0x00},
+	"lightblue":   {0xad, 0xd8, 0xe6},
+	"lightgreen":  {0x90, 0xee, 0x90},
+	"skyblue":     {0x87, 0xce, 0xeb},
+	"steelblue":   {0x46, 0x82, 0xb4},
+	"royalblue":   {0x41, 0x69, 0xe1},
+	"dodgerblue":  {0x1e, 0x90, 0xff},
+	"orangered":   {0xff, 0x45, 0x00},
+	"tomato":      {0xff, 0x63, 0x47},
+	"crimson":     {0xdc, 0x14, 0x3c},
+	"indigo":      {0x4b, 0x00, 0x82},
+	"violet":      {0xee, 0x82, 0xee},
+	"whitesmoke":  {0xf5, 0xf5, 0xf5},
+	"gainsboro":   {0xdc, 0xdc, 0xdc},
+	"slategray":   {0x70, 0x80, 0x90},
+	"slategrey":   {0x70, 0x80, 0x90},
+	"forestgreen": {0x22, 0x8b, 0x22},
+}
+
+// parse_color parses "#rgb", "#rrggbb", "rgb(r, g, b)" of the numbers or
+// the percentages, "rgba(r, g, b, a)", "transparent" and the named colors.
+func parse_color(v string) (vango.SolidPaint, bool) {
+	v = strings.ToLower(strings.TrimSpace(v))
+	if strings.HasPrefix(v, "#") {
+		hex := v[1:]
+		if len(hex) == 3 {
+			hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+		}
+		n, err := strconv.ParseUint(hex, 16, 32)
+		if len(hex) != 6 || err != nil {
+			return vango.SolidPaint{}, false
+		}
+		return vango.NewSolidPaint(byte(n>>16), byte(n>>8), byte(n), 0xff), true
+	}
+
+	if strings.HasPrefix(v, "rgb") && strings.HasSuffix(v, ")") {
+		open := strings.Index(v, "(")
+		if open < 0 {
+			return vango.SolidPaint{}, false
+		}
+		fields := strings.FieldsFunc(v[open+1:len(v)-1], is_separator)
+		if len(fields) != 3 && len(fields) != 4 {
+			return vango.SolidPaint{}, false
+		}
+		var c [4]byte
+		c[3] = 0xff
+		for i, field := range fields {
+			if i == 3 {
+				c[3] = byte(parse_opacity(field, 1)*0xff + 0.5)
+				break
+			}
+			f, ok := parse_length(field, 255)
+			if !ok {
+				return vango.SolidPaint{}, false
+			}
+			c[i] = byte(math.Max(0, math.Min(255, f)) + 0.5)
+		}
+		return vango.NewSolidPaint(c[0], c[1], c[2], c[3]), true
+	}
+
+	if v == "transparent" {
+		return vango.NewSolidPaint(0, 0, 0, 0), true
+	}
+	if c, ok := g_named_colors[v]; ok {
+		return vango.NewSolidPaint(c[0], c[1], c[2], 0xff), true
+	}
+	return vango.SolidPaint{}, false
+}
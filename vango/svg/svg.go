@@ -0,0 +1,363 @@
+// Copyright 2014 By Jshi. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package svg draws the svg documents through the vector api of vango. It
+// supports the paths, the basic shapes, the groups, the transforms, the fills
+// and the strokes, the opacities, the linear and the radial gradients, and
+// the viewBox. The text, the filters, the masks and the scripts are ignored.
+package svg
+
+import (
+	"encoding/xml"
+	"errors"
+	"gwk/vango"
+	"image"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+// node_t is an element of the document. The presentation attributes and the
+// properties of the style attribute are merged in |attrs|, the style ones
+// win.
+type node_t struct {
+	name     string
+	attrs    map[string]string
+	children []*node_t
+
+	// The outline of the shapes and the paths, in the user space of the
+	// element, nil for the others.
+	path *path_t
+}
+
+// Document is a parsed svg document, which can be drawn at any size.
+type Document struct {
+	root *node_t
+	// The elements with an id, for the gradients.
+	ids map[string]*node_t
+
+	view_box [4]float64 // x, y, width, height
+	width    float64
+	height   float64
+	align    string // The align of preserveAspectRatio, like "xMidYMid".
+	slice    bool   // The meet or slice of preserveAspectRatio.
+}
+
+// Parse reads the svg document from |r|.
+func Parse(r io.Reader) (*Document, error) {
+	d := xml.NewDecoder(r)
+	d.Strict = false
+
+	doc := &Document{ids: make(map[string]*node_t)}
+	var stack []*node_t
+	for {
+		t, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch token := t.(type) {
+		case xml.StartElement:
+			n := &node_t{name: token.Name.Local, attrs: make(map[string]string)}
+			for _, attr := range token.Attr {
+				// xlink:href and href are the same.
+				n.attrs[attr.Name.Local] = attr.Value
+			}
+			for _, prop := range strings.Split(n.attrs["style"], ";") {
+				if i := strings.Index(prop, ":"); i > 0 {
+					key := strings.TrimSpace(prop[:i])
+					n.attrs[key] = strings.TrimSpace(prop[i+1:])
+				}
+			}
+			n.path = shape_path(n)
+			if id := n.attrs["id"]; id != "" {
+				doc.ids[id] = n
+			}
+
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, n)
+			} else if doc.root == nil {
+				doc.root = n
+			}
+			stack = append(stack, n)
+		case xml.EndElement:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+
+	if doc.root == nil || doc.root.name != "svg" {
+		return nil, errors.New("svg no svg element")
+	}
+	doc.init_viewport()
+	return doc, nil
+}
+
+// ParseFile reads the svg document in the file.
+func ParseFile(filename string) (*Document, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// init_viewport reads the size and the viewBox of the root element. The size
+// is the one of the viewBox if it's missing or relative, and the other way
+// around, or 100x100 if both are missing.
+func (doc *Document) init_viewport() {
+	attrs := doc.root.attrs
+	box := parse_numbers(attrs["viewBox"])
+	has_box := len(box) == 4 && box[2] > 0 && box[3] > 0
+	if has_box {
+		copy(doc.view_box[:], box)
+	}
+
+	doc.width, _ = parse_length(attrs["width"], 0)
+	doc.height, _ = parse_length(attrs["height"], 0)
+	if strings.HasSuffix(attrs["width"], "%") {
+		doc.width = 0
+	}
+	if strings.HasSuffix(attrs["height"], "%") {
+		doc.height = 0
+	}
+	switch {
+	case doc.width <= 0 && doc.height <= 0 && has_box:
+		doc.width, doc.height = box[2], box[3]
+	case doc.width <= 0 && has_box:
+		doc.width = doc.height * box[2] / box[3]
+	case doc.height <= 0 && has_box:
+		doc.height = doc.width * box[3] / box[2]
+	}
+	if doc.width <= 0 {
+		doc.width = 100
+	}
+	if doc.height <= 0 {
+		doc.height = 100
+	}
+	if !has_box {
+		doc.view_box = [4]float64{0, 0, doc.width, doc.height}
+	}
+
+	doc.align = "xMidYMid"
+	fields := strings.Fields(attrs["preserveAspectRatio"])
+	if len(fields) > 0 {
+		doc.align = fields[0]
+	}
+	doc.slice = len(fields) > 1 && fields[1] == "slice"
+}
+
+// Size returns the size of the document in pixels, from its width and
+// height, or its viewBox.
+func (doc *Document) Size() (w, h float64) {
+	return doc.width, doc.height
+}
+
+// ViewBox returns the rect of the user space shown in the viewport.
+func (doc *Document) ViewBox() (x, y, w, h float64) {
+	return doc.view_box[0], doc.view_box[1], doc.view_box[2], doc.view_box[3]
+}
+
+// view_box_matrix returns the matrix mapping the viewBox into |rect| by the
+// preserveAspectRatio.
+func (doc *Document) view_box_matrix(rect image.Rectangle) vango.Matrix {
+	vx, vy, vw, vh := doc.ViewBox()
+	sx, sy := float64(rect.Dx())/vw, float64(rect.Dy())/vh
+	tx, ty := float64(rect.Min.X), float64(rect.Min.Y)
+	if doc.align != "none" {
+		if doc.slice {
+			sx = math.Max(sx, sy)
+		} else {
+			sx = math.Min(sx, sy)
+		}
+		sy = sx
+		// The free space goes to the left and the top by the align.
+		free_x, free_y := float64(rect.Dx())-vw*sx, float64(rect.Dy())-vh*sy
+		switch {
+		case strings.HasPrefix(doc.align, "xMid"):
+			tx += free_x / 2
+		case strings.HasPrefix(doc.align, "xMax"):
+			tx += free_x
+		}
+		switch {
+		case strings.HasSuffix(doc.align, "YMid"):
+			ty += free_y / 2
+		case strings.HasSuffix(doc.align, "YMax"):
+			ty += free_y
+		}
+	}
+	return vango.TranslateMatrix(tx, ty).Multiply(vango.ScaleMatrix(sx, sy)).
+		Multiply(vango.TranslateMatrix(-vx, -vy))
+}
+
+// Draw draws the document fit into |rect| in the user space of |c|, by the
+// preserveAspectRatio of the document. The state of |c| is kept.
+func (doc *Document) Draw(c vango.Context, rect image.Rectangle) {
+	if rect.Empty() {
+		return
+	}
+	c.SaveState()
+	defer c.RestoreState()
+
+	c.ClipRect(rect)
+	c.Transform(doc.view_box_matrix(rect))
+	doc.draw_children(c, doc.root, default_style())
+}
+
+// Rasterize returns the document drawn into a new canvas of |w|x|h|.
+func (doc *Document) Rasterize(w, h int) *vango.Canvas {
+	canvas := vango.NewCanvas(w, h)
+	c := vango.NewContext()
+	c.SetCanvas(canvas)
+	doc.Draw(c, image.Rect(0, 0, w, h))
+	return canvas
+}
+
+func (doc *Document) draw_children(c vango.Context, n *node_t, style style_t) {
+	for _, child := range n.children {
+		doc.draw_node(c, child, style)
+	}
+}
+
+func (doc *Document) draw_node(c vango.Context, n *node_t, parent style_t) {
+	switch n.name {
+	case "g", "svg", "a", "switch":
+	case "path", "rect", "circle", "ellipse", "line", "polyline", "polygon":
+	default:
+		// The defs, the gradients and the unsupported elements aren't drawn.
+		return
+	}
+
+	style := parent.inherit(n.attrs)
+	if style.display_none {
+		return
+	}
+
+	c.SaveState()
+	defer c.RestoreState()
+
+	if m, ok := parse_transform(n.attrs["transform"]); ok {
+		c.Transform(m)
+	}
+	// The opacity of a group is applied to every element in it, not to the
+	// group drawn as a whole, so the overlapped elements show through.
+	if style.opacity < 1 {
+		c.SetGlobalAlpha(c.GlobalAlpha() * style.opacity)
+	}
+
+	if n.path == nil {
+		if n.name == "svg" {
+			// The nested svg is a new viewport at (x, y).
+			x, _ := parse_length(n.attrs["x"], 0)
+			y, _ := parse_length(n.attrs["y"], 0)
+			c.Translate(x, y)
+		}
+		doc.draw_children(c, n, style)
+		return
+	}
+
+	if style.visible {
+		doc.fill_path(c, n.path, style)
+		doc.stroke_path(c, n.path, style)
+	}
+}
+
+func (doc *Document) fill_path(c vango.Context, path *path_t, style style_t) {
+	if style.fill.kind == kPaintNone {
+		return
+	}
+	c.SaveState()
+	defer c.RestoreState()
+
+	c.BeginPath()
+	path.emit(c)
+	c.SetFillRule(style.fill_rule)
+	if !doc.set_paint(c, style.fill, style.fill_opacity, path, c.SetFillPaint) {
+		return
+	}
+	c.Fill()
+}
+
+func (doc *Document) stroke_path(c vango.Context, path *path_t, style style_t) {
+	if style.stroke.kind == kPaintNone || style.stroke_width <= 0 {
+		return
+	}
+	c.SaveState()
+	defer c.RestoreState()
+
+	c.BeginPath()
+	path.emit(c)
+	width, dashes, offset := style.stroke_width, style.dashes, style.dash_offset
+
+	// The gradient of the bounding box changes the transform, the stroke is
+	// scaled back.
+	before := c.CurrentTransform()
+	if !doc.set_paint(c, style.stroke, style.stroke_opacity, path, c.SetStrokePaint) {
+		return
+	}
+	if after := c.CurrentTransform(); after != before {
+		inv, _ := before.Invert()
+		k := math.Sqrt(math.Abs(inv.Multiply(after).Determinant()))
+		if k > 0 {
+			width, offset = width/k, offset/k
+			scaled := make([]float64, len(dashes))
+			for i, l := range dashes {
+				scaled[i] = l / k
+			}
+			dashes = scaled
+		}
+	}
+
+	c.SetLineWidth(width)
+	c.SetLineCap(style.line_cap)
+	c.SetLineJoin(style.line_join)
+	c.SetMiterLimit(style.miter_limit)
+	c.SetLineDash(dashes)
+	c.SetLineDashOffset(offset)
+	c.Stroke()
+}
+
+// set_paint sets the paint by |set|. A gradient in the units of the bounding
+// box of |path| changes the transform to the box, after the path is built.
+// It returns false if nothing should be drawn.
+func (doc *Document) set_paint(c vango.Context, paint paint_t, opacity float64, path *path_t,
+	set func(vango.Paint)) bool {
+	switch paint.kind {
+	case kPaintColor:
+		clr := paint.color
+		clr.A = byte(float64(clr.A)*opacity + 0.5)
+		set(clr)
+		return true
+	case kPaintURL:
+		gr := doc.gradient(paint.url)
+		if gr == nil {
+			if paint.has_fallback {
+				clr := paint.color
+				clr.A = byte(float64(clr.A)*opacity + 0.5)
+				set(clr)
+				return true
+			}
+			return false
+		}
+		if !gr.user_space {
+			x0, y0, x1, y1, ok := path.bounds()
+			if !ok || x1 <= x0 || y1 <= y0 {
+				// The box without an area can't map the gradient.
+				return false
+			}
+			c.Transform(vango.Matrix{A: x1 - x0, D: y1 - y0, E: x0, F: y0})
+		}
+		c.Transform(gr.transform)
+		set(gr.paint(opacity))
+		return true
+	}
+	return false
+}
@@ -0,0 +1,231 @@
+package svg
+
+import (
+	"gwk/vango"
+	"gwk/vango/vangotest"
+	"math"
+	"strings"
+	"testing"
+)
+
+// pixel_at returns the premultiplied r, g, b, a of the BGRA8 canvas.
+func pixel_at(c *vango.Canvas, x, y int) (r, g, b, a byte) {
+	p := c.Pix()[c.PixOffset(x, y):]
+	return p[2], p[1], p[0], p[3]
+}
+
+func must_parse(t *testing.T, text string) *Document {
+	doc, err := Parse(strings.NewReader(text))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	return doc
+}
+
+func near_points(got, want []float64) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if math.Abs(got[i]-want[i]) > 1e-6 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestParsePathData(t *testing.T) {
+	for _, tt := range []struct {
+		d    string
+		cmds string
+		pts  []float64
+	}{
+		{"M10 20 l5-5h3v2 z m1 1", "MLLLZM", []float64{10, 20, 15, 15, 18, 15, 18, 17, 11, 21}},
+		{"M0,0 10,0 10,10", "MLL", []float64{0, 0, 10, 0, 10, 10}},
+		{"M0 0C1 2 3 4 5 6s1 1 2 2", "MCC", []float64{0, 0, 1, 2, 3, 4, 5, 6, 7, 8, 6, 7, 7, 8}},
+		{"M0 0Q5 5 10 0T20 0", "MQQ", []float64{0, 0, 5, 5, 10, 0, 15, -5, 20, 0}},
+		{"M.5.5-1e1-1", "ML", []float64{0.5, 0.5, -10, -1}},
+		// The arc of a half circle is two quarters, the flags need no
+		// separators.
+		{"M0 0a5 5 0 1010 0", "MCC", nil},
+		// Drawn up to the error.
+		{"M0 0L10 0L", "ML", []float64{0, 0, 10, 0}},
+		{"10 10", "", nil},
+	} {
+		p := &path_t{}
+		parse_path_data(p, tt.d)
+		if string(p.cmds) != tt.cmds {
+			t.Errorf("%q: got commands %q, want %q", tt.d, p.cmds, tt.cmds)
+			continue
+		}
+		if tt.pts != nil && !near_points(p.pts, tt.pts) {
+			t.Errorf("%q: got points %v, want %v", tt.d, p.pts, tt.pts)
+		}
+	}
+
+	p := &path_t{}
+	parse_path_data(p, "M0 0A5 5 0 0 0 10 0")
+	x0, y0, x1, y1, _ := p.bounds()
+	if !near_points([]float64{x0, x1, p.pts[len(p.pts)-2], p.pts[len(p.pts)-1]}, []float64{0, 10, 10, 0}) ||
+		y0 < -1e-6 || math.Abs(y1-5) > 1e-6 {
+		t.Errorf("arc bounds: got (%v, %v)-(%v, %v)", x0, y0, x1, y1)
+	}
+}
+
+func TestParseTransform(t *testing.T) {
+	for _, tt := range []struct {
+		v    string
+		x, y float64
+	}{
+		{"translate(10)", 11, 1},
+		{"translate(10, 20) scale(2)", 12, 22},
+		{"rotate(90)", -1, 1},
+		{"rotate(90 5 5)", 9, 1},
+		{"matrix(1 0 0 1 3 4)", 4, 5},
+		{"skewX(45)", 2, 1},
+	} {
+		m, ok := parse_transform(tt.v)
+		if !ok {
+			t.Errorf("%q: not parsed", tt.v)
+			continue
+		}
+		if x, y := m.TransformPoint(1, 1); math.Abs(x-tt.x) > 1e-9 || math.Abs(y-tt.y) > 1e-9 {
+			t.Errorf("%q: (1, 1) to (%v, %v), want (%v, %v)", tt.v, x, y, tt.x, tt.y)
+		}
+	}
+	for _, v := range []string{"", "scale()", "translate(1", "spin(3)"} {
+		if _, ok := parse_transform(v); ok {
+			t.Errorf("%q: parsed", v)
+		}
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	for _, tt := range []struct {
+		v    string
+		want vango.SolidPaint
+	}{
+		{"#f80", vango.NewSolidPaint(0xff, 0x88, 0, 0xff)},
+		{"#1020A0", vango.NewSolidPaint(0x10, 0x20, 0xa0, 0xff)},
+		{"rgb(1, 2, 3)", vango.NewSolidPaint(1, 2, 3, 0xff)},
+		{"rgb(100%,0%,50%)", vango.NewSolidPaint(0xff, 0, 0x80, 0xff)},
+		{"rgba(0,0,0,0.5)", vango.NewSolidPaint(0, 0, 0, 0x80)},
+		{"Orange", vango.NewSolidPaint(0xff, 0xa5, 0, 0xff)},
+	} {
+		if got, ok := parse_color(tt.v); !ok || got != tt.want {
+			t.Errorf("%q: got %v, want %v", tt.v, got, tt.want)
+		}
+	}
+	for _, v := range []string{"#ff", "rgb(1,2)", "nocolor"} {
+		if _, ok := parse_color(v); ok {
+			t.Errorf("%q: parsed", v)
+		}
+	}
+}
+
+func TestDocumentSize(t *testing.T) {
+	for _, tt := range []struct {
+		svg  string
+		w, h float64
+	}{
+		{`<svg width="32" height="16"/>`, 32, 16},
+		{`<svg viewBox="0 0 48 24"/>`, 48, 24},
+		{`<svg width="10mm" viewBox="0 0 2 1"/>`, 96 / 2.54, 96 / 5.08},
+		{`<svg width="100%" height="100%" viewBox="0 0 8 4"/>`, 8, 4},
+		{`<svg/>`, 100, 100},
+	} {
+		w, h := must_parse(t, tt.svg).Size()
+		if math.Abs(w-tt.w) > 1e-9 || math.Abs(h-tt.h) > 1e-9 {
+			t.Errorf("%v: got %vx%v, want %vx%v", tt.svg, w, h, tt.w, tt.h)
+		}
+	}
+	if _, err := Parse(strings.NewReader(`<html/>`)); err == nil {
+		t.Errorf("Parse of html: got no error")
+	}
+}
+
+func TestDraw(t *testing.T) {
+	doc := must_parse(t, `<svg viewBox="0 0 10 10">
+		<rect width="10" height="5" fill="#f00"/>
+		<g transform="translate(0 5)" opacity="0.5" fill="blue">
+			<rect width="5" height="5"/>
+			<rect x="5" width="5" height="5" style="display:none"/>
+		</g>
+		<circle cx="7.5" cy="7.5" r="2" fill="none" stroke="lime"/>
+	</svg>`)
+
+	// The viewBox is scaled to the canvas and centered.
+	canvas := doc.Rasterize(40, 20)
+	if r, g, b, a := pixel_at(canvas, 11, 2); r != 0xff || g != 0 || b != 0 || a != 0xff {
+		t.Errorf("the red rect: got %x %x %x %x", r, g, b, a)
+	}
+	if _, _, _, a := pixel_at(canvas, 9, 2); a != 0 {
+		t.Errorf("outside the viewBox: got alpha %x", a)
+	}
+	if r, _, b, a := pixel_at(canvas, 12, 15); r != 0 || b != 0x80 || a != 0x80 {
+		t.Errorf("the half opaque blue rect: got r %x b %x a %x", r, b, a)
+	}
+	if _, _, _, a := pixel_at(canvas, 29, 19); a != 0 {
+		t.Errorf("the hidden rect: got alpha %x", a)
+	}
+	if _, g, _, a := pixel_at(canvas, 25, 15); g != 0 || a != 0 {
+		t.Errorf("the inside of the stroked circle: got g %x a %x", g, a)
+	}
+	if _, g, _, _ := pixel_at(canvas, 25, 10); g < 0xc0 {
+		t.Errorf("the stroke of the circle: got g %x", g)
+	}
+}
+
+func TestDrawGradients(t *testing.T) {
+	doc := must_parse(t, `<svg width="40" height="20">
+		<defs>
+			<linearGradient id="a">
+				<stop offset="0" stop-color="red"/>
+				<stop offset="1" stop-color="blue"/>
+			</linearGradient>
+			<linearGradient id="b" href="#a" x2="0" y2="1"/>
+			<radialGradient id="c" gradientUnits="userSpaceOnUse" cx="30" cy="10" r="10">
+				<stop offset="0.5" stop-color="#fff"/>
+				<stop offset="0.5" stop-color="#000"/>
+			</radialGradient>
+		</defs>
+		<rect x="0" width="10" height="20" fill="url(#a)"/>
+		<rect x="10" width="10" height="20" fill="url(#b)"/>
+		<rect x="20" width="20" height="20" fill="url(#missing) #0f0"/>
+		<rect x="20" width="20" height="20" fill="url(#c)" fill-opacity="0.5"/>
+	</svg>`)
+
+	canvas := doc.Rasterize(40, 20)
+	// The box of the rect maps the gradient.
+	if r, _, b, _ := pixel_at(canvas, 0, 10); r < 0xe0 || b > 0x20 {
+		t.Errorf("the left of the linear gradient: got r %x b %x", r, b)
+	}
+	if r, _, b, _ := pixel_at(canvas, 9, 10); r > 0x20 || b < 0xe0 {
+		t.Errorf("the right of the linear gradient: got r %x b %x", r, b)
+	}
+	// The href gives the stops, the own attributes make it vertical.
+	r0, _, _, _ := pixel_at(canvas, 15, 1)
+	r1, _, _, _ := pixel_at(canvas, 15, 18)
+	if r0 < 0xe0 || r1 > 0x20 {
+		t.Errorf("the vertical gradient: got r %x to %x", r0, r1)
+	}
+	// The fallback color is under the radial gradient at the half alpha.
+	if r, g, _, _ := pixel_at(canvas, 30, 10); r != 0x80 || g != 0xff {
+		t.Errorf("the center of the radial gradient: got r %x g %x", r, g)
+	}
+	if r, g, _, _ := pixel_at(canvas, 30, 2); r != 0 || g < 0x7f || g > 0x80 {
+		t.Errorf("the outside of the radial gradient: got r %x g %x", r, g)
+	}
+}
+
+func TestGoldenIcon(t *testing.T) {
+	doc, err := ParseFile("testdata/icon.svg")
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+	if w, h := doc.Size(); w != 24 || h != 24 {
+		t.Errorf("Size: got %vx%v, want 24x24", w, h)
+	}
+	vangotest.AssertGolden(t, "icon_24", doc.Rasterize(24, 24), 2)
+	vangotest.AssertGolden(t, "icon_96", doc.Rasterize(96, 96), 2)
+}
@@ -0,0 +1,321 @@
+// Copyright 2014 By Jshi. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vango
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"gwk/vango/freetype"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// SVGWriter is the Context writing the draws as an svg document. The paths
+// and the texts are written as the paths in the pixels of the document, the
+// texts are the outlines of the glyphs labeled with the text, and the images
+// are embedded as png.
+type SVGWriter struct {
+	*vector_context_t
+	device *svg_device_t
+	w      io.Writer
+}
+
+// NewSVGWriter returns the SVGWriter of a document of |width| x |height|
+// pixels, written to |w| by Close.
+func NewSVGWriter(w io.Writer, width, height int) *SVGWriter {
+	device := &svg_device_t{
+		width:  width,
+		height: height,
+		clips:  make(map[*vector_clip_t]string),
+		images: make(map[*Canvas]string),
+	}
+	return &SVGWriter{new_vector_context(device, width, height), device, w}
+}
+
+// Close writes the document. The writer draws nothing after it.
+func (s *SVGWriter) Close() error {
+	if s.w == nil {
+		return nil
+	}
+	w := s.w
+	s.w = nil
+	if s.device.err != nil {
+		return s.device.err
+	}
+
+	d := s.device
+	d.closed = true
+	_, err := fmt.Fprintf(w, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n"+
+		"<svg xmlns=\"http://www.w3.org/2000/svg\" xmlns:xlink=\"http://www.w3.org/1999/xlink\" "+
+		"version=\"1.1\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\">\n",
+		d.width, d.height, d.width, d.height)
+	if err == nil {
+		_, err = w.Write(d.buf.Bytes())
+	}
+	if err == nil {
+		_, err = io.WriteString(w, "</svg>\n")
+	}
+	return err
+}
+
+// svg_device_t writes the draws as the svg elements. The clips, the gradients
+// and the images are defined in the defs before they are used.
+type svg_device_t struct {
+	width, height int
+	buf           bytes.Buffer
+	closed        bool
+	err           error
+
+	next_id int
+	clips   map[*vector_clip_t]string
+	images  map[*Canvas]string
+}
+
+func (d *svg_device_t) new_id(prefix string) string {
+	id := prefix + strconv.Itoa(d.next_id)
+	d.next_id++
+	return id
+}
+
+func (d *svg_device_t) can_paint(paint Paint) bool {
+	switch paint.(type) {
+	case SolidPaint, *LinearGradient, *RadialGradient:
+		return true
+	}
+	return false
+}
+
+func (d *svg_device_t) draw(v *vector_draw_t) {
+	if d.closed || d.err != nil {
+		return
+	}
+	if v.image != nil {
+		d.draw_image(v)
+		return
+	}
+
+	// The defs are written before the element.
+	clip := d.clip_id(v.clip)
+	paint, opacity := d.paint_value(v.paint, v.paint_transform)
+	opacity *= v.alpha
+
+	b := &d.buf
+	b.WriteString("<path")
+	if v.text != "" {
+		svg_attr(b, "aria-label", v.text)
+	}
+	if clip != "" {
+		svg_attr(b, "clip-path", "url(#"+clip+")")
+	}
+	name := "fill"
+	if v.stroke != nil {
+		svg_attr(b, "fill", "none")
+		name = "stroke"
+	}
+	svg_attr(b, name, paint)
+	if opacity < 1 {
+		svg_attr(b, name+"-opacity", svg_num(opacity))
+	}
+	if v.stroke != nil {
+		d.stroke_attrs(v.stroke)
+	} else if v.even_odd {
+		svg_attr(b, "fill-rule", "evenodd")
+	}
+	svg_attr(b, "d", svg_path_data(v.path))
+	b.WriteString("/>\n")
+}
+
+// clip_id returns the id of the clipPath of |clip|, defined with its parents
+// the first time.
+func (d *svg_device_t) clip_id(clip *vector_clip_t) string {
+	if clip == nil {
+		return ""
+	}
+	if id, ok := d.clips[clip]; ok {
+		return id
+	}
+	parent := d.clip_id(clip.parent)
+	id := d.new_id("c")
+	d.clips[clip] = id
+
+	b := &d.buf
+	b.WriteString("<defs><clipPath")
+	svg_attr(b, "id", id)
+	if parent != "" {
+		svg_attr(b, "clip-path", "url(#"+parent+")")
+	}
+	b.WriteString("><path")
+	if clip.even_odd {
+		svg_attr(b, "clip-rule", "evenodd")
+	}
+	svg_attr(b, "d", svg_path_data(clip.path))
+	b.WriteString("/></clipPath></defs>\n")
+	return id
+}
+
+// paint_value returns the value of the fill or the stroke attribute of the
+// paint, and the opacity of a solid paint. The gradients are defined in the
+// user space of |m|.
+func (d *svg_device_t) paint_value(paint Paint, m Matrix) (string, float64) {
+	b := &d.buf
+	var gr *Gradient
+	var id string
+	switch p := paint.(type) {
+	case SolidPaint:
+		return svg_color(p.R, p.G, p.B), float64(p.A) / 0xff
+	case *LinearGradient:
+		gr, id = &p.Gradient, d.new_id("g")
+		fmt.Fprintf(b, "<defs><linearGradient id=\"%s\" x1=\"%s\" y1=\"%s\" x2=\"%s\" y2=\"%s\"",
+			id, svg_num(p.X0), svg_num(p.Y0), svg_num(p.X1), svg_num(p.Y1))
+	case *RadialGradient:
+		gr, id = &p.Gradient, d.new_id("g")
+		fmt.Fprintf(b, "<defs><radialGradient id=\"%s\" cx=\"%s\" cy=\"%s\" r=\"%s\" fx=\"%s\" fy=\"%s\"",
+			id, svg_num(p.CX), svg_num(p.CY), svg_num(math.Abs(p.R)), svg_num(p.FX), svg_num(p.FY))
+	default:
+		return "none", 1
+	}
+
+	b.WriteString(" gradientUnits=\"userSpaceOnUse\"")
+	svg_attr(b, "gradientTransform", svg_matrix(m))
+	switch gr.Spread() {
+	case SpreadReflect:
+		svg_attr(b, "spreadMethod", "reflect")
+	case SpreadRepeat:
+		svg_attr(b, "spreadMethod", "repeat")
+	}
+	b.WriteString(">")
+	for _, stop := range gr.ColorStops() {
+		fmt.Fprintf(b, "<stop offset=\"%s\" stop-color=\"%s\"", svg_num(stop.Offset), svg_color(stop.R, stop.G, stop.B))
+		if stop.A != 0xff {
+			svg_attr(b, "stop-opacity", svg_num(float64(stop.A)/0xff))
+		}
+		b.WriteString("/>")
+	}
+	if _, ok := paint.(*LinearGradient); ok {
+		b.WriteString("</linearGradient></defs>\n")
+	} else {
+		b.WriteString("</radialGradient></defs>\n")
+	}
+	return "url(#" + id + ")", 1
+}
+
+func (d *svg_device_t) stroke_attrs(stroke *vector_stroke_t) {
+	b := &d.buf
+	svg_attr(b, "stroke-width", svg_num(stroke.width))
+	switch stroke.line_cap {
+	case LineCapRound:
+		svg_attr(b, "stroke-linecap", "round")
+	case LineCapSquare:
+		svg_attr(b, "stroke-linecap", "square")
+	}
+	switch stroke.line_join {
+	case LineJoinMiter:
+		svg_attr(b, "stroke-miterlimit", svg_num(stroke.miter_limit))
+	case LineJoinRound:
+		svg_attr(b, "stroke-linejoin", "round")
+	case LineJoinBevel:
+		svg_attr(b, "stroke-linejoin", "bevel")
+	}
+	if len(stroke.dashes) > 0 {
+		dashes := make([]string, len(stroke.dashes))
+		for i, l := range stroke.dashes {
+			dashes[i] = svg_num(l)
+		}
+		svg_attr(b, "stroke-dasharray", strings.Join(dashes, " "))
+		if stroke.dash_offset != 0 {
+			svg_attr(b, "stroke-dashoffset", svg_num(stroke.dash_offset))
+		}
+	}
+}
+
+// draw_image writes the image defined once and used by the draws, clipped
+// by a group, since the clip of the use would be transformed with it.
+func (d *svg_device_t) draw_image(v *vector_draw_t) {
+	b := &d.buf
+	id, ok := d.images[v.image]
+	if !ok {
+		var png bytes.Buffer
+		if err := EncodePNG(&png, v.image); err != nil {
+			d.err = err
+			return
+		}
+		id = d.new_id("i")
+		d.images[v.image] = id
+		fmt.Fprintf(b, "<defs><image id=\"%s\" width=\"%d\" height=\"%d\" xlink:href=\"data:image/png;base64,%s\"/></defs>\n",
+			id, v.image.W(), v.image.H(), base64.StdEncoding.EncodeToString(png.Bytes()))
+	}
+
+	clip := d.clip_id(v.clip)
+	if clip != "" {
+		fmt.Fprintf(b, "<g clip-path=\"url(#%s)\">", clip)
+	}
+	fmt.Fprintf(b, "<use xlink:href=\"#%s\"", id)
+	svg_attr(b, "transform", svg_matrix(v.image_transform))
+	if v.alpha < 1 {
+		svg_attr(b, "opacity", svg_num(v.alpha))
+	}
+	if !v.smooth {
+		svg_attr(b, "image-rendering", "optimizeSpeed")
+	}
+	b.WriteString("/>")
+	if clip != "" {
+		b.WriteString("</g>")
+	}
+	b.WriteString("\n")
+}
+
+// svg_attr writes the attribute with the escaped |value|.
+func svg_attr(b *bytes.Buffer, name, value string) {
+	b.WriteString(" " + name + "=\"")
+	xml.EscapeText(b, []byte(value))
+	b.WriteString("\"")
+}
+
+// svg_num formats |f| with at most 4 decimals.
+func svg_num(f float64) string {
+	f = math.Floor(f*1e4+0.5) / 1e4
+	if f == 0 {
+		f = 0 // No "-0".
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func svg_color(r, g, b byte) string {
+	return fmt.Sprintf("#%02x%02x%02x", r, g, b)
+}
+
+func svg_matrix(m Matrix) string {
+	return "matrix(" + svg_num(m.A) + " " + svg_num(m.B) + " " + svg_num(m.C) + " " +
+		svg_num(m.D) + " " + svg_num(m.E) + " " + svg_num(m.F) + ")"
+}
+
+// svg_path_data returns the path data of |path|, the sub paths closed by
+// ClosePath end with Z.
+func svg_path_data(path freetype.Path) string {
+	var b bytes.Buffer
+	pt := func(p freetype.RastPoint) {
+		b.WriteString(svg_num(float64(p.X)/256) + " " + svg_num(float64(p.Y)/256))
+	}
+	walk_path(path, func(op int, pts []freetype.RastPoint, closed bool) {
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteByte("MLQC"[op])
+		for i, p := range pts {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			pt(p)
+		}
+		if closed {
+			b.WriteString("Z")
+		}
+	})
+	return b.String()
+}
@@ -0,0 +1,214 @@
+package vango
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"image"
+	"image/png"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// svg_element_t is an element of the written svg, with the attributes by
+// their local names.
+type svg_element_t struct {
+	name  string
+	attrs map[string]string
+}
+
+func parse_svg_elements(t *testing.T, data []byte) []svg_element_t {
+	var elements []svg_element_t
+	d := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return elements
+		}
+		if err != nil {
+			t.Fatalf("the svg isn't well formed: %v\n%s", err, data)
+		}
+		if se, ok := tok.(xml.StartElement); ok {
+			e := svg_element_t{se.Name.Local, make(map[string]string)}
+			for _, a := range se.Attr {
+				e.attrs[a.Name.Local] = a.Value
+			}
+			elements = append(elements, e)
+		}
+	}
+}
+
+// draw_vector_test_scene draws the scene of the picture tests, with the text,
+// a clip and a conic gradient.
+func draw_vector_test_scene(c Context, sprite *Canvas) {
+	draw_picture_test_scene(c, sprite)
+
+	c.SaveState()
+	c.ClipRect(image.Rect(60, 40, 80, 60))
+	conic := NewConicGradient(70, 50, 0)
+	conic.AddColorStop(0, 0xff, 0, 0, 0xff)
+	conic.AddColorStop(1, 0, 0, 0xff, 0xff)
+	c.SetFillPaint(conic)
+	c.FillEllipse(70, 50, 15, 15)
+	c.RestoreState()
+
+	c.SetFontColor(0, 0, 0xff)
+	c.DrawText("Hi", image.Rect(0, 40, 40, 60))
+}
+
+func TestSVGWriter(t *testing.T) {
+	load_test_font(t)
+	var buf bytes.Buffer
+	w := NewSVGWriter(&buf, 80, 60)
+	draw_vector_test_scene(w, new_test_sprite())
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	elements := parse_svg_elements(t, buf.Bytes())
+	if root := elements[0]; root.name != "svg" || root.attrs["width"] != "80" || root.attrs["viewBox"] != "0 0 80 60" {
+		t.Fatalf("root: got %v", root)
+	}
+
+	count := make(map[string]int)
+	var paths []svg_element_t
+	for _, e := range elements {
+		count[e.name]++
+		if e.name == "path" {
+			paths = append(paths, e)
+		}
+		if e.name == "image" {
+			data := strings.TrimPrefix(e.attrs["href"], "data:image/png;base64,")
+			b, err := base64.StdEncoding.DecodeString(data)
+			if err != nil {
+				t.Errorf("image data: %v", err)
+				continue
+			}
+			img, err := png.Decode(bytes.NewReader(b))
+			if err != nil {
+				t.Errorf("image png: %v", err)
+				continue
+			}
+			if s := img.Bounds().Size(); e.attrs["width"] != strconv.Itoa(s.X) || e.attrs["height"] != strconv.Itoa(s.Y) {
+				t.Errorf("image of %v: got %vx%v", s, e.attrs["width"], e.attrs["height"])
+			}
+		}
+	}
+	// The sprite, its stretched part, the shadow and the conic gradient.
+	if count["image"] != 4 || count["use"] != 4 {
+		t.Errorf("images: got %v images and %v uses, want 4", count["image"], count["use"])
+	}
+	if count["linearGradient"] != 1 || count["stop"] != 2 || count["clipPath"] != 1 {
+		t.Errorf("defs: got %v", count)
+	}
+
+	rect := paths[0]
+	if rect.attrs["fill"] != "#204060" || rect.attrs["d"] != "M2 2 L30 2 L30 20 L2 20 L2 2Z" {
+		t.Errorf("the rect: got %v", rect.attrs)
+	}
+	if fill := paths[1].attrs["fill"]; fill != "url(#g0)" {
+		t.Errorf("the gradient fill: got %v", fill)
+	}
+	stroke := paths[2].attrs
+	if stroke["fill"] != "none" || stroke["stroke"] != "#00c000" || stroke["stroke-width"] != "3" ||
+		stroke["stroke-dasharray"] != "4 2" || !strings.HasPrefix(stroke["d"], "M10 30 C") {
+		t.Errorf("the dashed stroke: got %v", stroke)
+	}
+	if poly := paths[3].attrs; poly["fill"] != "#ffff00" || poly["fill-opacity"] != "0.7529" {
+		t.Errorf("the polygon: got %v", poly)
+	}
+	text := paths[len(paths)-1].attrs
+	if text["aria-label"] != "Hi" || text["fill"] != "#0000ff" || strings.Count(text["d"], "M") < 2 {
+		t.Errorf("the text: got %v", text)
+	}
+}
+
+func TestSVGWriterPaths(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewSVGWriter(&buf, 40, 40)
+	w.Scale(2, 2)
+	w.SetStrokeColor(0xff, 0, 0)
+	w.SetLineCap(LineCapRound)
+	w.SetLineJoin(LineJoinBevel)
+	w.BeginPath()
+	w.MoveTo(1, 1)
+	w.QuadTo(5, 1, 5, 5)
+	w.LineTo(1, 5)
+	w.Stroke()
+
+	// The current path is kept by the shapes.
+	w.StrokeRect(image.Rect(0, 0, 10, 10))
+	w.SetFillRule(FillRuleEvenOdd)
+	w.SetGlobalAlpha(0.5)
+	w.Fill()
+	w.Close()
+
+	var paths []svg_element_t
+	for _, e := range parse_svg_elements(t, buf.Bytes()) {
+		if e.name == "path" {
+			paths = append(paths, e)
+		}
+	}
+	if len(paths) != 3 {
+		t.Fatalf("paths: got %v", paths)
+	}
+	want := map[string]string{"d": "M2 2 Q10 2 10 10 L2 10", "stroke-width": "2",
+		"stroke-linecap": "round", "stroke-linejoin": "bevel"}
+	for k, v := range want {
+		if paths[0].attrs[k] != v {
+			t.Errorf("the stroke %v: got %q, want %q", k, paths[0].attrs[k], v)
+		}
+	}
+	// The outline of StrokeRect through the pixel centers.
+	if d := paths[1].attrs["d"]; d != "M1 1 L21 1 L21 21 L1 21 L1 1Z" {
+		t.Errorf("the rect: got %v", d)
+	}
+	if a := paths[2].attrs; a["d"] != paths[0].attrs["d"] || a["fill-rule"] != "evenodd" || a["fill-opacity"] != "0.5" {
+		t.Errorf("the fill of the kept path: got %v", a)
+	}
+}
+
+func TestSVGWriterChangedCanvas(t *testing.T) {
+	fill := func(c *Canvas, r, b byte) {
+		for y := 0; y < c.H(); y++ {
+			for x := 0; x < c.W(); x++ {
+				set_pixel(c, x, y, b, 0, r, 0xff)
+			}
+		}
+	}
+	var buf bytes.Buffer
+	w := NewSVGWriter(&buf, 12, 4)
+	src := NewCanvas(2, 2)
+	fill(src, 0xff, 0)
+	w.DrawCanvas(0, 0, src, src.LocalBounds())
+	fill(src, 0, 0xff)
+	w.DrawCanvas(4, 0, src, src.LocalBounds())
+	// The same pixels as the first draw share its image.
+	fill(src, 0xff, 0)
+	w.DrawCanvas(8, 0, src, src.LocalBounds())
+	w.Close()
+
+	var colors []string
+	uses := 0
+	for _, e := range parse_svg_elements(t, buf.Bytes()) {
+		if e.name == "use" {
+			uses++
+		}
+		if e.name != "image" {
+			continue
+		}
+		data := strings.TrimPrefix(e.attrs["href"], "data:image/png;base64,")
+		b, _ := base64.StdEncoding.DecodeString(data)
+		img, err := png.Decode(bytes.NewReader(b))
+		if err != nil {
+			t.Fatalf("image png: %v", err)
+		}
+		r, _, b2, _ := img.At(0, 0).RGBA()
+		colors = append(colors, strconv.Itoa(int(r>>8))+","+strconv.Itoa(int(b2>>8)))
+	}
+	if uses != 3 || len(colors) != 2 || colors[0] != "255,0" || colors[1] != "0,255" {
+		t.Errorf("images: got %v for %v uses, want the red and the blue for 3 uses", colors, uses)
+	}
+}
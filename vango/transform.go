@@ -0,0 +1,210 @@
+// Copyright 2014 By Jshi. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vango
+
+import (
+	"image"
+	"math"
+)
+
+// Translate moves the origin of the user space by (tx, ty).
+func (c *CanvasContext) Translate(tx, ty float64) {
+	c.transform = c.transform.Multiply(TranslateMatrix(tx, ty))
+}
+
+func (c *CanvasContext) Scale(sx, sy float64) {
+	c.transform = c.transform.Multiply(ScaleMatrix(sx, sy))
+}
+
+// Rotate rotates the user space by |angle| in radians, clockwise on screen.
+func (c *CanvasContext) Rotate(angle float64) {
+	c.transform = c.transform.Multiply(RotateMatrix(angle))
+}
+
+// Transform multiplies the current transform by m. The m is applied to the
+// points first.
+func (c *CanvasContext) Transform(m Matrix) {
+	c.transform = c.transform.Multiply(m)
+}
+
+// SetTransform replaces the current transform with m.
+func (c *CanvasContext) SetTransform(m Matrix) {
+	c.transform = m
+}
+
+func (c *CanvasContext) ResetTransform() {
+	c.transform = IdentityMatrix()
+}
+
+func (c *CanvasContext) CurrentTransform() Matrix {
+	return c.transform
+}
+
+// device_rect returns the pixel bounds of the user space rect after the
+// current transform.
+func (c *CanvasContext) device_rect(x, y, w, h float64) image.Rectangle {
+	m := c.transform
+	x0, y0 := math.Inf(1), math.Inf(1)
+	x1, y1 := math.Inf(-1), math.Inf(-1)
+	for _, pt := range [4][2]float64{{x, y}, {x + w, y}, {x, y + h}, {x + w, y + h}} {
+		dx, dy := m.TransformPoint(pt[0], pt[1])
+		x0, y0 = math.Min(x0, dx), math.Min(y0, dy)
+		x1, y1 = math.Max(x1, dx), math.Max(y1, dy)
+	}
+	return image.Rect(int(math.Floor(x0)), int(math.Floor(y0)),
+		int(math.Ceil(x1)), int(math.Ceil(y1)))
+}
+
+// sample_src_t is the source pixels of a transformed image draw. The pixels
+// are 4 bytes each, premultiplied in the canvas order (b, g, r, a), or not
+// premultiplied in the order (r, g, b, a) if |nrgba| is true.
+type sample_src_t struct {
+	pix    []byte
+	stride int
+	offset int // pix offset of the top left pixel.
+	w, h   int
+	nrgba  bool
+}
+
+// pixel returns the premultiplied pixel at (x, y) in the canvas order.
+func (s *sample_src_t) pixel(x, y int) (b, g, r, a float64) {
+	o := s.offset + y*s.stride + x*4
+	p := s.pix[o : o+4]
+	if !s.nrgba {
+		return float64(p[0]), float64(p[1]), float64(p[2]), float64(p[3])
+	}
+	a = float64(p[3])
+	return float64(p[2]) * a / 255, float64(p[1]) * a / 255, float64(p[0]) * a / 255, a
+}
+
+// sample returns the bilinear sample at (u, v) in the source coordinate,
+// premultiplied in the canvas order. The pixels outside the source are
+// clamped to the edges, the coverage of the edges is handled by the caller.
+func (s *sample_src_t) sample(u, v float64) (clr [4]float64) {
+	u, v = u-0.5, v-0.5
+	fx, fy := math.Floor(u), math.Floor(v)
+	x0, y0 := int(fx), int(fy)
+	ax, ay := u-fx, v-fy
+
+	clamp := func(i, n int) int {
+		if i < 0 {
+			return 0
+		} else if i >= n {
+			return n - 1
+		}
+		return i
+	}
+
+	for j := 0; j < 2; j++ {
+		wy := 1 - ay
+		if j == 1 {
+			wy = ay
+		}
+		if wy == 0 {
+			continue
+		}
+		y := clamp(y0+j, s.h)
+		for i := 0; i < 2; i++ {
+			wx := 1 - ax
+			if i == 1 {
+				wx = ax
+			}
+			if wx == 0 {
+				continue
+			}
+			x := clamp(x0+i, s.w)
+
+			b, g, r, a := s.pixel(x, y)
+			k := wx * wy
+			clr[0] += b * k
+			clr[1] += g * k
+			clr[2] += r * k
+			clr[3] += a * k
+		}
+	}
+	return clr
+}
+
+// edge_coverage returns the coverage of a pixel whose center is |d| away
+// from the edge, positive inside.
+func edge_coverage(d float64) float64 {
+	return math.Max(0, math.Min(1, d+0.5))
+}
+
+// draw_transformed draws |src| at (x, y) in the user space through the current
+// transform. Every covered pixel is resampled from the source, with several
+// samples per pixel when the image is scaled down, and composited like the
+// other draws. The coverage of the source edges is anti-aliased.
+func (c *CanvasContext) draw_transformed(x, y int, src *sample_src_t) {
+	if src.w <= 0 || src.h <= 0 {
+		return
+	}
+
+	m := c.transform.Multiply(TranslateMatrix(float64(x), float64(y)))
+	inv, ok := m.Invert()
+	if !ok {
+		return
+	}
+
+	dr := c.device_rect(float64(x), float64(y), float64(src.w), float64(src.h))
+	dr = dr.Intersect(c.clip_bounds())
+	if dr.Empty() {
+		return
+	}
+
+	// The number of the samples on each axis, by how many source pixels one
+	// destination pixel covers.
+	n := int(math.Ceil(math.Max(math.Hypot(inv.A, inv.B), math.Hypot(inv.C, inv.D))))
+	if n < 1 {
+		n = 1
+	} else if n > 4 {
+		n = 4
+	}
+	step := 1 / float64(n)
+
+	// The lengths of the gradients of u and v, to measure the distances to
+	// the source edges in the destination pixels.
+	gu, gv := math.Hypot(inv.A, inv.C), math.Hypot(inv.B, inv.D)
+	w, h := float64(src.w), float64(src.h)
+
+	// The colors and the coverage of one line.
+	row := make([]byte, dr.Dx()*4)
+	mask := make([]byte, dr.Dx())
+	for py := dr.Min.Y; py < dr.Max.Y; py++ {
+		for px := dr.Min.X; px < dr.Max.X; px++ {
+			var acc [4]float64
+			cov := 0.0
+			for sy := 0; sy < n; sy++ {
+				for sx := 0; sx < n; sx++ {
+					u, v := inv.TransformPoint(float64(px)+(float64(sx)+0.5)*step,
+						float64(py)+(float64(sy)+0.5)*step)
+					k := edge_coverage(math.Min(u, w-u)/gu*float64(n)) *
+						edge_coverage(math.Min(v, h-v)/gv*float64(n))
+					if k == 0 {
+						continue
+					}
+					clr := src.sample(u, v)
+					for j := range acc {
+						acc[j] += clr[j] * k
+					}
+					cov += k
+				}
+			}
+
+			// The average color among the samples inside the source, and the
+			// coverage of the pixel by the source.
+			i := px - dr.Min.X
+			mask[i] = 0
+			if cov == 0 {
+				continue
+			}
+			for j := range acc {
+				row[i*4+j] = byte(math.Min(255, acc[j]/cov+0.5))
+			}
+			mask[i] = byte(cov/float64(n*n)*255 + 0.5)
+		}
+		c.blend_span(dr.Min.X, py, dr.Dx(), [4]uint32{}, row, mask, 0xff)
+	}
+}
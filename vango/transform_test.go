@@ -0,0 +1,158 @@
+package vango
+
+import (
+	"gwk/vango/freetype"
+	"image"
+	"io/ioutil"
+	"math"
+	"testing"
+)
+
+// load_test_font loads the font of the freetype tests as the default font.
+func load_test_font(t *testing.T) {
+	if g_default_font != nil {
+		return
+	}
+	bytes, err := ioutil.ReadFile("./freetype/exp/data/luxisr.ttf")
+	if err != nil {
+		t.Skipf("no test font: %v", err)
+	}
+	g_default_font, err = freetype.ParseFont(bytes)
+	if err != nil {
+		t.Fatalf("ParseFont: %v", err)
+	}
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestMatrix(t *testing.T) {
+	m := TranslateMatrix(10, 20).Multiply(ScaleMatrix(2, 3))
+	if x, y := m.TransformPoint(1, 1); !near(x, 12) || !near(y, 23) {
+		t.Errorf("translate * scale: got (%v, %v), want (12, 23)", x, y)
+	}
+
+	m = RotateMatrix(math.Pi / 2)
+	if x, y := m.TransformPoint(1, 0); !near(x, 0) || !near(y, 1) {
+		t.Errorf("rotate: got (%v, %v), want (0, 1)", x, y)
+	}
+
+	m = TranslateMatrix(5, -3).Multiply(RotateMatrix(0.7)).Multiply(ScaleMatrix(2, 4))
+	inv, ok := m.Invert()
+	if !ok {
+		t.Fatalf("Invert failed")
+	}
+	if p := m.Multiply(inv); !near(p.A, 1) || !near(p.B, 0) || !near(p.C, 0) ||
+		!near(p.D, 1) || !near(p.E, 0) || !near(p.F, 0) {
+		t.Errorf("m * inv: got %v, want identity", p)
+	}
+
+	if _, ok := ScaleMatrix(0, 1).Invert(); ok {
+		t.Errorf("Invert of a singular matrix succeeded")
+	}
+}
+
+func TestTransformPath(t *testing.T) {
+	ctxt, canvas := new_test_context(40, 40)
+	ctxt.Translate(20, 0)
+	ctxt.Scale(2, 2)
+	ctxt.BeginPath()
+	ctxt.MoveTo(0, 5)
+	ctxt.LineTo(5, 5)
+	ctxt.LineTo(5, 10)
+	ctxt.LineTo(0, 10)
+	ctxt.Fill()
+
+	if !is_painted(canvas, 25, 15) {
+		t.Errorf("the transformed path isn't painted")
+	}
+	if is_painted(canvas, 5, 15) || is_painted(canvas, 25, 5) {
+		t.Errorf("the path is painted without the transform")
+	}
+}
+
+func TestTransformStateRestore(t *testing.T) {
+	ctxt, canvas := new_test_context(40, 40)
+	ctxt.SaveState()
+	ctxt.Translate(20, 20)
+	ctxt.FillRect(image.Rect(0, 0, 5, 5))
+	ctxt.RestoreState()
+
+	if !ctxt.CurrentTransform().IsIdentity() {
+		t.Errorf("RestoreState: got %v, want identity", ctxt.CurrentTransform())
+	}
+	if !is_painted(canvas, 22, 22) || is_painted(canvas, 2, 2) {
+		t.Errorf("FillRect isn't translated")
+	}
+}
+
+func TestTransformImage(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	for i := 0; i < len(src.Pix); i += 4 {
+		src.Pix[i+0], src.Pix[i+3] = 0xff, 0xff
+	}
+
+	ctxt, canvas := new_test_context(40, 40)
+	ctxt.Translate(20, 20)
+	ctxt.Scale(4, 4)
+	ctxt.DrawNRGBA(0, 0, src, src.Bounds())
+
+	if !is_painted(canvas, 28, 28) || !is_painted(canvas, 35, 35) {
+		t.Errorf("the scaled image doesn't cover the scaled rect")
+	}
+	if is_painted(canvas, 18, 28) || is_painted(canvas, 37, 28) {
+		t.Errorf("the scaled image is painted outside the scaled rect")
+	}
+
+	// A half pixel translation blends the edges with the resampling.
+	ctxt, canvas = new_test_context(40, 40)
+	ctxt.Translate(10.5, 10)
+	ctxt.DrawNRGBA(0, 0, src, src.Bounds())
+	if r, _, _, _ := pixel_at(canvas, 10, 11); r < 0x70 || r > 0x90 {
+		t.Errorf("the left edge: got r=%v, want about 0x80", r)
+	}
+	if r, _, _, _ := pixel_at(canvas, 12, 11); r != 0xff {
+		t.Errorf("the inside: got r=%v, want 0xff", r)
+	}
+}
+
+func TestTransformText(t *testing.T) {
+	load_test_font(t)
+
+	count := func(canvas *Canvas, rect image.Rectangle) int {
+		n := 0
+		for y := rect.Min.Y; y < rect.Max.Y; y++ {
+			for x := rect.Min.X; x < rect.Max.X; x++ {
+				if _, _, b, _ := pixel_at(canvas, x, y); b > 0x80 {
+					n++
+				}
+			}
+		}
+		return n
+	}
+
+	ctxt, canvas := new_test_context(100, 100)
+	ctxt.SetFont("default")
+	ctxt.SetFontColor(0, 0, 0xff)
+	ctxt.DrawText("III", image.Rect(0, 0, 100, 20))
+	plain := count(canvas, canvas.LocalBounds())
+	if plain == 0 {
+		t.Fatalf("the plain text isn't painted")
+	}
+
+	ctxt, canvas = new_test_context(100, 100)
+	ctxt.SetFont("default")
+	ctxt.SetFontColor(0, 0, 0xff)
+	ctxt.Translate(50, 0)
+	ctxt.Rotate(math.Pi / 2)
+	ctxt.DrawText("III", image.Rect(0, 0, 100, 20))
+
+	// Rotated by 90 degrees, the text goes down along x = 50 - 10.
+	if n := count(canvas, image.Rect(35, 0, 50, 100)); n == 0 {
+		t.Errorf("the rotated text isn't painted")
+	}
+	if n := count(canvas, image.Rect(0, 0, 30, 100)); n != 0 {
+		t.Errorf("the rotated text painted %v pixels at the unrotated place", n)
+	}
+}
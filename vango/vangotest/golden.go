@@ -0,0 +1,91 @@
+// Copyright 2014 By Jshi. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package vangotest compares the rendered canvases with the golden images
+// kept under the testdata directory of the package being tested. Run the
+// tests with -update to write the golden images again:
+//
+//	go test gwk/vango -update
+package vangotest
+
+import (
+	"flag"
+	"gwk/vango"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden images under testdata")
+
+// GoldenDir is the directory of the golden images, relative to the package
+// being tested.
+const GoldenDir = "testdata"
+
+// Updating reports whether the tests write the golden images.
+func Updating() bool {
+	return *update
+}
+
+// AssertGolden compares |canvas| with the golden image testdata/|name|.png.
+// A pixel mismatches if any channel differs more than |tolerance|. On a
+// mismatch, the canvas and the diff image are written to a temp directory
+// for the inspection. With -update, the golden image is written instead.
+func AssertGolden(t testing.TB, name string, canvas *vango.Canvas, tolerance int) {
+	path := filepath.Join(GoldenDir, name+".png")
+	if *update {
+		if err := os.MkdirAll(GoldenDir, 0755); err != nil {
+			t.Fatalf("vangotest: %v", err)
+		}
+		if err := write_png(path, canvas); err != nil {
+			t.Fatalf("vangotest: %v", err)
+		}
+		return
+	}
+
+	golden, err := read_png(path)
+	if err != nil {
+		t.Fatalf("vangotest: %v, run the test with -update to create it", err)
+	}
+
+	result, err := vango.Compare(golden, canvas, tolerance)
+	if err != nil {
+		t.Fatalf("vangotest %v: %vx%v, want %vx%v", name, canvas.W(), canvas.H(), golden.W(), golden.H())
+	}
+	if result.Mismatched == 0 {
+		return
+	}
+
+	dir, err := ioutil.TempDir("", "vangotest")
+	if err == nil {
+		write_png(filepath.Join(dir, name+".png"), canvas)
+		write_png(filepath.Join(dir, name+".diff.png"), result.Diff)
+	}
+	t.Errorf("vangotest %v: %v pixels mismatched, PSNR %.2f dB, see %v",
+		name, result.Mismatched, result.PSNR, dir)
+}
+
+func write_png(path string, canvas *vango.Canvas) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return vango.EncodePNG(f, canvas)
+}
+
+func read_png(path string) (*vango.Canvas, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+	return vango.CanvasFromImage(img), nil
+}
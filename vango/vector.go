@@ -0,0 +1,811 @@
+// Copyright 2014 By Jshi. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vango
+
+import (
+	"bytes"
+	"errors"
+	"gwk/vango/freetype"
+	"hash/fnv"
+	"image"
+	"math"
+)
+
+// vector_clip_t is a clip of the vector writers, the intersection of its path
+// and the parent clip. It's never changed once created, so the saved states
+// can share it.
+type vector_clip_t struct {
+	parent   *vector_clip_t
+	path     freetype.Path // In the device space.
+	even_odd bool
+}
+
+// vector_stroke_t is the line style of a stroke in the device space.
+type vector_stroke_t struct {
+	width       float64
+	line_cap    LineCap
+	line_join   LineJoin
+	miter_limit float64
+	dashes      []float64 // nil for the solid lines.
+	dash_offset float64
+}
+
+// vector_draw_t is one draw written by a vector device, a path filled or
+// stroked by a paint, or an image. The coordinates are in the device space.
+type vector_draw_t struct {
+	path     freetype.Path
+	even_odd bool
+	stroke   *vector_stroke_t // nil to fill the path.
+	// The paint, and the transform from its user space to the device.
+	paint           Paint
+	paint_transform Matrix
+	// The text of the glyph outlines in the path, if they are.
+	text string
+
+	// The image drawn instead of the path, through image_transform from its
+	// pixels to the device.
+	image           *Canvas
+	image_transform Matrix
+	smooth          bool
+
+	alpha float64 // The global alpha.
+	clip  *vector_clip_t
+}
+
+// vector_device_t is the document the draws of vector_context_t are written
+// to.
+type vector_device_t interface {
+	// can_paint returns whether the device writes |paint| as is, the draws
+	// with the other paints are rasterized into the images.
+	can_paint(paint Paint) bool
+	draw(d *vector_draw_t)
+}
+
+type vector_state_t struct {
+	context_state_t
+	vector_clip *vector_clip_t
+}
+
+// vector_image_key_t identifies the pixels of an image copy, so the copies of
+// the same content share one image in the device.
+type vector_image_key_t struct {
+	format PixelFormat
+	opaque bool
+	size   image.Point
+	sum    uint64
+}
+
+// vector_context_t is the Context of the vector writers. It keeps the graphics
+// state and the path like CanvasContext, and hands every draw in the device
+// space to the device. The composite ops and the dither are ignored, and the
+// draws the device can't write, like the conic gradients, are rasterized and
+// embedded as the images.
+type vector_context_t struct {
+	vector_state_t
+	state_stack []vector_state_t
+
+	device vector_device_t
+	bounds image.Rectangle // The page in the device space.
+	font   *Font
+
+	// path.
+	path              freetype.Path
+	start_point       freetype.RastPoint
+	current_point     freetype.RastPoint
+	has_current_point bool
+	path_closed       bool
+
+	// The copies of the drawn images, so the same part of a canvas is written
+	// once.
+	images map[vector_image_key_t]*Canvas
+}
+
+func new_vector_context(device vector_device_t, width, height int) *vector_context_t {
+	v := &vector_context_t{device: device, bounds: image.Rect(0, 0, width, height)}
+	v.font = NewFont()
+	v.reset()
+	return v
+}
+
+// reset puts the context back to the initial state, like a new page.
+func (v *vector_context_t) reset() {
+	v.vector_state_t = vector_state_t{}
+	v.state_stack = nil
+	v.stroke_color = pack_color(0, 0, 0, 0xff)
+	v.fill_color = pack_color(0, 0, 0, 0xff)
+	v.font_color = pack_color(0, 0, 0, 0xff)
+	v.font.SetFontFace(g_default_font)
+	v.font.SetFontSize(12)
+	v.font.SetFallbacks(nil)
+	v.font_face = v.font.font
+	v.font_size = v.font.size
+	v.global_alpha = 1
+	v.image_filter = FilterBilinear
+	v.transform = IdentityMatrix()
+	v.line_width = 1
+	v.miter_limit = 10
+	v.BeginPath()
+	v.images = make(map[vector_image_key_t]*Canvas)
+}
+
+func (v *vector_context_t) SaveState() {
+	v.state_stack = append(v.state_stack, v.vector_state_t)
+}
+
+func (v *vector_context_t) RestoreState() {
+	n := len(v.state_stack)
+	if n == 0 {
+		return
+	}
+	v.vector_state_t = v.state_stack[n-1]
+	v.state_stack = v.state_stack[:n-1]
+	v.font.SetFontFace(v.font_face)
+	v.font.SetFontSize(v.font_size)
+	v.font.SetFallbacks(v.font_fallbacks)
+}
+
+func (v *vector_context_t) StateDepth() int {
+	return len(v.state_stack)
+}
+
+func (v *vector_context_t) Translate(tx, ty float64) {
+	v.transform = v.transform.Multiply(TranslateMatrix(tx, ty))
+}
+
+func (v *vector_context_t) Scale(sx, sy float64) {
+	v.transform = v.transform.Multiply(ScaleMatrix(sx, sy))
+}
+
+func (v *vector_context_t) Rotate(angle float64) {
+	v.transform = v.transform.Multiply(RotateMatrix(angle))
+}
+
+func (v *vector_context_t) Transform(m Matrix) {
+	v.transform = v.transform.Multiply(m)
+}
+
+func (v *vector_context_t) SetTransform(m Matrix) {
+	v.transform = m
+}
+
+func (v *vector_context_t) ResetTransform() {
+	v.transform = IdentityMatrix()
+}
+
+func (v *vector_context_t) CurrentTransform() Matrix {
+	return v.transform
+}
+
+func (v *vector_context_t) ClipRect(rect image.Rectangle) {
+	v.vector_clip = &vector_clip_t{parent: v.vector_clip, path: v.rect_path(rect, 0)}
+}
+
+func (v *vector_context_t) ClipPath() {
+	path := append(freetype.Path(nil), v.path...)
+	v.vector_clip = &vector_clip_t{v.vector_clip, path, v.fill_rule == FillRuleEvenOdd}
+}
+
+func (v *vector_context_t) ResetClip() {
+	v.vector_clip = nil
+}
+
+func (v *vector_context_t) save_clip() interface{} {
+	return v.vector_clip
+}
+
+func (v *vector_context_t) restore_clip(clip interface{}) {
+	v.vector_clip = clip.(*vector_clip_t)
+}
+
+func (v *vector_context_t) SetStrokeColor(r, g, b byte) {
+	v.SetStrokeRGBA(r, g, b, 0xff)
+}
+
+func (v *vector_context_t) SetStrokeRGBA(r, g, b, a byte) {
+	v.stroke_color = pack_color(r, g, b, a)
+	v.stroke_paint = nil
+}
+
+func (v *vector_context_t) SetStrokePaint(paint Paint) {
+	v.stroke_paint = paint
+}
+
+func (v *vector_context_t) SetFillColor(r, g, b byte) {
+	v.SetFillRGBA(r, g, b, 0xff)
+}
+
+func (v *vector_context_t) SetFillRGBA(r, g, b, a byte) {
+	v.fill_color = pack_color(r, g, b, a)
+	v.fill_paint = nil
+}
+
+func (v *vector_context_t) SetFillPaint(paint Paint) {
+	v.fill_paint = paint
+}
+
+func (v *vector_context_t) SetFontColor(r, g, b byte) {
+	v.SetFontRGBA(r, g, b, 0xff)
+}
+
+func (v *vector_context_t) SetFontRGBA(r, g, b, a byte) {
+	v.font_color = pack_color(r, g, b, a)
+	v.font_paint = nil
+}
+
+func (v *vector_context_t) SetFontPaint(paint Paint) {
+	v.font_paint = paint
+}
+
+func (v *vector_context_t) SetFontSize(size float64) {
+	v.font_size = size
+	v.font.SetFontSize(size)
+}
+
+func (v *vector_context_t) FontSize() float64 {
+	return v.font_size
+}
+
+func (v *vector_context_t) SetFont(font_name string) {
+	face, size := font_by_name(font_name)
+	if face == nil {
+		return
+	}
+	v.SetFontFace(face)
+	if size > 0 {
+		v.SetFontSize(size)
+	}
+}
+
+func (v *vector_context_t) SetFontFace(face *freetype.Font) {
+	v.font_face = face
+	v.font.SetFontFace(face)
+}
+
+func (v *vector_context_t) SetFallbackFonts(font_names ...string) {
+	v.font_fallbacks = fallbacks_by_names(font_names)
+	v.font.SetFallbacks(v.font_fallbacks)
+}
+
+func (v *vector_context_t) SetDither(dither bool) {
+	v.dither = dither
+}
+
+func (v *vector_context_t) SetGlobalAlpha(alpha float64) {
+	v.global_alpha = math.Max(0, math.Min(1, alpha))
+}
+
+func (v *vector_context_t) GlobalAlpha() float64 {
+	return v.global_alpha
+}
+
+func (v *vector_context_t) SetCompositeOp(op CompositeOp) {
+	v.composite_op = op
+}
+
+func (v *vector_context_t) SetImageFilter(filter Filter) {
+	v.image_filter = filter
+}
+
+func (v *vector_context_t) SetFillRule(rule FillRule) {
+	v.fill_rule = rule
+}
+
+func (v *vector_context_t) SetLineWidth(width float64) {
+	v.line_width = width
+}
+
+func (v *vector_context_t) LineWidth() float64 {
+	return v.line_width
+}
+
+func (v *vector_context_t) SetLineCap(line_cap LineCap) {
+	v.line_cap = line_cap
+}
+
+func (v *vector_context_t) SetLineJoin(line_join LineJoin) {
+	v.line_join = line_join
+}
+
+func (v *vector_context_t) SetMiterLimit(limit float64) {
+	v.miter_limit = limit
+}
+
+func (v *vector_context_t) SetLineDash(dashes []float64) {
+	v.line_dash = append([]float64(nil), dashes...)
+}
+
+func (v *vector_context_t) SetLineDashOffset(offset float64) {
+	v.line_dash_offset = offset
+}
+
+func (v *vector_context_t) device_point(x, y float64) freetype.RastPoint {
+	return to_rast_point(v.transform.TransformPoint(x, y))
+}
+
+func (v *vector_context_t) BeginPath() {
+	v.path.Clear()
+	v.has_current_point, v.path_closed = false, false
+}
+
+func (v *vector_context_t) MoveTo(x, y float64) {
+	pt := v.device_point(x, y)
+	v.path.Start(pt)
+	v.start_point, v.current_point = pt, pt
+	v.has_current_point, v.path_closed = true, false
+}
+
+func (v *vector_context_t) continue_path() {
+	if v.path_closed {
+		v.path.Start(v.start_point)
+		v.path_closed = false
+	}
+}
+
+func (v *vector_context_t) LineTo(x, y float64) {
+	if !v.has_current_point {
+		v.MoveTo(x, y)
+		return
+	}
+	v.continue_path()
+	pt := v.device_point(x, y)
+	v.path.Add1(pt)
+	v.current_point = pt
+}
+
+func (v *vector_context_t) QuadTo(cx, cy, x, y float64) {
+	if !v.has_current_point {
+		v.MoveTo(cx, cy)
+	}
+	v.continue_path()
+	pt := v.device_point(x, y)
+	v.path.Add2(v.device_point(cx, cy), pt)
+	v.current_point = pt
+}
+
+func (v *vector_context_t) CubicTo(c1x, c1y, c2x, c2y, x, y float64) {
+	if !v.has_current_point {
+		v.MoveTo(c1x, c1y)
+	}
+	v.continue_path()
+	pt := v.device_point(x, y)
+	v.path.Add3(v.device_point(c1x, c1y), v.device_point(c2x, c2y), pt)
+	v.current_point = pt
+}
+
+func (v *vector_context_t) ClosePath() {
+	if !v.has_current_point || v.path_closed {
+		return
+	}
+	if v.current_point != v.start_point {
+		v.path.Add1(v.start_point)
+	}
+	v.path.Close()
+	v.current_point, v.path_closed = v.start_point, true
+}
+
+func (v *vector_context_t) Fill() {
+	v.fill_path(v.path, v.fill_rule == FillRuleEvenOdd, v.paint_of(v.fill_color, v.fill_paint), "")
+}
+
+func (v *vector_context_t) Stroke() {
+	v.stroke_path(v.path)
+}
+
+// with_path calls |build| on an empty path and |draw| to draw it, then puts
+// the current path back.
+func (v *vector_context_t) with_path(build func(), draw func()) {
+	path, start, current, has, closed := v.path, v.start_point, v.current_point, v.has_current_point, v.path_closed
+	v.path, v.has_current_point, v.path_closed = nil, false, false
+	build()
+	draw()
+	v.path, v.start_point, v.current_point, v.has_current_point, v.path_closed = path, start, current, has, closed
+}
+
+// rect_path returns the path of |rect| in the user space, outset by |outset|.
+func (v *vector_context_t) rect_path(rect image.Rectangle, outset float64) freetype.Path {
+	x0, y0 := float64(rect.Min.X)-outset, float64(rect.Min.Y)-outset
+	x1, y1 := float64(rect.Max.X)+outset, float64(rect.Max.Y)+outset
+	var path freetype.Path
+	path.Start(v.device_point(x0, y0))
+	path.Add1(v.device_point(x1, y0))
+	path.Add1(v.device_point(x1, y1))
+	path.Add1(v.device_point(x0, y1))
+	path.Add1(v.device_point(x0, y0))
+	path.Close()
+	return path
+}
+
+// paint_of returns |paint|, or the solid paint of |clr| if it's nil.
+func (v *vector_context_t) paint_of(clr uint32, paint Paint) Paint {
+	if paint != nil {
+		return paint
+	}
+	r, g, b, a := unpack_rgba(clr)
+	return NewSolidPaint(r, g, b, a)
+}
+
+// fill_path writes the |path| in the device space filled by |paint|.
+func (v *vector_context_t) fill_path(path freetype.Path, even_odd bool, paint Paint, text string) {
+	if len(path) == 0 {
+		return
+	}
+	if !v.device.can_paint(paint) {
+		v.rasterize(path_bounds(path, 1), func(c *CanvasContext, dx, dy freetype.Fix32) {
+			c.path = translate_path(path, dx, dy)
+			c.fill_color, c.fill_paint = pack_color(0, 0, 0, 0xff), paint
+			c.fill_rule = FillRuleNonZero
+			if even_odd {
+				c.fill_rule = FillRuleEvenOdd
+			}
+			c.Fill()
+		})
+		return
+	}
+	v.device.draw(&vector_draw_t{
+		path:            path,
+		even_odd:        even_odd,
+		paint:           paint,
+		paint_transform: v.transform,
+		text:            text,
+		alpha:           v.global_alpha,
+		clip:            v.vector_clip,
+	})
+}
+
+// stroke_path writes the |path| in the device space stroked by the stroke
+// paint and the line style, which are scaled like CanvasContext.
+func (v *vector_context_t) stroke_path(path freetype.Path) {
+	if len(path) == 0 || v.line_width <= 0 {
+		return
+	}
+	paint := v.paint_of(v.stroke_color, v.stroke_paint)
+	k := v.transform.scale_factor()
+	if !v.device.can_paint(paint) {
+		outset := v.line_width * k / 2 * math.Max(math.Sqrt2, v.miter_limit)
+		v.rasterize(path_bounds(path, outset+1), func(c *CanvasContext, dx, dy freetype.Fix32) {
+			c.path = translate_path(path, dx, dy)
+			c.stroke_color, c.stroke_paint = pack_color(0, 0, 0, 0xff), paint
+			c.Stroke()
+		})
+		return
+	}
+
+	stroke := &vector_stroke_t{
+		width:       v.line_width * k,
+		line_cap:    v.line_cap,
+		line_join:   v.line_join,
+		miter_limit: v.miter_limit,
+		dash_offset: v.line_dash_offset * k,
+	}
+	// The invalid patterns are solid like freetype.Dash, the odd ones are
+	// repeated twice.
+	total := 0.0
+	for _, l := range v.line_dash {
+		if l < 0 {
+			total = 0
+			break
+		}
+		total += l
+	}
+	if total > 0 {
+		for _, l := range v.line_dash {
+			stroke.dashes = append(stroke.dashes, l*k)
+		}
+		if len(stroke.dashes)%2 == 1 {
+			stroke.dashes = append(stroke.dashes, stroke.dashes...)
+		}
+	}
+	v.device.draw(&vector_draw_t{
+		path:            path,
+		stroke:          stroke,
+		paint:           paint,
+		paint_transform: v.transform,
+		alpha:           v.global_alpha,
+		clip:            v.vector_clip,
+	})
+}
+
+// rasterize draws by a CanvasContext with the current state into the image of
+// the |rect| in the device space, and writes the image. |draw| gets the
+// offset of the device points to the image.
+func (v *vector_context_t) rasterize(rect image.Rectangle, draw func(c *CanvasContext, dx, dy freetype.Fix32)) {
+	rect = rect.Intersect(v.bounds)
+	if rect.Empty() {
+		return
+	}
+	canvas := NewCanvas(rect.Dx(), rect.Dy())
+	ctxt := NewContext()
+	ctxt.SetCanvas(canvas)
+	ctxt.context_state_t = v.context_state_t
+	ctxt.clip = nil
+	ctxt.composite_op = CompositeSrcOver
+	ctxt.transform = TranslateMatrix(float64(-rect.Min.X), float64(-rect.Min.Y)).Multiply(v.transform)
+	draw(ctxt, to_fix32(float64(-rect.Min.X)), to_fix32(float64(-rect.Min.Y)))
+
+	// The global alpha is in the pixels already.
+	v.device.draw(&vector_draw_t{
+		image:           canvas,
+		image_transform: TranslateMatrix(float64(rect.Min.X), float64(rect.Min.Y)),
+		smooth:          true,
+		alpha:           1,
+		clip:            v.vector_clip,
+	})
+}
+
+// draw_image writes |img| through |m| from its pixels to the user space.
+func (v *vector_context_t) draw_image(img *Canvas, m Matrix, alpha float64) {
+	if img == nil || img.W() == 0 || img.H() == 0 {
+		return
+	}
+	v.device.draw(&vector_draw_t{
+		image:           img,
+		image_transform: v.transform.Multiply(m),
+		smooth:          v.image_filter != FilterNearest,
+		alpha:           alpha,
+		clip:            v.vector_clip,
+	})
+}
+
+// image_of returns the copy of the |rect| of |src|. The copy is shared with the
+// earlier draws of the same pixels only, the |src| may be changed between the
+// draws.
+func (v *vector_context_t) image_of(src *Canvas, rect image.Rectangle) *Canvas {
+	img := src.SubCanvas(rect.Intersect(src.LocalBounds())).ConvertTo(src.Format())
+	h := fnv.New64a()
+	h.Write(img.Pix())
+	key := vector_image_key_t{img.Format(), img.Opaque(), img.LocalBounds().Size(), h.Sum64()}
+	if old, ok := v.images[key]; ok {
+		if bytes.Equal(old.Pix(), img.Pix()) {
+			return old
+		}
+		return img
+	}
+	v.images[key] = img
+	return img
+}
+
+func (v *vector_context_t) FontMetrics() FontMetrics {
+	return v.font.Metrics()
+}
+
+func (v *vector_context_t) MeasureText(text string) TextMetrics {
+	return v.font.MeasureText(text)
+}
+
+// DrawText writes the outlines of the glyphs filled by the font paint, with
+// the text, so the documents keep it searchable. The text is placed like
+// CanvasContext.DrawText.
+func (v *vector_context_t) DrawText(text string, rect image.Rectangle) (freetype.RastPoint, error) {
+	if v.font == nil || v.font.font == nil {
+		return freetype.RastPoint{}, errors.New("vango DrawString called with nil font.")
+	}
+
+	var path freetype.Path
+	pt := v.font.text_origin(rect)
+	pt, err := v.font.walk_text(text, pt, func(face *freetype.Font, idx uint16, pt freetype.RastPoint) error {
+		x, y := float64(pt.X)/256, float64(pt.Y)/256
+		return v.font.add_glyph_outline(&path, face, idx, x, y, v.transform)
+	})
+	if err != nil {
+		return freetype.RastPoint{}, err
+	}
+	v.fill_path(path, false, v.paint_of(v.font_color, v.font_paint), text)
+	return pt, nil
+}
+
+// DrawColor fills the page, limited by the clip, with the opaque color.
+func (v *vector_context_t) DrawColor(r, g, b byte) {
+	var path freetype.Path
+	x0, y0 := to_fix32(float64(v.bounds.Min.X)), to_fix32(float64(v.bounds.Min.Y))
+	x1, y1 := to_fix32(float64(v.bounds.Max.X)), to_fix32(float64(v.bounds.Max.Y))
+	path.Start(freetype.RastPoint{X: x0, Y: y0})
+	path.Add1(freetype.RastPoint{X: x1, Y: y0})
+	path.Add1(freetype.RastPoint{X: x1, Y: y1})
+	path.Add1(freetype.RastPoint{X: x0, Y: y1})
+	v.fill_path(path, false, NewSolidPaint(r, g, b, 0xff), "")
+}
+
+func (v *vector_context_t) DrawImage(x, y int, src image.Image, rect image.Rectangle) {
+	switch typ := src.(type) {
+	case *image.Alpha:
+		v.DrawAlpha(x, y, typ, rect)
+	case *image.NRGBA:
+		v.DrawNRGBA(x, y, typ, rect)
+	case *image.RGBA:
+		v.DrawRGBA(x, y, typ, rect)
+	}
+}
+
+// draw_sub_image writes the |rect| of |src| at (x, y) in the user space.
+func (v *vector_context_t) draw_sub_image(x, y int, src image.Image, rect image.Rectangle) {
+	sub, ok := src.(interface {
+		SubImage(r image.Rectangle) image.Image
+	})
+	if !ok {
+		return
+	}
+	img := CanvasFromImage(sub.SubImage(rect.Intersect(src.Bounds())))
+	v.draw_image(img, TranslateMatrix(float64(x), float64(y)), v.global_alpha)
+}
+
+// DrawAlpha writes black through the |rect| of |src| at (x, y).
+func (v *vector_context_t) DrawAlpha(x, y int, src *image.Alpha, rect image.Rectangle) {
+	v.draw_sub_image(x, y, src, rect)
+}
+
+func (v *vector_context_t) DrawNRGBA(x, y int, src *image.NRGBA, rect image.Rectangle) {
+	v.draw_sub_image(x, y, src, rect)
+}
+
+func (v *vector_context_t) DrawRGBA(x, y int, src *image.RGBA, rect image.Rectangle) {
+	v.draw_sub_image(x, y, src, rect)
+}
+
+// DrawCanvas writes the |rect| of |src| at (x, y) without the global alpha.
+// It's composited over the page, not copied like the blit of CanvasContext.
+func (v *vector_context_t) DrawCanvas(x, y int, src *Canvas, rect image.Rectangle) {
+	v.draw_image(v.image_of(src, rect), TranslateMatrix(float64(x), float64(y)), 1)
+}
+
+func (v *vector_context_t) AlphaBlend(x, y int, src *Canvas, rect image.Rectangle) {
+	v.draw_image(v.image_of(src, rect), TranslateMatrix(float64(x), float64(y)), v.global_alpha)
+}
+
+// DrawStretch writes the |src_rect| of |src| scaled to |dst_rect|, the
+// viewer resamples it by the image filter, smooth or not.
+func (v *vector_context_t) DrawStretch(dst_rect image.Rectangle, src *Canvas, src_rect image.Rectangle) {
+	src_rect = src_rect.Intersect(src.LocalBounds())
+	if dst_rect.Empty() || src_rect.Empty() {
+		return
+	}
+	m := TranslateMatrix(float64(dst_rect.Min.X), float64(dst_rect.Min.Y)).
+		Multiply(ScaleMatrix(float64(dst_rect.Dx())/float64(src_rect.Dx()),
+			float64(dst_rect.Dy())/float64(src_rect.Dy())))
+	v.draw_image(v.image_of(src, src_rect), m, v.global_alpha)
+}
+
+func (v *vector_context_t) DrawNinePatch(np *NinePatch, rect image.Rectangle) {
+	draw_nine_patch(v, np, rect)
+}
+
+func (v *vector_context_t) DrawPicture(p *Picture) {
+	p.Replay(v, IdentityMatrix(), p.cull)
+}
+
+// DrawShadow writes the shadow as an image, rasterized like CanvasContext.
+func (v *vector_context_t) DrawShadow(rect image.Rectangle, radii CornerRadii, shadow Shadow) {
+	if rect.Empty() {
+		return
+	}
+	shape, pt := shadow_canvas(rect, radii, shadow, v.transform.inverse_bounds(v.bounds))
+	if shape != nil {
+		v.AlphaBlend(pt.X, pt.Y, shape, shape.LocalBounds())
+	}
+}
+
+func (v *vector_context_t) FillRect(rect image.Rectangle) {
+	if rect.Empty() {
+		return
+	}
+	v.fill_path(v.rect_path(rect, 0), false, v.paint_of(v.fill_color, v.fill_paint), "")
+}
+
+// StrokeRect strokes the 1 pixel outline through the pixel centers like
+// CanvasContext, with the butt caps and the miter joins.
+func (v *vector_context_t) StrokeRect(rect image.Rectangle) {
+	v.SaveState()
+	defer v.RestoreState()
+
+	v.line_width, v.line_cap, v.line_join = 1, LineCapButt, LineJoinMiter
+	v.stroke_path(v.rect_path(image.Rectangle{rect.Min, rect.Max.Add(image.Pt(1, 1))}, -0.5))
+}
+
+func (v *vector_context_t) FillRoundedRect(rect image.Rectangle, radii CornerRadii) {
+	v.with_path(func() { add_rounded_rect(v, rect, radii) }, v.Fill)
+}
+
+func (v *vector_context_t) StrokeRoundedRect(rect image.Rectangle, radii CornerRadii) {
+	v.with_path(func() { add_rounded_rect(v, rect, radii) }, v.Stroke)
+}
+
+func (v *vector_context_t) FillEllipse(cx, cy, rx, ry float64) {
+	v.with_path(func() { add_ellipse(v, cx, cy, rx, ry) }, v.Fill)
+}
+
+func (v *vector_context_t) StrokeEllipse(cx, cy, rx, ry float64) {
+	v.with_path(func() { add_ellipse(v, cx, cy, rx, ry) }, v.Stroke)
+}
+
+func (v *vector_context_t) Arc(cx, cy, rx, ry, start, sweep float64) {
+	v.with_path(func() { add_arc(v, cx, cy, rx, ry, start, sweep, false) }, v.Stroke)
+}
+
+func (v *vector_context_t) Pie(cx, cy, rx, ry, start, sweep float64) {
+	v.with_path(func() { add_pie(v, cx, cy, rx, ry, start, sweep) }, v.Fill)
+}
+
+func (v *vector_context_t) StrokePie(cx, cy, rx, ry, start, sweep float64) {
+	v.with_path(func() { add_pie(v, cx, cy, rx, ry, start, sweep) }, v.Stroke)
+}
+
+func (v *vector_context_t) Polygon(points []image.Point) {
+	v.with_path(func() { add_polyline(v, points, true) }, v.Fill)
+}
+
+func (v *vector_context_t) StrokePolygon(points []image.Point) {
+	v.with_path(func() { add_polyline(v, points, true) }, v.Stroke)
+}
+
+func (v *vector_context_t) Polyline(points []image.Point) {
+	v.with_path(func() { add_polyline(v, points, false) }, v.Stroke)
+}
+
+// walk_path calls |fn| with the opcode and the points of every entry of
+// |path|, 0 for the start of a sub path, and 1, 2 or 3 for the lines, the
+// quadratic and the cubic curves. |closed| is whether the entry is the last
+// of a sub path closed by ClosePath.
+func walk_path(path freetype.Path, fn func(op int, pts []freetype.RastPoint, closed bool)) {
+	var pts [3]freetype.RastPoint
+	start, end := 0, 0
+	for i := 0; i < len(path); {
+		op := int(path[i])
+		n := op
+		if op == 0 {
+			n = 1
+			// Find the end of the sub path.
+			start = i
+			for end = i + 4; end < len(path) && path[end] != 0; {
+				end += int(path[end])*2 + 2
+			}
+		}
+		for k := 0; k < n; k++ {
+			pts[k] = freetype.RastPoint{X: path[i+1+2*k], Y: path[i+2+2*k]}
+		}
+		i += n*2 + 2
+		fn(op, pts[:n], i == end && path.Closed(start))
+	}
+}
+
+// translate_path returns the copy of |path| moved by (dx, dy).
+func translate_path(path freetype.Path, dx, dy freetype.Fix32) freetype.Path {
+	p := make(freetype.Path, 0, len(path))
+	walk_path(path, func(op int, pts []freetype.RastPoint, closed bool) {
+		for k := range pts {
+			pts[k].X, pts[k].Y = pts[k].X+dx, pts[k].Y+dy
+		}
+		switch op {
+		case 0:
+			p.Start(pts[0])
+		case 1:
+			p.Add1(pts[0])
+		case 2:
+			p.Add2(pts[0], pts[1])
+		case 3:
+			p.Add3(pts[0], pts[1], pts[2])
+		}
+		if closed {
+			p.Close()
+		}
+	})
+	return p
+}
+
+// path_bounds returns the bounds of the points of |path| outset by |outset|.
+func path_bounds(path freetype.Path, outset float64) image.Rectangle {
+	x0, y0 := math.Inf(1), math.Inf(1)
+	x1, y1 := math.Inf(-1), math.Inf(-1)
+	walk_path(path, func(op int, pts []freetype.RastPoint, closed bool) {
+		for _, pt := range pts {
+			x, y := float64(pt.X)/256, float64(pt.Y)/256
+			x0, y0 = math.Min(x0, x), math.Min(y0, y)
+			x1, y1 = math.Max(x1, x), math.Max(y1, y)
+		}
+	})
+	if x0 > x1 {
+		return image.Rectangle{}
+	}
+	return image.Rect(int(math.Floor(x0-outset)), int(math.Floor(y0-outset)),
+		int(math.Ceil(x1+outset)), int(math.Ceil(y1+outset)))
+}
@@ -26,6 +26,9 @@ type BaseView struct {
 
 	// layout system
 	layouter Layouter
+
+	// The shadow drawn by the parent behind the bounds, nil for none.
+	shadow *Shadow
 }
 
 func NewBaseView() *BaseView {
@@ -210,6 +213,15 @@ func (v *BaseView) SetLayouter(layouter Layouter) {
 	v.layouter = layouter
 }
 
+func (v *BaseView) Shadow() *Shadow {
+	return v.shadow
+}
+
+// SetShadow sets the shadow behind the view, or removes it if |shadow| is nil.
+func (v *BaseView) SetShadow(shadow *Shadow) {
+	v.shadow = shadow
+}
+
 func (v *BaseView) OnDraw(event *DrawEvent) {
 	if v.delegate == nil {
 		return
@@ -228,6 +240,34 @@ func (v *BaseView) OnMouseLeave(event *MouseEvent) {
 	log.Printf("BaseView.OnMouseLeave()")
 }
 
+func (v *BaseView) OnMouseDown(event *MouseEvent) {
+	if v.delegate == nil {
+		return
+	}
+	v.delegate.OnMouseDown(event)
+}
+
+func (v *BaseView) OnMouseUp(event *MouseEvent) {
+	if v.delegate == nil {
+		return
+	}
+	v.delegate.OnMouseUp(event)
+}
+
+func (v *BaseView) OnKeyDown(event *KeyEvent) {
+	if v.delegate == nil {
+		return
+	}
+	v.delegate.OnKeyDown(event)
+}
+
+func (v *BaseView) OnKeyUp(event *KeyEvent) {
+	if v.delegate == nil {
+		return
+	}
+	v.delegate.OnKeyUp(event)
+}
+
 func (v *BaseView) SetUIMap(ui UIMap) {
 	v.uimap = ui
 }
@@ -270,6 +310,10 @@ type BaseViewDelegate interface {
 type base_view_delegate_t struct {
 	on_mouse_enter func(*MouseEvent)
 	on_mouse_leave func(*MouseEvent)
+	on_mouse_down  func(*MouseEvent)
+	on_mouse_up    func(*MouseEvent)
+	on_key_down    func(*KeyEvent)
+	on_key_up      func(*KeyEvent)
 	on_draw        func(*DrawEvent)
 }
 
@@ -294,6 +338,34 @@ func (slf *base_view_delegate_t) InitWithUIMap(delegate UIMap) *base_view_delega
 		}
 	}
 
+	p = delegate["on_mouse_down"]
+	if p != nil {
+		if on_mouse_down, ok := p.(func(*MouseEvent)); ok {
+			slf.on_mouse_down = on_mouse_down
+		}
+	}
+
+	p = delegate["on_mouse_up"]
+	if p != nil {
+		if on_mouse_up, ok := p.(func(*MouseEvent)); ok {
+			slf.on_mouse_up = on_mouse_up
+		}
+	}
+
+	p = delegate["on_key_down"]
+	if p != nil {
+		if on_key_down, ok := p.(func(*KeyEvent)); ok {
+			slf.on_key_down = on_key_down
+		}
+	}
+
+	p = delegate["on_key_up"]
+	if p != nil {
+		if on_key_up, ok := p.(func(*KeyEvent)); ok {
+			slf.on_key_up = on_key_up
+		}
+	}
+
 	p = delegate["on_draw"]
 	if p != nil {
 		if on_draw, ok := p.(func(*DrawEvent)); ok {
@@ -318,6 +390,34 @@ func (d *base_view_delegate_t) OnMouseLeave(event *MouseEvent) {
 	d.on_mouse_leave(event)
 }
 
+func (d *base_view_delegate_t) OnMouseDown(event *MouseEvent) {
+	if d.on_mouse_down == nil {
+		return
+	}
+	d.on_mouse_down(event)
+}
+
+func (d *base_view_delegate_t) OnMouseUp(event *MouseEvent) {
+	if d.on_mouse_up == nil {
+		return
+	}
+	d.on_mouse_up(event)
+}
+
+func (d *base_view_delegate_t) OnKeyDown(event *KeyEvent) {
+	if d.on_key_down == nil {
+		return
+	}
+	d.on_key_down(event)
+}
+
+func (d *base_view_delegate_t) OnKeyUp(event *KeyEvent) {
+	if d.on_key_up == nil {
+		return
+	}
+	d.on_key_up(event)
+}
+
 func (d *base_view_delegate_t) OnDraw(event *DrawEvent) {
 	if d.on_draw == nil {
 		return
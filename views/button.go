@@ -11,14 +11,16 @@ import (
 
 type Button struct {
 	BaseView
-	image_normal *Canvas `view:"image_normal"`
+	image_normal *NinePatch `view:"image_normal"`
 }
 
 func NewButton() *Button {
 	var b = new(Button)
 	b.SetID("button")
-	b.image_normal = resc.FindCanvasByID("button_normal")
-	b.SetBounds(b.image_normal.Bounds())
+	b.image_normal = resc.FindNinePatchByID("button_normal_9")
+	if b.image_normal != nil {
+		b.SetBounds(b.image_normal.Canvas.LocalBounds())
+	}
 	return b
 }
 
@@ -26,5 +28,5 @@ func (b *Button) OnDraw(event *DrawEvent) {
 	// event.Canvas.DrawCanvas(0, 0, b.image_normal)
 	// event.Canvas.DrawColor(0, 0, 250)
 	ctxt := GlobalDrawContext()
-	ctxt.AlphaBlend(0, 0, b.image_normal, b.image_normal.LocalBounds())
+	ctxt.DrawNinePatch(b.image_normal, b.LocalBounds())
 }
@@ -4,12 +4,60 @@ import (
 	"gwk/vango"
 )
 
-var g_draw_context *vango.Context
+// g_canvas_context draws the views into the canvases of the host windows.
+var g_canvas_context *vango.CanvasContext
+
+// g_draw_context is the context the views draw with in OnDraw.
+var g_draw_context vango.Context
 
 func init_draw_context() {
-	g_draw_context = vango.NewContext()
+	g_canvas_context = vango.NewContext()
+	g_draw_context = g_canvas_context
 }
 
-func GlobalDrawContext() *vango.Context {
+// GlobalDrawContext returns the context to draw with in OnDraw. It's the
+// canvas context of the host windows, or the one of ExportView while a view
+// is exported.
+func GlobalDrawContext() vango.Context {
 	return g_draw_context
 }
+
+// ExportView draws |v| and its children by |ctxt|, like a vango.SVGWriter or
+// a vango.PDFWriter, with the top left of |v| at the origin of the user space
+// of |ctxt|. The views draw with |ctxt| in OnDraw meanwhile, the draw events
+// have no canvas and the dirty rect is the whole view. Unlike the draws into
+// the canvases, the clip set in OnDraw doesn't clip the children.
+func ExportView(v View, ctxt vango.Context) {
+	old := g_draw_context
+	g_draw_context = ctxt
+	defer func() { g_draw_context = old }()
+
+	export_view(v, ctxt)
+}
+
+func export_view(v View, ctxt vango.Context) {
+	bounds := v.LocalBounds()
+	depth := ctxt.StateDepth()
+	ctxt.SaveState()
+	ctxt.ClipRect(bounds)
+	v.OnDraw(&DrawEvent{Owner: v, DirtyRect: bounds})
+	// The states left saved by OnDraw are undone too.
+	for ctxt.StateDepth() > depth {
+		ctxt.RestoreState()
+	}
+
+	ctxt.SaveState()
+	defer ctxt.RestoreState()
+	ctxt.ClipRect(bounds)
+	for _, child := range v.Children() {
+		// The shadow goes behind the child, over the siblings before it.
+		if shadow := child.Shadow(); shadow != nil {
+			ctxt.DrawShadow(child.Bounds(), vango.CornerRadii{}, *shadow)
+		}
+
+		ctxt.SaveState()
+		ctxt.Translate(float64(child.X()), float64(child.Y()))
+		export_view(child, ctxt)
+		ctxt.RestoreState()
+	}
+}
@@ -149,19 +149,19 @@ func (t *priority_task_queue_t) Pop() {
 	for {
 		l := p * 2
 
-		if l >= n {
+		if l > n {
 			break
 		}
 
 		// compare the right with left.
 		i, r := l, l+1
-		if r < n && t.higher_priority_than(r, l) {
+		if r <= n && t.higher_priority_than(r, l) {
 			i = r
 		}
 
 		// compare the highter child with parent.
 		if t.higher_priority_than(i, p) {
-			t.data[p], t.data[l] = t.data[l], t.data[p]
+			t.data[p], t.data[i] = t.data[i], t.data[p]
 			p = i
 		} else {
 			break
@@ -261,8 +261,9 @@ func (e *EventLoop) DoWork() bool {
 
 func (e *EventLoop) DoDelayedWork(next_delayed_work_time *time.Time) bool {
 	if e.delayed_task_queue.Empty() {
+		// A zero |next_delayed_work_time| means there is no delayed work.
 		e.recent_time = time.Now()
-		*next_delayed_work_time = e.recent_time
+		*next_delayed_work_time = time.Time{}
 		return false
 	}
 
@@ -277,7 +278,7 @@ func (e *EventLoop) DoDelayedWork(next_delayed_work_time *time.Time) bool {
 		// get a better view of Now();
 		e.recent_time = time.Now()
 		if next_run_time.After(e.recent_time) {
-			*next_delayed_work_time = e.recent_time
+			*next_delayed_work_time = next_run_time
 			return false
 		}
 	}
@@ -292,7 +293,7 @@ func (e *EventLoop) DoDelayedWork(next_delayed_work_time *time.Time) bool {
 	// DeferOrRunPendingTask()
 	task.closure()
 
-	return false
+	return true
 }
 
 // ============================================================================
@@ -2,6 +2,7 @@ package views
 
 import (
 	"testing"
+	"time"
 )
 
 func TestTaskQueue(t *testing.T) {
@@ -34,4 +35,63 @@ func TestPriorityQueue(t *testing.T) {
 	if pq.Count() != 0 {
 		t.Fatalf("priority task queue push/pop failed")
 	}
+
+	// The tasks pushed out of order are popped by their times.
+	now := time.Now()
+	for _, i := range []int{3, 1, 4, 1, 5, 9, 2, 6, 5, 3} {
+		pq.Push(NewTask(nil, now.Add(time.Duration(i)*time.Second)))
+	}
+	want := []int{1, 1, 2, 3, 3, 4, 5, 5, 6, 9}
+	for _, i := range want {
+		task := pq.Top()
+		if task == nil {
+			t.Fatalf("priority task queue is empty, want the task at %ds", i)
+		}
+		if got := task.delayed_run_time.Sub(now); got != time.Duration(i)*time.Second {
+			t.Errorf("priority task queue pop: got the task at %v, want %ds", got, i)
+		}
+		pq.Pop()
+	}
+	if !pq.Empty() || pq.Count() != 0 {
+		t.Fatalf("priority task queue keeps %d tasks", pq.Count())
+	}
+}
+
+func TestDoDelayedWork(t *testing.T) {
+	e := &EventLoop{}
+	e.init()
+
+	var ran []int
+	now := time.Now()
+	e.delayed_task_queue.Push(NewTask(func() { ran = append(ran, 2) }, now.Add(-time.Second)))
+	e.delayed_task_queue.Push(NewTask(func() { ran = append(ran, 1) }, now.Add(-2*time.Second)))
+	e.delayed_task_queue.Push(NewTask(func() { ran = append(ran, 3) }, now.Add(time.Hour)))
+
+	// The tasks due are run one at a time, the earliest first.
+	var next time.Time
+	if !e.DoDelayedWork(&next) {
+		t.Fatalf("DoDelayedWork: got false after running a task")
+	}
+	if len(ran) != 1 || ran[0] != 1 || !next.Equal(now.Add(-time.Second)) {
+		t.Errorf("DoDelayedWork: ran %v, next at %v", ran, next.Sub(now))
+	}
+	if !e.DoDelayedWork(&next) {
+		t.Fatalf("DoDelayedWork: got false after running a task")
+	}
+	if len(ran) != 2 || ran[1] != 2 || !next.Equal(now.Add(time.Hour)) {
+		t.Errorf("DoDelayedWork: ran %v, next at %v", ran, next.Sub(now))
+	}
+
+	// The task not due yet is left for the next time.
+	if e.DoDelayedWork(&next) {
+		t.Errorf("DoDelayedWork: got true before the task is due")
+	}
+	if len(ran) != 2 || !next.Equal(now.Add(time.Hour)) {
+		t.Errorf("DoDelayedWork: ran %v, next at %v", ran, next.Sub(now))
+	}
+
+	e.delayed_task_queue.Pop()
+	if e.DoDelayedWork(&next) || !next.IsZero() {
+		t.Errorf("DoDelayedWork of no task: got the next at %v", next)
+	}
 }
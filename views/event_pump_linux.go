@@ -0,0 +1,191 @@
+package views
+
+//
+// The headless event pump. There is no native message queue on the headless
+// backend, so the synthetic events posted to a HostWindow are queued in
+// |g_native_event_queue| and the pump sleeps on go channels and timers.
+//
+
+import (
+	"sync"
+	"time"
+)
+
+type native_event_queue_t struct {
+	lock   sync.Mutex
+	events []*native_event_t
+	wakeup chan struct{}
+}
+
+func new_native_event_queue() *native_event_queue_t {
+	q := new(native_event_queue_t)
+	q.wakeup = make(chan struct{}, 1)
+	return q
+}
+
+// Post may be called from any goroutine.
+func (q *native_event_queue_t) Post(event *native_event_t) {
+	q.lock.Lock()
+	q.events = append(q.events, event)
+	q.lock.Unlock()
+
+	select {
+	case q.wakeup <- struct{}{}:
+	default: // the pump is already woken up.
+	}
+}
+
+func (q *native_event_queue_t) Pop() *native_event_t {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if len(q.events) == 0 {
+		return nil
+	}
+
+	event := q.events[0]
+	q.events[0] = nil
+	q.events = q.events[1:]
+	return event
+}
+
+var g_native_event_queue = new_native_event_queue()
+
+type ui_event_pump_t struct {
+	delayed_work_time time.Time
+	delegate          event_pump_delegate_t
+	should_quit       bool
+	have_work         chan struct{}
+}
+
+func new_ui_event_pump(delegate event_pump_delegate_t) *ui_event_pump_t {
+	u := new(ui_event_pump_t)
+	u.delegate = delegate
+	u.should_quit = false
+	u.have_work = make(chan struct{}, 1)
+	return u
+}
+
+//
+// event_pump_t methods
+//
+func (u *ui_event_pump_t) Run() {
+	u.run(false)
+}
+
+func (u *ui_event_pump_t) Quit() {
+	u.should_quit = true
+	u.ScheduleWork()
+}
+
+func (u *ui_event_pump_t) ScheduleWork() {
+	select {
+	case u.have_work <- struct{}{}:
+	default: // someone else continued the pumping.
+	}
+}
+
+func (u *ui_event_pump_t) ScheduleDelayedWork(delayed_work_time time.Time) {
+	// We are always called on the pump goroutine, so the new time will be
+	// picked up by the next wait_for_work.
+	u.delayed_work_time = delayed_work_time
+}
+
+// RunUntilIdle processes all the pending events and tasks, then returns
+// instead of waiting for more work. Delayed tasks that are not ready yet are
+// left in the queue.
+func (u *ui_event_pump_t) RunUntilIdle() {
+	u.run(true)
+}
+
+func (u *ui_event_pump_t) run(until_idle bool) {
+	defer func() { u.should_quit = false }()
+
+	for {
+		more_work_is_plausible, more := false, false
+		more = u.process_next_ui_event()
+		more_work_is_plausible = more_work_is_plausible || more
+		if u.should_quit {
+			break
+		}
+
+		more = u.delegate.DoWork()
+		more_work_is_plausible = more_work_is_plausible || more
+		if u.should_quit {
+			break
+		}
+
+		var next_delayed_work_time time.Time
+		more = u.delegate.DoDelayedWork(&next_delayed_work_time)
+		more_work_is_plausible = more_work_is_plausible || more
+		u.delayed_work_time = next_delayed_work_time
+		if u.should_quit {
+			break
+		}
+
+		if more_work_is_plausible {
+			continue
+		}
+
+		if until_idle {
+			break
+		}
+
+		// Wait (sleep) until we have work to do again.
+		u.wait_for_work()
+	}
+}
+
+func (u *ui_event_pump_t) get_current_delay() time.Duration {
+	if u.delayed_work_time.IsZero() {
+		return -1
+	}
+
+	delay := u.delayed_work_time.Sub(time.Now())
+	if delay < 0 {
+		delay = 0
+	}
+
+	return delay
+}
+
+func (u *ui_event_pump_t) wait_for_work() {
+	delay := u.get_current_delay()
+	if delay == 0 {
+		return
+	}
+
+	// A nil channel blocks forever, so we only wake up for the delayed work if
+	// we have some.
+	var timeout <-chan time.Time
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case <-u.have_work:
+	case <-g_native_event_queue.wakeup:
+	case <-timeout:
+	}
+}
+
+func (u *ui_event_pump_t) process_next_ui_event() bool {
+	event := g_native_event_queue.Pop()
+	if event == nil {
+		return false
+	}
+
+	if event.window != nil {
+		event.window.on_native_event(event)
+	}
+
+	return true
+}
+
+// RunUntilIdle runs the headless ui event loop until there is nothing left to
+// do right now. It's useful to drive the synthetic events in tests.
+func (u *UIEventLoop) RunUntilIdle() {
+	u.pump.(*ui_event_pump_t).RunUntilIdle()
+}
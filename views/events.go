@@ -19,9 +19,17 @@ type DrawEvent struct {
 	DirtyRect Rectangle
 }
 
+const (
+	MouseButtonNone = iota
+	MouseButtonLeft
+	MouseButtonMiddle
+	MouseButtonRight
+)
+
 type MouseEvent struct {
 	Owner    View
 	Location Point
+	Button   int
 }
 
 func NewMouseEvent(pt Point) *MouseEvent {
@@ -29,3 +37,16 @@ func NewMouseEvent(pt Point) *MouseEvent {
 	mouse_event.Location = pt
 	return mouse_event
 }
+
+type KeyEvent struct {
+	Owner   View
+	KeyCode int
+	Char    rune
+}
+
+func NewKeyEvent(key_code int, char rune) *KeyEvent {
+	key_event := new(KeyEvent)
+	key_event.KeyCode = key_code
+	key_event.Char = char
+	return key_event
+}
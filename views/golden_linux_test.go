@@ -0,0 +1,38 @@
+package views
+
+import (
+	"gwk/vango/vangotest"
+	"testing"
+)
+
+func TestGoldenWidgets(t *testing.T) {
+	host_view := new_test_host_view(UIMap{
+		"type": "base_view",
+		"children": []UIMap{
+			{
+				"type":   "toolbar",
+				"left":   0,
+				"top":    0,
+				"width":  200,
+				"height": 24,
+			},
+			{
+				"type":   "panel",
+				"left":   10,
+				"top":    30,
+				"width":  110,
+				"height": 64,
+			},
+			{
+				"type":   "image_view",
+				"left":   130,
+				"top":    30,
+				"width":  60,
+				"height": 64,
+				"color":  0x3070b0,
+			},
+		},
+	})
+
+	vangotest.AssertGolden(t, "widgets", host_view.Screen(), 2)
+}
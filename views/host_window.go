@@ -9,15 +9,23 @@ import (
 	"image"
 )
 
+// OnHostPaint draws the area the root view scheduled to draw, then copies the
+// |dirty_rect| of its canvas to the window.
 func (h *HostWindow) OnHostPaint(native_context NativeContext, dirty_rect image.Rectangle) {
+	h.root_view.draw_dirty()
+
 	native_canvas := NewNativeCanvas(dirty_rect)
 	defer native_canvas.Release()
 
 	draw_rect := dirty_rect.Sub(dirty_rect.Min)
-	draw_context := GlobalDrawContext()
+	draw_context := g_canvas_context
+	draw_context.SaveState()
+	draw_context.ResetTransform()
+	draw_context.ResetClip()
 	draw_context.SetCanvas(native_canvas.Canvas)
 	draw_context.DrawCanvas(draw_rect.Min.X, draw_rect.Min.Y,
 		h.root_view.Canvas(), dirty_rect)
+	draw_context.RestoreState()
 
 	native_canvas.BlitToNativeContext(native_context, dirty_rect.Min.X,
 		dirty_rect.Min.Y, &draw_rect)
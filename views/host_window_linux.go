@@ -0,0 +1,221 @@
+// Copyright 2014 By Jshi. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package views
+
+import (
+	. "gwk/vango"
+	"image"
+)
+
+// The default client size when the HostWindow is created with empty bounds.
+// It's the replacement of CW_USEDEFAULT on windows.
+const (
+	kHostWindowDefaultWidth  = 800
+	kHostWindowDefaultHeight = 600
+)
+
+// The kinds of the synthetic events. They play the role of the WM_* messages
+// on windows.
+const (
+	kNativeMouseMove = iota
+	kNativeMouseDown
+	kNativeMouseUp
+	kNativeKeyDown
+	kNativeKeyUp
+	kNativeSize
+	kNativePaint
+)
+
+type native_event_t struct {
+	window   *HostWindow
+	kind     int
+	point    image.Point
+	button   int
+	key_code int
+	char     rune
+}
+
+// HostWindow is the headless host window. It renders the RootView into an
+// in-memory canvas (the screen), and receives the synthetic events posted by
+// the Post* methods.
+type HostWindow struct {
+	root_view     *RootView
+	bounds        image.Rectangle
+	screen        *Canvas
+	dirty         Region
+	paint_pending bool
+	visible       bool
+}
+
+func NewHostWindow(bounds image.Rectangle) *HostWindow {
+	var hw HostWindow
+	hw.Init(bounds)
+	return &hw
+}
+
+func (hw *HostWindow) Init(bounds image.Rectangle) {
+	if bounds.Empty() {
+		bounds = image.Rect(bounds.Min.X, bounds.Min.Y,
+			bounds.Min.X+kHostWindowDefaultWidth,
+			bounds.Min.Y+kHostWindowDefaultHeight)
+	}
+
+	hw.bounds = bounds
+	hw.screen = NewCanvas(bounds.Dx(), bounds.Dy())
+}
+
+func (h *HostWindow) on_native_event(event *native_event_t) {
+	switch event.kind {
+	case kNativeMouseMove:
+		if h.root_view != nil {
+			h.root_view.DispatchMouseMove(event.point)
+		}
+	case kNativeMouseDown:
+		if h.root_view != nil {
+			h.root_view.DispatchMouseDown(event.point, event.button)
+		}
+	case kNativeMouseUp:
+		if h.root_view != nil {
+			h.root_view.DispatchMouseUp(event.point, event.button)
+		}
+	case kNativeKeyDown:
+		if h.root_view != nil {
+			h.root_view.DispatchKeyDown(event.key_code, event.char)
+		}
+	case kNativeKeyUp:
+		if h.root_view != nil {
+			h.root_view.DispatchKeyUp(event.key_code, event.char)
+		}
+	case kNativeSize:
+		h.on_size(event.point.X, event.point.Y)
+	case kNativePaint:
+		h.on_paint()
+	}
+}
+
+func (h *HostWindow) on_size(width, height int) {
+	if width <= 0 || height <= 0 {
+		return
+	}
+
+	h.bounds.Max = h.bounds.Min.Add(image.Pt(width, height))
+	if h.screen.W() != width || h.screen.H() != height {
+		h.screen = NewCanvas(width, height)
+	}
+
+	rc := h.ClientBounds()
+	if h.root_view != nil {
+		h.root_view.SetBounds(rc)
+		h.root_view.DispatchLayout()
+	}
+
+	h.InvalidateRect(rc)
+}
+
+func (h *HostWindow) on_paint() {
+	h.paint_pending = false
+
+	dirty := h.dirty.IntersectRect(h.ClientBounds())
+	h.dirty = Region{}
+	if dirty.Empty() || h.root_view == nil {
+		return
+	}
+
+	for _, dirty_rect := range dirty.Rects() {
+		h.OnHostPaint(NativeContext(h.screen), dirty_rect)
+	}
+}
+
+func (h *HostWindow) post_native_event(event *native_event_t) {
+	event.window = h
+	g_native_event_queue.Post(event)
+}
+
+// PostMouseMove queues a synthetic mouse move at |pt| in client coordinates.
+// The Post* methods may be called from any goroutine. The events are
+// dispatched when the ui event loop runs.
+func (h *HostWindow) PostMouseMove(pt image.Point) {
+	h.post_native_event(&native_event_t{kind: kNativeMouseMove, point: pt})
+}
+
+func (h *HostWindow) PostMouseDown(pt image.Point, button int) {
+	h.post_native_event(&native_event_t{kind: kNativeMouseDown, point: pt,
+		button: button})
+}
+
+func (h *HostWindow) PostMouseUp(pt image.Point, button int) {
+	h.post_native_event(&native_event_t{kind: kNativeMouseUp, point: pt,
+		button: button})
+}
+
+func (h *HostWindow) PostKeyDown(key_code int, char rune) {
+	h.post_native_event(&native_event_t{kind: kNativeKeyDown,
+		key_code: key_code, char: char})
+}
+
+func (h *HostWindow) PostKeyUp(key_code int, char rune) {
+	h.post_native_event(&native_event_t{kind: kNativeKeyUp,
+		key_code: key_code, char: char})
+}
+
+// PostResize queues a synthetic resize of the client area to width x height.
+func (h *HostWindow) PostResize(width, height int) {
+	h.post_native_event(&native_event_t{kind: kNativeSize,
+		point: image.Pt(width, height)})
+}
+
+func (h *HostWindow) Run() {
+	MainUIEventLoop().Run()
+}
+
+func (hw *HostWindow) Show() {
+	if hw.visible {
+		return
+	}
+	hw.visible = true
+
+	// Like ShowWindow, the first show sizes the window then paints it.
+	rc := hw.ClientBounds()
+	hw.PostResize(rc.Dx(), rc.Dy())
+}
+
+func (hw *HostWindow) Visible() bool {
+	return hw.visible
+}
+
+func (hw *HostWindow) ClientBounds() image.Rectangle {
+	return hw.bounds.Sub(hw.bounds.Min)
+}
+
+func (hw *HostWindow) Bounds() image.Rectangle {
+	return hw.bounds
+}
+
+// Screen returns the canvas the window is painted to. It's what would be on
+// the screen with a native window system.
+func (hw *HostWindow) Screen() *Canvas {
+	return hw.screen
+}
+
+func (h *HostWindow) UpdateWindow() {
+	if h.paint_pending {
+		h.on_paint()
+	}
+}
+
+func (h *HostWindow) InvalidateRect(r image.Rectangle) {
+	r = r.Intersect(h.ClientBounds())
+	if r.Empty() {
+		return
+	}
+
+	h.dirty = h.dirty.UnionRect(r)
+
+	// Coalesce the paints like WM_PAINT does.
+	if !h.paint_pending {
+		h.paint_pending = true
+		h.post_native_event(&native_event_t{kind: kNativePaint})
+	}
+}
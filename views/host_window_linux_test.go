@@ -0,0 +1,318 @@
+package views
+
+import (
+	"bytes"
+	"gwk/vango"
+	"image"
+	"strings"
+	"testing"
+)
+
+func new_test_host_view(ui UIMap) *HostView {
+	InitViews()
+
+	host_view := NewHostView(image.Rect(0, 0, 200, 100))
+	host_view.RootView.AddChild(MockUp(ui))
+	host_view.Show()
+	MainUIEventLoop().RunUntilIdle()
+	return host_view
+}
+
+func TestHeadlessHostWindowPaint(t *testing.T) {
+	host_view := new_test_host_view(UIMap{
+		"type": "base_view",
+		"children": []UIMap{
+			{
+				"type":   "image_view",
+				"left":   10,
+				"top":    10,
+				"width":  50,
+				"height": 50,
+				"color":  0x102030,
+			},
+		},
+	})
+
+	screen := host_view.Screen()
+	if screen.W() != 200 || screen.H() != 100 {
+		t.Fatalf("screen size: got %vx%v, want 200x100", screen.W(), screen.H())
+	}
+
+	i := screen.PixOffset(20, 20)
+	got := screen.Pix()[i : i+4]
+	if got[0] != 0x30 || got[1] != 0x20 || got[2] != 0x10 {
+		t.Errorf("pixel inside image_view: got %v", got)
+	}
+
+	i = screen.PixOffset(100, 80)
+	got = screen.Pix()[i : i+4]
+	if got[0] != 0 || got[1] != 0 || got[2] != 0 {
+		t.Errorf("pixel outside image_view: got %v", got)
+	}
+}
+
+func TestHeadlessHostWindowEvents(t *testing.T) {
+	var entered, clicked bool
+	var char rune
+
+	host_view := new_test_host_view(UIMap{
+		"type": "base_view",
+		"children": []UIMap{
+			{
+				"type":   "image_view",
+				"left":   10,
+				"top":    10,
+				"width":  50,
+				"height": 50,
+				"delegate": UIMap{
+					"on_mouse_enter": func(event *MouseEvent) {
+						entered = true
+					},
+					"on_mouse_down": func(event *MouseEvent) {
+						clicked = event.Button == MouseButtonLeft
+					},
+					"on_key_down": func(event *KeyEvent) {
+						char = event.Char
+					},
+				},
+			},
+		},
+	})
+
+	host_view.PostMouseMove(image.Pt(20, 20))
+	host_view.PostMouseDown(image.Pt(20, 20), MouseButtonLeft)
+	host_view.PostKeyDown('A', 'a')
+	MainUIEventLoop().RunUntilIdle()
+
+	if !entered {
+		t.Errorf("on_mouse_enter isn't called")
+	}
+	if !clicked {
+		t.Errorf("on_mouse_down isn't called with the left button")
+	}
+	if char != 'a' {
+		t.Errorf("on_key_down: got %q, want 'a'", char)
+	}
+
+	host_view.PostResize(300, 150)
+	MainUIEventLoop().RunUntilIdle()
+
+	if got := host_view.RootView.Bounds(); got != image.Rect(0, 0, 300, 150) {
+		t.Errorf("root view bounds after resize: got %v", got)
+	}
+	if screen := host_view.Screen(); screen.W() != 300 || screen.H() != 150 {
+		t.Errorf("screen size after resize: got %vx%v", screen.W(), screen.H())
+	}
+}
+
+func TestHeadlessEventPumpDelayedTask(t *testing.T) {
+	loop := MainUIEventLoop()
+
+	var order []int
+	loop.PostDelayedTask(func() {
+		order = append(order, 2)
+		loop.pump.Quit()
+	}, 20)
+	loop.PostDelayedTask(func() { order = append(order, 1) }, 10)
+	loop.Run()
+
+	if len(order) != 2 || order[0] != 1 || order[1] != 2 {
+		t.Errorf("delayed tasks run order: got %v, want [1 2]", order)
+	}
+}
+
+func TestHeadlessClipChildren(t *testing.T) {
+	host_view := new_test_host_view(UIMap{
+		"type": "base_view",
+		"delegate": UIMap{
+			"on_draw": func(event *DrawEvent) {
+				GlobalDrawContext().ClipRect(image.Rect(0, 0, 40, 40))
+			},
+		},
+		"children": []UIMap{
+			{
+				"type":   "image_view",
+				"left":   10,
+				"top":    10,
+				"width":  100,
+				"height": 50,
+				"color":  0x102030,
+			},
+		},
+	})
+
+	screen := host_view.Screen()
+	i := screen.PixOffset(20, 20)
+	if got := screen.Pix()[i : i+4]; got[0] != 0x30 {
+		t.Errorf("pixel inside the clip: got %v", got)
+	}
+	i = screen.PixOffset(60, 20)
+	if got := screen.Pix()[i : i+4]; got[0] != 0 {
+		t.Errorf("pixel outside the clip: got %v", got)
+	}
+}
+
+func TestHeadlessViewShadow(t *testing.T) {
+	host_view := new_test_host_view(UIMap{
+		"type": "base_view",
+		"delegate": UIMap{
+			"on_draw": func(event *DrawEvent) {
+				GlobalDrawContext().DrawColor(0xff, 0xff, 0xff)
+			},
+		},
+		"children": []UIMap{
+			{
+				"type":   "image_view",
+				"left":   10,
+				"top":    10,
+				"width":  50,
+				"height": 50,
+				"color":  0x102030,
+				"shadow": UIMap{"dx": 4, "dy": 4, "blur": 4, "alpha": 0xff},
+			},
+		},
+	})
+
+	if shadow := host_view.RootView.Children()[0].Children()[0].Shadow(); shadow == nil || shadow.DX != 4 || shadow.Blur != 4 {
+		t.Fatalf("the shadow isn't mocked up: got %v", shadow)
+	}
+
+	screen := host_view.Screen()
+	i := screen.PixOffset(40, 62)
+	if got := screen.Pix()[i : i+4]; got[0] > 0x80 {
+		t.Errorf("pixel in the shadow: got %v", got)
+	}
+	i = screen.PixOffset(20, 20)
+	if got := screen.Pix()[i : i+4]; got[0] != 0x30 {
+		t.Errorf("the shadow covers the view: got %v", got)
+	}
+	i = screen.PixOffset(100, 80)
+	if got := screen.Pix()[i : i+4]; got[0] != 0xff {
+		t.Errorf("pixel outside the shadow: got %v", got)
+	}
+}
+
+func TestExportView(t *testing.T) {
+	host_view := new_test_host_view(UIMap{
+		"type": "base_view",
+		"children": []UIMap{
+			{
+				"type":   "image_view",
+				"left":   10,
+				"top":    10,
+				"width":  50,
+				"height": 50,
+				"color":  0x102030,
+			},
+		},
+	})
+
+	var buf bytes.Buffer
+	w := vango.NewSVGWriter(&buf, 200, 100)
+	ExportView(host_view.RootView, w)
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if GlobalDrawContext() != vango.Context(g_canvas_context) {
+		t.Errorf("the draw context isn't restored")
+	}
+
+	// The color of image_view fills the page clipped by its bounds.
+	svg := buf.String()
+	for _, want := range []string{
+		`<path d="M10 10 L60 10 L60 60 L10 60 L10 10Z"/>`,
+		`clip-path="url(#c2)" fill="#102030" d="M0 0 L200 0 L200 100 L0 100"`,
+	} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("the svg has no %v:\n%s", want, svg)
+		}
+	}
+}
+
+func TestScheduleDrawRegion(t *testing.T) {
+	host_view := new_test_host_view(UIMap{
+		"type": "base_view",
+		"children": []UIMap{
+			{
+				"type":   "image_view",
+				"left":   0,
+				"top":    0,
+				"width":  200,
+				"height": 100,
+				"color":  0x102030,
+			},
+		},
+	})
+
+	// The invalidations at the opposite corners don't draw the area between
+	// them.
+	image_view := host_view.RootView.Children()[0].Children()[0].(*ImageView)
+	image_view.SetColorRGB(0x40, 0x50, 0x60)
+	image_view.ScheduleDrawInRect(image.Rect(0, 0, 10, 10))
+	image_view.ScheduleDrawInRect(image.Rect(190, 90, 200, 100))
+	MainUIEventLoop().RunUntilIdle()
+
+	screen := host_view.Screen()
+	for _, test := range []struct {
+		x, y int
+		r    byte
+	}{
+		{5, 5, 0x40},
+		{195, 95, 0x40},
+		{10, 10, 0x10},
+		{100, 50, 0x10},
+		{195, 5, 0x10},
+	} {
+		i := screen.PixOffset(test.x, test.y)
+		if r := screen.Pix()[i+2]; r != test.r {
+			t.Errorf("the pixel at (%v, %v): got r=%x, want %x", test.x, test.y, r, test.r)
+		}
+	}
+
+	// The dirty rect of a nested child is in its coordinates.
+	host_view = new_test_host_view(UIMap{
+		"type": "base_view",
+		"children": []UIMap{
+			{
+				"type":   "image_view",
+				"left":   100,
+				"top":    0,
+				"width":  100,
+				"height": 100,
+				"color":  0x708090,
+				"children": []UIMap{
+					{
+						"type":   "image_view",
+						"left":   50,
+						"top":    50,
+						"width":  40,
+						"height": 40,
+						"color":  0xa0b0c0,
+					},
+				},
+			},
+		},
+	})
+
+	nested := host_view.RootView.Children()[0].Children()[0].Children()[0].(*ImageView)
+	nested.SetColorRGB(0xd0, 0xe0, 0xf0)
+	nested.ScheduleDrawInRect(image.Rect(10, 10, 30, 30))
+	MainUIEventLoop().RunUntilIdle()
+
+	screen = host_view.Screen()
+	for _, test := range []struct {
+		x, y int
+		r    byte
+	}{
+		{170, 70, 0xd0},
+		{161, 61, 0xd0},
+		{155, 55, 0xa0},
+		{185, 85, 0xa0},
+		{120, 20, 0x70},
+	} {
+		i := screen.PixOffset(test.x, test.y)
+		if r := screen.Pix()[i+2]; r != test.r {
+			t.Errorf("the nested pixel at (%v, %v): got r=%x, want %x", test.x, test.y, r, test.r)
+		}
+	}
+}
@@ -5,12 +5,21 @@
 package views
 
 import (
+	. "gwk/vango"
+	"gwk/views/resc"
 	"image/color"
 )
 
+// ImageView draws the color, and the image scaled to the view if there is
+// one. FilterNearest is fast for the previews, FilterBicubic and
+// FilterLanczos are sharp for the final images. The image of an Svg resource
+// is rasterized at the size of the view.
 type ImageView struct {
 	BaseView
-	clr color.RGBA
+	clr      color.RGBA
+	image    *Canvas
+	image_id string
+	filter   Filter
 }
 
 func NewImageView() *ImageView {
@@ -18,6 +27,7 @@ func NewImageView() *ImageView {
 	v.SetID("image_view")
 	v.SetLayouter(v)
 	v.SetXYWH(0, 0, 50, 50)
+	v.filter = FilterBilinear
 	return v
 }
 
@@ -31,6 +41,24 @@ func (v *ImageView) MockUp(tbl UIMap) {
 		v.clr.B = byte(val & 0x0000ff)
 		v.clr.A = 0x00
 	}
+
+	if id, ok := tbl.String("image"); ok {
+		v.image = resc.FindCanvasByID(id)
+		v.image_id = id
+	}
+
+	if filter, ok := tbl.String("filter"); ok {
+		switch filter {
+		case "nearest":
+			v.filter = FilterNearest
+		case "bilinear":
+			v.filter = FilterBilinear
+		case "bicubic":
+			v.filter = FilterBicubic
+		case "lanczos":
+			v.filter = FilterLanczos
+		}
+	}
 }
 
 func (v *ImageView) Layout(parent View) {
@@ -40,9 +68,36 @@ func (v *ImageView) Layout(parent View) {
 func (v *ImageView) OnDraw(event *DrawEvent) {
 	ctxt := GlobalDrawContext()
 	ctxt.DrawColor(v.clr.R, v.clr.G, v.clr.B)
+	if v.image_id != "" {
+		if image := resc.FindCanvasByIDWithSize(v.image_id, v.W(), v.H()); image != nil {
+			v.image = image
+		}
+	}
+	if v.image != nil {
+		ctxt.SetImageFilter(v.filter)
+		ctxt.DrawStretch(v.LocalBounds(), v.image, v.image.LocalBounds())
+	}
 	v.BaseView.OnDraw(event)
 }
 
 func (v *ImageView) SetColorRGB(r, g, b byte) {
 	v.clr.R, v.clr.G, v.clr.B = r, g, b
 }
+
+func (v *ImageView) SetImage(image *Canvas) {
+	v.image = image
+	v.image_id = ""
+}
+
+func (v *ImageView) Image() *Canvas {
+	return v.image
+}
+
+// SetFilter sets the filter to scale the image.
+func (v *ImageView) SetFilter(filter Filter) {
+	v.filter = filter
+}
+
+func (v *ImageView) Filter() Filter {
+	return v.filter
+}
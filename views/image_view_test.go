@@ -0,0 +1,35 @@
+package views
+
+import (
+	"gwk/views/resc"
+	"os"
+	"testing"
+)
+
+func TestImageViewSvgSize(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Getwd: %v", err)
+	}
+	if err := os.Chdir("../expr"); err != nil {
+		t.Skipf("no resources of the examples: %v", err)
+	}
+	defer os.Chdir(wd)
+	resc.InitResc()
+
+	small := resc.FindCanvasByIDWithSize("toolbar_open", 10, 10)
+	if small == nil || small.W() != 10 || small.H() != 10 {
+		t.Fatalf("the svg at 10x10: got %v", small)
+	}
+	large := resc.FindCanvasByIDWithSize("toolbar_open", 30, 20)
+	if large == nil || large.W() != 30 || large.H() != 20 {
+		t.Fatalf("the svg at 30x20: got %v", large)
+	}
+	if again := resc.FindCanvasByIDWithSize("toolbar_open", 30, 20); again != large {
+		t.Errorf("the svg at the same size is rasterized again")
+	}
+	// Only the last size is kept, the views resized don't keep the others.
+	if again := resc.FindCanvasByIDWithSize("toolbar_open", 10, 10); again == small {
+		t.Errorf("the canvas of an earlier size is kept")
+	}
+}
@@ -1,6 +1,7 @@
 package views
 
 import (
+	. "gwk/vango"
 	"log"
 )
 
@@ -78,6 +79,10 @@ func MockUp(ui UIMap) View {
 		}
 	}
 
+	if shadow, ok := ui.UIMap("shadow"); ok {
+		v.SetShadow(mock_up_shadow(shadow))
+	}
+
 	v.SetUIMap(ui)
 
 	// If the view has some view specifc attributes.
@@ -105,6 +110,26 @@ func MockUp(ui UIMap) View {
 	return v
 }
 
+// mock_up_shadow returns the shadow of the keys "dx", "dy", "blur", "color"
+// (0xRRGGBB) and "alpha". The shadow is black and half transparent by default.
+func mock_up_shadow(ui UIMap) *Shadow {
+	shadow := &Shadow{Color: NewSolidPaint(0, 0, 0, 0x80)}
+	shadow.DX, _ = ui.Int("dx")
+	shadow.DY, _ = ui.Int("dy")
+	if blur, ok := ui.Int("blur"); ok {
+		shadow.Blur = float64(blur)
+	}
+	if clr, ok := ui.Int("color"); ok {
+		shadow.Color.R = byte(clr >> 16)
+		shadow.Color.G = byte(clr >> 8)
+		shadow.Color.B = byte(clr)
+	}
+	if alpha, ok := ui.Int("alpha"); ok {
+		shadow.Color.A = byte(alpha)
+	}
+	return shadow
+}
+
 func hierarchy_mockup() {
 
 }
@@ -5,8 +5,8 @@
 package views
 
 import (
-	//. "gwk/vango"
-	//"gwk/views/resc"
+	. "gwk/vango"
+	"gwk/views/resc"
 	. "image"
 	//"log"
 )
@@ -14,6 +14,12 @@ import (
 type Panel struct {
 	BaseView
 	title string
+	// title_layout is the title on one line, cut by the ellipsis to the width
+	// of the header.
+	title_layout *Paragraph
+	// The skin of the border and the background, the panel draws the plain
+	// colors without it.
+	skin *NinePatch
 }
 
 const (
@@ -24,19 +30,30 @@ const (
 func NewPanel() *Panel {
 	new_panel := new(Panel)
 	// new_panel.header = resc.FindCanvasByID("panel_header")
+	new_panel.skin = resc.FindNinePatchByID("panel_skin")
 	return new_panel
 }
 
 func (p *Panel) DrawPanelHeader(event *DrawEvent) {
 	header_rect := p.get_header_bounds()
 	ctxt := GlobalDrawContext()
-	ctxt.SetFillColor(19, 19, 19)
-	ctxt.FillRect(header_rect)
+	gradient := NewLinearGradient(0, 0, 0, float64(header_rect.Dy()))
+	gradient.AddColorStop(0, 44, 44, 44, 255)
+	gradient.AddColorStop(1, 19, 19, 19, 255)
+	ctxt.SetFillPaint(gradient)
+	ctxt.SetDither(true)
+	ctxt.FillRoundedRect(header_rect, CornerRadii{TopLeft: 3, TopRight: 3})
 
 	header_rect.Min.X = header_rect.Min.X + kPanelBorderSize
+	header_rect.Max.X = header_rect.Max.X - kPanelBorderSize
+	if p.title_layout == nil || p.title_layout.Text() != p.title {
+		p.title_layout = NewParagraph(p.title, nil, 14,
+			ParagraphStyle{MaxLines: 1, Ellipsis: EllipsisEnd})
+	}
 	ctxt.SetFontColor(240, 240, 240)
-	ctxt.SetFontSize(14)
-	ctxt.DrawText(p.title, header_rect)
+	// The title is centered vertically by the ascent and the descent.
+	header_rect.Min.Y += int(float64(header_rect.Dy())-p.title_layout.Height()) / 2
+	p.title_layout.Draw(ctxt, header_rect)
 }
 
 func (p *Panel) DrawPanelBorder(event *DrawEvent) {
@@ -61,6 +78,11 @@ func (p *Panel) DrawPanelContentBackground(event *DrawEvent) {
 }
 
 func (p *Panel) OnDraw(event *DrawEvent) {
+	if p.skin != nil {
+		GlobalDrawContext().DrawNinePatch(p.skin, p.LocalBounds())
+		p.DrawPanelHeader(event)
+		return
+	}
 	p.DrawPanelHeader(event)
 	p.DrawPanelBorder(event)
 	p.DrawPanelContentBackground(event)
@@ -0,0 +1,46 @@
+package views
+
+import (
+	"gwk/vango"
+	"gwk/views/resc"
+	"image"
+	"os"
+	"testing"
+)
+
+func TestPanelSkin(t *testing.T) {
+	// The resources of the examples have the skin of the panel.
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Getwd: %v", err)
+	}
+	if err := os.Chdir("../expr"); err != nil {
+		t.Skipf("no resources of the examples: %v", err)
+	}
+	defer os.Chdir(wd)
+	resc.InitResc()
+
+	p := NewPanel()
+	if p.skin == nil {
+		t.Fatalf("the panel has no skin")
+	}
+	p.SetBounds(image.Rect(0, 0, 110, 64))
+	canvas := vango.NewCanvas(110, 64)
+	ctxt := vango.NewContext()
+	ctxt.SetCanvas(canvas)
+	ExportView(p, ctxt)
+
+	// The border and the content are the ones of the skin.
+	pixel := func(x, y int) []byte {
+		i := canvas.PixOffset(x, y)
+		return canvas.Pix()[i : i+4]
+	}
+	if got := pixel(55, 40); got[0] != 92 || got[1] != 88 || got[2] != 88 {
+		t.Errorf("the content: got %v, want the skin", got)
+	}
+	for _, pt := range []image.Point{{4, 40}, {105, 40}, {55, 59}} {
+		if got := pixel(pt.X, pt.Y); got[0] != 32 || got[1] != 28 || got[2] != 28 {
+			t.Errorf("the border at %v: got %v, want the skin", pt, got)
+		}
+	}
+}
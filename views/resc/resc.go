@@ -8,9 +8,16 @@ import (
 	"bytes"
 	"encoding/xml"
 	. "gwk/vango"
-	"image/png"
+	"gwk/vango/svg"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
 	"io/ioutil"
+	"log"
 	"os"
+	"strconv"
+	"strings"
 )
 
 const (
@@ -28,6 +35,27 @@ type CanvasResc struct {
 	filename string
 }
 
+// NinePatchResc is loaded from the png of the Android convention if its
+// filename ends with ".9.png", otherwise the insets are from the attributes.
+type NinePatchResc struct {
+	id         string
+	nine_patch *NinePatch
+	insets     Insets
+	mode       NinePatchMode
+	filename   string
+}
+
+// SvgResc is loaded from an svg document, which is rasterized again when the
+// requested size changes. Only the canvas of the last size is kept, so a view
+// resized interactively doesn't keep one for every size it passed.
+type SvgResc struct {
+	id       string
+	doc      *svg.Document
+	size     image.Point
+	canvas   *Canvas
+	filename string
+}
+
 // type Canvas3Resc struct {
 // 	id       string
 // 	canvas3  *Canvas3
@@ -70,11 +98,66 @@ func LoadRescFile(filename string) {
 		}
 	}
 
+	// <NinePatch id="button" left="4" top="4" right="4" bottom="4"
+	// mode="tile">button.png</NinePatch>
+	load_nine_patch_resc := func(start xml.StartElement) {
+		var resc NinePatchResc
+		inset := func(attr xml.Attr) int {
+			n, err := strconv.Atoi(attr.Value)
+			if err != nil {
+				log.Printf("WARNING: nine patch resource %v: bad %v inset %q", resc.id, attr.Name.Local, attr.Value)
+			}
+			return n
+		}
+		for _, attr := range start.Attr {
+			switch attr.Name.Local {
+			case "id":
+				resc.id = attr.Value
+			case "left":
+				resc.insets.Left = inset(attr)
+			case "top":
+				resc.insets.Top = inset(attr)
+			case "right":
+				resc.insets.Right = inset(attr)
+			case "bottom":
+				resc.insets.Bottom = inset(attr)
+			case "mode":
+				if attr.Value == "tile" {
+					resc.mode = NinePatchTile
+				}
+			}
+		}
+		t, _ := d.Token()
+		if char_data, ok := t.(xml.CharData); ok {
+			resc.filename = strings.TrimSpace(string([]byte(char_data)))
+		}
+		g_id_resc_map[resc.id] = &resc
+	}
+
+	// <Svg id="toolbar_open">toolbar_open.svg</Svg>
+	load_svg_resc := func(start xml.StartElement) {
+		var resc SvgResc
+		for _, attr := range start.Attr {
+			if attr.Name.Local == "id" {
+				resc.id = attr.Value
+			}
+		}
+		t, _ := d.Token()
+		if char_data, ok := t.(xml.CharData); ok {
+			resc.filename = strings.TrimSpace(string([]byte(char_data)))
+		}
+		g_id_resc_map[resc.id] = &resc
+	}
+
 	for err == nil {
 		switch token := t.(type) {
 		case xml.StartElement:
 			if token.Name.Local == "Image" {
 				load_image_resc(token)
+			} else if token.Name.Local == "NinePatch" {
+				load_nine_patch_resc(token)
+			} else if token.Name.Local == "Svg" {
+				load_svg_resc(token)
 			}
 		default:
 		}
@@ -82,17 +165,83 @@ func LoadRescFile(filename string) {
 	}
 }
 
+// FindCanvasByID returns the Image resource, or the Svg resource rasterized
+// at the size of its document.
 func FindCanvasByID(id string) *Canvas {
-	resc := g_id_resc_map[id]
-	if canvas_resc, ok := resc.(*CanvasResc); ok {
-		if canvas_resc.canvas == nil {
-			canvas_resc.canvas = LoadCanvas("resc/" + canvas_resc.filename)
+	return FindCanvasByIDWithSize(id, 0, 0)
+}
+
+// FindCanvasByIDWithSize returns the Svg resource rasterized at |w|x|h|, or at
+// the size of its document if the size is 0x0. The canvas of the last size is
+// cached. The Image resource is the same at any size.
+func FindCanvasByIDWithSize(id string, w, h int) *Canvas {
+	switch resc := g_id_resc_map[id].(type) {
+	case *CanvasResc:
+		if resc.canvas == nil {
+			resc.canvas = LoadCanvas("resc/" + resc.filename)
+		}
+		return resc.canvas
+	case *SvgResc:
+		if resc.doc == nil {
+			doc, err := svg.ParseFile("resc/" + resc.filename)
+			if err != nil {
+				log.Printf("WARNING: svg resource %v: %v", resc.id, err)
+				return nil
+			}
+			resc.doc = doc
 		}
-		return canvas_resc.canvas
+		if w <= 0 || h <= 0 {
+			dw, dh := resc.doc.Size()
+			w, h = int(dw+0.5), int(dh+0.5)
+		}
+		size := image.Pt(w, h)
+		if resc.canvas == nil || resc.size != size {
+			resc.canvas = resc.doc.Rasterize(w, h)
+			resc.size = size
+		}
+		return resc.canvas
 	}
 	return nil
 }
 
+// FindNinePatchByID returns the NinePatch resource, or a plain Image or Svg
+// resource as a nine patch without insets.
+func FindNinePatchByID(id string) *NinePatch {
+	switch resc := g_id_resc_map[id].(type) {
+	case *NinePatchResc:
+		if resc.nine_patch == nil {
+			if strings.HasSuffix(resc.filename, ".9.png") {
+				resc.nine_patch = LoadNinePatch("resc/" + resc.filename)
+			} else if canvas := LoadCanvas("resc/" + resc.filename); canvas != nil {
+				resc.nine_patch = NewNinePatch(canvas, resc.insets)
+			}
+			if resc.nine_patch != nil {
+				resc.nine_patch.Mode = resc.mode
+			}
+		}
+		return resc.nine_patch
+	case *CanvasResc, *SvgResc:
+		if canvas := FindCanvasByID(id); canvas != nil {
+			return NewNinePatch(canvas, Insets{})
+		}
+	}
+	return nil
+}
+
+// LoadNinePatch loads the png of the Android nine patch convention, with the
+// 1 pixel border of the marks.
+func LoadNinePatch(filename string) *NinePatch {
+	canvas := LoadCanvas(filename)
+	if canvas == nil {
+		return nil
+	}
+	np, err := NinePatchFromCanvas(canvas)
+	if err != nil {
+		return nil
+	}
+	return np
+}
+
 func LoadCanvas(filename string) *Canvas {
 	var fd, err = os.Open(filename)
 	if err != nil {
@@ -102,11 +251,13 @@ func LoadCanvas(filename string) *Canvas {
 	return LoadCanvasFile(fd)
 }
 
+// LoadCanvasFile decodes the image of any registered format, like png, jpeg,
+// gif and bmp.
 func LoadCanvasFile(fd *os.File) *Canvas {
-	var png, err = png.Decode(fd)
+	var img, _, err = image.Decode(fd)
 	if err != nil {
 		return nil
 	}
 
-	return CanvasFromImage(png)
+	return CanvasFromImage(img)
 }
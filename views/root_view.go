@@ -15,6 +15,10 @@ type RootView struct {
 	host_window *HostWindow
 
 	mouse_move_handler View
+	focus_view         View
+
+	// dirty is the area scheduled to draw, drawn by the next paint.
+	dirty Region
 }
 
 func NewRootView(bounds Rectangle) *RootView {
@@ -74,7 +78,9 @@ func (r *RootView) Canvas() *Canvas {
 	return r.canvas
 }
 
-func (r *RootView) DispatchDraw(dirty_rect Rectangle) {
+// DispatchDraw draws the views in each rect of |dirty| into the canvas, with
+// the draws clipped by the rect.
+func (r *RootView) DispatchDraw(dirty Region) {
 	children := r.Children()
 	if r.children_count() == 0 {
 		return
@@ -94,19 +100,35 @@ func (r *RootView) DispatchDraw(dirty_rect Rectangle) {
 			return
 		}
 
-		// GlobalDrawContext().SaveState()
-		GlobalDrawContext().SetCanvas(event.Canvas)
+		// Every view draws with a fresh copy of the state, so the changes made
+		// in OnDraw don't leak into the siblings and the children. Except the
+		// clip left by OnDraw, it clips the children too.
+		ctxt := g_canvas_context
+		ctxt.SaveState()
+		ctxt.SetCanvas(event.Canvas)
 		view.OnDraw(event)
-		// GlobalDrawContext().RestoreState()
+		clip := ctxt.Clip()
+		ctxt.RestoreState()
+
+		ctxt.SaveState()
+		ctxt.SetClip(clip)
+		defer ctxt.RestoreState()
 
 		view_canvas := event.Canvas
 		for _, child := range view.Children() {
+			// The shadow goes behind the child, over the siblings before it.
+			if shadow := child.Shadow(); shadow != nil &&
+				!dirty_rect.Intersect(shadow.Bounds(child.Bounds())).Empty() {
+				ctxt.SetCanvas(view_canvas)
+				ctxt.DrawShadow(child.Bounds(), CornerRadii{}, *shadow)
+			}
+
 			// caculate the child dirty rectangle.
 			child_dirty_rect := dirty_rect.Intersect(child.Bounds())
 			if child_dirty_rect.Empty() {
 				continue
 			}
-			child_dirty_rect = child_dirty_rect.Sub(child_dirty_rect.Min)
+			child_dirty_rect = child_dirty_rect.Sub(child.Bounds().Min)
 
 			// clip the canvas to child bounds.
 			child_canvas := view_canvas.SubCanvas(child.Bounds())
@@ -124,12 +146,31 @@ func (r *RootView) DispatchDraw(dirty_rect Rectangle) {
 	}
 
 	// RootView only have one child. That's the MainFrame.
-	event := &DrawEvent{
-		Owner:     children[0],
-		DirtyRect: dirty_rect,
-		Canvas:    r.Canvas(),
+	canvas := r.Canvas()
+	ctxt := g_canvas_context
+	for _, dirty_rect := range dirty.IntersectRect(r.LocalBounds()).Rects() {
+		ctxt.SaveState()
+		ctxt.ResetTransform()
+		ctxt.ResetClip()
+		ctxt.SetCanvas(canvas)
+		ctxt.ClipRect(dirty_rect)
+		event := &DrawEvent{
+			Owner:     children[0],
+			DirtyRect: dirty_rect,
+			Canvas:    canvas,
+		}
+		dispatch_draw_event(event)
+		ctxt.RestoreState()
+	}
+}
+
+// draw_dirty draws the area scheduled to draw.
+func (r *RootView) draw_dirty() {
+	dirty := r.dirty
+	r.dirty = Region{}
+	if !dirty.Empty() {
+		r.DispatchDraw(dirty)
 	}
-	dispatch_draw_event(event)
 }
 
 func DispatchLayout(v View) {
@@ -152,7 +193,7 @@ func (r *RootView) DispatchLayout() {
 	r.Children()[0].SetXYWH(0, 0, new_rect.Dx(), new_rect.Dy())
 	DispatchLayout(r.Children()[0])
 
-	r.DispatchDraw(r.Bounds())
+	r.ScheduleDraw()
 }
 
 func get_event_handler_for_point(v View, pt Point) View {
@@ -196,14 +237,80 @@ func (r *RootView) DispatchMouseMove(pt Point) {
 	}
 }
 
+func (r *RootView) DispatchMouseDown(pt Point, button int) {
+	v := get_event_handler_for_point(r, pt)
+	if v == nil || v == r {
+		return
+	}
+
+	// The view clicked on takes the keyboard focus.
+	r.focus_view = v
+
+	mouse_event := NewMouseEvent(pt)
+	mouse_event.Owner = v
+	mouse_event.Button = button
+	v.OnMouseDown(mouse_event)
+}
+
+func (r *RootView) DispatchMouseUp(pt Point, button int) {
+	v := get_event_handler_for_point(r, pt)
+	if v == nil || v == r {
+		return
+	}
+
+	mouse_event := NewMouseEvent(pt)
+	mouse_event.Owner = v
+	mouse_event.Button = button
+	v.OnMouseUp(mouse_event)
+}
+
+func (r *RootView) DispatchKeyDown(key_code int, char rune) {
+	if r.focus_view == nil {
+		return
+	}
+
+	key_event := NewKeyEvent(key_code, char)
+	key_event.Owner = r.focus_view
+	r.focus_view.OnKeyDown(key_event)
+}
+
+func (r *RootView) DispatchKeyUp(key_code int, char rune) {
+	if r.focus_view == nil {
+		return
+	}
+
+	key_event := NewKeyEvent(key_code, char)
+	key_event.Owner = r.focus_view
+	r.focus_view.OnKeyUp(key_event)
+}
+
+func (r *RootView) FocusView() View {
+	return r.focus_view
+}
+
+func (r *RootView) SetFocusView(v View) {
+	r.focus_view = v
+}
+
+func (r *RootView) ScheduleDraw() {
+	r.ScheduleDrawInRect(r.LocalBounds())
+}
+
 func (r *RootView) ScheduleDrawInRect(rect Rectangle) {
 	r.UpdateRect(rect)
 }
 
+// UpdateRect adds |rect| to the area drawn by the next paint of the host
+// window, so the separate updates don't draw the area between them.
 func (r *RootView) UpdateRect(rect Rectangle) {
-	rect = rect.Intersect(r.Bounds())
-	r.DispatchDraw(rect)
-	r.host_window.InvalidateRect(rect)
+	rect = rect.Intersect(r.LocalBounds())
+	if rect.Empty() {
+		return
+	}
+	r.dirty = r.dirty.UnionRect(rect)
+	if r.host_window != nil {
+		r.host_window.InvalidateRect(rect)
+	}
 }
 
 func (r *RootView) children_count() int {
@@ -6,6 +6,7 @@ package views
 
 import (
 	"fmt"
+	. "gwk/vango"
 	"log"
 )
 
@@ -24,7 +25,7 @@ func (v *TestView) OnDraw(event *DrawEvent) {
 	ctxt.FillRect(event.DirtyRect)
 	ctxt.SetFontColor(0x00, 0x00, 0xff)
 	text := fmt.Sprintf("id: %v xywh: %v %v %v %v", v.ID(), v.X(), v.Y(), v.W(), v.H())
-	ctxt.DrawText(text, v.LocalBounds())
+	NewParagraph(text, nil, 12, ParagraphStyle{}).Draw(ctxt, v.LocalBounds())
 	ctxt.SetStrokeColor(0x00, 0x00, 0xff)
 	ctxt.StrokeRect(v.LocalBounds())
 }
@@ -1,5 +1,9 @@
 package views
 
+import (
+	. "gwk/vango"
+)
+
 type Toolbar struct {
 	BaseView
 }
@@ -11,5 +15,13 @@ func NewToolbar() *Toolbar {
 
 func (t *Toolbar) OnDraw(event *DrawEvent) {
 	ctxt := GlobalDrawContext()
-	ctxt.DrawColor(19, 19, 19)
+
+	// A slight vertical gradient, dithered to hide the banding.
+	bounds := t.LocalBounds()
+	gradient := NewLinearGradient(0, 0, 0, float64(bounds.Dy()))
+	gradient.AddColorStop(0, 44, 44, 44, 255)
+	gradient.AddColorStop(1, 19, 19, 19, 255)
+	ctxt.SetFillPaint(gradient)
+	ctxt.SetDither(true)
+	ctxt.FillRect(bounds)
 }
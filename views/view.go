@@ -31,6 +31,11 @@ type View interface {
 
 	OnMouseEnter(event *MouseEvent)
 	OnMouseLeave(event *MouseEvent)
+	OnMouseDown(event *MouseEvent)
+	OnMouseUp(event *MouseEvent)
+
+	OnKeyDown(event *KeyEvent)
+	OnKeyUp(event *KeyEvent)
 
 	ScheduleDraw()
 	ScheduleDrawInRect(dirty Rectangle)
@@ -62,6 +67,9 @@ type View interface {
 	Layouter() Layouter
 	SetLayouter(l Layouter)
 
+	Shadow() *Shadow
+	SetShadow(shadow *Shadow)
+
 	SetDelegate(delegate ViewDelegate)
 	Delegate() ViewDelegate
 }
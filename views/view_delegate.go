@@ -3,6 +3,10 @@ package views
 type ViewDelegate interface {
 	OnMouseEnter(event *MouseEvent)
 	OnMouseLeave(event *MouseEvent)
+	OnMouseDown(event *MouseEvent)
+	OnMouseUp(event *MouseEvent)
+	OnKeyDown(event *KeyEvent)
+	OnKeyUp(event *KeyEvent)
 	OnDraw(event *DrawEvent)
 }
 